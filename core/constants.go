@@ -5,3 +5,60 @@ const (
 	CONTACT_TYPE_EMAIL  = "email"
 	CONTACT_TYPE_MOBILE = "mobile"
 )
+
+const (
+	OAUTH_RESPONSE_TYPE_CODE = "code"
+
+	OAUTH_GRANT_TYPE_AUTHORIZATION_CODE = "authorization_code"
+	OAUTH_GRANT_TYPE_CLIENT_CREDENTIALS = "client_credentials"
+	OAUTH_GRANT_TYPE_REFRESH_TOKEN      = "refresh_token"
+
+	OAUTH_TOKEN_TYPE_BEARER = "Bearer"
+
+	// token type hints defined in https://datatracker.ietf.org/doc/html/rfc7009#section-2.1
+	OAUTH_TOKEN_TYPE_HINT_ACCESS_TOKEN  = "access_token"
+	OAUTH_TOKEN_TYPE_HINT_REFRESH_TOKEN = "refresh_token"
+
+	OAUTH_CLIENT_AUTH_METHOD_CLIENT_SECRET_BASIC = "client_secret_basic"
+	OAUTH_CLIENT_AUTH_METHOD_CLIENT_SECRET_POST  = "client_secret_post"
+
+	// access token formats an app can be configured to receive
+	OAUTH_ACCESS_TOKEN_FORMAT_JWT    = "jwt"
+	OAUTH_ACCESS_TOKEN_FORMAT_OPAQUE = "opaque"
+)
+
+// standard scopes defined in https://openid.net/specs/openid-connect-core-1_0.html#ScopeClaims
+const (
+	OIDC_SCOPE_OPENID  = "openid"
+	OIDC_SCOPE_PROFILE = "profile"
+	OIDC_SCOPE_EMAIL   = "email"
+	OIDC_SCOPE_PHONE   = "phone"
+	OIDC_SCOPE_ADDRESS = "address"
+)
+
+// authentication context class references used for the acr claim of id tokens
+const (
+	// OIDC_ACR_PASSWORD is the acr value for a user who authenticated with a password
+	OIDC_ACR_PASSWORD = "urn:goauth:acr:password"
+	// OIDC_ACR_MFA is the acr value for a user who authenticated with a password and a second factor
+	OIDC_ACR_MFA = "urn:goauth:acr:mfa"
+)
+
+// prompt values for authorization requests defined in https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+const (
+	// OIDC_PROMPT_CONSENT forces the consent page to be shown even if the user has already consented to every requested scope
+	OIDC_PROMPT_CONSENT = "consent"
+)
+
+const (
+	PKCE_CODE_CHALLENGE_METHOD_S256  = "S256"
+	PKCE_CODE_CHALLENGE_METHOD_PLAIN = "plain"
+)
+
+// password hash algorithms, named by the identifier used for them in PHC string format hashes https://github.com/P-H-C/phc-string-format
+const (
+	PASSWORD_HASH_ALGORITHM_ARGON2ID      = "argon2id"
+	PASSWORD_HASH_ALGORITHM_BCRYPT        = "bcrypt"
+	PASSWORD_HASH_ALGORITHM_SCRYPT        = "scrypt"
+	PASSWORD_HASH_ALGORITHM_PBKDF2_SHA256 = "pbkdf2-sha256"
+)
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeAppDisabled app is disabled
+const ErrCodeAppDisabled = "AppDisabled"
+
+// NewAppDisabledError creates a new specific error
+func NewAppDisabledError(clientId string, includeStack bool) errors.RichError {
+	msg := "app is disabled"
+	err := errors.NewRichError(ErrCodeAppDisabled, msg).AddMetaData("clientId", clientId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsAppDisabledError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeAppDisabled
+}
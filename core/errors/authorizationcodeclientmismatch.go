@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeAuthorizationCodeClientMismatch authorization code was not issued to client
+const ErrCodeAuthorizationCodeClientMismatch = "AuthorizationCodeClientMismatch"
+
+// NewAuthorizationCodeClientMismatchError creates a new specific error
+func NewAuthorizationCodeClientMismatchError(clientId string, includeStack bool) errors.RichError {
+	msg := "authorization code was not issued to client"
+	err := errors.NewRichError(ErrCodeAuthorizationCodeClientMismatch, msg).AddMetaData("clientId", clientId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsAuthorizationCodeClientMismatchError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeAuthorizationCodeClientMismatch
+}
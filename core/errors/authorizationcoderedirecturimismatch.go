@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeAuthorizationCodeRedirectURIMismatch redirect uri does not match the authorization request
+const ErrCodeAuthorizationCodeRedirectURIMismatch = "AuthorizationCodeRedirectURIMismatch"
+
+// NewAuthorizationCodeRedirectURIMismatchError creates a new specific error
+func NewAuthorizationCodeRedirectURIMismatchError(clientId string, redirectUri string, includeStack bool) errors.RichError {
+	msg := "redirect uri does not match the authorization request"
+	err := errors.NewRichError(ErrCodeAuthorizationCodeRedirectURIMismatch, msg).AddMetaData("clientId", clientId).AddMetaData("redirectUri", redirectUri)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsAuthorizationCodeRedirectURIMismatchError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeAuthorizationCodeRedirectURIMismatch
+}
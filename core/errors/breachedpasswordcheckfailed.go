@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeBreachedPasswordCheckFailed failed to check password against the breached password file
+const ErrCodeBreachedPasswordCheckFailed = "BreachedPasswordCheckFailed"
+
+// NewBreachedPasswordCheckFailedError creates a new specific error
+func NewBreachedPasswordCheckFailedError(breachedPasswordFile string, checkError error, includeStack bool) errors.RichError {
+	msg := "failed to check password against the breached password file"
+	err := errors.NewRichError(ErrCodeBreachedPasswordCheckFailed, msg).AddMetaData("breachedPasswordFile", breachedPasswordFile).AddError(checkError)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsBreachedPasswordCheckFailedError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeBreachedPasswordCheckFailed
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeClientSecretNotFound no client secret found with the given id for the app
+const ErrCodeClientSecretNotFound = "ClientSecretNotFound"
+
+// NewClientSecretNotFoundError creates a new specific error
+func NewClientSecretNotFoundError(appId string, clientSecretId string, includeStack bool) errors.RichError {
+	msg := "no client secret found with the given id for the app"
+	err := errors.NewRichError(ErrCodeClientSecretNotFound, msg).AddMetaData("appId", appId).AddMetaData("clientSecretId", clientSecretId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsClientSecretNotFoundError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeClientSecretNotFound
+}
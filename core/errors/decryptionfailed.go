@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeDecryptionFailed failed to decrypt data
+const ErrCodeDecryptionFailed = "DecryptionFailed"
+
+// NewDecryptionFailedError creates a new specific error
+func NewDecryptionFailedError(error error, includeStack bool) errors.RichError {
+	msg := "failed to decrypt data"
+	err := errors.NewRichError(ErrCodeDecryptionFailed, msg).AddError(error)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsDecryptionFailedError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeDecryptionFailed
+}
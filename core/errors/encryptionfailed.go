@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeEncryptionFailed failed to encrypt data
+const ErrCodeEncryptionFailed = "EncryptionFailed"
+
+// NewEncryptionFailedError creates a new specific error
+func NewEncryptionFailedError(error error, includeStack bool) errors.RichError {
+	msg := "failed to encrypt data"
+	err := errors.NewRichError(ErrCodeEncryptionFailed, msg).AddError(error)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsEncryptionFailedError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeEncryptionFailed
+}
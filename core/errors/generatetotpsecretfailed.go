@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeGenerateTOTPSecretFailed failed to generate totp secret
+const ErrCodeGenerateTOTPSecretFailed = "GenerateTOTPSecretFailed"
+
+// NewGenerateTOTPSecretFailedError creates a new specific error
+func NewGenerateTOTPSecretFailedError(error error, includeStack bool) errors.RichError {
+	msg := "failed to generate totp secret"
+	err := errors.NewRichError(ErrCodeGenerateTOTPSecretFailed, msg).AddError(error)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsGenerateTOTPSecretFailedError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeGenerateTOTPSecretFailed
+}
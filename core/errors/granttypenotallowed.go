@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeGrantTypeNotAllowed the app is not allowed to use the grant type
+const ErrCodeGrantTypeNotAllowed = "GrantTypeNotAllowed"
+
+// NewGrantTypeNotAllowedError creates a new specific error
+func NewGrantTypeNotAllowedError(clientId string, grantType string, includeStack bool) errors.RichError {
+	msg := "the app is not allowed to use the grant type"
+	err := errors.NewRichError(ErrCodeGrantTypeNotAllowed, msg).AddMetaData("clientId", clientId).AddMetaData("grantType", grantType)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsGrantTypeNotAllowedError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeGrantTypeNotAllowed
+}
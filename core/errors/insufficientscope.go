@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeInsufficientScope access token was not granted the required scope
+const ErrCodeInsufficientScope = "InsufficientScope"
+
+// NewInsufficientScopeError creates a new specific error
+func NewInsufficientScopeError(requiredScope string, includeStack bool) errors.RichError {
+	msg := "access token was not granted the required scope"
+	err := errors.NewRichError(ErrCodeInsufficientScope, msg).AddMetaData("requiredScope", requiredScope)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsInsufficientScopeError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeInsufficientScope
+}
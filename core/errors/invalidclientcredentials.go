@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeInvalidClientCredentials client authentication failed
+const ErrCodeInvalidClientCredentials = "InvalidClientCredentials"
+
+// NewInvalidClientCredentialsError creates a new specific error
+func NewInvalidClientCredentialsError(clientId string, includeStack bool) errors.RichError {
+	msg := "client authentication failed"
+	err := errors.NewRichError(ErrCodeInvalidClientCredentials, msg).AddMetaData("clientId", clientId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsInvalidClientCredentialsError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeInvalidClientCredentials
+}
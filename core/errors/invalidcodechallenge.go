@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeInvalidCodeChallenge code challenge is not valid
+const ErrCodeInvalidCodeChallenge = "InvalidCodeChallenge"
+
+// NewInvalidCodeChallengeError creates a new specific error
+func NewInvalidCodeChallengeError(clientId string, includeStack bool) errors.RichError {
+	msg := "code challenge is not valid"
+	err := errors.NewRichError(ErrCodeInvalidCodeChallenge, msg).AddMetaData("clientId", clientId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsInvalidCodeChallengeError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeInvalidCodeChallenge
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeInvalidCodeVerifier code verifier does not match code challenge
+const ErrCodeInvalidCodeVerifier = "InvalidCodeVerifier"
+
+// NewInvalidCodeVerifierError creates a new specific error
+func NewInvalidCodeVerifierError(clientId string, includeStack bool) errors.RichError {
+	msg := "code verifier does not match code challenge"
+	err := errors.NewRichError(ErrCodeInvalidCodeVerifier, msg).AddMetaData("clientId", clientId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsInvalidCodeVerifierError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeInvalidCodeVerifier
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeInvalidJWTSignature jwt signature is not valid
+const ErrCodeInvalidJWTSignature = "InvalidJWTSignature"
+
+// NewInvalidJWTSignatureError creates a new specific error
+func NewInvalidJWTSignatureError(keyId string, includeStack bool) errors.RichError {
+	msg := "jwt signature is not valid"
+	err := errors.NewRichError(ErrCodeInvalidJWTSignature, msg).AddMetaData("keyId", keyId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsInvalidJWTSignatureError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeInvalidJWTSignature
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeInvalidPasswordHashOptions password hash options are not valid
+const ErrCodeInvalidPasswordHashOptions = "InvalidPasswordHashOptions"
+
+// NewInvalidPasswordHashOptionsError creates a new specific error
+func NewInvalidPasswordHashOptionsError(fields map[string]interface{}, includeStack bool) errors.RichError {
+	msg := "password hash options are not valid"
+	err := errors.NewRichError(ErrCodeInvalidPasswordHashOptions, msg).WithMetaData(fields)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsInvalidPasswordHashOptionsError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeInvalidPasswordHashOptions
+}
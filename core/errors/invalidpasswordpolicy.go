@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeInvalidPasswordPolicy password policy had bad or conflicting settings
+const ErrCodeInvalidPasswordPolicy = "InvalidPasswordPolicy"
+
+// NewInvalidPasswordPolicyError creates a new specific error
+func NewInvalidPasswordPolicyError(fields map[string]interface{}, includeStack bool) errors.RichError {
+	msg := "password policy had bad or conflicting settings"
+	err := errors.NewRichError(ErrCodeInvalidPasswordPolicy, msg).WithMetaData(fields)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsInvalidPasswordPolicyError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeInvalidPasswordPolicy
+}
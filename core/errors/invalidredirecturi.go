@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeInvalidRedirectURI redirect uri is not registered for app
+const ErrCodeInvalidRedirectURI = "InvalidRedirectURI"
+
+// NewInvalidRedirectURIError creates a new specific error
+func NewInvalidRedirectURIError(clientId string, redirectUri string, includeStack bool) errors.RichError {
+	msg := "redirect uri is not registered for app"
+	err := errors.NewRichError(ErrCodeInvalidRedirectURI, msg).AddMetaData("clientId", clientId).AddMetaData("redirectUri", redirectUri)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsInvalidRedirectURIError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeInvalidRedirectURI
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeInvalidScope requested scope is not valid for app
+const ErrCodeInvalidScope = "InvalidScope"
+
+// NewInvalidScopeError creates a new specific error
+func NewInvalidScopeError(clientId string, scope string, includeStack bool) errors.RichError {
+	msg := "requested scope is not valid for app"
+	err := errors.NewRichError(ErrCodeInvalidScope, msg).AddMetaData("clientId", clientId).AddMetaData("scope", scope)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsInvalidScopeError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeInvalidScope
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeInvalidSigningKey signing key is not valid for algorithm
+const ErrCodeInvalidSigningKey = "InvalidSigningKey"
+
+// NewInvalidSigningKeyError creates a new specific error
+func NewInvalidSigningKeyError(algorithm string, reason string, includeStack bool) errors.RichError {
+	msg := "signing key is not valid for algorithm"
+	err := errors.NewRichError(ErrCodeInvalidSigningKey, msg).AddMetaData("algorithm", algorithm).AddMetaData("reason", reason)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsInvalidSigningKeyError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeInvalidSigningKey
+}
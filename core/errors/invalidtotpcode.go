@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeInvalidTOTPCode totp code is not valid
+const ErrCodeInvalidTOTPCode = "InvalidTOTPCode"
+
+// NewInvalidTOTPCodeError creates a new specific error
+func NewInvalidTOTPCodeError(userId string, includeStack bool) errors.RichError {
+	msg := "totp code is not valid"
+	err := errors.NewRichError(ErrCodeInvalidTOTPCode, msg).AddMetaData("userId", userId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsInvalidTOTPCodeError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeInvalidTOTPCode
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeJWTAlgorithmMismatch jwt algorithm does not match signing key algorithm
+const ErrCodeJWTAlgorithmMismatch = "JWTAlgorithmMismatch"
+
+// NewJWTAlgorithmMismatchError creates a new specific error
+func NewJWTAlgorithmMismatchError(algorithm string, expectedAlgorithm string, includeStack bool) errors.RichError {
+	msg := "jwt algorithm does not match signing key algorithm"
+	err := errors.NewRichError(ErrCodeJWTAlgorithmMismatch, msg).AddMetaData("algorithm", algorithm).AddMetaData("expectedAlgorithm", expectedAlgorithm)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsJWTAlgorithmMismatchError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeJWTAlgorithmMismatch
+}
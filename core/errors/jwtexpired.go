@@ -0,0 +1,26 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+
+	"time"
+)
+
+// ErrCodeJWTExpired jwt has expired
+const ErrCodeJWTExpired = "JWTExpired"
+
+// NewJWTExpiredError creates a new specific error
+func NewJWTExpiredError(expiredOn time.Time, includeStack bool) errors.RichError {
+	msg := "jwt has expired"
+	err := errors.NewRichError(ErrCodeJWTExpired, msg).AddMetaData("expiredOn", expiredOn)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsJWTExpiredError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeJWTExpired
+}
@@ -0,0 +1,26 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+
+	"time"
+)
+
+// ErrCodeJWTNotYetValid jwt is not valid yet
+const ErrCodeJWTNotYetValid = "JWTNotYetValid"
+
+// NewJWTNotYetValidError creates a new specific error
+func NewJWTNotYetValidError(notBefore time.Time, includeStack bool) errors.RichError {
+	msg := "jwt is not valid yet"
+	err := errors.NewRichError(ErrCodeJWTNotYetValid, msg).AddMetaData("notBefore", notBefore)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsJWTNotYetValidError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeJWTNotYetValid
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeJWTSigningFailed failed to sign jwt
+const ErrCodeJWTSigningFailed = "JWTSigningFailed"
+
+// NewJWTSigningFailedError creates a new specific error
+func NewJWTSigningFailedError(keyId string, signingError error, includeStack bool) errors.RichError {
+	msg := "failed to sign jwt"
+	err := errors.NewRichError(ErrCodeJWTSigningFailed, msg).AddMetaData("keyId", keyId).AddError(signingError)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsJWTSigningFailedError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeJWTSigningFailed
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeJWTSigningKeySetConflict the jwt signing key set was changed by another instance
+const ErrCodeJWTSigningKeySetConflict = "JWTSigningKeySetConflict"
+
+// NewJWTSigningKeySetConflictError creates a new specific error
+func NewJWTSigningKeySetConflictError(version int64, includeStack bool) errors.RichError {
+	msg := "the jwt signing key set was changed by another instance"
+	err := errors.NewRichError(ErrCodeJWTSigningKeySetConflict, msg).AddMetaData("version", version)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsJWTSigningKeySetConflictError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeJWTSigningKeySetConflict
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeLastActiveClientSecret the last active client secret of an app cannot be revoked
+const ErrCodeLastActiveClientSecret = "LastActiveClientSecret"
+
+// NewLastActiveClientSecretError creates a new specific error
+func NewLastActiveClientSecretError(appId string, includeStack bool) errors.RichError {
+	msg := "the last active client secret of an app cannot be revoked"
+	err := errors.NewRichError(ErrCodeLastActiveClientSecret, msg).AddMetaData("appId", appId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsLastActiveClientSecretError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeLastActiveClientSecret
+}
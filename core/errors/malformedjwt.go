@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeMalformedJWT jwt is not well formed
+const ErrCodeMalformedJWT = "MalformedJWT"
+
+// NewMalformedJWTError creates a new specific error
+func NewMalformedJWTError(reason string, includeStack bool) errors.RichError {
+	msg := "jwt is not well formed"
+	err := errors.NewRichError(ErrCodeMalformedJWT, msg).AddMetaData("reason", reason)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsMalformedJWTError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeMalformedJWT
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeMalformedPasswordHash password hash is not in a recognized format
+const ErrCodeMalformedPasswordHash = "MalformedPasswordHash"
+
+// NewMalformedPasswordHashError creates a new specific error
+func NewMalformedPasswordHashError(algorithm string, assetId string, includeStack bool) errors.RichError {
+	msg := "password hash is not in a recognized format"
+	err := errors.NewRichError(ErrCodeMalformedPasswordHash, msg).AddMetaData("algorithm", algorithm).AddMetaData("assetId", assetId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsMalformedPasswordHashError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeMalformedPasswordHash
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeMalformedTOTPSecret totp secret is not valid base32
+const ErrCodeMalformedTOTPSecret = "MalformedTOTPSecret"
+
+// NewMalformedTOTPSecretError creates a new specific error
+func NewMalformedTOTPSecretError(includeStack bool) errors.RichError {
+	msg := "totp secret is not valid base32"
+	err := errors.NewRichError(ErrCodeMalformedTOTPSecret, msg)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsMalformedTOTPSecretError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeMalformedTOTPSecret
+}
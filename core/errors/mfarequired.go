@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeMFARequired a second factor is required to complete login
+const ErrCodeMFARequired = "MFARequired"
+
+// NewMFARequiredError creates a new specific error
+func NewMFARequiredError(userId string, includeStack bool) errors.RichError {
+	msg := "a second factor is required to complete login"
+	err := errors.NewRichError(ErrCodeMFARequired, msg).AddMetaData("userId", userId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsMFARequiredError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeMFARequired
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeMissingRequiredParameter a required parameter was not provided
+const ErrCodeMissingRequiredParameter = "MissingRequiredParameter"
+
+// NewMissingRequiredParameterError creates a new specific error
+func NewMissingRequiredParameterError(parameterName string, includeStack bool) errors.RichError {
+	msg := "a required parameter was not provided"
+	err := errors.NewRichError(ErrCodeMissingRequiredParameter, msg).AddMetaData("parameterName", parameterName)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsMissingRequiredParameterError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeMissingRequiredParameter
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeMultipleAccessTokenMethods access token was provided using more than one method
+const ErrCodeMultipleAccessTokenMethods = "MultipleAccessTokenMethods"
+
+// NewMultipleAccessTokenMethodsError creates a new specific error
+func NewMultipleAccessTokenMethodsError(includeStack bool) errors.RichError {
+	msg := "access token was provided using more than one method"
+	err := errors.NewRichError(ErrCodeMultipleAccessTokenMethods, msg)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsMultipleAccessTokenMethodsError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeMultipleAccessTokenMethods
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeNoAddressFound no address found for given query
+const ErrCodeNoAddressFound = "NoAddressFound"
+
+// NewNoAddressFoundError creates a new specific error
+func NewNoAddressFoundError(fields map[string]interface{}, includeStack bool) errors.RichError {
+	msg := "no address found for given query"
+	err := errors.NewRichError(ErrCodeNoAddressFound, msg).WithMetaData(fields)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsNoAddressFoundError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeNoAddressFound
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeNoProfileFound no profile found for given query
+const ErrCodeNoProfileFound = "NoProfileFound"
+
+// NewNoProfileFoundError creates a new specific error
+func NewNoProfileFoundError(fields map[string]interface{}, includeStack bool) errors.RichError {
+	msg := "no profile found for given query"
+	err := errors.NewRichError(ErrCodeNoProfileFound, msg).WithMetaData(fields)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsNoProfileFoundError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeNoProfileFound
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeNoSigningKeyFound no signing key found for key id
+const ErrCodeNoSigningKeyFound = "NoSigningKeyFound"
+
+// NewNoSigningKeyFoundError creates a new specific error
+func NewNoSigningKeyFoundError(keyId string, includeStack bool) errors.RichError {
+	msg := "no signing key found for key id"
+	err := errors.NewRichError(ErrCodeNoSigningKeyFound, msg).AddMetaData("keyId", keyId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsNoSigningKeyFoundError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeNoSigningKeyFound
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodePasswordHashError an error occurred while processing password hash
+const ErrCodePasswordHashError = "PasswordHashError"
+
+// NewPasswordHashErrorError creates a new specific error
+func NewPasswordHashErrorError(algorithm string, assetId string, error error, includeStack bool) errors.RichError {
+	msg := "an error occurred while processing password hash"
+	err := errors.NewRichError(ErrCodePasswordHashError, msg).AddMetaData("algorithm", algorithm).AddMetaData("assetId", assetId).AddError(error)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsPasswordHashErrorError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodePasswordHashError
+}
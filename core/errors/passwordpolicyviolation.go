@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodePasswordPolicyViolation password does not meet the password policy
+const ErrCodePasswordPolicyViolation = "PasswordPolicyViolation"
+
+// NewPasswordPolicyViolationError creates a new specific error
+func NewPasswordPolicyViolationError(fields map[string]interface{}, includeStack bool) errors.RichError {
+	msg := "password does not meet the password policy"
+	err := errors.NewRichError(ErrCodePasswordPolicyViolation, msg).WithMetaData(fields)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsPasswordPolicyViolationError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodePasswordPolicyViolation
+}
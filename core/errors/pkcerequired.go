@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodePKCERequired app requires a pkce code challenge
+const ErrCodePKCERequired = "PKCERequired"
+
+// NewPKCERequiredError creates a new specific error
+func NewPKCERequiredError(clientId string, includeStack bool) errors.RichError {
+	msg := "app requires a pkce code challenge"
+	err := errors.NewRichError(ErrCodePKCERequired, msg).AddMetaData("clientId", clientId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsPKCERequiredError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodePKCERequired
+}
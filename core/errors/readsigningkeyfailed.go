@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeReadSigningKeyFailed failed to read signing key file
+const ErrCodeReadSigningKeyFailed = "ReadSigningKeyFailed"
+
+// NewReadSigningKeyFailedError creates a new specific error
+func NewReadSigningKeyFailedError(path string, readError error, includeStack bool) errors.RichError {
+	msg := "failed to read signing key file"
+	err := errors.NewRichError(ErrCodeReadSigningKeyFailed, msg).AddMetaData("path", path).AddError(readError)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsReadSigningKeyFailedError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeReadSigningKeyFailed
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeRefreshTokenClientMismatch refresh token was not issued to client
+const ErrCodeRefreshTokenClientMismatch = "RefreshTokenClientMismatch"
+
+// NewRefreshTokenClientMismatchError creates a new specific error
+func NewRefreshTokenClientMismatchError(clientId string, includeStack bool) errors.RichError {
+	msg := "refresh token was not issued to client"
+	err := errors.NewRichError(ErrCodeRefreshTokenClientMismatch, msg).AddMetaData("clientId", clientId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsRefreshTokenClientMismatchError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeRefreshTokenClientMismatch
+}
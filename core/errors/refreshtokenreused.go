@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeRefreshTokenReused refresh token has already been used
+const ErrCodeRefreshTokenReused = "RefreshTokenReused"
+
+// NewRefreshTokenReusedError creates a new specific error
+func NewRefreshTokenReusedError(clientId string, includeStack bool) errors.RichError {
+	msg := "refresh token has already been used"
+	err := errors.NewRichError(ErrCodeRefreshTokenReused, msg).AddMetaData("clientId", clientId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsRefreshTokenReusedError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeRefreshTokenReused
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeResponseTypeNotAllowed the app is not allowed to use the response type
+const ErrCodeResponseTypeNotAllowed = "ResponseTypeNotAllowed"
+
+// NewResponseTypeNotAllowedError creates a new specific error
+func NewResponseTypeNotAllowedError(clientId string, responseType string, includeStack bool) errors.RichError {
+	msg := "the app is not allowed to use the response type"
+	err := errors.NewRichError(ErrCodeResponseTypeNotAllowed, msg).AddMetaData("clientId", clientId).AddMetaData("responseType", responseType)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsResponseTypeNotAllowedError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeResponseTypeNotAllowed
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeSchemaMigrationFailed failed to apply schema migration
+const ErrCodeSchemaMigrationFailed = "SchemaMigrationFailed"
+
+// NewSchemaMigrationFailedError creates a new specific error
+func NewSchemaMigrationFailedError(version int, migrationError error, includeStack bool) errors.RichError {
+	msg := "failed to apply schema migration"
+	err := errors.NewRichError(ErrCodeSchemaMigrationFailed, msg).AddMetaData("version", version).AddError(migrationError)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsSchemaMigrationFailedError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeSchemaMigrationFailed
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeTokenClientMismatch token was not issued to client
+const ErrCodeTokenClientMismatch = "TokenClientMismatch"
+
+// NewTokenClientMismatchError creates a new specific error
+func NewTokenClientMismatchError(clientId string, includeStack bool) errors.RichError {
+	msg := "token was not issued to client"
+	err := errors.NewRichError(ErrCodeTokenClientMismatch, msg).AddMetaData("clientId", clientId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsTokenClientMismatchError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeTokenClientMismatch
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeTOTPAlreadyEnabled totp is already enabled for user
+const ErrCodeTOTPAlreadyEnabled = "TOTPAlreadyEnabled"
+
+// NewTOTPAlreadyEnabledError creates a new specific error
+func NewTOTPAlreadyEnabledError(userId string, includeStack bool) errors.RichError {
+	msg := "totp is already enabled for user"
+	err := errors.NewRichError(ErrCodeTOTPAlreadyEnabled, msg).AddMetaData("userId", userId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsTOTPAlreadyEnabledError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeTOTPAlreadyEnabled
+}
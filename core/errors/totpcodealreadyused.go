@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeTOTPCodeAlreadyUsed a totp code for this time step has already been used
+const ErrCodeTOTPCodeAlreadyUsed = "TOTPCodeAlreadyUsed"
+
+// NewTOTPCodeAlreadyUsedError creates a new specific error
+func NewTOTPCodeAlreadyUsedError(userId string, timeStep int64, includeStack bool) errors.RichError {
+	msg := "a totp code for this time step has already been used"
+	err := errors.NewRichError(ErrCodeTOTPCodeAlreadyUsed, msg).AddMetaData("userId", userId).AddMetaData("timeStep", timeStep)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsTOTPCodeAlreadyUsedError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeTOTPCodeAlreadyUsed
+}
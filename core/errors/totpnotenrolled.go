@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeTOTPNotEnrolled user has not started totp enrollment
+const ErrCodeTOTPNotEnrolled = "TOTPNotEnrolled"
+
+// NewTOTPNotEnrolledError creates a new specific error
+func NewTOTPNotEnrolledError(userId string, includeStack bool) errors.RichError {
+	msg := "user has not started totp enrollment"
+	err := errors.NewRichError(ErrCodeTOTPNotEnrolled, msg).AddMetaData("userId", userId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsTOTPNotEnrolledError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeTOTPNotEnrolled
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeUnsupportedCodeChallengeMethod code challenge method is not supported
+const ErrCodeUnsupportedCodeChallengeMethod = "UnsupportedCodeChallengeMethod"
+
+// NewUnsupportedCodeChallengeMethodError creates a new specific error
+func NewUnsupportedCodeChallengeMethodError(codeChallengeMethod string, includeStack bool) errors.RichError {
+	msg := "code challenge method is not supported"
+	err := errors.NewRichError(ErrCodeUnsupportedCodeChallengeMethod, msg).AddMetaData("codeChallengeMethod", codeChallengeMethod)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsUnsupportedCodeChallengeMethodError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeUnsupportedCodeChallengeMethod
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeUnsupportedGrantType grant type is not supported
+const ErrCodeUnsupportedGrantType = "UnsupportedGrantType"
+
+// NewUnsupportedGrantTypeError creates a new specific error
+func NewUnsupportedGrantTypeError(grantType string, includeStack bool) errors.RichError {
+	msg := "grant type is not supported"
+	err := errors.NewRichError(ErrCodeUnsupportedGrantType, msg).AddMetaData("grantType", grantType)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsUnsupportedGrantTypeError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeUnsupportedGrantType
+}
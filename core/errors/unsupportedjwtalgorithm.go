@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeUnsupportedJWTAlgorithm jwt signing algorithm is not supported
+const ErrCodeUnsupportedJWTAlgorithm = "UnsupportedJWTAlgorithm"
+
+// NewUnsupportedJWTAlgorithmError creates a new specific error
+func NewUnsupportedJWTAlgorithmError(algorithm string, includeStack bool) errors.RichError {
+	msg := "jwt signing algorithm is not supported"
+	err := errors.NewRichError(ErrCodeUnsupportedJWTAlgorithm, msg).AddMetaData("algorithm", algorithm)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsUnsupportedJWTAlgorithmError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeUnsupportedJWTAlgorithm
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeUnsupportedPasswordHashAlgorithm password hash algorithm is not supported
+const ErrCodeUnsupportedPasswordHashAlgorithm = "UnsupportedPasswordHashAlgorithm"
+
+// NewUnsupportedPasswordHashAlgorithmError creates a new specific error
+func NewUnsupportedPasswordHashAlgorithmError(algorithm string, assetId string, includeStack bool) errors.RichError {
+	msg := "password hash algorithm is not supported"
+	err := errors.NewRichError(ErrCodeUnsupportedPasswordHashAlgorithm, msg).AddMetaData("algorithm", algorithm).AddMetaData("assetId", assetId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsUnsupportedPasswordHashAlgorithmError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeUnsupportedPasswordHashAlgorithm
+}
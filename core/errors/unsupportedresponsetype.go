@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeUnsupportedResponseType response type is not supported
+const ErrCodeUnsupportedResponseType = "UnsupportedResponseType"
+
+// NewUnsupportedResponseTypeError creates a new specific error
+func NewUnsupportedResponseTypeError(responseType string, includeStack bool) errors.RichError {
+	msg := "response type is not supported"
+	err := errors.NewRichError(ErrCodeUnsupportedResponseType, msg).AddMetaData("responseType", responseType)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsUnsupportedResponseTypeError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeUnsupportedResponseType
+}
@@ -0,0 +1,24 @@
+package errors
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"github.com/calvine/richerror/errors"
+)
+
+// ErrCodeUserPasswordAlreadySet user already has a password set
+const ErrCodeUserPasswordAlreadySet = "UserPasswordAlreadySet"
+
+// NewUserPasswordAlreadySetError creates a new specific error
+func NewUserPasswordAlreadySetError(userId string, includeStack bool) errors.RichError {
+	msg := "user already has a password set"
+	err := errors.NewRichError(ErrCodeUserPasswordAlreadySet, msg).AddMetaData("userId", userId)
+	if includeStack {
+		err = err.WithStack(1)
+	}
+	return err
+}
+
+func IsUserPasswordAlreadySetError(err errors.ReadOnlyRichError) bool {
+	return err.GetErrorCode() == ErrCodeUserPasswordAlreadySet
+}
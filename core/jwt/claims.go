@@ -0,0 +1,47 @@
+package jwt
+
+import (
+	"time"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+)
+
+// StandardClaims are the registered claims defined in https://datatracker.ietf.org/doc/html/rfc7519#section-4.1
+type StandardClaims struct {
+	Issuer    string `json:"iss,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ID        string `json:"jti,omitempty"`
+}
+
+// Valid ensures the claims are not expired and are not being used before they are valid.
+func (sc StandardClaims) Valid(now time.Time) errors.RichError {
+	if sc.ExpiresAt != 0 && now.Unix() >= sc.ExpiresAt {
+		return coreerrors.NewJWTExpiredError(time.Unix(sc.ExpiresAt, 0), true)
+	}
+	if sc.NotBefore != 0 && now.Unix() < sc.NotBefore {
+		return coreerrors.NewJWTNotYetValidError(time.Unix(sc.NotBefore, 0), true)
+	}
+	return nil
+}
+
+// AccessTokenClaims are the claims of an access token based on https://datatracker.ietf.org/doc/html/rfc9068#section-2.2
+type AccessTokenClaims struct {
+	StandardClaims
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// IDTokenClaims are the claims of an OIDC id token described in https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+type IDTokenClaims struct {
+	StandardClaims
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time,omitempty"`
+	ACR      string `json:"acr,omitempty"`
+	models.UserClaims
+}
@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// JSONWebKey is the public part of a signing key as described in https://datatracker.ietf.org/doc/html/rfc7517#section-4
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	// RSA public key parameters from https://datatracker.ietf.org/doc/html/rfc7518#section-6.3.1
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP public key parameters from https://datatracker.ietf.org/doc/html/rfc7518#section-6.2.1 and https://datatracker.ietf.org/doc/html/rfc8037#section-2
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is a set of public keys as described in https://datatracker.ietf.org/doc/html/rfc7517#section-5
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKey creates the JSON web key for the public part of a signing key. It returns false for symmetric keys because they must never be published.
+func NewJSONWebKey(signingKey SigningKey) (JSONWebKey, bool) {
+	jwk := JSONWebKey{
+		Use:       "sig",
+		Algorithm: signingKey.Algorithm(),
+		KeyID:     signingKey.KeyID(),
+	}
+	switch publicKey := signingKey.PublicKey().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk.KeyType = "EC"
+		jwk.Curve = publicKey.Curve.Params().Name
+		jwk.X = encodeCoordinate(publicKey.X)
+		jwk.Y = encodeCoordinate(publicKey.Y)
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return JSONWebKey{}, false
+	}
+	return jwk, true
+}
+
+// NewJSONWebKeySet creates a JSON web key set with the public parts of the signing keys, skipping symmetric keys.
+func NewJSONWebKeySet(signingKeys []SigningKey) JSONWebKeySet {
+	keySet := JSONWebKeySet{
+		Keys: make([]JSONWebKey, 0, len(signingKeys)),
+	}
+	for _, signingKey := range signingKeys {
+		if jwk, ok := NewJSONWebKey(signingKey); ok {
+			keySet.Keys = append(keySet.Keys, jwk)
+		}
+	}
+	return keySet
+}
+
+// encodeCoordinate encodes an elliptic curve coordinate with the full length of the curve as required by https://datatracker.ietf.org/doc/html/rfc7518#section-6.2.1.2
+func encodeCoordinate(coordinate *big.Int) string {
+	coordinateBytes := make([]byte, es256CoordinateSize)
+	coordinate.FillBytes(coordinateBytes)
+	return base64.RawURLEncoding.EncodeToString(coordinateBytes)
+}
@@ -0,0 +1,67 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+func TestNewJSONWebKey(t *testing.T) {
+	tests := []struct {
+		Algorithm       string
+		ExpectedKeyType string
+		ExpectedCurve   string
+	}{
+		{AlgorithmRS256, "RSA", ""},
+		{AlgorithmES256, "EC", "P-256"},
+		{AlgorithmEdDSA, "OKP", "Ed25519"},
+	}
+	for _, test := range tests {
+		signingKey := generateTestSigningKey(t, test.Algorithm, "key_id")
+		jwk, ok := NewJSONWebKey(signingKey)
+		if !ok {
+			t.Error(test.Algorithm, "failed to create json web key")
+			continue
+		}
+		if jwk.KeyType != test.ExpectedKeyType {
+			t.Error(test.Algorithm, "key type did not match expected value", test.ExpectedKeyType, jwk.KeyType)
+		}
+		if jwk.Curve != test.ExpectedCurve {
+			t.Error(test.Algorithm, "curve did not match expected value", test.ExpectedCurve, jwk.Curve)
+		}
+		if jwk.KeyID != "key_id" || jwk.Algorithm != test.Algorithm || jwk.Use != "sig" {
+			t.Error(test.Algorithm, "json web key metadata is not correct", jwk)
+		}
+		switch publicKey := signingKey.PublicKey().(type) {
+		case *rsa.PublicKey:
+			n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+			if new(big.Int).SetBytes(n).Cmp(publicKey.N) != 0 {
+				t.Error(test.Algorithm, "modulus did not match public key")
+			}
+			if jwk.E != "AQAB" {
+				t.Error(test.Algorithm, "exponent did not match expected value", "AQAB", jwk.E)
+			}
+		case *ecdsa.PublicKey:
+			x, _ := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, _ := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if len(x) != 32 || len(y) != 32 {
+				t.Error(test.Algorithm, "coordinates must be 32 bytes")
+			}
+			if new(big.Int).SetBytes(x).Cmp(publicKey.X) != 0 || new(big.Int).SetBytes(y).Cmp(publicKey.Y) != 0 {
+				t.Error(test.Algorithm, "coordinates did not match public key")
+			}
+		}
+	}
+}
+
+func TestNewJSONWebKeySetSkipsSymmetricKeys(t *testing.T) {
+	keySet := NewJSONWebKeySet([]SigningKey{
+		generateTestSigningKey(t, AlgorithmHS256, "hmac_key"),
+		generateTestSigningKey(t, AlgorithmEdDSA, "eddsa_key"),
+	})
+	if len(keySet.Keys) != 1 || keySet.Keys[0].KeyID != "eddsa_key" {
+		t.Error("symmetric keys must not be published", keySet)
+	}
+}
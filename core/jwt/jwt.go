@@ -0,0 +1,117 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/richerror/errors"
+)
+
+// signing algorithms defined in https://datatracker.ietf.org/doc/html/rfc7518#section-3.1 and https://datatracker.ietf.org/doc/html/rfc8037#section-3.1
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmHS256 = "HS256"
+)
+
+const headerTypeJWT = "JWT"
+
+// Header is the JOSE header of a JWT.
+type Header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// ClaimsValidator is implemented by claims that can check if they are valid at a given time.
+type ClaimsValidator interface {
+	Valid(now time.Time) errors.RichError
+}
+
+// Sign creates a compact serialized JWT with the given claims signed by the signer.
+func Sign(signer Signer, claims interface{}) (string, errors.RichError) {
+	header := Header{
+		Algorithm: signer.Algorithm(),
+		Type:      headerTypeJWT,
+		KeyID:     signer.KeyID(),
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", coreerrors.NewJWTSigningFailedError(signer.KeyID(), err, true)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", coreerrors.NewJWTSigningFailedError(signer.KeyID(), err, true)
+	}
+	signingInput := encodeSegment(headerJSON) + "." + encodeSegment(claimsJSON)
+	signature, rErr := signer.Sign([]byte(signingInput))
+	if rErr != nil {
+		return "", rErr
+	}
+	return signingInput + "." + encodeSegment(signature), nil
+}
+
+// Verify checks the signature of the JWT with the verifier for the key id in its header, then decodes its claims into the claims parameter which must be a pointer.
+// If the claims implement ClaimsValidator they are also validated against the current time.
+func Verify(token string, verifierProvider VerifierProvider, claims interface{}) (Header, errors.RichError) {
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return Header{}, coreerrors.NewMalformedJWTError("jwt must have three segments", true)
+	}
+	var header Header
+	rErr := decodeSegmentJSON(segments[0], &header)
+	if rErr != nil {
+		return Header{}, rErr
+	}
+	if header.KeyID == "" {
+		return Header{}, coreerrors.NewMalformedJWTError("jwt header does not have a key id", true)
+	}
+	verifier, rErr := verifierProvider.GetVerifier(header.KeyID)
+	if rErr != nil {
+		return Header{}, rErr
+	}
+	// the algorithm is dictated by the key rather than the token header to prevent algorithm substitution attacks.
+	if header.Algorithm != verifier.Algorithm() {
+		return Header{}, coreerrors.NewJWTAlgorithmMismatchError(header.Algorithm, verifier.Algorithm(), true)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return Header{}, coreerrors.NewMalformedJWTError("jwt signature is not base64url encoded", true)
+	}
+	signingInput := segments[0] + "." + segments[1]
+	rErr = verifier.Verify([]byte(signingInput), signature)
+	if rErr != nil {
+		return Header{}, rErr
+	}
+	rErr = decodeSegmentJSON(segments[1], claims)
+	if rErr != nil {
+		return Header{}, rErr
+	}
+	if validator, ok := claims.(ClaimsValidator); ok {
+		rErr = validator.Valid(time.Now())
+		if rErr != nil {
+			return Header{}, rErr
+		}
+	}
+	return header, nil
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSegmentJSON(segment string, value interface{}) errors.RichError {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return coreerrors.NewMalformedJWTError("jwt segment is not base64url encoded", true)
+	}
+	err = json.Unmarshal(data, value)
+	if err != nil {
+		return coreerrors.NewMalformedJWTError("jwt segment is not valid json", true)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package jwt
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+)
+
+var testAlgorithms = []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA, AlgorithmHS256}
+
+func generateTestSigningKey(t *testing.T, algorithm, keyID string) SigningKey {
+	signingKey, err := GenerateSigningKey(algorithm, keyID)
+	if err != nil {
+		t.Fatalf("failed to generate %s signing key: %s", algorithm, err.Error())
+	}
+	return signingKey
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, algorithm := range testAlgorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			signingKey := generateTestSigningKey(t, algorithm, "")
+			keyProvider := NewStaticKeyProvider(signingKey)
+			claims := AccessTokenClaims{
+				StandardClaims: StandardClaims{
+					Issuer:    "https://goauth.test",
+					Subject:   "user_id",
+					ExpiresAt: time.Now().Add(time.Minute).Unix(),
+					IssuedAt:  time.Now().Unix(),
+					ID:        "token_id",
+				},
+				ClientID: "client_id",
+				Scope:    "scope_1 scope_2",
+			}
+			token, err := Sign(signingKey, claims)
+			if err != nil {
+				t.Fatalf("failed to sign jwt: %s", err.Error())
+			}
+			var verifiedClaims AccessTokenClaims
+			header, err := Verify(token, keyProvider, &verifiedClaims)
+			if err != nil {
+				t.Fatalf("failed to verify jwt: %s", err.Error())
+			}
+			if header.KeyID != signingKey.KeyID() {
+				t.Errorf("header key id does not match expected value: got %s - expected %s", header.KeyID, signingKey.KeyID())
+			}
+			if header.Algorithm != algorithm {
+				t.Errorf("header algorithm does not match expected value: got %s - expected %s", header.Algorithm, algorithm)
+			}
+			if verifiedClaims != claims {
+				t.Errorf("verified claims do not match expected value: got %v - expected %v", verifiedClaims, claims)
+			}
+		})
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	signingKey := generateTestSigningKey(t, AlgorithmES256, "key_1")
+	otherSigningKey := generateTestSigningKey(t, AlgorithmES256, "key_1")
+	hmacSigningKey := generateTestSigningKey(t, AlgorithmHS256, "key_1")
+	validClaims := StandardClaims{Subject: "user_id", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+	validToken, _ := Sign(signingKey, validClaims)
+	expiredToken, _ := Sign(signingKey, StandardClaims{Subject: "user_id", ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	notYetValidToken, _ := Sign(signingKey, StandardClaims{Subject: "user_id", NotBefore: time.Now().Add(time.Minute).Unix()})
+	otherKeyToken, _ := Sign(otherSigningKey, validClaims)
+	hmacToken, _ := Sign(hmacSigningKey, validClaims)
+	segments := strings.Split(validToken, ".")
+	tamperedClaims, _ := Sign(signingKey, StandardClaims{Subject: "other_user_id"})
+	tamperedToken := segments[0] + "." + strings.Split(tamperedClaims, ".")[1] + "." + segments[2]
+	unknownKeyToken, _ := Sign(generateTestSigningKey(t, AlgorithmES256, "key_2"), validClaims)
+
+	tests := []struct {
+		Name              string
+		Token             string
+		ExpectedErrorCode string
+	}{
+		{
+			Name:              "Test malformed token",
+			Token:             "not.a.jwt.at.all",
+			ExpectedErrorCode: coreerrors.ErrCodeMalformedJWT,
+		}, {
+			Name:              "Test expired token",
+			Token:             expiredToken,
+			ExpectedErrorCode: coreerrors.ErrCodeJWTExpired,
+		}, {
+			Name:              "Test token not yet valid",
+			Token:             notYetValidToken,
+			ExpectedErrorCode: coreerrors.ErrCodeJWTNotYetValid,
+		}, {
+			Name:              "Test token signed by another key with the same key id",
+			Token:             otherKeyToken,
+			ExpectedErrorCode: coreerrors.ErrCodeInvalidJWTSignature,
+		}, {
+			Name:              "Test token with substituted algorithm",
+			Token:             hmacToken,
+			ExpectedErrorCode: coreerrors.ErrCodeJWTAlgorithmMismatch,
+		}, {
+			Name:              "Test token with tampered claims",
+			Token:             tamperedToken,
+			ExpectedErrorCode: coreerrors.ErrCodeInvalidJWTSignature,
+		}, {
+			Name:              "Test token with unknown key id",
+			Token:             unknownKeyToken,
+			ExpectedErrorCode: coreerrors.ErrCodeNoSigningKeyFound,
+		},
+	}
+
+	keyProvider := NewStaticKeyProvider(signingKey)
+	for _, test := range tests {
+		var claims StandardClaims
+		_, err := Verify(test.Token, keyProvider, &claims)
+		if err == nil {
+			t.Error(test.Name, "expected error but got none", test.ExpectedErrorCode)
+			continue
+		}
+		if err.GetErrorCode() != test.ExpectedErrorCode {
+			t.Error(test.Name, "error code did not match expected value", test.ExpectedErrorCode, err.GetErrorCode())
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/utilities"
+	"github.com/calvine/richerror/errors"
+)
+
+const (
+	rsaKeySize          = 2048
+	hmacMinSecretLength = 32
+)
+
+// GenerateSigningKey creates a new random signing key for the algorithm. If no key id is provided a random one is generated.
+func GenerateSigningKey(algorithm, keyID string) (SigningKey, errors.RichError) {
+	keyID, rErr := ensureKeyID(keyID)
+	if rErr != nil {
+		return nil, rErr
+	}
+	switch algorithm {
+	case AlgorithmRS256:
+		privateKey, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+		if err != nil {
+			return nil, coreerrors.NewInvalidSigningKeyError(algorithm, err.Error(), true)
+		}
+		return rsaSigningKey{keyID, privateKey}, nil
+	case AlgorithmES256:
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, coreerrors.NewInvalidSigningKeyError(algorithm, err.Error(), true)
+		}
+		return ecdsaSigningKey{keyID, privateKey}, nil
+	case AlgorithmEdDSA:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, coreerrors.NewInvalidSigningKeyError(algorithm, err.Error(), true)
+		}
+		return ed25519SigningKey{keyID, privateKey}, nil
+	case AlgorithmHS256:
+		secret := make([]byte, hmacMinSecretLength)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return nil, coreerrors.NewInvalidSigningKeyError(algorithm, err.Error(), true)
+		}
+		return hmacSigningKey{keyID, secret}, nil
+	default:
+		return nil, coreerrors.NewUnsupportedJWTAlgorithmError(algorithm, true)
+	}
+}
+
+// LoadSigningKeyFromFile reads a signing key for the algorithm from a file. See ParseSigningKey for the supported formats.
+func LoadSigningKeyFromFile(algorithm, keyID, path string) (SigningKey, errors.RichError) {
+	keyData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, coreerrors.NewReadSigningKeyFailedError(path, err, true)
+	}
+	return ParseSigningKey(algorithm, keyID, keyData)
+}
+
+// ParseSigningKey parses a signing key for the algorithm. If no key id is provided a random one is generated.
+// RS256 keys can be PEM encoded PKCS #1 or PKCS #8, ES256 keys can be PEM encoded SEC 1 or PKCS #8 on the P-256 curve, and EdDSA keys must be PEM encoded PKCS #8.
+// HS256 keys are the raw secret which must be at least 32 bytes.
+func ParseSigningKey(algorithm, keyID string, keyData []byte) (SigningKey, errors.RichError) {
+	keyID, rErr := ensureKeyID(keyID)
+	if rErr != nil {
+		return nil, rErr
+	}
+	if algorithm == AlgorithmHS256 {
+		if len(keyData) < hmacMinSecretLength {
+			return nil, coreerrors.NewInvalidSigningKeyError(algorithm, "secret must be at least 32 bytes", true)
+		}
+		return hmacSigningKey{keyID, keyData}, nil
+	}
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return nil, coreerrors.NewInvalidSigningKeyError(algorithm, "key is not pem encoded", true)
+	}
+	switch algorithm {
+	case AlgorithmRS256:
+		var privateKey *rsa.PrivateKey
+		if block.Type == "RSA PRIVATE KEY" {
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, coreerrors.NewInvalidSigningKeyError(algorithm, err.Error(), true)
+			}
+			privateKey = key
+		} else {
+			key, rErr := parsePKCS8PrivateKey(algorithm, block.Bytes)
+			if rErr != nil {
+				return nil, rErr
+			}
+			rsaKey, ok := key.(*rsa.PrivateKey)
+			if !ok {
+				return nil, coreerrors.NewInvalidSigningKeyError(algorithm, "key is not an rsa private key", true)
+			}
+			privateKey = rsaKey
+		}
+		if privateKey.N.BitLen() < rsaKeySize {
+			return nil, coreerrors.NewInvalidSigningKeyError(algorithm, "rsa key must be at least 2048 bits", true)
+		}
+		return rsaSigningKey{keyID, privateKey}, nil
+	case AlgorithmES256:
+		var privateKey *ecdsa.PrivateKey
+		if block.Type == "EC PRIVATE KEY" {
+			key, err := x509.ParseECPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, coreerrors.NewInvalidSigningKeyError(algorithm, err.Error(), true)
+			}
+			privateKey = key
+		} else {
+			key, rErr := parsePKCS8PrivateKey(algorithm, block.Bytes)
+			if rErr != nil {
+				return nil, rErr
+			}
+			ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+			if !ok {
+				return nil, coreerrors.NewInvalidSigningKeyError(algorithm, "key is not an ecdsa private key", true)
+			}
+			privateKey = ecdsaKey
+		}
+		if privateKey.Curve != elliptic.P256() {
+			return nil, coreerrors.NewInvalidSigningKeyError(algorithm, "ecdsa key must use the P-256 curve", true)
+		}
+		return ecdsaSigningKey{keyID, privateKey}, nil
+	case AlgorithmEdDSA:
+		key, rErr := parsePKCS8PrivateKey(algorithm, block.Bytes)
+		if rErr != nil {
+			return nil, rErr
+		}
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, coreerrors.NewInvalidSigningKeyError(algorithm, "key is not an ed25519 private key", true)
+		}
+		return ed25519SigningKey{keyID, privateKey}, nil
+	default:
+		return nil, coreerrors.NewUnsupportedJWTAlgorithmError(algorithm, true)
+	}
+}
+
+// MarshalSigningKey encodes the private key of a signing key in a format ParseSigningKey can read. HS256 keys are the raw secret and the other keys are PEM encoded PKCS #8.
+func MarshalSigningKey(signingKey SigningKey) ([]byte, errors.RichError) {
+	var privateKey interface{}
+	switch key := signingKey.(type) {
+	case hmacSigningKey:
+		return key.secret, nil
+	case rsaSigningKey:
+		privateKey = key.privateKey
+	case ecdsaSigningKey:
+		privateKey = key.privateKey
+	case ed25519SigningKey:
+		privateKey = key.privateKey
+	default:
+		return nil, coreerrors.NewUnsupportedJWTAlgorithmError(signingKey.Algorithm(), true)
+	}
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, coreerrors.NewInvalidSigningKeyError(signingKey.Algorithm(), err.Error(), true)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}), nil
+}
+
+func parsePKCS8PrivateKey(algorithm string, keyBytes []byte) (interface{}, errors.RichError) {
+	key, err := x509.ParsePKCS8PrivateKey(keyBytes)
+	if err != nil {
+		return nil, coreerrors.NewInvalidSigningKeyError(algorithm, err.Error(), true)
+	}
+	return key, nil
+}
+
+func ensureKeyID(keyID string) (string, errors.RichError) {
+	if keyID != "" {
+		return keyID, nil
+	}
+	return utilities.NewVariableLengthTokenString(1)
+}
@@ -0,0 +1,173 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+)
+
+func encodeTestPEM(t *testing.T, blockType string, keyBytes []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: keyBytes})
+}
+
+func encodeTestPKCS8PEM(t *testing.T, privateKey interface{}) []byte {
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("failed to marshal private key: %s", err.Error())
+	}
+	return encodeTestPEM(t, "PRIVATE KEY", keyBytes)
+}
+
+func TestParseSigningKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	smallRSAKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecdsaP384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	ecdsaSEC1Bytes, _ := x509.MarshalECPrivateKey(ecdsaKey)
+	_, ed25519Key, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		Name              string
+		Algorithm         string
+		KeyData           []byte
+		ExpectedErrorCode string
+	}{
+		{
+			Name:      "Test RS256 PKCS1 key",
+			Algorithm: AlgorithmRS256,
+			KeyData:   encodeTestPEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)),
+		}, {
+			Name:      "Test RS256 PKCS8 key",
+			Algorithm: AlgorithmRS256,
+			KeyData:   encodeTestPKCS8PEM(t, rsaKey),
+		}, {
+			Name:              "Test RS256 key too small",
+			Algorithm:         AlgorithmRS256,
+			KeyData:           encodeTestPKCS8PEM(t, smallRSAKey),
+			ExpectedErrorCode: coreerrors.ErrCodeInvalidSigningKey,
+		}, {
+			Name:      "Test ES256 SEC1 key",
+			Algorithm: AlgorithmES256,
+			KeyData:   encodeTestPEM(t, "EC PRIVATE KEY", ecdsaSEC1Bytes),
+		}, {
+			Name:      "Test ES256 PKCS8 key",
+			Algorithm: AlgorithmES256,
+			KeyData:   encodeTestPKCS8PEM(t, ecdsaKey),
+		}, {
+			Name:              "Test ES256 key with wrong curve",
+			Algorithm:         AlgorithmES256,
+			KeyData:           encodeTestPKCS8PEM(t, ecdsaP384Key),
+			ExpectedErrorCode: coreerrors.ErrCodeInvalidSigningKey,
+		}, {
+			Name:      "Test EdDSA PKCS8 key",
+			Algorithm: AlgorithmEdDSA,
+			KeyData:   encodeTestPKCS8PEM(t, ed25519Key),
+		}, {
+			Name:              "Test EdDSA with rsa key",
+			Algorithm:         AlgorithmEdDSA,
+			KeyData:           encodeTestPKCS8PEM(t, rsaKey),
+			ExpectedErrorCode: coreerrors.ErrCodeInvalidSigningKey,
+		}, {
+			Name:      "Test HS256 secret",
+			Algorithm: AlgorithmHS256,
+			KeyData:   []byte("this is a secret that is long enough"),
+		}, {
+			Name:              "Test HS256 secret too short",
+			Algorithm:         AlgorithmHS256,
+			KeyData:           []byte("short secret"),
+			ExpectedErrorCode: coreerrors.ErrCodeInvalidSigningKey,
+		}, {
+			Name:              "Test key not pem encoded",
+			Algorithm:         AlgorithmRS256,
+			KeyData:           []byte("not a pem encoded key"),
+			ExpectedErrorCode: coreerrors.ErrCodeInvalidSigningKey,
+		}, {
+			Name:              "Test unsupported algorithm",
+			Algorithm:         "PS256",
+			KeyData:           encodeTestPKCS8PEM(t, rsaKey),
+			ExpectedErrorCode: coreerrors.ErrCodeUnsupportedJWTAlgorithm,
+		},
+	}
+
+	for _, test := range tests {
+		signingKey, err := ParseSigningKey(test.Algorithm, "key_id", test.KeyData)
+		if test.ExpectedErrorCode != "" {
+			if err == nil {
+				t.Error(test.Name, "expected error but got none", test.ExpectedErrorCode)
+			} else if err.GetErrorCode() != test.ExpectedErrorCode {
+				t.Error(test.Name, "error code did not match expected value", test.ExpectedErrorCode, err.GetErrorCode())
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(test.Name, "failed to parse signing key", err.Error())
+			continue
+		}
+		if signingKey.Algorithm() != test.Algorithm {
+			t.Error(test.Name, "algorithm did not match expected value", test.Algorithm, signingKey.Algorithm())
+		}
+		if signingKey.KeyID() != "key_id" {
+			t.Error(test.Name, "key id did not match expected value", "key_id", signingKey.KeyID())
+		}
+	}
+}
+
+func TestLoadSigningKeyFromFile(t *testing.T) {
+	_, ed25519Key, _ := ed25519.GenerateKey(rand.Reader)
+	dir, err := ioutil.TempDir("", "goauth_jwt_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	keyPath := filepath.Join(dir, "signing_key.pem")
+	err = ioutil.WriteFile(keyPath, encodeTestPKCS8PEM(t, ed25519Key), 0600)
+	if err != nil {
+		t.Fatalf("failed to write key file: %s", err.Error())
+	}
+	signingKey, rErr := LoadSigningKeyFromFile(AlgorithmEdDSA, "", keyPath)
+	if rErr != nil {
+		t.Fatalf("failed to load signing key: %s", rErr.Error())
+	}
+	if signingKey.KeyID() == "" {
+		t.Error("key id was not generated")
+	}
+	_, rErr = LoadSigningKeyFromFile(AlgorithmEdDSA, "", filepath.Join(dir, "missing.pem"))
+	if rErr == nil || rErr.GetErrorCode() != coreerrors.ErrCodeReadSigningKeyFailed {
+		t.Error("expected read signing key failed error for missing file")
+	}
+}
+
+func TestMarshalSigningKey(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA, AlgorithmHS256} {
+		t.Run(algorithm, func(t *testing.T) {
+			signingKey := generateTestSigningKey(t, algorithm, "marshal_key")
+			keyData, err := MarshalSigningKey(signingKey)
+			if err != nil {
+				t.Fatalf("failed to marshal signing key: %s", err.Error())
+			}
+			parsedKey, err := ParseSigningKey(algorithm, signingKey.KeyID(), keyData)
+			if err != nil {
+				t.Fatalf("failed to parse marshalled signing key: %s", err.Error())
+			}
+			token, err := Sign(signingKey, StandardClaims{Subject: "user_id"})
+			if err != nil {
+				t.Fatalf("failed to sign jwt: %s", err.Error())
+			}
+			var claims StandardClaims
+			_, err = Verify(token, NewStaticKeyProvider(parsedKey), &claims)
+			if err != nil {
+				t.Errorf("token signed by the original key should verify with the parsed key: %s", err.Error())
+			}
+		})
+	}
+}
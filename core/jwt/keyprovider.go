@@ -0,0 +1,45 @@
+package jwt
+
+import (
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/richerror/errors"
+)
+
+// VerifierProvider looks up the verifier for a key id found in a JWT header.
+type VerifierProvider interface {
+	GetVerifier(keyID string) (Verifier, errors.RichError)
+}
+
+// KeyProvider provides the signer for new JWTs and the verifiers for existing JWTs.
+type KeyProvider interface {
+	// GetSigner returns the signer that should be used for new JWTs.
+	GetSigner() (Signer, errors.RichError)
+	// GetJSONWebKeySet returns the public keys that can be used to verify JWTs issued by the provider.
+	GetJSONWebKeySet() JSONWebKeySet
+
+	VerifierProvider
+}
+
+type staticKeyProvider struct {
+	signingKey SigningKey
+}
+
+// NewStaticKeyProvider creates a key provider that always uses a single signing key.
+func NewStaticKeyProvider(signingKey SigningKey) KeyProvider {
+	return staticKeyProvider{signingKey}
+}
+
+func (skp staticKeyProvider) GetSigner() (Signer, errors.RichError) {
+	return skp.signingKey, nil
+}
+
+func (skp staticKeyProvider) GetJSONWebKeySet() JSONWebKeySet {
+	return NewJSONWebKeySet([]SigningKey{skp.signingKey})
+}
+
+func (skp staticKeyProvider) GetVerifier(keyID string) (Verifier, errors.RichError) {
+	if keyID != skp.signingKey.KeyID() {
+		return nil, coreerrors.NewNoSigningKeyFoundError(keyID, true)
+	}
+	return skp.signingKey, nil
+}
@@ -0,0 +1,403 @@
+package jwt
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	repo "github.com/calvine/goauth/core/repositories"
+	"github.com/calvine/goauth/core/utilities"
+	"github.com/calvine/richerror/errors"
+)
+
+// pendingKeyRefreshInterval limits how often a token with the key id of a pending key makes the keyring reload its keys, so those tokens cannot be used to flood the key repo.
+const pendingKeyRefreshInterval = time.Second * 5
+
+// KeyState is the stage of a signing key in its lifecycle. Keys move from pending to active to retired, and are removed once every token they signed has expired.
+type KeyState string
+
+const (
+	// KeyStatePending keys are published so verifiers can cache them before they are used to sign tokens.
+	KeyStatePending KeyState = "pending"
+	// KeyStateActive keys sign new tokens. There is only one active key at a time.
+	KeyStateActive KeyState = "active"
+	// KeyStateRetired keys no longer sign tokens, but are still published so tokens they signed can be verified.
+	KeyStateRetired KeyState = "retired"
+)
+
+// KeyringOptions configures a Keyring.
+type KeyringOptions struct {
+	// Algorithm is the algorithm of the keys generated by the keyring.
+	Algorithm string
+	// MaxTokenLifetime is the longest lifetime of any token signed by the keyring. Retired keys stay published for this long after they are retired.
+	MaxTokenLifetime time.Duration
+	// KeyRepo stores the keys so they survive restarts and are shared by every instance using the same store. When it is nil the keys are only held in memory.
+	KeyRepo repo.JWTSigningKeyRepo
+	// EncryptionKey encrypts the private keys stored in the key repo.
+	EncryptionKey []byte
+	// RefreshInterval is how often the keys are reloaded from the key repo. Another instance may keep signing with a key for this long after it is retired, so retired keys stay published for this long on top of MaxTokenLifetime.
+	RefreshInterval time.Duration
+}
+
+// KeyInfo describes a key in a keyring.
+type KeyInfo struct {
+	KeyID       string
+	Algorithm   string
+	State       KeyState
+	CreatedAt   time.Time
+	ActivatedAt time.Time
+	RetiredAt   time.Time
+}
+
+type managedKey struct {
+	signingKey SigningKey
+	info       KeyInfo
+}
+
+// keyChange changes a copy of the keys of a keyring, and reports whether anything was changed.
+type keyChange func(keys []*managedKey, now time.Time) ([]*managedKey, bool, errors.RichError)
+
+// Keyring is a KeyProvider that manages the lifecycle of signing keys. It is safe for concurrent use.
+// With a key repo the keys are loaded from and saved to the repo, so every instance sharing the repo agrees on the active key. Without one the keys are only held in memory, so a new keyring starts with only the key it was created with.
+type Keyring struct {
+	lock             sync.RWMutex
+	algorithm        string
+	maxTokenLifetime time.Duration
+	refreshInterval  time.Duration
+	keyRepo          repo.JWTSigningKeyRepo
+	encryptionKey    []byte
+	keys             []*managedKey
+	version          int64
+	loadedAt         time.Time
+	now              func() time.Time
+}
+
+// NewKeyring creates a keyring. With a key repo the stored keys are loaded, and the active key provided is only used when no keys have been stored yet.
+// Otherwise the keyring starts with the active key provided and a new pending key. If no active key is provided one is generated.
+func NewKeyring(ctx context.Context, options KeyringOptions, activeKey SigningKey) (*Keyring, errors.RichError) {
+	if activeKey != nil {
+		if options.Algorithm == "" {
+			options.Algorithm = activeKey.Algorithm()
+		} else if options.Algorithm != activeKey.Algorithm() {
+			return nil, coreerrors.NewInvalidSigningKeyError(options.Algorithm, "active key algorithm does not match keyring algorithm", true)
+		}
+	}
+	keyring := &Keyring{
+		algorithm:        options.Algorithm,
+		maxTokenLifetime: options.MaxTokenLifetime,
+		refreshInterval:  options.RefreshInterval,
+		keyRepo:          options.KeyRepo,
+		encryptionKey:    options.EncryptionKey,
+		now:              time.Now,
+	}
+	keyring.lock.Lock()
+	defer keyring.lock.Unlock()
+	_, err := keyring.update(ctx, func(keys []*managedKey, now time.Time) ([]*managedKey, bool, errors.RichError) {
+		if len(keys) > 0 {
+			return keys, false, nil
+		}
+		if activeKey == nil {
+			generatedKey, err := GenerateSigningKey(keyring.algorithm, "")
+			if err != nil {
+				return nil, false, err
+			}
+			activeKey = generatedKey
+		}
+		keys = append(keys, newManagedKey(activeKey, KeyStateActive, now))
+		keys, err := keyring.addPendingKey(keys, now)
+		return keys, true, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keyring, nil
+}
+
+func newManagedKey(signingKey SigningKey, state KeyState, now time.Time) *managedKey {
+	key := &managedKey{
+		signingKey: signingKey,
+		info: KeyInfo{
+			KeyID:     signingKey.KeyID(),
+			Algorithm: signingKey.Algorithm(),
+			State:     state,
+			CreatedAt: now,
+		},
+	}
+	if state == KeyStateActive {
+		key.info.ActivatedAt = now
+	}
+	return key
+}
+
+// Rotate retires the active key, activates the pending key, adds a new pending key and removes retired keys that are no longer needed.
+// When another instance changes the stored keys at the same time its keys are used and this rotation is dropped, so a rotation requested on several instances at once only happens once.
+func (kr *Keyring) Rotate(ctx context.Context) errors.RichError {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+	_, err := kr.update(ctx, kr.rotateKeys)
+	return err
+}
+
+// RotateIfDue rotates the keys like Rotate, but only when the active key has been active for at least the rotation interval. Instances sharing a key repo can all call it on a schedule and the keys are only rotated once per interval.
+func (kr *Keyring) RotateIfDue(ctx context.Context, rotationInterval time.Duration) (bool, errors.RichError) {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+	return kr.update(ctx, func(keys []*managedKey, now time.Time) ([]*managedKey, bool, errors.RichError) {
+		for _, key := range keys {
+			if key.info.State == KeyStateActive && now.Before(key.info.ActivatedAt.Add(rotationInterval)) {
+				return keys, false, nil
+			}
+		}
+		return kr.rotateKeys(keys, now)
+	})
+}
+
+// Refresh reloads the keys from the key repo so changes made by other instances are picked up.
+func (kr *Keyring) Refresh(ctx context.Context) errors.RichError {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+	if kr.keyRepo == nil {
+		return nil
+	}
+	return kr.load(ctx)
+}
+
+// RemoveExpiredKeys removes retired keys once every token they could have signed has expired.
+func (kr *Keyring) RemoveExpiredKeys(ctx context.Context) errors.RichError {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+	_, err := kr.update(ctx, func(keys []*managedKey, now time.Time) ([]*managedKey, bool, errors.RichError) {
+		remainingKeys := kr.removeExpiredKeys(keys, now)
+		return remainingKeys, len(remainingKeys) != len(keys), nil
+	})
+	return err
+}
+
+// GetKeyInfo returns information about the keys in the keyring.
+func (kr *Keyring) GetKeyInfo() []KeyInfo {
+	kr.lock.RLock()
+	defer kr.lock.RUnlock()
+	keyInfo := make([]KeyInfo, 0, len(kr.keys))
+	for _, key := range kr.keys {
+		keyInfo = append(keyInfo, key.info)
+	}
+	return keyInfo
+}
+
+func (kr *Keyring) GetSigner() (Signer, errors.RichError) {
+	kr.lock.RLock()
+	defer kr.lock.RUnlock()
+	for _, key := range kr.keys {
+		if key.info.State == KeyStateActive {
+			return key.signingKey, nil
+		}
+	}
+	return nil, coreerrors.NewNoSigningKeyFoundError("", true)
+}
+
+// GetVerifier returns the active or retired key with the given key id. Pending keys are published but have not signed anything yet, so tokens with their key id are rejected.
+// Another instance may have activated a key this keyring still has as pending, so the keys are reloaded from the key repo before such a token is rejected.
+func (kr *Keyring) GetVerifier(keyID string) (Verifier, errors.RichError) {
+	verifier, pending := kr.getVerifier(keyID)
+	if verifier == nil && pending && kr.refreshForPendingKey() {
+		verifier, _ = kr.getVerifier(keyID)
+	}
+	if verifier == nil {
+		return nil, coreerrors.NewNoSigningKeyFoundError(keyID, true)
+	}
+	return verifier, nil
+}
+
+func (kr *Keyring) GetJSONWebKeySet() JSONWebKeySet {
+	kr.lock.RLock()
+	defer kr.lock.RUnlock()
+	now := kr.now()
+	signingKeys := make([]SigningKey, 0, len(kr.keys))
+	for _, key := range kr.keys {
+		if !kr.isExpired(key, now) {
+			signingKeys = append(signingKeys, key.signingKey)
+		}
+	}
+	return NewJSONWebKeySet(signingKeys)
+}
+
+// getVerifier returns the verifier for the key id if the key can verify tokens, and whether the key id belongs to a pending key.
+func (kr *Keyring) getVerifier(keyID string) (Verifier, bool) {
+	kr.lock.RLock()
+	defer kr.lock.RUnlock()
+	now := kr.now()
+	for _, key := range kr.keys {
+		if key.info.KeyID != keyID {
+			continue
+		}
+		if key.info.State == KeyStatePending {
+			return nil, true
+		}
+		if !kr.isExpired(key, now) {
+			return key.signingKey, false
+		}
+	}
+	return nil, false
+}
+
+// refreshForPendingKey reloads the keys from the key repo unless they were loaded within the pending key refresh interval, and returns true if they were reloaded.
+func (kr *Keyring) refreshForPendingKey() bool {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+	if kr.keyRepo == nil || kr.now().Before(kr.loadedAt.Add(pendingKeyRefreshInterval)) {
+		return false
+	}
+	return kr.load(context.TODO()) == nil
+}
+
+// update applies a change to a copy of the keys. With a key repo the stored keys are loaded first, and the changed keys are saved with a compare and swap on the version of the stored keys.
+// When another instance saved first its keys are loaded instead of retrying the change. The lock must be held by the caller.
+func (kr *Keyring) update(ctx context.Context, change keyChange) (bool, errors.RichError) {
+	if kr.keyRepo != nil {
+		err := kr.load(ctx)
+		if err != nil {
+			return false, err
+		}
+	}
+	keys := make([]*managedKey, 0, len(kr.keys))
+	for _, key := range kr.keys {
+		keyCopy := *key
+		keys = append(keys, &keyCopy)
+	}
+	keys, changed, err := change(keys, kr.now())
+	if err != nil || !changed {
+		return false, err
+	}
+	if kr.keyRepo != nil {
+		err = kr.save(ctx, keys)
+		if err != nil {
+			if err.GetErrorCode() == coreerrors.ErrCodeJWTSigningKeySetConflict {
+				return false, kr.load(ctx)
+			}
+			return false, err
+		}
+	}
+	kr.keys = keys
+	return true, nil
+}
+
+// load replaces the keys with the keys stored in the key repo. The stored keys are only decrypted when they changed since they were last loaded.
+func (kr *Keyring) load(ctx context.Context) errors.RichError {
+	keySet, err := kr.keyRepo.GetJWTSigningKeySet(ctx)
+	if err != nil {
+		return err
+	}
+	if keySet.Version != kr.version || kr.keys == nil {
+		keys := make([]*managedKey, 0, len(keySet.Keys))
+		for _, storedKey := range keySet.Keys {
+			keyData, err := utilities.DecryptWithAssociatedData(kr.encryptionKey, storedKey.EncryptedKey, storedKey.KeyID)
+			if err != nil {
+				return err
+			}
+			signingKey, err := ParseSigningKey(storedKey.Algorithm, storedKey.KeyID, []byte(keyData))
+			if err != nil {
+				return err
+			}
+			keys = append(keys, &managedKey{
+				signingKey: signingKey,
+				info: KeyInfo{
+					KeyID:       storedKey.KeyID,
+					Algorithm:   storedKey.Algorithm,
+					State:       KeyState(storedKey.State),
+					CreatedAt:   storedKey.CreatedAt,
+					ActivatedAt: storedKey.ActivatedAt,
+					RetiredAt:   storedKey.RetiredAt,
+				},
+			})
+		}
+		kr.keys = keys
+		kr.version = keySet.Version
+	}
+	kr.loadedAt = kr.now()
+	return nil
+}
+
+// save stores the keys in the key repo if the stored keys have not changed since they were loaded. The private keys are encrypted with their key id as associated data so a stored key cannot be moved to another key id.
+func (kr *Keyring) save(ctx context.Context, keys []*managedKey) errors.RichError {
+	keySet := models.JWTSigningKeySet{
+		Version: kr.version,
+		Keys:    make([]models.JWTSigningKey, 0, len(keys)),
+	}
+	for _, key := range keys {
+		keyData, err := MarshalSigningKey(key.signingKey)
+		if err != nil {
+			return err
+		}
+		encryptedKey, err := utilities.EncryptWithAssociatedData(kr.encryptionKey, string(keyData), key.info.KeyID)
+		if err != nil {
+			return err
+		}
+		keySet.Keys = append(keySet.Keys, models.JWTSigningKey{
+			KeyID:        key.info.KeyID,
+			Algorithm:    key.info.Algorithm,
+			State:        string(key.info.State),
+			EncryptedKey: encryptedKey,
+			CreatedAt:    key.info.CreatedAt,
+			ActivatedAt:  key.info.ActivatedAt,
+			RetiredAt:    key.info.RetiredAt,
+		})
+	}
+	err := kr.keyRepo.SaveJWTSigningKeySet(ctx, &keySet)
+	if err != nil {
+		return err
+	}
+	kr.version = keySet.Version
+	kr.loadedAt = kr.now()
+	return nil
+}
+
+func (kr *Keyring) rotateKeys(keys []*managedKey, now time.Time) ([]*managedKey, bool, errors.RichError) {
+	activated := false
+	for _, key := range keys {
+		switch key.info.State {
+		case KeyStateActive:
+			key.info.State = KeyStateRetired
+			key.info.RetiredAt = now
+		case KeyStatePending:
+			if !activated {
+				key.info.State = KeyStateActive
+				key.info.ActivatedAt = now
+				activated = true
+			}
+		}
+	}
+	if !activated {
+		generatedKey, err := GenerateSigningKey(kr.algorithm, "")
+		if err != nil {
+			return nil, false, err
+		}
+		keys = append(keys, newManagedKey(generatedKey, KeyStateActive, now))
+	}
+	keys = kr.removeExpiredKeys(keys, now)
+	keys, err := kr.addPendingKey(keys, now)
+	return keys, true, err
+}
+
+func (kr *Keyring) addPendingKey(keys []*managedKey, now time.Time) ([]*managedKey, errors.RichError) {
+	pendingKey, err := GenerateSigningKey(kr.algorithm, "")
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, newManagedKey(pendingKey, KeyStatePending, now)), nil
+}
+
+func (kr *Keyring) removeExpiredKeys(keys []*managedKey, now time.Time) []*managedKey {
+	remainingKeys := make([]*managedKey, 0, len(keys))
+	for _, key := range keys {
+		if !kr.isExpired(key, now) {
+			remainingKeys = append(remainingKeys, key)
+		}
+	}
+	return remainingKeys
+}
+
+func (kr *Keyring) isExpired(key *managedKey, now time.Time) bool {
+	return key.info.State == KeyStateRetired && !now.Before(key.info.RetiredAt.Add(kr.maxTokenLifetime+kr.refreshInterval))
+}
@@ -0,0 +1,342 @@
+package jwt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/utilities"
+	"github.com/calvine/richerror/errors"
+)
+
+// testJWTSigningKeyRepo is a key repo for keyring tests. beforeSave is called once before the next save so tests can change the stored keys while a save is in progress.
+type testJWTSigningKeyRepo struct {
+	keySet     models.JWTSigningKeySet
+	beforeSave func()
+}
+
+func (*testJWTSigningKeyRepo) GetName() string {
+	return "testJWTSigningKeyRepo"
+}
+
+func (*testJWTSigningKeyRepo) GetType() string {
+	return "test"
+}
+
+func (r *testJWTSigningKeyRepo) GetJWTSigningKeySet(ctx context.Context) (models.JWTSigningKeySet, errors.RichError) {
+	return models.JWTSigningKeySet{
+		Version: r.keySet.Version,
+		Keys:    append([]models.JWTSigningKey(nil), r.keySet.Keys...),
+	}, nil
+}
+
+func (r *testJWTSigningKeyRepo) SaveJWTSigningKeySet(ctx context.Context, keySet *models.JWTSigningKeySet) errors.RichError {
+	if r.beforeSave != nil {
+		beforeSave := r.beforeSave
+		r.beforeSave = nil
+		beforeSave()
+	}
+	if r.keySet.Version != keySet.Version {
+		return coreerrors.NewJWTSigningKeySetConflictError(keySet.Version, true)
+	}
+	keySet.Version++
+	r.keySet = models.JWTSigningKeySet{
+		Version: keySet.Version,
+		Keys:    append([]models.JWTSigningKey(nil), keySet.Keys...),
+	}
+	return nil
+}
+
+func newTestStoredKeyring(t *testing.T, keyRepo *testJWTSigningKeyRepo, encryptionKey []byte, activeKey SigningKey) *Keyring {
+	options := KeyringOptions{
+		Algorithm:        AlgorithmES256,
+		MaxTokenLifetime: time.Hour,
+		KeyRepo:          keyRepo,
+		EncryptionKey:    encryptionKey,
+	}
+	keyring, err := NewKeyring(context.TODO(), options, activeKey)
+	if err != nil {
+		t.Fatalf("failed to create keyring: %s", err.Error())
+	}
+	return keyring
+}
+
+func newTestEncryptionKey(t *testing.T) []byte {
+	encryptionKey, err := utilities.NewEncryptionKey()
+	if err != nil {
+		t.Fatalf("failed to generate encryption key: %s", err.Error())
+	}
+	return encryptionKey
+}
+
+func getKeyStates(keyring *Keyring) map[string]KeyState {
+	keyStates := make(map[string]KeyState)
+	for _, keyInfo := range keyring.GetKeyInfo() {
+		keyStates[keyInfo.KeyID] = keyInfo.State
+	}
+	return keyStates
+}
+
+func getKeyIDByState(t *testing.T, keyring *Keyring, state KeyState) string {
+	for _, keyInfo := range keyring.GetKeyInfo() {
+		if keyInfo.State == state {
+			return keyInfo.KeyID
+		}
+	}
+	t.Fatalf("no key found with state %s", state)
+	return ""
+}
+
+func TestKeyringLifecycle(t *testing.T) {
+	now := time.Now()
+	activeKey := generateTestSigningKey(t, AlgorithmES256, "initial_key")
+	keyring, err := NewKeyring(context.TODO(), KeyringOptions{MaxTokenLifetime: time.Hour}, activeKey)
+	if err != nil {
+		t.Fatalf("failed to create keyring: %s", err.Error())
+	}
+	keyring.now = func() time.Time { return now }
+
+	keyStates := getKeyStates(keyring)
+	if len(keyStates) != 2 {
+		t.Fatalf("expected an active and pending key: got %v", keyStates)
+	}
+	if keyStates["initial_key"] != KeyStateActive {
+		t.Errorf("initial key should be active: got %s", keyStates["initial_key"])
+	}
+	pendingKeyID := getKeyIDByState(t, keyring, KeyStatePending)
+	if len(keyring.GetJSONWebKeySet().Keys) != 2 {
+		t.Error("active and pending keys should be published")
+	}
+	token, err := Sign(activeKey, StandardClaims{Subject: "user_id", ExpiresAt: now.Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("failed to sign jwt: %s", err.Error())
+	}
+
+	err = keyring.Rotate(context.TODO())
+	if err != nil {
+		t.Fatalf("failed to rotate keyring: %s", err.Error())
+	}
+	keyStates = getKeyStates(keyring)
+	if keyStates["initial_key"] != KeyStateRetired {
+		t.Errorf("initial key should be retired: got %s", keyStates["initial_key"])
+	}
+	if keyStates[pendingKeyID] != KeyStateActive {
+		t.Errorf("pending key should be active: got %s", keyStates[pendingKeyID])
+	}
+	signer, err := keyring.GetSigner()
+	if err != nil {
+		t.Fatalf("failed to get signer: %s", err.Error())
+	}
+	if signer.KeyID() != pendingKeyID {
+		t.Errorf("signer key id does not match expected value: got %s - expected %s", signer.KeyID(), pendingKeyID)
+	}
+	var claims StandardClaims
+	_, err = Verify(token, keyring, &claims)
+	if err != nil {
+		t.Errorf("token signed by retired key should still verify: %s", err.Error())
+	}
+	if len(keyring.GetJSONWebKeySet().Keys) != 3 {
+		t.Error("retired, active and pending keys should be published")
+	}
+
+	// once the max token lifetime has passed the retired key is no longer needed.
+	now = now.Add(time.Hour)
+	if len(keyring.GetJSONWebKeySet().Keys) != 2 {
+		t.Error("expired retired key should not be published")
+	}
+	_, err = keyring.GetVerifier("initial_key")
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeNoSigningKeyFound {
+		t.Error("expired retired key should not be used for verification")
+	}
+	err = keyring.RemoveExpiredKeys(context.TODO())
+	if err != nil {
+		t.Fatalf("failed to remove expired keys: %s", err.Error())
+	}
+	if _, ok := getKeyStates(keyring)["initial_key"]; ok {
+		t.Error("expired retired key should be removed")
+	}
+}
+
+func TestKeyringPendingKeyVerification(t *testing.T) {
+	keyring, err := NewKeyring(context.TODO(), KeyringOptions{Algorithm: AlgorithmES256, MaxTokenLifetime: time.Hour}, nil)
+	if err != nil {
+		t.Fatalf("failed to create keyring: %s", err.Error())
+	}
+	pendingKeyID := getKeyIDByState(t, keyring, KeyStatePending)
+	var pendingKey SigningKey
+	for _, key := range keyring.keys {
+		if key.info.KeyID == pendingKeyID {
+			pendingKey = key.signingKey
+		}
+	}
+	token, err := Sign(pendingKey, StandardClaims{Subject: "user_id", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("failed to sign jwt: %s", err.Error())
+	}
+	_, err = keyring.GetVerifier(pendingKeyID)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeNoSigningKeyFound {
+		t.Error("pending key should not be used for verification")
+	}
+	var claims StandardClaims
+	_, err = Verify(token, keyring, &claims)
+	if err == nil {
+		t.Error("token signed by pending key should not verify")
+	}
+
+	err = keyring.Rotate(context.TODO())
+	if err != nil {
+		t.Fatalf("failed to rotate keyring: %s", err.Error())
+	}
+	_, err = Verify(token, keyring, &claims)
+	if err != nil {
+		t.Errorf("token should verify once its key is active: %s", err.Error())
+	}
+}
+
+func TestNewKeyringFailures(t *testing.T) {
+	activeKey := generateTestSigningKey(t, AlgorithmES256, "")
+	_, err := NewKeyring(context.TODO(), KeyringOptions{Algorithm: AlgorithmRS256}, activeKey)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeInvalidSigningKey {
+		t.Error("expected error when active key algorithm does not match keyring algorithm")
+	}
+	_, err = NewKeyring(context.TODO(), KeyringOptions{Algorithm: "PS256"}, nil)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeUnsupportedJWTAlgorithm {
+		t.Error("expected error for unsupported algorithm")
+	}
+}
+
+func TestKeyringPersistence(t *testing.T) {
+	keyRepo := &testJWTSigningKeyRepo{}
+	encryptionKey := newTestEncryptionKey(t)
+	initialKey := generateTestSigningKey(t, AlgorithmES256, "initial_key")
+	keyring := newTestStoredKeyring(t, keyRepo, encryptionKey, initialKey)
+	token, err := Sign(initialKey, StandardClaims{Subject: "user_id", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("failed to sign jwt: %s", err.Error())
+	}
+	err = keyring.Rotate(context.TODO())
+	if err != nil {
+		t.Fatalf("failed to rotate keyring: %s", err.Error())
+	}
+	for _, storedKey := range keyRepo.keySet.Keys {
+		if storedKey.EncryptedKey == "" {
+			t.Errorf("stored key %s has no encrypted key", storedKey.KeyID)
+		}
+	}
+
+	// a restarted instance loads the stored keys and ignores the key it is given.
+	restartedKeyring := newTestStoredKeyring(t, keyRepo, encryptionKey, generateTestSigningKey(t, AlgorithmES256, "ignored_key"))
+	if len(restartedKeyring.GetKeyInfo()) != len(keyring.GetKeyInfo()) {
+		t.Fatalf("restarted keyring should have the stored keys: got %v - expected %v", restartedKeyring.GetKeyInfo(), keyring.GetKeyInfo())
+	}
+	for i, keyInfo := range restartedKeyring.GetKeyInfo() {
+		expectedKeyInfo := keyring.GetKeyInfo()[i]
+		if keyInfo.KeyID != expectedKeyInfo.KeyID || keyInfo.State != expectedKeyInfo.State || !keyInfo.RetiredAt.Equal(expectedKeyInfo.RetiredAt) {
+			t.Errorf("restarted keyring key does not match stored key: got %v - expected %v", keyInfo, expectedKeyInfo)
+		}
+	}
+	var claims StandardClaims
+	_, err = Verify(token, restartedKeyring, &claims)
+	if err != nil {
+		t.Errorf("token signed before the restart should verify with the retired key: %s", err.Error())
+	}
+	signer, _ := restartedKeyring.GetSigner()
+	expectedSigner, _ := keyring.GetSigner()
+	if signer.KeyID() != expectedSigner.KeyID() {
+		t.Errorf("restarted keyring should sign with the stored active key: got %s - expected %s", signer.KeyID(), expectedSigner.KeyID())
+	}
+
+	_, err = NewKeyring(context.TODO(), KeyringOptions{KeyRepo: keyRepo, EncryptionKey: newTestEncryptionKey(t)}, nil)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeDecryptionFailed {
+		t.Errorf("expected loading stored keys with a different encryption key to fail: got %v", err)
+	}
+}
+
+func TestKeyringSharedRotation(t *testing.T) {
+	keyRepo := &testJWTSigningKeyRepo{}
+	encryptionKey := newTestEncryptionKey(t)
+	keyring := newTestStoredKeyring(t, keyRepo, encryptionKey, nil)
+	otherKeyring := newTestStoredKeyring(t, keyRepo, encryptionKey, nil)
+	now := time.Now()
+	keyring.now = func() time.Time { return now }
+	otherKeyring.now = func() time.Time { return now }
+
+	rotated, err := keyring.RotateIfDue(context.TODO(), time.Hour)
+	if err != nil || rotated {
+		t.Fatalf("keys should not be rotated before the rotation interval: rotated %t - %v", rotated, err)
+	}
+	now = now.Add(time.Hour)
+	rotated, err = keyring.RotateIfDue(context.TODO(), time.Hour)
+	if err != nil || !rotated {
+		t.Fatalf("keys should be rotated once the rotation interval has passed: rotated %t - %v", rotated, err)
+	}
+	// the other instance sees the rotation and does not rotate again.
+	rotated, err = otherKeyring.RotateIfDue(context.TODO(), time.Hour)
+	if err != nil || rotated {
+		t.Fatalf("keys rotated by another instance should not be rotated again: rotated %t - %v", rotated, err)
+	}
+	signer, _ := keyring.GetSigner()
+	otherSigner, _ := otherKeyring.GetSigner()
+	if signer.KeyID() != otherSigner.KeyID() {
+		t.Errorf("instances should agree on the active key: got %s - expected %s", otherSigner.KeyID(), signer.KeyID())
+	}
+
+	// when another instance rotates while a rotation is being saved the compare and swap fails and its keys are used instead.
+	keyRepo.beforeSave = func() {
+		err := keyring.Rotate(context.TODO())
+		if err != nil {
+			t.Fatalf("failed to rotate keyring: %s", err.Error())
+		}
+	}
+	err = otherKeyring.Rotate(context.TODO())
+	if err != nil {
+		t.Fatalf("failed to rotate keyring: %s", err.Error())
+	}
+	signer, _ = keyring.GetSigner()
+	otherSigner, _ = otherKeyring.GetSigner()
+	if signer.KeyID() != otherSigner.KeyID() {
+		t.Errorf("instances should agree on the active key after concurrent rotations: got %s - expected %s", otherSigner.KeyID(), signer.KeyID())
+	}
+	numRetired := 0
+	for _, keyInfo := range otherKeyring.GetKeyInfo() {
+		if keyInfo.State == KeyStateRetired {
+			numRetired++
+		}
+	}
+	if numRetired != 2 {
+		t.Errorf("concurrent rotations should only rotate once: got %d retired keys - expected %d", numRetired, 2)
+	}
+}
+
+func TestKeyringRefreshesPendingKey(t *testing.T) {
+	now := time.Now()
+	keyRepo := &testJWTSigningKeyRepo{}
+	encryptionKey := newTestEncryptionKey(t)
+	keyring := newTestStoredKeyring(t, keyRepo, encryptionKey, nil)
+	otherKeyring := newTestStoredKeyring(t, keyRepo, encryptionKey, nil)
+	keyring.now = func() time.Time { return now }
+	otherKeyring.now = func() time.Time { return now }
+	err := keyring.Rotate(context.TODO())
+	if err != nil {
+		t.Fatalf("failed to rotate keyring: %s", err.Error())
+	}
+	signer, _ := keyring.GetSigner()
+	token, err := Sign(signer, StandardClaims{Subject: "user_id", ExpiresAt: now.Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("failed to sign jwt: %s", err.Error())
+	}
+	// the other instance still has the new active key as pending, and only reloads once the pending key refresh interval has passed.
+	otherKeyring.loadedAt = now
+	var claims StandardClaims
+	_, err = Verify(token, otherKeyring, &claims)
+	if err == nil {
+		t.Error("pending key should not be reloaded within the pending key refresh interval")
+	}
+	now = now.Add(pendingKeyRefreshInterval)
+	_, err = Verify(token, otherKeyring, &claims)
+	if err != nil {
+		t.Errorf("token signed by a key activated by another instance should verify after reloading: %s", err.Error())
+	}
+}
@@ -0,0 +1,183 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/richerror/errors"
+)
+
+const es256CoordinateSize = 32
+
+// Signer signs JWTs.
+type Signer interface {
+	// Algorithm returns the JWS algorithm name for the key like RS256.
+	Algorithm() string
+	// KeyID returns the kid used to identify the key in JWT headers.
+	KeyID() string
+	// Sign returns the signature for the JWS signing input.
+	Sign(signingInput []byte) ([]byte, errors.RichError)
+}
+
+// Verifier verifies the signatures of JWTs.
+type Verifier interface {
+	// Algorithm returns the JWS algorithm name for the key like RS256.
+	Algorithm() string
+	// KeyID returns the kid used to identify the key in JWT headers.
+	KeyID() string
+	// Verify returns an error if the signature is not valid for the JWS signing input.
+	Verify(signingInput, signature []byte) errors.RichError
+}
+
+// SigningKey is a key that can both sign and verify JWTs.
+type SigningKey interface {
+	Signer
+	Verifier
+	// PublicKey returns the public key for asymmetric keys, and nil for symmetric keys which must never be published.
+	PublicKey() crypto.PublicKey
+}
+
+type rsaSigningKey struct {
+	keyID      string
+	privateKey *rsa.PrivateKey
+}
+
+func (rsaSigningKey) Algorithm() string {
+	return AlgorithmRS256
+}
+
+func (k rsaSigningKey) KeyID() string {
+	return k.keyID
+}
+
+func (k rsaSigningKey) PublicKey() crypto.PublicKey {
+	return &k.privateKey.PublicKey
+}
+
+func (k rsaSigningKey) Sign(signingInput []byte) ([]byte, errors.RichError) {
+	hash := sha256.Sum256(signingInput)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, k.privateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return nil, coreerrors.NewJWTSigningFailedError(k.keyID, err, true)
+	}
+	return signature, nil
+}
+
+func (k rsaSigningKey) Verify(signingInput, signature []byte) errors.RichError {
+	hash := sha256.Sum256(signingInput)
+	err := rsa.VerifyPKCS1v15(&k.privateKey.PublicKey, crypto.SHA256, hash[:], signature)
+	if err != nil {
+		return coreerrors.NewInvalidJWTSignatureError(k.keyID, true)
+	}
+	return nil
+}
+
+type ecdsaSigningKey struct {
+	keyID      string
+	privateKey *ecdsa.PrivateKey
+}
+
+func (ecdsaSigningKey) Algorithm() string {
+	return AlgorithmES256
+}
+
+func (k ecdsaSigningKey) KeyID() string {
+	return k.keyID
+}
+
+func (k ecdsaSigningKey) PublicKey() crypto.PublicKey {
+	return &k.privateKey.PublicKey
+}
+
+// Sign produces the fixed length R || S signature required by https://datatracker.ietf.org/doc/html/rfc7518#section-3.4 rather than the ASN.1 form.
+func (k ecdsaSigningKey) Sign(signingInput []byte) ([]byte, errors.RichError) {
+	hash := sha256.Sum256(signingInput)
+	r, s, err := ecdsa.Sign(rand.Reader, k.privateKey, hash[:])
+	if err != nil {
+		return nil, coreerrors.NewJWTSigningFailedError(k.keyID, err, true)
+	}
+	signature := make([]byte, es256CoordinateSize*2)
+	r.FillBytes(signature[:es256CoordinateSize])
+	s.FillBytes(signature[es256CoordinateSize:])
+	return signature, nil
+}
+
+func (k ecdsaSigningKey) Verify(signingInput, signature []byte) errors.RichError {
+	if len(signature) != es256CoordinateSize*2 {
+		return coreerrors.NewInvalidJWTSignatureError(k.keyID, true)
+	}
+	hash := sha256.Sum256(signingInput)
+	r := new(big.Int).SetBytes(signature[:es256CoordinateSize])
+	s := new(big.Int).SetBytes(signature[es256CoordinateSize:])
+	if !ecdsa.Verify(&k.privateKey.PublicKey, hash[:], r, s) {
+		return coreerrors.NewInvalidJWTSignatureError(k.keyID, true)
+	}
+	return nil
+}
+
+type ed25519SigningKey struct {
+	keyID      string
+	privateKey ed25519.PrivateKey
+}
+
+func (ed25519SigningKey) Algorithm() string {
+	return AlgorithmEdDSA
+}
+
+func (k ed25519SigningKey) KeyID() string {
+	return k.keyID
+}
+
+func (k ed25519SigningKey) PublicKey() crypto.PublicKey {
+	return k.privateKey.Public()
+}
+
+func (k ed25519SigningKey) Sign(signingInput []byte) ([]byte, errors.RichError) {
+	return ed25519.Sign(k.privateKey, signingInput), nil
+}
+
+func (k ed25519SigningKey) Verify(signingInput, signature []byte) errors.RichError {
+	publicKey := k.privateKey.Public().(ed25519.PublicKey)
+	if !ed25519.Verify(publicKey, signingInput, signature) {
+		return coreerrors.NewInvalidJWTSignatureError(k.keyID, true)
+	}
+	return nil
+}
+
+type hmacSigningKey struct {
+	keyID  string
+	secret []byte
+}
+
+func (hmacSigningKey) Algorithm() string {
+	return AlgorithmHS256
+}
+
+func (k hmacSigningKey) KeyID() string {
+	return k.keyID
+}
+
+func (hmacSigningKey) PublicKey() crypto.PublicKey {
+	return nil
+}
+
+func (k hmacSigningKey) Sign(signingInput []byte) ([]byte, errors.RichError) {
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(signingInput)
+	return mac.Sum(nil), nil
+}
+
+func (k hmacSigningKey) Verify(signingInput, signature []byte) errors.RichError {
+	expectedSignature, _ := k.Sign(signingInput)
+	if !hmac.Equal(expectedSignature, signature) {
+		return coreerrors.NewInvalidJWTSignatureError(k.keyID, true)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package models
+
+// AccessTokenResponse is the successful response from the token endpoint described in https://datatracker.ietf.org/doc/html/rfc6749#section-5.1
+type AccessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the lifetime of the access token in seconds.
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	// IDToken is the OIDC id token which is only issued when the openid scope was granted.
+	IDToken string `json:"id_token,omitempty"`
+}
@@ -0,0 +1,95 @@
+package aggregate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/calvine/goauth/core"
+	"github.com/calvine/goauth/core/models"
+)
+
+// oidcBirthdateFormat is the YYYY-MM-DD format required for the birthdate claim.
+const oidcBirthdateFormat = "2006-01-02"
+
+// GetUserClaims returns the standard OIDC claims for the user that are granted by the given scopes.
+// The email and phone claims come from the users primary contact of each type, and are only marked verified when the contact is confirmed. The address claim comes from the users primary address.
+func (fu FullUser) GetUserClaims(scopes []string) models.UserClaims {
+	var userClaims models.UserClaims
+	for _, scope := range scopes {
+		switch scope {
+		case core.OIDC_SCOPE_PROFILE:
+			fu.addProfileClaims(&userClaims)
+		case core.OIDC_SCOPE_EMAIL:
+			if contact, ok := fu.getPrimaryContact(core.CONTACT_TYPE_EMAIL); ok {
+				emailVerified := contact.IsConfirmed()
+				userClaims.Email = contact.RawPrincipal
+				userClaims.EmailVerified = &emailVerified
+			}
+		case core.OIDC_SCOPE_PHONE:
+			if contact, ok := fu.getPrimaryContact(core.CONTACT_TYPE_MOBILE); ok {
+				phoneNumberVerified := contact.IsConfirmed()
+				userClaims.PhoneNumber = contact.RawPrincipal
+				userClaims.PhoneNumberVerified = &phoneNumberVerified
+			}
+		case core.OIDC_SCOPE_ADDRESS:
+			if address, ok := fu.getPrimaryAddress(); ok {
+				userClaims.Address = newAddressClaim(address)
+			}
+		}
+	}
+	return userClaims
+}
+
+func (fu FullUser) addProfileClaims(userClaims *models.UserClaims) {
+	nameParts := make([]string, 0, 3)
+	if fu.Profile.FirstName.HasValue {
+		userClaims.GivenName = fu.Profile.FirstName.Value
+		nameParts = append(nameParts, fu.Profile.FirstName.Value)
+	}
+	if fu.Profile.MiddleName.HasValue {
+		userClaims.MiddleName = fu.Profile.MiddleName.Value
+		nameParts = append(nameParts, fu.Profile.MiddleName.Value)
+	}
+	if fu.Profile.LastName.HasValue {
+		userClaims.FamilyName = fu.Profile.LastName.Value
+		nameParts = append(nameParts, fu.Profile.LastName.Value)
+	}
+	userClaims.Name = strings.Join(nameParts, " ")
+	if fu.Profile.DateOfBirth.HasValue {
+		userClaims.Birthdate = fu.Profile.DateOfBirth.Value.Format(oidcBirthdateFormat)
+	}
+}
+
+func (fu FullUser) getPrimaryContact(contactType string) (models.Contact, bool) {
+	for _, contact := range fu.Contacts {
+		if contact.IsPrimary && contact.Type == contactType {
+			return contact, true
+		}
+	}
+	return models.Contact{}, false
+}
+
+func (fu FullUser) getPrimaryAddress() (models.Address, bool) {
+	for _, address := range fu.Addresses {
+		if address.IsPrimary {
+			return address, true
+		}
+	}
+	return models.Address{}, false
+}
+
+// newAddressClaim maps an address to the address claim. Multiple street address lines are separated by a new line as required by the spec.
+func newAddressClaim(address models.Address) *models.AddressClaim {
+	streetAddressLines := []string{address.Line1}
+	if address.Line2.HasValue {
+		streetAddressLines = append(streetAddressLines, address.Line2.Value)
+	}
+	streetAddress := strings.Join(streetAddressLines, "\n")
+	return &models.AddressClaim{
+		Formatted:     fmt.Sprintf("%s\n%s, %s %s", streetAddress, address.City, address.State, address.PostalCode),
+		StreetAddress: streetAddress,
+		Locality:      address.City,
+		Region:        address.State,
+		PostalCode:    address.PostalCode,
+	}
+}
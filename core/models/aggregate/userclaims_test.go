@@ -0,0 +1,80 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/calvine/goauth/core"
+	"github.com/calvine/goauth/core/models"
+)
+
+func TestGetUserClaims(t *testing.T) {
+	profile := models.NewProfile("user_id", "Jane", "Q", "Public", time.Date(1990, time.March, 4, 0, 0, 0, 0, time.UTC))
+	primaryEmail := models.NewContact("user_id", "", "Jane@Email.com", core.CONTACT_TYPE_EMAIL, true)
+	primaryEmail.ConfirmedDate.Set(time.Now().Add(-time.Minute))
+	secondaryEmail := models.NewContact("user_id", "", "other@email.com", core.CONTACT_TYPE_EMAIL, false)
+	secondaryEmail.ConfirmedDate.Set(time.Now().Add(-time.Minute))
+	primaryMobile := models.NewContact("user_id", "", "555-555-5555", core.CONTACT_TYPE_MOBILE, true)
+	secondaryAddress := models.NewAddress("user_id", "work", "1 Work Way", "", "Worktown", "NY", "10001", false)
+	primaryAddress := models.NewAddress("user_id", "home", "123 Main St", "Apt 4", "Springfield", "IL", "62701", true)
+	fullUser := NewFullUserWithData(models.User{ID: "user_id"}, []models.Address{secondaryAddress, primaryAddress}, []models.Contact{secondaryEmail, primaryEmail, primaryMobile}, &profile)
+
+	t.Run("profile scope", func(t *testing.T) {
+		userClaims := fullUser.GetUserClaims([]string{core.OIDC_SCOPE_PROFILE})
+		if userClaims.Name != "Jane Q Public" {
+			t.Errorf("name claim not expected value: got %s - expected %s", userClaims.Name, "Jane Q Public")
+		}
+		if userClaims.GivenName != "Jane" || userClaims.MiddleName != "Q" || userClaims.FamilyName != "Public" {
+			t.Errorf("name part claims not expected values: %v", userClaims)
+		}
+		if userClaims.Birthdate != "1990-03-04" {
+			t.Errorf("birthdate claim not expected value: got %s - expected %s", userClaims.Birthdate, "1990-03-04")
+		}
+		if userClaims.Email != "" || userClaims.PhoneNumber != "" || userClaims.Address != nil {
+			t.Errorf("claims for scopes that were not granted should be empty: %v", userClaims)
+		}
+	})
+
+	t.Run("email and phone scopes", func(t *testing.T) {
+		userClaims := fullUser.GetUserClaims([]string{core.OIDC_SCOPE_EMAIL, core.OIDC_SCOPE_PHONE})
+		if userClaims.Email != primaryEmail.RawPrincipal {
+			t.Errorf("email claim not expected value: got %s - expected %s", userClaims.Email, primaryEmail.RawPrincipal)
+		}
+		if userClaims.EmailVerified == nil || !*userClaims.EmailVerified {
+			t.Error("email_verified claim should be true for a confirmed primary email")
+		}
+		if userClaims.PhoneNumber != primaryMobile.RawPrincipal {
+			t.Errorf("phone_number claim not expected value: got %s - expected %s", userClaims.PhoneNumber, primaryMobile.RawPrincipal)
+		}
+		if userClaims.PhoneNumberVerified == nil || *userClaims.PhoneNumberVerified {
+			t.Error("phone_number_verified claim should be false for an unconfirmed primary mobile")
+		}
+		if userClaims.Name != "" {
+			t.Errorf("claims for scopes that were not granted should be empty: %v", userClaims)
+		}
+	})
+
+	t.Run("address scope", func(t *testing.T) {
+		userClaims := fullUser.GetUserClaims([]string{core.OIDC_SCOPE_ADDRESS})
+		if userClaims.Address == nil {
+			t.Fatal("address claim should be populated from the primary address")
+		}
+		expectedAddress := models.AddressClaim{
+			Formatted:     "123 Main St\nApt 4\nSpringfield, IL 62701",
+			StreetAddress: "123 Main St\nApt 4",
+			Locality:      "Springfield",
+			Region:        "IL",
+			PostalCode:    "62701",
+		}
+		if *userClaims.Address != expectedAddress {
+			t.Errorf("address claim not expected value: got %v - expected %v", *userClaims.Address, expectedAddress)
+		}
+	})
+
+	t.Run("no primary contact", func(t *testing.T) {
+		userClaims := NewFullUser(models.User{ID: "user_id"}).GetUserClaims([]string{core.OIDC_SCOPE_EMAIL})
+		if userClaims.Email != "" || userClaims.EmailVerified != nil {
+			t.Errorf("email claims should be omitted when there is no primary email: %v", userClaims)
+		}
+	})
+}
@@ -1,42 +1,49 @@
 package models
 
 import (
+	"fmt"
+
 	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/nullable"
 	"github.com/calvine/goauth/core/utilities"
 	"github.com/calvine/richerror/errors"
 )
 
 type App struct {
-	ID               string    `bson:"-"`
-	OwnerID          string    `bson:"-"`
-	Name             string    `bson:"name"`
-	ClientID         string    `bson:"clientId"`
-	ClientSecretHash string    `bson:"clientSecret"`
-	CallbackURI      string    `bson:"callbackUri"`
-	IsDisabled       bool      `bson:"isDisabled"`
-	LogoURI          string    `bson:"logoUri"`
-	AuditData        auditable `bson:",inline"`
+	ID       string `bson:"-"`
+	OwnerID  string `bson:"-"`
+	Name     string `bson:"name"`
+	ClientID string `bson:"clientId"`
+	// ClientSecrets are the secrets the app can authenticate with. More than one can be active while a client secret is being rotated.
+	ClientSecrets []ClientSecret `bson:"clientSecrets"`
+	// RedirectURIs are the redirect uris registered for the app. A redirect uri provided in an authorization request must exactly match one of them, except for the port of loopback redirect uris.
+	RedirectURIs []string `bson:"redirectUris"`
+	// AllowedOrigins are the web origins that browser based clients of the app are served from.
+	AllowedOrigins []string `bson:"allowedOrigins"`
+	IsDisabled     bool     `bson:"isDisabled"`
+	// Policy controls the grant types, response types and token lifetimes for the app.
+	Policy    AppPolicy `bson:"policy"`
+	LogoURI   string    `bson:"logoUri"`
+	AuditData auditable `bson:",inline"`
 }
 
-func NewApp(ownerID, name, callbackURI, logoURI string) (App, string, errors.RichError) {
+func NewApp(ownerID, name string, redirectURIs []string, logoURI string) (App, string, errors.RichError) {
 	clientID, err := utilities.NewVariableLengthTokenString(1)
 	if err != nil {
 		return App{}, "", err
 	}
-	clientSecret, err := utilities.NewVariableLengthTokenString(3)
+	// We save the client secret hash so that is not saved in plain text in the database
+	initialClientSecret, clientSecret, err := NewClientSecret(nullable.NullableTime{})
 	if err != nil {
 		return App{}, "", err
 	}
-	// We save the client secret hash so that is not saved in plain text in the database
-	// Not using bcrypt because its slow and this needs to be checked per requesat in some cases
-	clientSecretHash := utilities.SHA512(clientSecret)
 	return App{
-		ClientID:         clientID,
-		ClientSecretHash: clientSecretHash,
-		OwnerID:          ownerID,
-		Name:             name,
-		CallbackURI:      callbackURI,
-		LogoURI:          logoURI,
+		ClientID:      clientID,
+		ClientSecrets: []ClientSecret{initialClientSecret},
+		OwnerID:       ownerID,
+		Name:          name,
+		RedirectURIs:  redirectURIs,
+		LogoURI:       logoURI,
 	}, clientSecret, nil
 }
 
@@ -48,21 +55,64 @@ func ValidateApp(includeID bool, app App) errors.RichError {
 	if app.ClientID == "" {
 		fields["ClientID"] = "app ClientID cannot be empty"
 	}
-	if app.ClientSecretHash == "" {
-		fields["ClientSecretHash"] = "app ClientSecretHash cannot be empty"
+	if len(app.ClientSecrets) == 0 {
+		fields["ClientSecrets"] = "app ClientSecrets cannot be empty"
+	}
+	for i, clientSecret := range app.ClientSecrets {
+		if clientSecret.ID == "" {
+			fields[fmt.Sprintf("ClientSecrets[%d].ID", i)] = "client secret ID cannot be empty"
+		}
+		if clientSecret.Hash == "" {
+			fields[fmt.Sprintf("ClientSecrets[%d].Hash", i)] = "client secret Hash cannot be empty"
+		}
 	}
 	if app.Name == "" {
 		fields["Name"] = "app Name cannot be empty"
 	}
-	if app.CallbackURI == "" {
-		fields["CallbackURI"] = "app CallbackURI cannot be empty"
+	if len(app.RedirectURIs) == 0 {
+		fields["RedirectURIs"] = "app RedirectURIs cannot be empty"
+	}
+	for i, redirectURI := range app.RedirectURIs {
+		if reason := validateRedirectURI(redirectURI); reason != "" {
+			fields[fmt.Sprintf("RedirectURIs[%d]", i)] = reason
+		}
+	}
+	for i, origin := range app.AllowedOrigins {
+		if reason := validateOrigin(origin); reason != "" {
+			fields[fmt.Sprintf("AllowedOrigins[%d]", i)] = reason
+		}
 	}
 	if app.LogoURI == "" {
 		fields["LogoURI"] = "app LogoURI cannot be empty"
 	}
+	app.Policy.validate(fields)
 
 	if len(fields) > 0 {
 		return coreerrors.NewInvalidAppCreationError(fields, false)
 	}
 	return nil
 }
+
+// MatchRedirectURI returns true if the redirect uri is registered for the app.
+// Per https://datatracker.ietf.org/doc/html/rfc8252#section-7.3 any port is allowed for loopback ip redirect uris, because native apps listen on an ephemeral port.
+func (app App) MatchRedirectURI(redirectURI string) bool {
+	for _, registeredURI := range app.RedirectURIs {
+		if registeredURI == redirectURI {
+			return true
+		}
+		if isLoopbackRedirectURI(registeredURI) && matchLoopbackRedirectURI(registeredURI, redirectURI) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAllowedOrigin returns true if the web origin is allowed for the app.
+func (app App) IsAllowedOrigin(origin string) bool {
+	for _, allowedOrigin := range app.AllowedOrigins {
+		if allowedOrigin == origin {
+			return true
+		}
+	}
+	return false
+}
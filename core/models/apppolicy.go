@@ -0,0 +1,102 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/calvine/goauth/core"
+)
+
+// SupportedGrantTypes are the grant types the server supports at the token endpoint.
+var SupportedGrantTypes = []string{
+	core.OAUTH_GRANT_TYPE_AUTHORIZATION_CODE,
+	core.OAUTH_GRANT_TYPE_REFRESH_TOKEN,
+	core.OAUTH_GRANT_TYPE_CLIENT_CREDENTIALS,
+}
+
+// SupportedResponseTypes are the response types the server supports at the authorization endpoint.
+var SupportedResponseTypes = []string{
+	core.OAUTH_RESPONSE_TYPE_CODE,
+}
+
+// AppPolicy is the per app configuration of what the app is allowed to do and how long the tokens issued to it live.
+// The zero value allows everything the server supports and uses the server defaults, so apps created before a setting existed keep working.
+type AppPolicy struct {
+	// AllowedGrantTypes are the grant types the app may use. When empty every supported grant type is allowed.
+	AllowedGrantTypes []string `bson:"allowedGrantTypes"`
+	// AllowedResponseTypes are the response types the app may use. When empty every supported response type is allowed.
+	AllowedResponseTypes []string `bson:"allowedResponseTypes"`
+	// AuthorizationCodeDuration is the lifetime of authorization codes issued for the app. When zero the server default is used.
+	AuthorizationCodeDuration time.Duration `bson:"authorizationCodeDuration"`
+	// AccessTokenDuration is the lifetime of access tokens issued to the app. When zero the server default is used.
+	AccessTokenDuration time.Duration `bson:"accessTokenDuration"`
+	// RefreshTokenDuration is the lifetime of refresh tokens issued to the app. When zero the server default is used.
+	RefreshTokenDuration time.Duration `bson:"refreshTokenDuration"`
+	// IDTokenDuration is the lifetime of id tokens issued to the app. When zero the access token lifetime is used.
+	IDTokenDuration time.Duration `bson:"idTokenDuration"`
+	// AccessTokenFormat is either jwt or opaque. Opaque access tokens can only be validated with the introspection endpoint. When empty access tokens are JWTs.
+	AccessTokenFormat string `bson:"accessTokenFormat"`
+	// RequirePKCE makes a PKCE code challenge mandatory for authorization requests for the app. This should be set for public clients like SPAs and mobile apps.
+	RequirePKCE bool `bson:"requirePkce"`
+}
+
+// AllowsGrantType returns true if the app may use the grant type.
+func (policy AppPolicy) AllowsGrantType(grantType string) bool {
+	if len(policy.AllowedGrantTypes) == 0 {
+		return containsString(SupportedGrantTypes, grantType)
+	}
+	return containsString(policy.AllowedGrantTypes, grantType)
+}
+
+// AllowsResponseType returns true if the app may use the response type.
+func (policy AppPolicy) AllowsResponseType(responseType string) bool {
+	if len(policy.AllowedResponseTypes) == 0 {
+		return containsString(SupportedResponseTypes, responseType)
+	}
+	return containsString(policy.AllowedResponseTypes, responseType)
+}
+
+// UsesOpaqueAccessTokens returns true if access tokens issued to the app are opaque rather than JWTs.
+func (policy AppPolicy) UsesOpaqueAccessTokens() bool {
+	return policy.AccessTokenFormat == core.OAUTH_ACCESS_TOKEN_FORMAT_OPAQUE
+}
+
+// validate adds the reason each invalid policy setting is not valid to fields.
+func (policy AppPolicy) validate(fields map[string]interface{}) {
+	for i, grantType := range policy.AllowedGrantTypes {
+		if !containsString(SupportedGrantTypes, grantType) {
+			fields[fmt.Sprintf("Policy.AllowedGrantTypes[%d]", i)] = fmt.Sprintf("grant type is not supported: %s", grantType)
+		}
+	}
+	for i, responseType := range policy.AllowedResponseTypes {
+		if !containsString(SupportedResponseTypes, responseType) {
+			fields[fmt.Sprintf("Policy.AllowedResponseTypes[%d]", i)] = fmt.Sprintf("response type is not supported: %s", responseType)
+		}
+	}
+	if policy.AuthorizationCodeDuration < 0 {
+		fields["Policy.AuthorizationCodeDuration"] = "authorization code duration cannot be negative"
+	}
+	if policy.AccessTokenDuration < 0 {
+		fields["Policy.AccessTokenDuration"] = "access token duration cannot be negative"
+	}
+	if policy.RefreshTokenDuration < 0 {
+		fields["Policy.RefreshTokenDuration"] = "refresh token duration cannot be negative"
+	}
+	if policy.IDTokenDuration < 0 {
+		fields["Policy.IDTokenDuration"] = "id token duration cannot be negative"
+	}
+	switch policy.AccessTokenFormat {
+	case "", core.OAUTH_ACCESS_TOKEN_FORMAT_JWT, core.OAUTH_ACCESS_TOKEN_FORMAT_OPAQUE:
+	default:
+		fields["Policy.AccessTokenFormat"] = fmt.Sprintf("access token format is not supported: %s", policy.AccessTokenFormat)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
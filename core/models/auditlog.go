@@ -44,3 +44,35 @@ type AuditLog struct {
 	AuditLogDate time.Time              `bson:"auditLogDate"`
 	Data         map[string]interface{} `bson:"data"`
 }
+
+// audit log codes for changes to the client secrets of an app
+const (
+	AuditLogCode_ClientSecretCreated       = "ClientSecretCreated"
+	AuditLogCode_ClientSecretExpirationSet = "ClientSecretExpirationSet"
+	AuditLogCode_ClientSecretRevoked       = "ClientSecretRevoked"
+)
+
+// audit log codes for tokens revoked in bulk
+const (
+	AuditLogCode_AppTokensRevoked  = "AppTokensRevoked"
+	AuditLogCode_UserTokensRevoked = "UserTokensRevoked"
+)
+
+// audit log codes for oauth grants and token misuse
+const (
+	AuditLogCode_ConsentGranted      = "ConsentGranted"
+	AuditLogCode_RefreshTokenReused  = "RefreshTokenReused"
+	AuditLogCode_TokenClientMismatch = "TokenClientMismatch"
+)
+
+// NewAuditLog creates an audit log message for an asset, dated now.
+func NewAuditLog(code, message, assetType, assetID string, data map[string]interface{}) AuditLog {
+	return AuditLog{
+		Message:      message,
+		Code:         code,
+		AssetType:    assetType,
+		AssetID:      assetID,
+		AuditLogDate: time.Now().UTC(),
+		Data:         data,
+	}
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Authentication describes when and how a user authenticated with goauth.
+type Authentication struct {
+	UserID string
+	// AuthTime is when the user authenticated, which is the auth_time claim of id tokens.
+	AuthTime time.Time
+	// ACR is the authentication context class reference the user authenticated with, which is the acr claim of id tokens.
+	ACR string
+}
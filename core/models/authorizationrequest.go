@@ -0,0 +1,46 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/calvine/goauth/core"
+)
+
+// AuthorizationRequest holds the parameters of an OAuth 2.0 authorization request sent to the authorize endpoint.
+type AuthorizationRequest struct {
+	ClientID     string
+	RedirectURI  string
+	ResponseType string
+	Scope        string
+	State        string
+	// CodeChallenge is the PKCE code challenge described in https://datatracker.ietf.org/doc/html/rfc7636#section-4.2
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// Nonce is the OIDC nonce described in https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest which is returned in the id token.
+	Nonce string
+	// Prompt is the space delimited list of OIDC prompt values described in https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+	Prompt string
+}
+
+// RequestedScopes returns the names of the scopes in the space delimited Scope field.
+func (ar AuthorizationRequest) RequestedScopes() []string {
+	return strings.Fields(ar.Scope)
+}
+
+// HasPrompt returns true if the prompt value is in the space delimited Prompt field.
+func (ar AuthorizationRequest) HasPrompt(prompt string) bool {
+	for _, p := range strings.Fields(ar.Prompt) {
+		if p == prompt {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCodeChallengeMethod returns the code challenge method for the request, which defaults to plain when a code challenge is provided without a method.
+func (ar AuthorizationRequest) GetCodeChallengeMethod() string {
+	if ar.CodeChallenge != "" && ar.CodeChallengeMethod == "" {
+		return core.PKCE_CODE_CHALLENGE_METHOD_PLAIN
+	}
+	return ar.CodeChallengeMethod
+}
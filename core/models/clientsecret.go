@@ -0,0 +1,69 @@
+package models
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/calvine/goauth/core/nullable"
+	"github.com/calvine/goauth/core/utilities"
+	"github.com/calvine/richerror/errors"
+)
+
+// ClientSecret is a hashed secret an app authenticates with. An app can have more than one active client secret so a secret can be rotated without downtime.
+type ClientSecret struct {
+	// ID identifies the client secret so it can be revoked without knowing the secret.
+	ID string `bson:"id"`
+	// Hash is the SHA512 hash of the client secret.
+	// Not using bcrypt because its slow and this needs to be checked per request in some cases
+	Hash string `bson:"hash"`
+	// ExpirationDate is when the client secret stops being accepted. When not set the client secret does not expire.
+	ExpirationDate nullable.NullableTime `bson:"expirationDate"`
+	CreatedDate    time.Time             `bson:"createdDate"`
+}
+
+// NewClientSecret creates a new client secret with an optional expiration date. It returns the client secret and the plain text secret, which is not stored and must be given to the app owner.
+func NewClientSecret(expirationDate nullable.NullableTime) (ClientSecret, string, errors.RichError) {
+	id, err := utilities.NewVariableLengthTokenString(1)
+	if err != nil {
+		return ClientSecret{}, "", err
+	}
+	clientSecret, err := utilities.NewVariableLengthTokenString(3)
+	if err != nil {
+		return ClientSecret{}, "", err
+	}
+	return ClientSecret{
+		ID:             id,
+		Hash:           utilities.SHA512(clientSecret),
+		ExpirationDate: expirationDate,
+		CreatedDate:    time.Now().UTC(),
+	}, clientSecret, nil
+}
+
+// IsActive returns true if the client secret has not expired at the given time.
+func (cs ClientSecret) IsActive(now time.Time) bool {
+	return !cs.ExpirationDate.HasValue || now.Before(cs.ExpirationDate.Value)
+}
+
+// MatchClientSecret returns true if the client secret matches one of the active client secrets of the app.
+// Every active client secret is compared so the time taken does not reveal which one matched.
+func (app App) MatchClientSecret(clientSecret string, now time.Time) bool {
+	clientSecretHash := []byte(utilities.SHA512(clientSecret))
+	matched := false
+	for _, cs := range app.ClientSecrets {
+		if subtle.ConstantTimeCompare(clientSecretHash, []byte(cs.Hash)) == 1 && cs.IsActive(now) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// GetActiveClientSecrets returns the client secrets of the app that have not expired at the given time.
+func (app App) GetActiveClientSecrets(now time.Time) []ClientSecret {
+	activeClientSecrets := make([]ClientSecret, 0, len(app.ClientSecrets))
+	for _, cs := range app.ClientSecrets {
+		if cs.IsActive(now) {
+			activeClientSecrets = append(activeClientSecrets, cs)
+		}
+	}
+	return activeClientSecrets
+}
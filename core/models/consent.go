@@ -0,0 +1,19 @@
+package models
+
+// Consent records that a user approved an app acting on their behalf with a scope.
+// Scopes are referenced by name because the OIDC scopes are not stored with the app.
+type Consent struct {
+	ID        string    `bson:"-"`
+	UserID    string    `bson:"userId"`
+	AppID     string    `bson:"appId"`
+	ScopeName string    `bson:"scopeName"`
+	AuditData auditable `bson:",inline"`
+}
+
+func NewConsent(userID, appID, scopeName string) Consent {
+	return Consent{
+		UserID:    userID,
+		AppID:     appID,
+		ScopeName: scopeName,
+	}
+}
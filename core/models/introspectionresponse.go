@@ -0,0 +1,15 @@
+package models
+
+// IntrospectionResponse is the response from the introspection endpoint described in https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
+// Only Active is populated for tokens that are not active.
+type IntrospectionResponse struct {
+	Active   bool   `json:"active"`
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Subject  string `json:"sub,omitempty"`
+	// ExpiresAt is the unix time the token expires.
+	ExpiresAt int64 `json:"exp,omitempty"`
+	// TokenType is Bearer for access tokens and refresh_token for refresh tokens.
+	TokenType string `json:"token_type,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// JWTSigningKey is a stored jwt signing key. The private key is stored encrypted so the key store alone cannot be used to sign tokens.
+type JWTSigningKey struct {
+	KeyID        string    `bson:"keyId" json:"keyId"`
+	Algorithm    string    `bson:"algorithm" json:"algorithm"`
+	State        string    `bson:"state" json:"state"`
+	EncryptedKey string    `bson:"encryptedKey" json:"encryptedKey"`
+	CreatedAt    time.Time `bson:"createdAt" json:"createdAt"`
+	ActivatedAt  time.Time `bson:"activatedAt" json:"activatedAt"`
+	RetiredAt    time.Time `bson:"retiredAt" json:"retiredAt"`
+}
+
+// JWTSigningKeySet is the set of jwt signing keys shared by every instance. The version is incremented each time the set is saved so an instance can tell when another instance changed it.
+type JWTSigningKeySet struct {
+	Version int64           `bson:"version" json:"version"`
+	Keys    []JWTSigningKey `bson:"keys" json:"keys"`
+}
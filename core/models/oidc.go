@@ -0,0 +1,84 @@
+package models
+
+import "github.com/calvine/goauth/core"
+
+// OIDCScopeClaims maps the standard OIDC scopes to the claims they grant access to per https://openid.net/specs/openid-connect-core-1_0.html#ScopeClaims
+// Only the claims goauth has data for are included.
+var OIDCScopeClaims = map[string][]string{
+	core.OIDC_SCOPE_OPENID:  {"sub"},
+	core.OIDC_SCOPE_PROFILE: {"name", "given_name", "middle_name", "family_name", "birthdate"},
+	core.OIDC_SCOPE_EMAIL:   {"email", "email_verified"},
+	core.OIDC_SCOPE_PHONE:   {"phone_number", "phone_number_verified"},
+	core.OIDC_SCOPE_ADDRESS: {"address"},
+}
+
+// OIDCScopes is the list of standard OIDC scopes supported, in the order they are advertised.
+var OIDCScopes = []string{
+	core.OIDC_SCOPE_OPENID,
+	core.OIDC_SCOPE_PROFILE,
+	core.OIDC_SCOPE_EMAIL,
+	core.OIDC_SCOPE_PHONE,
+	core.OIDC_SCOPE_ADDRESS,
+}
+
+// OIDCScopeDescriptions describes what each standard OIDC scope grants access to, so they can be shown to users like app scopes.
+var OIDCScopeDescriptions = map[string]string{
+	core.OIDC_SCOPE_OPENID:  "Sign you in with your account",
+	core.OIDC_SCOPE_PROFILE: "View your name and date of birth",
+	core.OIDC_SCOPE_EMAIL:   "View your primary email address",
+	core.OIDC_SCOPE_PHONE:   "View your primary phone number",
+	core.OIDC_SCOPE_ADDRESS: "View your primary address",
+}
+
+// DiscoveryDocument is the OpenID provider metadata described in https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type DiscoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint,omitempty"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint,omitempty"`
+	RevocationEndpoint                string   `json:"revocation_endpoint,omitempty"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ACRValuesSupported                []string `json:"acr_values_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// UserClaims are the standard claims about a user described in https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+// Claims that were not granted or have no value are omitted.
+type UserClaims struct {
+	Name                string        `json:"name,omitempty"`
+	GivenName           string        `json:"given_name,omitempty"`
+	MiddleName          string        `json:"middle_name,omitempty"`
+	FamilyName          string        `json:"family_name,omitempty"`
+	Birthdate           string        `json:"birthdate,omitempty"`
+	Email               string        `json:"email,omitempty"`
+	EmailVerified       *bool         `json:"email_verified,omitempty"`
+	PhoneNumber         string        `json:"phone_number,omitempty"`
+	PhoneNumberVerified *bool         `json:"phone_number_verified,omitempty"`
+	Address             *AddressClaim `json:"address,omitempty"`
+}
+
+// AddressClaim is the address claim described in https://openid.net/specs/openid-connect-core-1_0.html#AddressClaim
+type AddressClaim struct {
+	Formatted     string `json:"formatted,omitempty"`
+	StreetAddress string `json:"street_address,omitempty"`
+	Locality      string `json:"locality,omitempty"`
+	Region        string `json:"region,omitempty"`
+	PostalCode    string `json:"postal_code,omitempty"`
+}
+
+// UserInfo is the response from the userinfo endpoint described in https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
+type UserInfo struct {
+	Subject string `json:"sub"`
+	// ClientID is the client the access token was issued to. It is not part of the response.
+	ClientID string `json:"-"`
+	UserClaims
+}
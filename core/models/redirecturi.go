@@ -0,0 +1,108 @@
+package models
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// validateRedirectURI returns the reason the redirect uri is not valid, or an empty string if it is valid.
+// The rules for native apps come from https://datatracker.ietf.org/doc/html/rfc8252#section-7
+func validateRedirectURI(redirectURI string) string {
+	parsedURI, err := url.Parse(redirectURI)
+	if err != nil {
+		return "redirect uri is not a valid uri"
+	}
+	if !parsedURI.IsAbs() {
+		return "redirect uri must be absolute"
+	}
+	// fragments are not allowed per https://datatracker.ietf.org/doc/html/rfc6749#section-3.1.2
+	if parsedURI.Fragment != "" || strings.Contains(redirectURI, "#") {
+		return "redirect uri must not include a fragment"
+	}
+	switch parsedURI.Scheme {
+	case "https":
+		if parsedURI.Host == "" {
+			return "https redirect uri must include a host"
+		}
+		if parsedURI.User != nil {
+			return "redirect uri must not include user info"
+		}
+	case "http":
+		if !isLoopbackHost(parsedURI.Hostname()) {
+			return "http redirect uri is only allowed for loopback hosts"
+		}
+		if parsedURI.User != nil {
+			return "redirect uri must not include user info"
+		}
+	default:
+		// private-use uri schemes must be a reverse domain name the app controls per https://datatracker.ietf.org/doc/html/rfc8252#section-7.1
+		if !strings.Contains(parsedURI.Scheme, ".") {
+			return "private-use uri scheme must be a reverse domain name"
+		}
+	}
+	return ""
+}
+
+// validateOrigin returns the reason the web origin is not valid, or an empty string if it is valid.
+// An origin is only a scheme, host and optional port as described in https://datatracker.ietf.org/doc/html/rfc6454#section-6.2
+func validateOrigin(origin string) string {
+	parsedOrigin, err := url.Parse(origin)
+	if err != nil {
+		return "origin is not a valid uri"
+	}
+	switch parsedOrigin.Scheme {
+	case "https":
+	case "http":
+		if !isLoopbackHost(parsedOrigin.Hostname()) {
+			return "http origin is only allowed for loopback hosts"
+		}
+	default:
+		return "origin scheme must be http or https"
+	}
+	if parsedOrigin.Host == "" {
+		return "origin must include a host"
+	}
+	if origin != parsedOrigin.Scheme+"://"+parsedOrigin.Host {
+		return "origin must only include a scheme, host and port"
+	}
+	return ""
+}
+
+// isLoopbackHost returns true for localhost and loopback ip addresses.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLoopbackRedirectURI returns true if the redirect uri is an http uri for a loopback ip literal.
+// localhost is not included because it is not guaranteed to resolve to the loopback interface per https://datatracker.ietf.org/doc/html/rfc8252#section-8.3
+func isLoopbackRedirectURI(redirectURI string) bool {
+	parsedURI, err := url.Parse(redirectURI)
+	if err != nil || parsedURI.Scheme != "http" {
+		return false
+	}
+	ip := net.ParseIP(parsedURI.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// matchLoopbackRedirectURI returns true if the redirect uri matches the registered loopback redirect uri in everything but the port.
+func matchLoopbackRedirectURI(registeredURI, redirectURI string) bool {
+	parsedRegisteredURI, err := url.Parse(registeredURI)
+	if err != nil {
+		return false
+	}
+	parsedURI, err := url.Parse(redirectURI)
+	if err != nil {
+		return false
+	}
+	return parsedURI.Scheme == parsedRegisteredURI.Scheme &&
+		parsedURI.Hostname() == parsedRegisteredURI.Hostname() &&
+		parsedURI.User == nil &&
+		parsedURI.EscapedPath() == parsedRegisteredURI.EscapedPath() &&
+		parsedURI.RawQuery == parsedRegisteredURI.RawQuery &&
+		parsedURI.Fragment == "" && !strings.Contains(redirectURI, "#")
+}
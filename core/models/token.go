@@ -16,6 +16,41 @@ const (
 	TokenTypeConfirmContact
 	TokenTypePasswordReset
 	TokenTypeSession
+	TokenTypeRefreshToken
+	TokenTypeAuthorizationCode
+	TokenTypeAccessToken
+	TokenTypeMFAChallenge
+)
+
+// SignInTokenTypes are the token types that keep a user signed in to goauth or an app.
+var SignInTokenTypes = []TokenType{
+	TokenTypeSession,
+	TokenTypeAuthorizationCode,
+	TokenTypeAccessToken,
+	TokenTypeRefreshToken,
+}
+
+const (
+	// TokenMetaDataKeyClientID is the meta data key for the client id of the app a token was issued to.
+	TokenMetaDataKeyClientID = "client_id"
+	// TokenMetaDataKeyRedirectURI is the meta data key for the redirect uri provided in an authorization request.
+	TokenMetaDataKeyRedirectURI = "redirect_uri"
+	// TokenMetaDataKeyScope is the meta data key for the space delimited scopes granted with a token.
+	TokenMetaDataKeyScope = "scope"
+	// TokenMetaDataKeyCodeChallenge is the meta data key for the PKCE code challenge provided in an authorization request.
+	TokenMetaDataKeyCodeChallenge = "code_challenge"
+	// TokenMetaDataKeyCodeChallengeMethod is the meta data key for the PKCE code challenge method provided in an authorization request.
+	TokenMetaDataKeyCodeChallengeMethod = "code_challenge_method"
+	// TokenMetaDataKeyAccessToken is the meta data key for the access token issued along with a refresh token.
+	TokenMetaDataKeyAccessToken = "access_token"
+	// TokenMetaDataKeyRotatedTo is the meta data key for the refresh token that replaced a refresh token when it was used.
+	TokenMetaDataKeyRotatedTo = "rotated_to"
+	// TokenMetaDataKeyNonce is the meta data key for the OIDC nonce provided in an authorization request.
+	TokenMetaDataKeyNonce = "nonce"
+	// TokenMetaDataKeyAuthTime is the meta data key for the unix time the user authenticated.
+	TokenMetaDataKeyAuthTime = "auth_time"
+	// TokenMetaDataKeyACR is the meta data key for the authentication context class reference the user authenticated with.
+	TokenMetaDataKeyACR = "acr"
 )
 
 // Token is a temporary item that can be used as a shared secret like a password reset token or a confirm contact token. They can be tide to a target entity like a user to ensure they are consumed by the proper targets.
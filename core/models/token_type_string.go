@@ -13,11 +13,15 @@ func _() {
 	_ = x[TokenTypeConfirmContact-2]
 	_ = x[TokenTypePasswordReset-3]
 	_ = x[TokenTypeSession-4]
+	_ = x[TokenTypeRefreshToken-5]
+	_ = x[TokenTypeAuthorizationCode-6]
+	_ = x[TokenTypeAccessToken-7]
+	_ = x[TokenTypeMFAChallenge-8]
 }
 
-const _TokenType_name = "TokenTypeInvalidTokenTypeCSRFTokenTypeConfirmContactTokenTypePasswordResetTokenTypeSession"
+const _TokenType_name = "TokenTypeInvalidTokenTypeCSRFTokenTypeConfirmContactTokenTypePasswordResetTokenTypeSessionTokenTypeRefreshTokenTokenTypeAuthorizationCodeTokenTypeAccessTokenTokenTypeMFAChallenge"
 
-var _TokenType_index = [...]uint8{0, 16, 29, 52, 74, 90}
+var _TokenType_index = [...]uint8{0, 16, 29, 52, 74, 90, 111, 137, 157, 178}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
@@ -12,6 +12,12 @@ type User struct {
 	ConsecutiveFailedLoginAttempts int                   `bson:"consecutiveFailedLoginAttempts"`
 	LockedOutUntil                 nullable.NullableTime `bson:"lockedOutUntil"`
 	LastLoginDate                  nullable.NullableTime `bson:"lastLoginDate"`
+	// TOTPSecret is the users TOTP secret encrypted with the encryption key. It is set when the user starts TOTP enrollment.
+	TOTPSecret string `bson:"totpSecret"`
+	// TOTPEnabled is set once the user confirms TOTP enrollment with a code, after which logging in requires a code as well as a password.
+	TOTPEnabled bool `bson:"totpEnabled"`
+	// TOTPLastUsedTimeStep is the time step of the last TOTP code the user logged in with, so the same code cannot be used again.
+	TOTPLastUsedTimeStep int64 `bson:"totpLastUsedTimeStep"`
 	// PasswordResetToken             nullable.NullableString `bson:"passwordResetToken"`
 	// PasswordResetTokenExpiration   nullable.NullableTime   `bson:"passwordResetTokenExpiration"`
 	AuditData auditable `bson:",inline"`
@@ -1,6 +1,7 @@
 package nullable
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 
@@ -66,6 +67,11 @@ func (nb *NullableBool) Scan(value interface{}) error {
 		nb.HasValue = true
 		nb.Value = t
 		return nil
+	case int64:
+		// sqlite and some other databases store booleans as integers.
+		nb.HasValue = true
+		nb.Value = t != 0
+		return nil
 	default:
 		nb.HasValue = false
 		nb.Value = false
@@ -74,6 +80,11 @@ func (nb *NullableBool) Scan(value interface{}) error {
 	}
 }
 
+// SQLValue returns a driver.Valuer so the NullableBool can be used as an argument to a database/sql query.
+func (nb *NullableBool) SQLValue() driver.Valuer {
+	return sqlValuer{nb.HasValue, nb.Value}
+}
+
 func (nb *NullableBool) MarshalBSONValue() (bsontype.Type, []byte, error) {
 	if !nb.HasValue {
 		// make temp pointer with null value to marshal
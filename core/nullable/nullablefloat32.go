@@ -1,6 +1,7 @@
 package nullable
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 
@@ -66,6 +67,10 @@ func (nf *NullableFloat32) Scan(value interface{}) error {
 		nf.HasValue = true
 		nf.Value = t
 		return nil
+	case float64:
+		nf.HasValue = true
+		nf.Value = float32(t)
+		return nil
 	default:
 		nf.HasValue = false
 		nf.Value = 0
@@ -74,6 +79,11 @@ func (nf *NullableFloat32) Scan(value interface{}) error {
 	}
 }
 
+// SQLValue returns a driver.Valuer so the NullableFloat32 can be used as an argument to a database/sql query.
+func (nf *NullableFloat32) SQLValue() driver.Valuer {
+	return sqlValuer{nf.HasValue, float64(nf.Value)}
+}
+
 func (nf *NullableFloat32) MarshalBSONValue() (bsontype.Type, []byte, error) {
 	if !nf.HasValue {
 		// make temp pointer with null value to marshal
@@ -1,6 +1,7 @@
 package nullable
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 
@@ -74,6 +75,11 @@ func (nf *NullableFloat64) Scan(value interface{}) error {
 	}
 }
 
+// SQLValue returns a driver.Valuer so the NullableFloat64 can be used as an argument to a database/sql query.
+func (nf *NullableFloat64) SQLValue() driver.Valuer {
+	return sqlValuer{nf.HasValue, nf.Value}
+}
+
 func (nf *NullableFloat64) MarshalBSONValue() (bsontype.Type, []byte, error) {
 	if !nf.HasValue {
 		// make temp pointer with null value to marshal
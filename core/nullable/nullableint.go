@@ -1,6 +1,7 @@
 package nullable
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 
@@ -66,6 +67,10 @@ func (ni *NullableInt) Scan(value interface{}) error {
 		ni.HasValue = true
 		ni.Value = t
 		return nil
+	case int64:
+		ni.HasValue = true
+		ni.Value = int(t)
+		return nil
 	default:
 		ni.HasValue = false
 		ni.Value = 0
@@ -74,6 +79,11 @@ func (ni *NullableInt) Scan(value interface{}) error {
 	}
 }
 
+// SQLValue returns a driver.Valuer so the NullableInt can be used as an argument to a database/sql query.
+func (ni *NullableInt) SQLValue() driver.Valuer {
+	return sqlValuer{ni.HasValue, int64(ni.Value)}
+}
+
 func (ni *NullableInt) MarshalBSONValue() (bsontype.Type, []byte, error) {
 	if !ni.HasValue {
 		// make temp pointer with null value to marshal
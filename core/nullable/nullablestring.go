@@ -1,6 +1,7 @@
 package nullable
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 
@@ -70,6 +71,10 @@ func (ns *NullableString) Scan(value interface{}) error {
 		ns.HasValue = true
 		ns.Value = t
 		return nil
+	case []byte:
+		ns.HasValue = true
+		ns.Value = string(t)
+		return nil
 	default:
 		ns.HasValue = false
 		ns.Value = ""
@@ -78,6 +83,11 @@ func (ns *NullableString) Scan(value interface{}) error {
 	}
 }
 
+// SQLValue returns a driver.Valuer so the NullableString can be used as an argument to a database/sql query.
+func (ns *NullableString) SQLValue() driver.Valuer {
+	return sqlValuer{ns.HasValue, ns.Value}
+}
+
 func (ns *NullableString) MarshalBSONValue() (bsontype.Type, []byte, error) {
 	if !ns.HasValue {
 		// make temp pointer with null value to marshal
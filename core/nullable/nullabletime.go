@@ -1,6 +1,7 @@
 package nullable
 
 import (
+	"database/sql/driver"
 	"encoding/binary"
 	"fmt"
 	"time"
@@ -76,6 +77,11 @@ func (nt *NullableTime) Scan(value interface{}) error {
 	}
 }
 
+// SQLValue returns a driver.Valuer so the NullableTime can be used as an argument to a database/sql query.
+func (nt *NullableTime) SQLValue() driver.Valuer {
+	return sqlValuer{nt.HasValue, nt.Value}
+}
+
 func (nt *NullableTime) MarshalBSONValue() (bsontype.Type, []byte, error) {
 	if !nt.HasValue {
 		// make temp pointer with null value to marshal
@@ -0,0 +1,16 @@
+package nullable
+
+import "database/sql/driver"
+
+// sqlValuer is the driver.Valuer returned by the SQLValue methods. The nullable types cannot implement driver.Valuer themselves because a Value method would collide with their Value field.
+type sqlValuer struct {
+	hasValue bool
+	value    driver.Value
+}
+
+func (sv sqlValuer) Value() (driver.Value, error) {
+	if !sv.hasValue {
+		return nil, nil
+	}
+	return sv.value, nil
+}
@@ -0,0 +1,98 @@
+package nullable
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+func TestSQLValue(t *testing.T) {
+	testTime := time.Date(2018, 5, 2, 18, 7, 10, 0, time.UTC)
+	type testCase struct {
+		name          string
+		valuer        driver.Valuer
+		expectedValue driver.Value
+	}
+	testCases := []testCase{
+		{
+			name:          "GIVEN a NullableBool with a value EXPECT the value",
+			valuer:        (&NullableBool{HasValue: true, Value: true}).SQLValue(),
+			expectedValue: true,
+		},
+		{
+			name:          "GIVEN a NullableBool without a value EXPECT nil",
+			valuer:        (&NullableBool{}).SQLValue(),
+			expectedValue: nil,
+		},
+		{
+			name:          "GIVEN a NullableFloat32 with a value EXPECT the value as a float64",
+			valuer:        (&NullableFloat32{HasValue: true, Value: 1.5}).SQLValue(),
+			expectedValue: float64(1.5),
+		},
+		{
+			name:          "GIVEN a NullableFloat64 with a value EXPECT the value",
+			valuer:        (&NullableFloat64{HasValue: true, Value: 2.5}).SQLValue(),
+			expectedValue: float64(2.5),
+		},
+		{
+			name:          "GIVEN a NullableInt with a value EXPECT the value as an int64",
+			valuer:        (&NullableInt{HasValue: true, Value: 3}).SQLValue(),
+			expectedValue: int64(3),
+		},
+		{
+			name:          "GIVEN a NullableInt without a value EXPECT nil",
+			valuer:        (&NullableInt{}).SQLValue(),
+			expectedValue: nil,
+		},
+		{
+			name:          "GIVEN a NullableString with a value EXPECT the value",
+			valuer:        (&NullableString{HasValue: true, Value: "abc"}).SQLValue(),
+			expectedValue: "abc",
+		},
+		{
+			name:          "GIVEN a NullableString with an empty value EXPECT an empty string",
+			valuer:        (&NullableString{HasValue: true}).SQLValue(),
+			expectedValue: "",
+		},
+		{
+			name:          "GIVEN a NullableTime with a value EXPECT the value",
+			valuer:        (&NullableTime{HasValue: true, Value: testTime}).SQLValue(),
+			expectedValue: testTime,
+		},
+		{
+			name:          "GIVEN a NullableTime without a value EXPECT nil",
+			valuer:        (&NullableTime{}).SQLValue(),
+			expectedValue: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := tc.valuer.Value()
+			if err != nil {
+				t.Errorf("unexpected error getting value: %s", err.Error())
+			}
+			if value != tc.expectedValue {
+				t.Errorf("value does not match expected value: got: %v - expected: %v", value, tc.expectedValue)
+			}
+		})
+	}
+}
+
+func TestScanDriverValues(t *testing.T) {
+	nb := NullableBool{}
+	if err := nb.Scan(int64(1)); err != nil || !nb.HasValue || !nb.Value {
+		t.Error("expected NullableBool to scan an integer as true", err, nb)
+	}
+	nf := NullableFloat32{}
+	if err := nf.Scan(float64(1.5)); err != nil || !nf.HasValue || nf.Value != 1.5 {
+		t.Error("expected NullableFloat32 to scan a float64", err, nf)
+	}
+	ni := NullableInt{}
+	if err := ni.Scan(int64(3)); err != nil || !ni.HasValue || ni.Value != 3 {
+		t.Error("expected NullableInt to scan an int64", err, ni)
+	}
+	ns := NullableString{}
+	if err := ns.Scan([]byte("abc")); err != nil || !ns.HasValue || ns.Value != "abc" {
+		t.Error("expected NullableString to scan a byte slice", err, ns)
+	}
+}
@@ -2,9 +2,12 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"github.com/calvine/goauth/core"
 	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/models/aggregate"
+	"github.com/calvine/goauth/core/nullable"
 	"github.com/calvine/richerror/errors"
 )
 
@@ -27,6 +30,15 @@ type TokenRepo interface {
 	PutToken(ctx context.Context, token models.Token) errors.RichError
 	// DeleteToken deletes a token from a store
 	DeleteToken(ctx context.Context, tokenValue string) errors.RichError
+	// ConsumeToken retreives and deletes a token from a store in a single atomic operation so a token can only be consumed once
+	ConsumeToken(ctx context.Context, tokenValue string) (models.Token, errors.RichError)
+	// MarkTokenRotated records the token a token was rotated to and moves its expiration, but only if it has not already been rotated. It is a single atomic operation so when the same token is rotated concurrently only one caller marks it.
+	// The token is returned as it was before the call, so a caller can tell another caller rotated it first when it already has a rotated to value.
+	MarkTokenRotated(ctx context.Context, tokenValue, rotatedTo string, expiration time.Time) (models.Token, errors.RichError)
+	// DeleteTokensByTargetID deletes every token of the given types tied to the target id from a store
+	DeleteTokensByTargetID(ctx context.Context, targetID string, tokenTypes []models.TokenType) errors.RichError
+	// DeleteTokensByClientID deletes every token issued to the app with the given client id from a store
+	DeleteTokensByClientID(ctx context.Context, clientID string) errors.RichError
 
 	Repo
 }
@@ -35,10 +47,15 @@ type TokenRepo interface {
 type UserRepo interface {
 	// GetUserByID gets a user by its id
 	GetUserByID(ctx context.Context, id string) (models.User, errors.RichError)
+	// GetFullUserByID gets a user along with their profile, contacts and addresses by its id
+	GetFullUserByID(ctx context.Context, id string) (aggregate.FullUser, errors.RichError)
 	// AddUser adds a user record
 	AddUser(ctx context.Context, user *models.User, createdByID string) errors.RichError
-	// UpdateUser updates a user record
+	// UpdateUser updates a user record. The TOTP last used time step is never lowered, so a stale copy of the user cannot undo UpdateTOTPLastUsedTimeStep.
 	UpdateUser(ctx context.Context, user *models.User, modifiedByID string) errors.RichError
+	// UpdateTOTPLastUsedTimeStep records the time step of a TOTP code the user has used, only when it is later than the last one recorded.
+	// It returns a TOTPCodeAlreadyUsed error otherwise, so when the same code is submitted concurrently only one submission is accepted.
+	UpdateTOTPLastUsedTimeStep(ctx context.Context, userID string, timeStep int64, modifiedByID string) errors.RichError
 	// GetUserByPrimaryContact gets the user by their primary contact
 	GetUserByPrimaryContact(ctx context.Context, contactPrincipalType, contactPrincipal string) (models.User, errors.RichError)
 	// GetUserAndContactByConfirmedContact gets the user and the primary contact by a confirmed contact principal and contactType
@@ -103,9 +120,17 @@ type AppRepo interface {
 	GetAppByClientID(ctx context.Context, clientID string) (models.App, errors.RichError)
 	GetAppAndScopesByClientID(ctx context.Context, clientID string) (models.App, []models.Scope, errors.RichError)
 	AddApp(ctx context.Context, app *models.App, createdBy string) errors.RichError
+	// UpdateApp updates the app. The client secrets of the app are left as they are, they are changed through the client secret functions so concurrent changes to them are not lost.
 	UpdateApp(ctx context.Context, app *models.App, modifiedBy string) errors.RichError
 	DeleteApp(ctx context.Context, app *models.App, deletedBy string) errors.RichError
 
+	// AddClientSecret adds a client secret to an app without touching its other client secrets.
+	AddClientSecret(ctx context.Context, appID string, clientSecret models.ClientSecret, modifiedBy string) errors.RichError
+	// SetClientSecretExpiration sets or clears the expiration date of a single client secret of an app.
+	SetClientSecretExpiration(ctx context.Context, appID, clientSecretID string, expirationDate nullable.NullableTime, modifiedBy string) errors.RichError
+	// RevokeClientSecret removes a client secret from an app. The check that another client secret of the app is still active at now is made atomically with the removal, so concurrent revocations cannot leave an app without an active client secret.
+	RevokeClientSecret(ctx context.Context, appID, clientSecretID string, now time.Time, modifiedBy string) errors.RichError
+
 	GetScopeByID(ctx context.Context, id string) (models.Scope, errors.RichError)
 	GetScopesByAppID(ctx context.Context, appID string) ([]models.Scope, errors.RichError)
 	AddScope(ctx context.Context, scope *models.Scope, createdBy string) errors.RichError
@@ -114,3 +139,22 @@ type AppRepo interface {
 
 	Repo
 }
+
+type JWTSigningKeyRepo interface {
+	// GetJWTSigningKeySet gets the stored jwt signing key set. If no key set has been stored an empty key set with version 0 is returned.
+	GetJWTSigningKeySet(ctx context.Context) (models.JWTSigningKeySet, errors.RichError)
+	// SaveJWTSigningKeySet stores the key set only if the stored version still matches the version of the key set, then increments the version of the key set.
+	// It is a single atomic compare and swap, so when instances change the key set concurrently only one succeeds and the others get a JWTSigningKeySetConflict error.
+	SaveJWTSigningKeySet(ctx context.Context, keySet *models.JWTSigningKeySet) errors.RichError
+
+	Repo
+}
+
+type ConsentRepo interface {
+	// GetConsentsByUserIDAndAppID gets the consents a user has given to an app
+	GetConsentsByUserIDAndAppID(ctx context.Context, userID, appID string) ([]models.Consent, errors.RichError)
+	// AddConsent adds a consent record. A user only has one consent per app and scope, so when one already exists it is not added again and consent is populated with the existing record.
+	AddConsent(ctx context.Context, consent *models.Consent, createdByID string) errors.RichError
+
+	Repo
+}
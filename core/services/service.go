@@ -2,9 +2,12 @@ package services
 
 import (
 	"context"
+	"time"
 
 	"github.com/calvine/goauth/core"
 	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/models/aggregate"
+	"github.com/calvine/goauth/core/nullable"
 	"github.com/calvine/richerror/errors"
 	"go.uber.org/zap"
 )
@@ -17,11 +20,35 @@ type Service interface {
 type LoginService interface {
 	// LoginWithContact attempts to confirm a users credentials and if they match it returns true and resets the users ConsecutiveFailedLoginAttempts, otherwise it returns false and increments the users ConsecutiveFailedLoginAttempts
 	// The principal should only work when it has been confirmed
+	// When the user has TOTP enabled the user is returned with an MFARequired error, and the login has to be completed with LoginWithTOTP.
 	LoginWithPrimaryContact(ctx context.Context, logger *zap.Logger, principal, principalType, password string, initiator string) (models.User, errors.RichError)
-	// StartPasswordResetByContact sets a password reset token for the user with the corresponding principal and type that are confirmed.
-	StartPasswordResetByPrimaryContact(ctx context.Context, logger *zap.Logger, principal, principalType string, initiator string) (string, errors.RichError)
-	// ResetPassword resets a users password given a password reset token and new password hash and salt.
+	// LoginWithTOTP completes the login of a user with TOTP enabled once their password has been confirmed by LoginWithPrimaryContact.
+	// Wrong codes count as failed login attempts.
+	LoginWithTOTP(ctx context.Context, logger *zap.Logger, userID string, code string, initiator string) (models.User, errors.RichError)
+	// StartTOTPEnrollment generates a new TOTP secret for the user and returns the provisioning uri for it, which is usually shown as a QR code.
+	// A code is not required to log in until the enrollment is confirmed with ConfirmTOTPEnrollment.
+	StartTOTPEnrollment(ctx context.Context, logger *zap.Logger, userID string, initiator string) (string, errors.RichError)
+	// GetTOTPEnrollment returns the provisioning uri for the secret from StartTOTPEnrollment while the enrollment has not been confirmed, so it can be shown again without replacing the secret.
+	GetTOTPEnrollment(ctx context.Context, logger *zap.Logger, userID string, initiator string) (string, errors.RichError)
+	// ConfirmTOTPEnrollment enables TOTP for the user once they provide a valid code for the secret from StartTOTPEnrollment.
+	ConfirmTOTPEnrollment(ctx context.Context, logger *zap.Logger, userID string, code string, initiator string) errors.RichError
+	// StartPasswordResetByContact sets a password reset token for the user with the corresponding principal and type that are confirmed,
+	// and sends the user a link to reset their password.
+	StartPasswordResetByPrimaryContact(ctx context.Context, logger *zap.Logger, principal, principalType string, initiator string) errors.RichError
+	// ResetPassword resets a users password given a password reset token and new password.
+	// The token is consumed and the user is signed out of all sessions.
 	ResetPassword(ctx context.Context, logger *zap.Logger, passwordResetToken string, newPassword string, initiator string) errors.RichError
+	// ChangePassword changes the password of a user who knows their current password.
+	ChangePassword(ctx context.Context, logger *zap.Logger, userID string, currentPassword string, newPassword string, initiator string) errors.RichError
+
+	Service
+}
+
+// PasswordPolicy checks that new passwords are strong enough to be used.
+type PasswordPolicy interface {
+	// ValidatePassword returns a PasswordPolicyViolation error with an entry for each rule the password breaks.
+	// The users contact principals are passed so passwords containing them can be rejected.
+	ValidatePassword(ctx context.Context, logger *zap.Logger, password string, contactPrincipals []string) errors.RichError
 
 	Service
 }
@@ -33,7 +60,10 @@ type UserService interface {
 	// RegisterUserAndPrimaryContact registers a new user. it has several responsibilities.
 	//	1. ensure no other user has the contact provided as a confirmed contact.
 	//	2. send notification to user with link to confirm contact and set password
+	// when the contact is already confirmed the owner of the contact is notified and a RegistrationContactAlreadyConfirmed error is returned.
 	RegisterUserAndPrimaryContact(ctx context.Context, logger *zap.Logger, contactType, contactPrincipal string, initiator string) errors.RichError
+	// GetFullUserByID gets a user along with their profile, contacts and addresses
+	GetFullUserByID(ctx context.Context, logger *zap.Logger, userID string, initiator string) (aggregate.FullUser, errors.RichError)
 	// GetUserPrimaryContact gets a users primary contact
 	GetUserPrimaryContact(ctx context.Context, logger *zap.Logger, userID string, contactType string, initiator string) (models.Contact, errors.RichError)
 	// GetUsersContacts gets all of a users contacts
@@ -51,6 +81,9 @@ type UserService interface {
 	SetContactAsPrimary(ctx context.Context, logger *zap.Logger, userID string, newPrimaryContactID string, initiator string) errors.RichError
 	// ConfirmContact takes a confirmation code and updates the users contact record to be confirmed.
 	ConfirmContact(ctx context.Context, logger *zap.Logger, confirmationCode string, initiator string) errors.RichError
+	// ConfirmContactAndSetPassword confirms the contact like ConfirmContact and sets the initial password for a newly registered user.
+	// It fails if the user already has a password set, changing an existing password goes through the password reset flow.
+	ConfirmContactAndSetPassword(ctx context.Context, logger *zap.Logger, confirmationCode string, newPassword string, initiator string) errors.RichError
 
 	Service
 }
@@ -70,6 +103,12 @@ type AppService interface {
 	UpdateApp(ctx context.Context, logger *zap.Logger, app *models.App, initiator string) errors.RichError
 	// DeleteApp deletes an app
 	DeleteApp(ctx context.Context, logger *zap.Logger, app *models.App, initiator string) errors.RichError
+	// AddClientSecret adds a new client secret to an app with an optional expiration date, so it can be used alongside the existing client secrets while they are rotated out. It returns the plain text client secret.
+	AddClientSecret(ctx context.Context, logger *zap.Logger, app *models.App, expirationDate nullable.NullableTime, initiator string) (string, errors.RichError)
+	// SetClientSecretExpiration sets or clears the expiration date of a client secret of an app.
+	SetClientSecretExpiration(ctx context.Context, logger *zap.Logger, app *models.App, clientSecretID string, expirationDate nullable.NullableTime, initiator string) errors.RichError
+	// RevokeClientSecret removes a client secret from an app. The last active client secret of an app cannot be revoked.
+	RevokeClientSecret(ctx context.Context, logger *zap.Logger, app *models.App, clientSecretID string, initiator string) errors.RichError
 	// GetScopeByID retrives a scope by its id
 	GetScopeByID(ctx context.Context, logger *zap.Logger, id string, initiator string) (models.Scope, errors.RichError)
 	// GetScopesByAppID get scopes for an app by its id
@@ -87,6 +126,48 @@ type AppService interface {
 	Service
 }
 
+// OAuthService is a service that facilitates the OAuth 2.0 authorization flows
+type OAuthService interface {
+	// ValidateAuthorizationClient ensures the client id belongs to an enabled app and the redirect uri is registered for it. It returns the app, the apps scopes and the redirect uri to send the user agent back to.
+	// Errors from this function must not be sent to the redirect uri because it could not be trusted.
+	ValidateAuthorizationClient(ctx context.Context, logger *zap.Logger, clientID, redirectURI string, initiator string) (models.App, []models.Scope, string, errors.RichError)
+	// ValidateAuthorizationRequest ensures the response type, requested scopes and PKCE code challenge of an authorization request are valid for the app. It returns the requested scopes.
+	ValidateAuthorizationRequest(ctx context.Context, logger *zap.Logger, app models.App, appScopes []models.Scope, authorizationRequest models.AuthorizationRequest, initiator string) ([]models.Scope, errors.RichError)
+	// GetMissingConsent returns the scopes the user has not yet consented to the app being granted.
+	GetMissingConsent(ctx context.Context, logger *zap.Logger, userID string, app models.App, scopes []models.Scope, initiator string) ([]models.Scope, errors.RichError)
+	// GrantConsent records that the user consented to the app being granted the scopes. Scopes the user has already consented to are skipped.
+	GrantConsent(ctx context.Context, logger *zap.Logger, userID string, app models.App, scopes []models.Scope, initiator string) errors.RichError
+	// IssueAuthorizationCode creates and stores a single use authorization code for the authenticated user based on the authorization request. The lifetime of the code comes from the app policy.
+	IssueAuthorizationCode(ctx context.Context, logger *zap.Logger, app models.App, authentication models.Authentication, authorizationRequest models.AuthorizationRequest, initiator string) (models.Token, errors.RichError)
+	// AuthenticateClient ensures the client secret is valid for the enabled app with the given client id. It returns the app and its scopes.
+	AuthenticateClient(ctx context.Context, logger *zap.Logger, clientID, clientSecret string, initiator string) (models.App, []models.Scope, errors.RichError)
+	// ExchangeAuthorizationCode consumes an authorization code issued to the app and issues an access token and refresh token for the user it was issued to.
+	// When the openid scope was granted an id token is issued as well.
+	// The redirect uri must match the one provided in the authorization request, and the code verifier must match the code challenge if one was provided.
+	ExchangeAuthorizationCode(ctx context.Context, logger *zap.Logger, app models.App, code, redirectURI, codeVerifier string, initiator string) (models.AccessTokenResponse, errors.RichError)
+	// IssueClientCredentialsToken issues an access token to the app itself for the requested scopes.
+	IssueClientCredentialsToken(ctx context.Context, logger *zap.Logger, app models.App, appScopes []models.Scope, scope string, initiator string) (models.AccessTokenResponse, errors.RichError)
+	// ExchangeRefreshToken rotates a refresh token issued to the app, issuing a new access token and refresh token. The requested scope can only narrow the scope originally granted.
+	// If a refresh token that has already been rotated is presented, every token issued from it is revoked.
+	ExchangeRefreshToken(ctx context.Context, logger *zap.Logger, app models.App, refreshToken, scope string, initiator string) (models.AccessTokenResponse, errors.RichError)
+	// GetUserInfo returns the claims about the user an access token was issued to, limited to the claims granted by the scopes of the access token.
+	// The access token must have been granted the openid scope.
+	GetUserInfo(ctx context.Context, logger *zap.Logger, accessToken string, initiator string) (models.UserInfo, errors.RichError)
+	// IntrospectToken returns the state of a signed access token, or an opaque access or refresh token, for an authenticated app. The token type hint only changes the order token types are checked in.
+	// Tokens that are expired, revoked, rotated or unknown are reported as not active rather than returning an error.
+	IntrospectToken(ctx context.Context, logger *zap.Logger, app models.App, token, tokenTypeHint string, initiator string) (models.IntrospectionResponse, errors.RichError)
+	// RevokeToken revokes an access or refresh token issued to the app. Revoking a refresh token also revokes every token issued from it.
+	// Unknown, expired and already revoked tokens are ignored because the outcome for the client is the same.
+	RevokeToken(ctx context.Context, logger *zap.Logger, app models.App, token, tokenTypeHint string, initiator string) errors.RichError
+	// RevokeAppTokens revokes every token issued to the app and writes an audit log entry for it.
+	RevokeAppTokens(ctx context.Context, logger *zap.Logger, app models.App, initiator string) errors.RichError
+	// RevokeCompromisedClientSecret revokes a client secret that has been compromised along with every token issued to the app, since anyone holding the secret could have used it to get tokens.
+	// Like RevokeClientSecret the last active client secret of an app cannot be revoked, so a new client secret must be added first.
+	RevokeCompromisedClientSecret(ctx context.Context, logger *zap.Logger, app *models.App, clientSecretID string, initiator string) errors.RichError
+
+	Service
+}
+
 type EmailService interface {
 	// SendPlainTextEmail sends a plain text email.
 	SendPlainTextEmail(ctx context.Context, logger *zap.Logger, to []string, subject, body string) errors.RichError
@@ -101,6 +182,14 @@ type TokenService interface {
 	PutToken(ctx context.Context, logger *zap.Logger, token models.Token) errors.RichError
 	// DeleteToken deletes a token from the underlying data store
 	DeleteToken(ctx context.Context, logger *zap.Logger, tokenValue string) errors.RichError
+	// ConsumeToken retreives and deletes a token from the underlying data store so it can only be used once. The token is removed even if it turns out to be expired or of the wrong type
+	ConsumeToken(ctx context.Context, logger *zap.Logger, tokenValue string, expectedTokenType models.TokenType) (models.Token, errors.RichError)
+	// MarkTokenRotated records the token a token was rotated to and moves its expiration, unless it was already rotated. The token is returned as it was before the call, so a rotated to value means another caller rotated it first
+	MarkTokenRotated(ctx context.Context, logger *zap.Logger, tokenValue, rotatedTo string, expiration time.Time) (models.Token, errors.RichError)
+	// DeleteTokensByTargetID deletes every token of the given types tied to the target id from the underlying data store
+	DeleteTokensByTargetID(ctx context.Context, logger *zap.Logger, targetID string, tokenTypes []models.TokenType) errors.RichError
+	// DeleteTokensByClientID deletes every token issued to the app with the given client id from the underlying data store
+	DeleteTokensByClientID(ctx context.Context, logger *zap.Logger, clientID string) errors.RichError
 
 	Service
 }
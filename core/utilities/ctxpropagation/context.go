@@ -24,11 +24,11 @@ func SetLoggerForContext(ctx context.Context, logger *zap.Logger) context.Contex
 }
 
 func GetRequestIDFromContext(ctx context.Context) string {
-	requestID := ctx.Value(loggerContextKey).(string)
+	requestID := ctx.Value(requestIDContextKey).(string)
 	return requestID
 }
 
 func SetRequestIDForContext(ctx context.Context, requestID string) context.Context {
-	ctx = context.WithValue(ctx, loggerContextKey, requestID)
+	ctx = context.WithValue(ctx, requestIDContextKey, requestID)
 	return ctx
 }
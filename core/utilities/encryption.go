@@ -1,9 +1,14 @@
 package utilities
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"crypto/sha256"
 	"crypto/sha512"
+	"encoding/base64"
 	"encoding/hex"
+	"fmt"
 	"strings"
 
 	coreerrors "github.com/calvine/goauth/core/errors"
@@ -54,3 +59,73 @@ func NewVariableLengthTokenString(numGuids int) (string, errors.RichError) {
 	}
 	return tokenString, nil
 }
+
+// EncryptionKeyLength is the length in bytes of the AES-256 keys used by Encrypt and Decrypt.
+const EncryptionKeyLength = 32
+
+// NewEncryptionKey generates a random key for Encrypt and Decrypt.
+func NewEncryptionKey() ([]byte, errors.RichError) {
+	key := make([]byte, EncryptionKeyLength)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, coreerrors.NewEncryptionFailedError(err, true)
+	}
+	return key, nil
+}
+
+// Encrypt encrypts the plain text with AES-256-GCM. The result is the random nonce followed by the cipher text, base64 encoded so it can be stored as a string.
+func Encrypt(key []byte, plainText string) (string, errors.RichError) {
+	return EncryptWithAssociatedData(key, plainText, "")
+}
+
+// EncryptWithAssociatedData encrypts like Encrypt, but also authenticates the associated data. The same associated data must be passed to DecryptWithAssociatedData, which binds the cipher text to what it belongs to, like the id of the record it is stored on.
+func EncryptWithAssociatedData(key []byte, plainText, associatedData string) (string, errors.RichError) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", coreerrors.NewEncryptionFailedError(err, true)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", coreerrors.NewEncryptionFailedError(err, true)
+	}
+	cipherText := aead.Seal(nonce, nonce, []byte(plainText), []byte(associatedData))
+	return base64.StdEncoding.EncodeToString(cipherText), nil
+}
+
+// Decrypt decrypts cipher text created by Encrypt. It fails if the cipher text was created with a different key or has been modified.
+func Decrypt(key []byte, cipherText string) (string, errors.RichError) {
+	return DecryptWithAssociatedData(key, cipherText, "")
+}
+
+// DecryptWithAssociatedData decrypts cipher text created by EncryptWithAssociatedData. It also fails if the associated data does not match the associated data the cipher text was created with.
+func DecryptWithAssociatedData(key []byte, cipherText, associatedData string) (string, errors.RichError) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", coreerrors.NewDecryptionFailedError(err, true)
+	}
+	data, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil {
+		return "", coreerrors.NewDecryptionFailedError(err, true)
+	}
+	if len(data) < aead.NonceSize() {
+		return "", coreerrors.NewDecryptionFailedError(fmt.Errorf("cipher text is shorter than the nonce"), true)
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plainText, err := aead.Open(nil, nonce, sealed, []byte(associatedData))
+	if err != nil {
+		return "", coreerrors.NewDecryptionFailedError(err, true)
+	}
+	return string(plainText), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != EncryptionKeyLength {
+		return nil, fmt.Errorf("encryption key must be %d bytes", EncryptionKeyLength)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
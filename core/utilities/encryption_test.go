@@ -1,6 +1,10 @@
 package utilities
 
-import "testing"
+import (
+	"testing"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+)
 
 type hashTestCase struct {
 	ExpectedOutput, Input, Name string
@@ -81,3 +85,65 @@ func TestSHA512(t *testing.T) {
 
 	}
 }
+
+func TestEncryptAndDecrypt(t *testing.T) {
+	key, err := NewEncryptionKey()
+	if err != nil {
+		t.Fatalf("failed to generate encryption key: %s - %s", err.GetErrorCode(), err.Error())
+	}
+	plainText := "JBSWY3DPEHPK3PXP"
+	cipherText, err := Encrypt(key, plainText)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %s - %s", err.GetErrorCode(), err.Error())
+	}
+	if cipherText == plainText {
+		t.Error("cipher text should not be the plain text")
+	}
+	otherCipherText, _ := Encrypt(key, plainText)
+	if cipherText == otherCipherText {
+		t.Error("encrypting the same plain text twice should use different nonces")
+	}
+	decrypted, err := Decrypt(key, cipherText)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %s - %s", err.GetErrorCode(), err.Error())
+	}
+	if decrypted != plainText {
+		t.Errorf("decrypted text does not match: got %s - expected %s", decrypted, plainText)
+	}
+	otherKey, _ := NewEncryptionKey()
+	_, err = Decrypt(otherKey, cipherText)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeDecryptionFailed {
+		t.Errorf("expected decrypting with the wrong key to fail with %s got %v", coreerrors.ErrCodeDecryptionFailed, err)
+	}
+	_, err = Encrypt([]byte("too short"), plainText)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeEncryptionFailed {
+		t.Errorf("expected encrypting with a short key to fail with %s got %v", coreerrors.ErrCodeEncryptionFailed, err)
+	}
+}
+
+func TestEncryptAndDecryptWithAssociatedData(t *testing.T) {
+	key, err := NewEncryptionKey()
+	if err != nil {
+		t.Fatalf("failed to generate encryption key: %s - %s", err.GetErrorCode(), err.Error())
+	}
+	plainText := "JBSWY3DPEHPK3PXP"
+	cipherText, err := EncryptWithAssociatedData(key, plainText, "user_1")
+	if err != nil {
+		t.Fatalf("failed to encrypt: %s - %s", err.GetErrorCode(), err.Error())
+	}
+	decrypted, err := DecryptWithAssociatedData(key, cipherText, "user_1")
+	if err != nil {
+		t.Fatalf("failed to decrypt: %s - %s", err.GetErrorCode(), err.Error())
+	}
+	if decrypted != plainText {
+		t.Errorf("decrypted text does not match: got %s - expected %s", decrypted, plainText)
+	}
+	_, err = DecryptWithAssociatedData(key, cipherText, "user_2")
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeDecryptionFailed {
+		t.Errorf("expected decrypting with different associated data to fail with %s got %v", coreerrors.ErrCodeDecryptionFailed, err)
+	}
+	_, err = Decrypt(key, cipherText)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeDecryptionFailed {
+		t.Errorf("expected decrypting without the associated data to fail with %s got %v", coreerrors.ErrCodeDecryptionFailed, err)
+	}
+}
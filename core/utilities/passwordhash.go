@@ -0,0 +1,369 @@
+package utilities
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/calvine/goauth/core"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/richerror/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	passwordHashSaltLength int = 16
+	passwordHashKeyLength  int = 32
+
+	// defaults follow https://cheatsheetseries.owasp.org/cheatsheets/Password_Storage_Cheat_Sheet.html
+	defaultArgon2idMemory      uint32 = 19 * 1024
+	defaultArgon2idIterations  uint32 = 2
+	defaultArgon2idParallelism uint8  = 1
+	defaultScryptLogN          int    = 15
+	defaultScryptR             int    = 8
+	defaultScryptP             int    = 1
+	defaultPBKDF2Iterations    int    = 600000
+)
+
+// PasswordHasher hashes and verifies passwords with a single algorithm.
+type PasswordHasher interface {
+	// Algorithm is the identifier of the algorithm at the start of the hashes the hasher creates.
+	Algorithm() string
+	Hash(password string) (string, errors.RichError)
+	// Verify checks the password against the hash. outdated is true when the hash was created with different parameters than the hasher now uses.
+	Verify(hash, password, assetID string) (match bool, outdated bool, err errors.RichError)
+}
+
+type PasswordHashOptions struct {
+	// Algorithm is used for new hashes, and existing hashes from any other algorithm are upgraded to it when the password is next verified. Defaults to argon2id.
+	Algorithm  string
+	BcryptCost int
+	// Argon2idMemory is in KiB.
+	Argon2idMemory      uint32
+	Argon2idIterations  uint32
+	Argon2idParallelism uint8
+	// ScryptLogN is the base 2 log of the scrypt N parameter.
+	ScryptLogN       int
+	ScryptR          int
+	ScryptP          int
+	PBKDF2Iterations int
+}
+
+// PasswordHashers verifies passwords hashed with any supported algorithm, and creates new hashes with the configured algorithm.
+type PasswordHashers struct {
+	preferred PasswordHasher
+	hashers   map[string]PasswordHasher
+}
+
+// NewPasswordHashers creates a password hasher for each supported algorithm. Zero values in the options are replaced with defaults.
+func NewPasswordHashers(options PasswordHashOptions) (*PasswordHashers, errors.RichError) {
+	if options.Algorithm == "" {
+		options.Algorithm = core.PASSWORD_HASH_ALGORITHM_ARGON2ID
+	}
+	if options.BcryptCost == 0 {
+		options.BcryptCost = bcrypt.DefaultCost
+	}
+	if options.Argon2idMemory == 0 {
+		options.Argon2idMemory = defaultArgon2idMemory
+	}
+	if options.Argon2idIterations == 0 {
+		options.Argon2idIterations = defaultArgon2idIterations
+	}
+	if options.Argon2idParallelism == 0 {
+		options.Argon2idParallelism = defaultArgon2idParallelism
+	}
+	if options.ScryptLogN == 0 {
+		options.ScryptLogN = defaultScryptLogN
+	}
+	if options.ScryptR == 0 {
+		options.ScryptR = defaultScryptR
+	}
+	if options.ScryptP == 0 {
+		options.ScryptP = defaultScryptP
+	}
+	if options.PBKDF2Iterations == 0 {
+		options.PBKDF2Iterations = defaultPBKDF2Iterations
+	}
+	fields := make(map[string]interface{})
+	if options.BcryptCost < bcrypt.MinCost || options.BcryptCost > bcrypt.MaxCost {
+		fields["BcryptCost"] = fmt.Sprintf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if options.Argon2idMemory < 8*uint32(options.Argon2idParallelism) {
+		fields["Argon2idMemory"] = "argon2id memory must be at least 8 KiB per thread of parallelism"
+	}
+	if options.ScryptLogN <= 1 || options.ScryptLogN >= 63 {
+		fields["ScryptLogN"] = "scrypt log N must be between 2 and 62"
+	}
+	if options.ScryptR < 0 || options.ScryptP < 0 || uint64(options.ScryptR)*uint64(options.ScryptP) >= 1<<30 {
+		fields["ScryptR"] = "scrypt r * p must be less than 2^30"
+	}
+	if options.PBKDF2Iterations < 0 {
+		fields["PBKDF2Iterations"] = "pbkdf2 iterations cannot be negative"
+	}
+	hashers := []PasswordHasher{
+		bcryptPasswordHasher{cost: options.BcryptCost},
+		argon2idPasswordHasher{memory: options.Argon2idMemory, iterations: options.Argon2idIterations, parallelism: options.Argon2idParallelism},
+		scryptPasswordHasher{logN: options.ScryptLogN, r: options.ScryptR, p: options.ScryptP},
+		pbkdf2PasswordHasher{iterations: options.PBKDF2Iterations},
+	}
+	passwordHashers := PasswordHashers{
+		hashers: make(map[string]PasswordHasher, len(hashers)),
+	}
+	for _, hasher := range hashers {
+		passwordHashers.hashers[hasher.Algorithm()] = hasher
+	}
+	preferred, ok := passwordHashers.hashers[options.Algorithm]
+	if !ok {
+		fields["Algorithm"] = fmt.Sprintf("password hash algorithm is not supported: %s", options.Algorithm)
+	}
+	if len(fields) > 0 {
+		return nil, coreerrors.NewInvalidPasswordHashOptionsError(fields, true)
+	}
+	passwordHashers.preferred = preferred
+	return &passwordHashers, nil
+}
+
+// DefaultPasswordHashers creates password hashers with the default options.
+func DefaultPasswordHashers() *PasswordHashers {
+	// the default options are always valid, so creating the hashers cannot fail.
+	passwordHashers, _ := NewPasswordHashers(PasswordHashOptions{})
+	return passwordHashers
+}
+
+// Hash hashes the password with the preferred algorithm.
+func (ph *PasswordHashers) Hash(password string) (string, errors.RichError) {
+	return ph.preferred.Hash(password)
+}
+
+// Verify checks the password against a hash from any supported algorithm. needsRehash is true when the password matches but the hash
+// was not created by the preferred algorithm with its current parameters, so the caller should replace it with a new hash.
+func (ph *PasswordHashers) Verify(hash, password, assetID string) (match bool, needsRehash bool, err errors.RichError) {
+	algorithm := GetPasswordHashAlgorithm(hash)
+	if algorithm == "" {
+		return false, false, coreerrors.NewMalformedPasswordHashError("", assetID, true)
+	}
+	hasher, ok := ph.hashers[algorithm]
+	if !ok {
+		return false, false, coreerrors.NewUnsupportedPasswordHashAlgorithmError(algorithm, assetID, true)
+	}
+	match, outdated, err := hasher.Verify(hash, password, assetID)
+	if err != nil || !match {
+		return false, false, err
+	}
+	return true, outdated || algorithm != ph.preferred.Algorithm(), nil
+}
+
+// GetPasswordHashAlgorithm returns the algorithm from the $id$ prefix of a hash, or an empty string if the hash has no prefix.
+// bcrypt hashes are in the modular crypt format with a version as the identifier, so each bcrypt version is reported as bcrypt.
+func GetPasswordHashAlgorithm(hash string) string {
+	if !strings.HasPrefix(hash, "$") {
+		return ""
+	}
+	end := strings.Index(hash[1:], "$")
+	if end <= 0 {
+		return ""
+	}
+	algorithm := hash[1 : end+1]
+	switch algorithm {
+	case "2", "2a", "2b", "2x", "2y":
+		return core.PASSWORD_HASH_ALGORITHM_BCRYPT
+	}
+	return algorithm
+}
+
+type bcryptPasswordHasher struct {
+	cost int
+}
+
+func (bcryptPasswordHasher) Algorithm() string {
+	return core.PASSWORD_HASH_ALGORITHM_BCRYPT
+}
+
+func (h bcryptPasswordHasher) Hash(password string) (string, errors.RichError) {
+	return BcryptHashString(password, h.cost)
+}
+
+func (h bcryptPasswordHasher) Verify(hash, password, assetID string) (bool, bool, errors.RichError) {
+	match, err := BcryptCompareStringAndHash(hash, password, assetID)
+	if err != nil || !match {
+		return false, false, err
+	}
+	cost, costErr := bcrypt.Cost([]byte(hash))
+	if costErr != nil {
+		return false, false, coreerrors.NewBcryptPasswordHashErrorError(assetID, costErr, true)
+	}
+	return true, cost != h.cost, nil
+}
+
+// argon2idPasswordHasher creates hashes like $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+type argon2idPasswordHasher struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+func (argon2idPasswordHasher) Algorithm() string {
+	return core.PASSWORD_HASH_ALGORITHM_ARGON2ID
+}
+
+func (h argon2idPasswordHasher) Hash(password string) (string, errors.RichError) {
+	salt, err := newPasswordHashSalt(h.Algorithm())
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memory, h.parallelism, uint32(passwordHashKeyLength))
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", h.memory, h.iterations, h.parallelism)
+	return formatPHCHash(h.Algorithm(), fmt.Sprintf("v=%d", argon2.Version), params, salt, key), nil
+}
+
+func (h argon2idPasswordHasher) Verify(hash, password, assetID string) (bool, bool, errors.RichError) {
+	parts, err := parsePHCHash(hash, h.Algorithm(), assetID, 6)
+	if err != nil {
+		return false, false, err
+	}
+	var version int
+	var memory, iterations uint32
+	var parallelism uint8
+	_, versionErr := fmt.Sscanf(parts.version, "v=%d", &version)
+	_, paramsErr := fmt.Sscanf(parts.params, "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism)
+	if versionErr != nil || paramsErr != nil || version != argon2.Version || iterations == 0 || parallelism == 0 {
+		return false, false, coreerrors.NewMalformedPasswordHashError(h.Algorithm(), assetID, true)
+	}
+	key := argon2.IDKey([]byte(password), parts.salt, iterations, memory, parallelism, uint32(len(parts.key)))
+	if subtle.ConstantTimeCompare(key, parts.key) != 1 {
+		return false, false, nil
+	}
+	return true, memory != h.memory || iterations != h.iterations || parallelism != h.parallelism, nil
+}
+
+// scryptPasswordHasher creates hashes like $scrypt$ln=15,r=8,p=1$<salt>$<hash>
+type scryptPasswordHasher struct {
+	logN int
+	r    int
+	p    int
+}
+
+func (scryptPasswordHasher) Algorithm() string {
+	return core.PASSWORD_HASH_ALGORITHM_SCRYPT
+}
+
+func (h scryptPasswordHasher) Hash(password string) (string, errors.RichError) {
+	salt, err := newPasswordHashSalt(h.Algorithm())
+	if err != nil {
+		return "", err
+	}
+	key, scryptErr := scrypt.Key([]byte(password), salt, 1<<h.logN, h.r, h.p, passwordHashKeyLength)
+	if scryptErr != nil {
+		return "", coreerrors.NewPasswordHashErrorError(h.Algorithm(), "", scryptErr, true)
+	}
+	params := fmt.Sprintf("ln=%d,r=%d,p=%d", h.logN, h.r, h.p)
+	return formatPHCHash(h.Algorithm(), "", params, salt, key), nil
+}
+
+func (h scryptPasswordHasher) Verify(hash, password, assetID string) (bool, bool, errors.RichError) {
+	parts, err := parsePHCHash(hash, h.Algorithm(), assetID, 5)
+	if err != nil {
+		return false, false, err
+	}
+	var logN, r, p int
+	_, paramsErr := fmt.Sscanf(parts.params, "ln=%d,r=%d,p=%d", &logN, &r, &p)
+	if paramsErr != nil || logN <= 1 || logN >= 63 {
+		return false, false, coreerrors.NewMalformedPasswordHashError(h.Algorithm(), assetID, true)
+	}
+	key, scryptErr := scrypt.Key([]byte(password), parts.salt, 1<<logN, r, p, len(parts.key))
+	if scryptErr != nil {
+		return false, false, coreerrors.NewPasswordHashErrorError(h.Algorithm(), assetID, scryptErr, true)
+	}
+	if subtle.ConstantTimeCompare(key, parts.key) != 1 {
+		return false, false, nil
+	}
+	return true, logN != h.logN || r != h.r || p != h.p, nil
+}
+
+// pbkdf2PasswordHasher creates hashes like $pbkdf2-sha256$i=600000$<salt>$<hash>
+type pbkdf2PasswordHasher struct {
+	iterations int
+}
+
+func (pbkdf2PasswordHasher) Algorithm() string {
+	return core.PASSWORD_HASH_ALGORITHM_PBKDF2_SHA256
+}
+
+func (h pbkdf2PasswordHasher) Hash(password string) (string, errors.RichError) {
+	salt, err := newPasswordHashSalt(h.Algorithm())
+	if err != nil {
+		return "", err
+	}
+	key := pbkdf2.Key([]byte(password), salt, h.iterations, passwordHashKeyLength, sha256.New)
+	return formatPHCHash(h.Algorithm(), "", fmt.Sprintf("i=%d", h.iterations), salt, key), nil
+}
+
+func (h pbkdf2PasswordHasher) Verify(hash, password, assetID string) (bool, bool, errors.RichError) {
+	parts, err := parsePHCHash(hash, h.Algorithm(), assetID, 5)
+	if err != nil {
+		return false, false, err
+	}
+	var iterations int
+	_, paramsErr := fmt.Sscanf(parts.params, "i=%d", &iterations)
+	if paramsErr != nil || iterations <= 0 {
+		return false, false, coreerrors.NewMalformedPasswordHashError(h.Algorithm(), assetID, true)
+	}
+	key := pbkdf2.Key([]byte(password), parts.salt, iterations, len(parts.key), sha256.New)
+	if subtle.ConstantTimeCompare(key, parts.key) != 1 {
+		return false, false, nil
+	}
+	return true, iterations != h.iterations, nil
+}
+
+type phcHash struct {
+	version string
+	params  string
+	salt    []byte
+	key     []byte
+}
+
+// formatPHCHash formats a hash as $<algorithm>[$<version>]$<params>$<salt>$<hash> with the salt and hash in unpadded base64.
+func formatPHCHash(algorithm, version, params string, salt, key []byte) string {
+	fields := []string{"", algorithm}
+	if version != "" {
+		fields = append(fields, version)
+	}
+	fields = append(fields, params, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	return strings.Join(fields, "$")
+}
+
+// parsePHCHash splits a hash created by formatPHCHash. fieldCount is the number of $ separated fields including the empty one before the first $.
+func parsePHCHash(hash, algorithm, assetID string, fieldCount int) (phcHash, errors.RichError) {
+	fields := strings.Split(hash, "$")
+	if len(fields) != fieldCount || fields[1] != algorithm {
+		return phcHash{}, coreerrors.NewMalformedPasswordHashError(algorithm, assetID, true)
+	}
+	var parts phcHash
+	if fieldCount == 6 {
+		parts.version = fields[2]
+	}
+	parts.params = fields[fieldCount-3]
+	salt, saltErr := base64.RawStdEncoding.DecodeString(fields[fieldCount-2])
+	key, keyErr := base64.RawStdEncoding.DecodeString(fields[fieldCount-1])
+	if saltErr != nil || keyErr != nil || len(key) == 0 {
+		return phcHash{}, coreerrors.NewMalformedPasswordHashError(algorithm, assetID, true)
+	}
+	parts.salt = salt
+	parts.key = key
+	return parts, nil
+}
+
+func newPasswordHashSalt(algorithm string) ([]byte, errors.RichError) {
+	salt := make([]byte, passwordHashSaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, coreerrors.NewPasswordHashErrorError(algorithm, "", err, true)
+	}
+	return salt, nil
+}
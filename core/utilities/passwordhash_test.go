@@ -0,0 +1,201 @@
+package utilities
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/calvine/goauth/core"
+	coreerrors "github.com/calvine/goauth/core/errors"
+)
+
+const passwordHashTestPassword = "correct horse battery staple"
+
+// low cost options keep the tests fast, the parameters do not change how the hashes are checked.
+func passwordHashTestOptions(algorithm string) PasswordHashOptions {
+	return PasswordHashOptions{
+		Algorithm:           algorithm,
+		BcryptCost:          4,
+		Argon2idMemory:      64,
+		Argon2idIterations:  1,
+		Argon2idParallelism: 1,
+		ScryptLogN:          4,
+		ScryptR:             8,
+		ScryptP:             1,
+		PBKDF2Iterations:    10,
+	}
+}
+
+func TestNewPasswordHashers(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Options           PasswordHashOptions
+		ExpectedErrorCode string
+	}{
+		{
+			Name:    "Test default options",
+			Options: PasswordHashOptions{},
+		}, {
+			Name:              "Test unsupported algorithm",
+			Options:           PasswordHashOptions{Algorithm: "md5"},
+			ExpectedErrorCode: coreerrors.ErrCodeInvalidPasswordHashOptions,
+		}, {
+			Name:              "Test bcrypt cost too high",
+			Options:           PasswordHashOptions{BcryptCost: 40},
+			ExpectedErrorCode: coreerrors.ErrCodeInvalidPasswordHashOptions,
+		}, {
+			Name:              "Test scrypt log N too high",
+			Options:           PasswordHashOptions{ScryptLogN: 64},
+			ExpectedErrorCode: coreerrors.ErrCodeInvalidPasswordHashOptions,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := NewPasswordHashers(test.Options)
+			if test.ExpectedErrorCode == "" && err != nil {
+				t.Errorf("unexpected error: %s - %s", err.GetErrorCode(), err.Error())
+			} else if test.ExpectedErrorCode != "" && (err == nil || err.GetErrorCode() != test.ExpectedErrorCode) {
+				t.Errorf("expected error code %s got %v", test.ExpectedErrorCode, err)
+			}
+		})
+	}
+}
+
+func TestPasswordHashersHashAndVerify(t *testing.T) {
+	tests := []struct {
+		Algorithm      string
+		ExpectedPrefix string
+	}{
+		{Algorithm: core.PASSWORD_HASH_ALGORITHM_ARGON2ID, ExpectedPrefix: "$argon2id$v=19$m=64,t=1,p=1$"},
+		{Algorithm: core.PASSWORD_HASH_ALGORITHM_BCRYPT, ExpectedPrefix: "$2a$04$"},
+		{Algorithm: core.PASSWORD_HASH_ALGORITHM_SCRYPT, ExpectedPrefix: "$scrypt$ln=4,r=8,p=1$"},
+		{Algorithm: core.PASSWORD_HASH_ALGORITHM_PBKDF2_SHA256, ExpectedPrefix: "$pbkdf2-sha256$i=10$"},
+	}
+	for _, test := range tests {
+		t.Run(test.Algorithm, func(t *testing.T) {
+			passwordHashers, err := NewPasswordHashers(passwordHashTestOptions(test.Algorithm))
+			if err != nil {
+				t.Fatalf("failed to create password hashers: %s - %s", err.GetErrorCode(), err.Error())
+			}
+			hash, err := passwordHashers.Hash(passwordHashTestPassword)
+			if err != nil {
+				t.Fatalf("failed to hash password: %s - %s", err.GetErrorCode(), err.Error())
+			}
+			if !strings.HasPrefix(hash, test.ExpectedPrefix) {
+				t.Errorf("hash is not in the expected format: got %s - expected prefix %s", hash, test.ExpectedPrefix)
+			}
+			if algorithm := GetPasswordHashAlgorithm(hash); algorithm != test.Algorithm {
+				t.Errorf("wrong algorithm found for hash: got %s - expected %s", algorithm, test.Algorithm)
+			}
+			otherHash, _ := passwordHashers.Hash(passwordHashTestPassword)
+			if hash == otherHash {
+				t.Error("hashes of the same password should use different salts")
+			}
+			match, needsRehash, err := passwordHashers.Verify(hash, passwordHashTestPassword, "")
+			if err != nil {
+				t.Fatalf("failed to verify password: %s - %s", err.GetErrorCode(), err.Error())
+			}
+			if !match {
+				t.Error("password should match its hash")
+			}
+			if needsRehash {
+				t.Error("a hash made with the current options should not need a rehash")
+			}
+			match, _, err = passwordHashers.Verify(hash, "wrong password", "")
+			if err != nil {
+				t.Fatalf("failed to verify password: %s - %s", err.GetErrorCode(), err.Error())
+			}
+			if match {
+				t.Error("wrong password should not match the hash")
+			}
+		})
+	}
+}
+
+func TestPasswordHashersNeedsRehash(t *testing.T) {
+	argon2idHashers, _ := NewPasswordHashers(passwordHashTestOptions(core.PASSWORD_HASH_ALGORITHM_ARGON2ID))
+	bcryptHashers, _ := NewPasswordHashers(passwordHashTestOptions(core.PASSWORD_HASH_ALGORITHM_BCRYPT))
+	strongerOptions := passwordHashTestOptions(core.PASSWORD_HASH_ALGORITHM_ARGON2ID)
+	strongerOptions.Argon2idIterations = 2
+	strongerArgon2idHashers, _ := NewPasswordHashers(strongerOptions)
+	bcryptHash, _ := bcryptHashers.Hash(passwordHashTestPassword)
+	argon2idHash, _ := argon2idHashers.Hash(passwordHashTestPassword)
+	tests := []struct {
+		Name                string
+		PasswordHashers     *PasswordHashers
+		Hash                string
+		ExpectedNeedsRehash bool
+	}{
+		{
+			Name:                "Test hash from another algorithm",
+			PasswordHashers:     argon2idHashers,
+			Hash:                bcryptHash,
+			ExpectedNeedsRehash: true,
+		}, {
+			Name:                "Test hash with outdated parameters",
+			PasswordHashers:     strongerArgon2idHashers,
+			Hash:                argon2idHash,
+			ExpectedNeedsRehash: true,
+		}, {
+			Name:                "Test current hash",
+			PasswordHashers:     argon2idHashers,
+			Hash:                argon2idHash,
+			ExpectedNeedsRehash: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			match, needsRehash, err := test.PasswordHashers.Verify(test.Hash, passwordHashTestPassword, "")
+			if err != nil {
+				t.Fatalf("failed to verify password: %s - %s", err.GetErrorCode(), err.Error())
+			}
+			if !match {
+				t.Error("password should match its hash")
+			}
+			if needsRehash != test.ExpectedNeedsRehash {
+				t.Errorf("wrong rehash result: got %t - expected %t", needsRehash, test.ExpectedNeedsRehash)
+			}
+		})
+	}
+}
+
+func TestPasswordHashersVerifyInvalidHash(t *testing.T) {
+	passwordHashers, _ := NewPasswordHashers(passwordHashTestOptions(core.PASSWORD_HASH_ALGORITHM_ARGON2ID))
+	tests := []struct {
+		Name              string
+		Hash              string
+		ExpectedErrorCode string
+	}{
+		{
+			Name:              "Test empty hash",
+			Hash:              "",
+			ExpectedErrorCode: coreerrors.ErrCodeMalformedPasswordHash,
+		}, {
+			Name:              "Test unsupported algorithm",
+			Hash:              "$md5$salt$hash",
+			ExpectedErrorCode: coreerrors.ErrCodeUnsupportedPasswordHashAlgorithm,
+		}, {
+			Name:              "Test missing fields",
+			Hash:              "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ",
+			ExpectedErrorCode: coreerrors.ErrCodeMalformedPasswordHash,
+		}, {
+			Name:              "Test bad parameters",
+			Hash:              "$pbkdf2-sha256$rounds=10$c2FsdHNhbHQ$aGFzaGhhc2g",
+			ExpectedErrorCode: coreerrors.ErrCodeMalformedPasswordHash,
+		}, {
+			Name:              "Test bad base64",
+			Hash:              "$scrypt$ln=4,r=8,p=1$not base64!$aGFzaGhhc2g",
+			ExpectedErrorCode: coreerrors.ErrCodeMalformedPasswordHash,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			match, _, err := passwordHashers.Verify(test.Hash, passwordHashTestPassword, "")
+			if match {
+				t.Error("invalid hash should not match")
+			}
+			if err == nil || err.GetErrorCode() != test.ExpectedErrorCode {
+				t.Errorf("expected error code %s got %v", test.ExpectedErrorCode, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package utilities
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+
+	"github.com/calvine/goauth/core"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/richerror/errors"
+)
+
+const (
+	pkceMinLength = 43
+	pkceMaxLength = 128
+)
+
+// IsValidPKCEValue checks that a code verifier or code challenge meets the requirements in https://datatracker.ietf.org/doc/html/rfc7636#section-4.1
+func IsValidPKCEValue(value string) bool {
+	length := len(value)
+	if length < pkceMinLength || length > pkceMaxLength {
+		return false
+	}
+	for _, c := range value {
+		isUnreserved := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '.' || c == '_' || c == '~'
+		if !isUnreserved {
+			return false
+		}
+	}
+	return true
+}
+
+// ComputePKCECodeChallenge computes the code challenge for a code verifier using the given code challenge method.
+func ComputePKCECodeChallenge(codeVerifier, codeChallengeMethod string) (string, errors.RichError) {
+	switch codeChallengeMethod {
+	case core.PKCE_CODE_CHALLENGE_METHOD_S256:
+		hash := sha256.Sum256([]byte(codeVerifier))
+		return base64.RawURLEncoding.EncodeToString(hash[:]), nil
+	case core.PKCE_CODE_CHALLENGE_METHOD_PLAIN:
+		return codeVerifier, nil
+	default:
+		return "", coreerrors.NewUnsupportedCodeChallengeMethodError(codeChallengeMethod, true)
+	}
+}
+
+// VerifyPKCECodeVerifier checks that the code verifier matches the code challenge provided in the authorization request.
+func VerifyPKCECodeVerifier(codeVerifier, codeChallenge, codeChallengeMethod string) bool {
+	if !IsValidPKCEValue(codeVerifier) {
+		return false
+	}
+	computedCodeChallenge, err := ComputePKCECodeChallenge(codeVerifier, codeChallengeMethod)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(computedCodeChallenge), []byte(codeChallenge)) == 1
+}
@@ -0,0 +1,111 @@
+package utilities
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/calvine/goauth/core"
+)
+
+// values taken from https://datatracker.ietf.org/doc/html/rfc7636#appendix-B
+const (
+	pkceTestCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	pkceTestCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestIsValidPKCEValue(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Value    string
+		Expected bool
+	}{
+		{
+			Name:     "Test valid value",
+			Value:    pkceTestCodeVerifier,
+			Expected: true,
+		}, {
+			Name:     "Test value too short",
+			Value:    strings.Repeat("a", 42),
+			Expected: false,
+		}, {
+			Name:     "Test value too long",
+			Value:    strings.Repeat("a", 129),
+			Expected: false,
+		}, {
+			Name:     "Test value with invalid character",
+			Value:    strings.Repeat("a", 42) + "+",
+			Expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		output := IsValidPKCEValue(test.Value)
+		if output != test.Expected {
+			t.Error(test.Name, "output did not match expected value", test.Expected, output)
+		}
+	}
+}
+
+func TestComputePKCECodeChallenge(t *testing.T) {
+	codeChallenge, err := ComputePKCECodeChallenge(pkceTestCodeVerifier, core.PKCE_CODE_CHALLENGE_METHOD_S256)
+	if err != nil {
+		t.Error("failed to compute S256 code challenge", err)
+	}
+	if codeChallenge != pkceTestCodeChallenge {
+		t.Error("S256 code challenge did not match expected value", pkceTestCodeChallenge, codeChallenge)
+	}
+	codeChallenge, err = ComputePKCECodeChallenge(pkceTestCodeVerifier, core.PKCE_CODE_CHALLENGE_METHOD_PLAIN)
+	if err != nil {
+		t.Error("failed to compute plain code challenge", err)
+	}
+	if codeChallenge != pkceTestCodeVerifier {
+		t.Error("plain code challenge did not match expected value", pkceTestCodeVerifier, codeChallenge)
+	}
+	_, err = ComputePKCECodeChallenge(pkceTestCodeVerifier, "S512")
+	if err == nil {
+		t.Error("expected error for unsupported code challenge method")
+	}
+}
+
+func TestVerifyPKCECodeVerifier(t *testing.T) {
+	tests := []struct {
+		Name                string
+		CodeVerifier        string
+		CodeChallenge       string
+		CodeChallengeMethod string
+		Expected            bool
+	}{
+		{
+			Name:                "Test S256 match",
+			CodeVerifier:        pkceTestCodeVerifier,
+			CodeChallenge:       pkceTestCodeChallenge,
+			CodeChallengeMethod: core.PKCE_CODE_CHALLENGE_METHOD_S256,
+			Expected:            true,
+		}, {
+			Name:                "Test plain match",
+			CodeVerifier:        pkceTestCodeVerifier,
+			CodeChallenge:       pkceTestCodeVerifier,
+			CodeChallengeMethod: core.PKCE_CODE_CHALLENGE_METHOD_PLAIN,
+			Expected:            true,
+		}, {
+			Name:                "Test S256 mismatch",
+			CodeVerifier:        strings.Repeat("a", 43),
+			CodeChallenge:       pkceTestCodeChallenge,
+			CodeChallengeMethod: core.PKCE_CODE_CHALLENGE_METHOD_S256,
+			Expected:            false,
+		}, {
+			Name:                "Test invalid code verifier",
+			CodeVerifier:        "short",
+			CodeChallenge:       "short",
+			CodeChallengeMethod: core.PKCE_CODE_CHALLENGE_METHOD_PLAIN,
+			Expected:            false,
+		},
+	}
+
+	for _, test := range tests {
+		output := VerifyPKCECodeVerifier(test.CodeVerifier, test.CodeChallenge, test.CodeChallengeMethod)
+		if output != test.Expected {
+			t.Error(test.Name, "output did not match expected value", test.Expected, output)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package utilities
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/richerror/errors"
+)
+
+// TOTP codes are generated as described in https://datatracker.ietf.org/doc/html/rfc6238 with the defaults authenticator apps expect.
+const (
+	totpSecretLength int   = 20
+	totpDigits       int   = 6
+	totpPeriod       int64 = 30
+	// totpSkew is the number of time steps before and after the current one that codes are accepted for, to allow for clock drift.
+	totpSkew int64 = 1
+)
+
+var totpSecretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret generates a random base32 encoded TOTP secret.
+func NewTOTPSecret() (string, errors.RichError) {
+	secret := make([]byte, totpSecretLength)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", coreerrors.NewGenerateTOTPSecretFailedError(err, true)
+	}
+	return totpSecretEncoding.EncodeToString(secret), nil
+}
+
+// GetTOTPProvisioningURI builds the otpauth uri authenticator apps use to add an account, usually by scanning it as a QR code.
+// The format is described in https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func GetTOTPProvisioningURI(secret, issuer, accountName string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, query.Encode())
+}
+
+// GetTOTPTimeStep returns the time step a TOTP code is generated for at the given time.
+func GetTOTPTimeStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// GenerateTOTPCode generates the TOTP code for the secret at the given time step.
+func GenerateTOTPCode(secret string, timeStep int64) (string, errors.RichError) {
+	key, err := totpSecretEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", coreerrors.NewMalformedTOTPSecretError(true)
+	}
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(timeStep))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+	// dynamic truncation from https://datatracker.ietf.org/doc/html/rfc4226#section-5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulus := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%modulus), nil
+}
+
+// ValidateTOTPCode checks the code against the time steps around the given time. Codes for time steps at or before lastUsedTimeStep are rejected so a code cannot be used twice.
+// The time step the code was generated for is returned so it can be stored as the new lastUsedTimeStep.
+func ValidateTOTPCode(secret, code string, t time.Time, lastUsedTimeStep int64) (int64, bool, errors.RichError) {
+	if len(code) != totpDigits {
+		return 0, false, nil
+	}
+	currentTimeStep := GetTOTPTimeStep(t)
+	for timeStep := currentTimeStep - totpSkew; timeStep <= currentTimeStep+totpSkew; timeStep++ {
+		if timeStep <= lastUsedTimeStep {
+			continue
+		}
+		expectedCode, err := GenerateTOTPCode(secret, timeStep)
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expectedCode), []byte(code)) == 1 {
+			return timeStep, true, nil
+		}
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,110 @@
+package utilities
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// the SHA1 secret from https://datatracker.ietf.org/doc/html/rfc6238#appendix-B base32 encoded
+var totpTestSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateTOTPCode(t *testing.T) {
+	// the expected codes are the last 6 digits of the 8 digit codes in the RFC 6238 test vectors
+	tests := []struct {
+		Name         string
+		Time         int64
+		ExpectedCode string
+	}{
+		{Name: "Test time 59", Time: 59, ExpectedCode: "287082"},
+		{Name: "Test time 1111111109", Time: 1111111109, ExpectedCode: "081804"},
+		{Name: "Test time 1111111111", Time: 1111111111, ExpectedCode: "050471"},
+		{Name: "Test time 1234567890", Time: 1234567890, ExpectedCode: "005924"},
+		{Name: "Test time 2000000000", Time: 2000000000, ExpectedCode: "279037"},
+		{Name: "Test time 20000000000", Time: 20000000000, ExpectedCode: "353130"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			code, err := GenerateTOTPCode(totpTestSecret, GetTOTPTimeStep(time.Unix(test.Time, 0)))
+			if err != nil {
+				t.Fatalf("failed to generate totp code: %s - %s", err.GetErrorCode(), err.Error())
+			}
+			if code != test.ExpectedCode {
+				t.Errorf("wrong totp code: got %s - expected %s", code, test.ExpectedCode)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	currentTimeStep := GetTOTPTimeStep(now)
+	currentCode, _ := GenerateTOTPCode(totpTestSecret, currentTimeStep)
+	previousCode, _ := GenerateTOTPCode(totpTestSecret, currentTimeStep-1)
+	expiredCode, _ := GenerateTOTPCode(totpTestSecret, currentTimeStep-2)
+	tests := []struct {
+		Name             string
+		Code             string
+		LastUsedTimeStep int64
+		ExpectedValid    bool
+		ExpectedTimeStep int64
+	}{
+		{
+			Name:             "Test current code",
+			Code:             currentCode,
+			ExpectedValid:    true,
+			ExpectedTimeStep: currentTimeStep,
+		}, {
+			Name:             "Test previous code within clock drift",
+			Code:             previousCode,
+			ExpectedValid:    true,
+			ExpectedTimeStep: currentTimeStep - 1,
+		}, {
+			Name:          "Test expired code",
+			Code:          expiredCode,
+			ExpectedValid: false,
+		}, {
+			Name:             "Test code already used",
+			Code:             currentCode,
+			LastUsedTimeStep: currentTimeStep,
+			ExpectedValid:    false,
+		}, {
+			Name:          "Test wrong length code",
+			Code:          currentCode + "0",
+			ExpectedValid: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			timeStep, valid, err := ValidateTOTPCode(totpTestSecret, test.Code, now, test.LastUsedTimeStep)
+			if err != nil {
+				t.Fatalf("failed to validate totp code: %s - %s", err.GetErrorCode(), err.Error())
+			}
+			if valid != test.ExpectedValid {
+				t.Errorf("wrong validation result: got %t - expected %t", valid, test.ExpectedValid)
+			}
+			if valid && timeStep != test.ExpectedTimeStep {
+				t.Errorf("wrong time step: got %d - expected %d", timeStep, test.ExpectedTimeStep)
+			}
+		})
+	}
+}
+
+func TestGetTOTPProvisioningURI(t *testing.T) {
+	uri := GetTOTPProvisioningURI(totpTestSecret, "goauth", "jane@example.com")
+	parsedURI, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("provisioning uri could not be parsed: %s", err.Error())
+	}
+	if parsedURI.Scheme != "otpauth" || parsedURI.Host != "totp" {
+		t.Errorf("wrong provisioning uri type: %s", uri)
+	}
+	if parsedURI.Path != "/goauth:jane@example.com" {
+		t.Errorf("wrong provisioning uri label: got %s - expected /goauth:jane@example.com", parsedURI.Path)
+	}
+	query := parsedURI.Query()
+	if query.Get("secret") != totpTestSecret || query.Get("issuer") != "goauth" {
+		t.Errorf("wrong provisioning uri parameters: %s", uri)
+	}
+}
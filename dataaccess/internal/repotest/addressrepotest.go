@@ -1,9 +1,255 @@
 package repotest
 
 import (
+	"context"
 	"testing"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	repo "github.com/calvine/goauth/core/repositories"
+	"github.com/calvine/goauth/internal/testutils"
+)
+
+const (
+	addressRepoCreatedBy = "address repo tests"
+)
+
+var (
+	newPrimaryAddress     models.Address
+	newSecondaryAddress   models.Address
+	noMatchingUserAddress models.Address
+
+	nonExistantAddressID string
 )
 
+func setupAddressTestData(t *testing.T, testHarness RepoTestHarnessInput) {
+	newPrimaryAddress = models.NewAddress(initialTestUser.ID, "home", "1 Main Street", "", "Springfield", "IL", "62701", true)
+
+	newSecondaryAddress = models.NewAddress(initialTestUser.ID, "", "200 Office Park", "Suite 4", "Springfield", "IL", "62702", false)
+
+	noMatchingUserAddress = models.NewAddress(testHarness.IDGenerator(false), "", "3 Nowhere Road", "", "Springfield", "IL", "62703", false)
+
+	nonExistantAddressID = testHarness.IDGenerator(false)
+	nonExistantUserID = testHarness.IDGenerator(false)
+}
+
 func testAddressRepo(t *testing.T, testHarness RepoTestHarnessInput) {
-	t.Error("tests not implemented")
+	setupAddressTestData(t, testHarness)
+	t.Run("AddAddress", func(t *testing.T) {
+		_testAddAddress(t, *testHarness.AddressRepo)
+	})
+	t.Run("GetAddressByID", func(t *testing.T) {
+		_testGetAddressByID(t, *testHarness.AddressRepo)
+	})
+	t.Run("GetPrimaryAddressByUserID", func(t *testing.T) {
+		_testGetPrimaryAddressByUserID(t, *testHarness.AddressRepo)
+	})
+	t.Run("GetAddressesByUserID", func(t *testing.T) {
+		_testGetAddressesByUserID(t, *testHarness.AddressRepo)
+	})
+	t.Run("UpdateAddress", func(t *testing.T) {
+		_testUpdateAddress(t, *testHarness.AddressRepo)
+	})
+}
+
+func _testAddAddress(t *testing.T, addressRepo repo.AddressRepo) {
+	type testCase struct {
+		name              string
+		addressToAdd      *models.Address
+		expectedErrorCode string
+	}
+	testCases := []testCase{
+		{
+			name:         "GIVEN primary address to insert EXPECT address to be inserted",
+			addressToAdd: &newPrimaryAddress,
+		},
+		{
+			name:         "GIVEN secondary address to insert EXPECT address to be inserted",
+			addressToAdd: &newSecondaryAddress,
+		},
+		{
+			name:              "GIVEN address with non existant user id EXPECT no user found error code",
+			addressToAdd:      &noMatchingUserAddress,
+			expectedErrorCode: coreerrors.ErrCodeNoUserFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := addressRepo.AddAddress(context.TODO(), tc.addressToAdd, addressRepoCreatedBy)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			} else {
+				if tc.addressToAdd.ID == "" {
+					t.Error("\taddress id is blank")
+				}
+				if tc.addressToAdd.CreatedByID != addressRepoCreatedBy {
+					t.Errorf("\taddress created by id not set: got - %s expected - %s", tc.addressToAdd.CreatedByID, addressRepoCreatedBy)
+				}
+			}
+		})
+	}
+}
+
+func _testGetAddressByID(t *testing.T, addressRepo repo.AddressRepo) {
+	type testCase struct {
+		name              string
+		addressID         string
+		expectedAddress   models.Address
+		expectedErrorCode string
+	}
+	testCases := []testCase{
+		{
+			name:            "GIVEN an existing address id EXPECT the address to be returned",
+			addressID:       newSecondaryAddress.ID,
+			expectedAddress: newSecondaryAddress,
+		},
+		{
+			name:              "GIVEN a non existant address id EXPECT no address found error code",
+			addressID:         nonExistantAddressID,
+			expectedErrorCode: coreerrors.ErrCodeNoAddressFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			address, err := addressRepo.GetAddressByID(context.TODO(), tc.addressID)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			} else {
+				if address.ID != tc.expectedAddress.ID {
+					t.Errorf("\taddress id not expected: got - %s expected - %s", address.ID, tc.expectedAddress.ID)
+				}
+				if address.UserID != tc.expectedAddress.UserID {
+					t.Errorf("\taddress user id not expected: got - %s expected - %s", address.UserID, tc.expectedAddress.UserID)
+				}
+				if address.Line1 != tc.expectedAddress.Line1 {
+					t.Errorf("\taddress line1 not expected: got - %s expected - %s", address.Line1, tc.expectedAddress.Line1)
+				}
+				if address.Line2 != tc.expectedAddress.Line2 {
+					t.Errorf("\taddress line2 not expected: got - %v expected - %v", address.Line2, tc.expectedAddress.Line2)
+				}
+			}
+		})
+	}
+}
+
+func _testGetPrimaryAddressByUserID(t *testing.T, addressRepo repo.AddressRepo) {
+	type testCase struct {
+		name              string
+		userID            string
+		expectedAddressID string
+		expectedErrorCode string
+	}
+	testCases := []testCase{
+		{
+			name:              "GIVEN a user with a primary address EXPECT the primary address to be returned",
+			userID:            initialTestUser.ID,
+			expectedAddressID: newPrimaryAddress.ID,
+		},
+		{
+			name:              "GIVEN a non existant user id EXPECT no address found error code",
+			userID:            nonExistantUserID,
+			expectedErrorCode: coreerrors.ErrCodeNoAddressFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			address, err := addressRepo.GetPrimaryAddressByUserID(context.TODO(), tc.userID)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			} else {
+				if address.ID != tc.expectedAddressID {
+					t.Errorf("\taddress id not expected: got - %s expected - %s", address.ID, tc.expectedAddressID)
+				}
+				if !address.IsPrimary {
+					t.Error("\taddress is not primary")
+				}
+			}
+		})
+	}
+}
+
+func _testGetAddressesByUserID(t *testing.T, addressRepo repo.AddressRepo) {
+	type testCase struct {
+		name                 string
+		userID               string
+		expectedNumAddresses int
+		expectedErrorCode    string
+	}
+	testCases := []testCase{
+		{
+			name:                 "GIVEN a user with addresses EXPECT all of their addresses to be returned",
+			userID:               initialTestUser.ID,
+			expectedNumAddresses: 2,
+		},
+		{
+			name:              "GIVEN a non existant user id EXPECT no user found error code",
+			userID:            nonExistantUserID,
+			expectedErrorCode: coreerrors.ErrCodeNoUserFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addresses, err := addressRepo.GetAddressesByUserID(context.TODO(), tc.userID)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			} else if len(addresses) != tc.expectedNumAddresses {
+				t.Errorf("\tnumber of addresses not expected: got - %d expected - %d", len(addresses), tc.expectedNumAddresses)
+			}
+		})
+	}
+}
+
+func _testUpdateAddress(t *testing.T, addressRepo repo.AddressRepo) {
+	type testCase struct {
+		name              string
+		addressToUpdate   *models.Address
+		newLine1          string
+		expectedErrorCode string
+	}
+	nonExistantAddress := newSecondaryAddress
+	nonExistantAddress.ID = nonExistantAddressID
+	testCases := []testCase{
+		{
+			name:            "GIVEN an existing address EXPECT the address to be updated",
+			addressToUpdate: &newSecondaryAddress,
+			newLine1:        "300 Office Park",
+		},
+		{
+			name:              "GIVEN a non existant address EXPECT no address found error code",
+			addressToUpdate:   &nonExistantAddress,
+			newLine1:          "400 Office Park",
+			expectedErrorCode: coreerrors.ErrCodeNoAddressFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.addressToUpdate.Line1 = tc.newLine1
+			err := addressRepo.UpdateAddress(context.TODO(), tc.addressToUpdate, addressRepoCreatedBy)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			} else {
+				if !tc.addressToUpdate.ModifiedByID.HasValue || tc.addressToUpdate.ModifiedByID.Value != addressRepoCreatedBy {
+					t.Errorf("\taddress modified by id not set: got - %v", tc.addressToUpdate.ModifiedByID)
+				}
+				retreivedAddress, err := addressRepo.GetAddressByID(context.TODO(), tc.addressToUpdate.ID)
+				if err != nil {
+					t.Log(err.Error())
+					t.Fatalf("\tfailed to retreive updated address: %s", err.GetErrorCode())
+				}
+				if retreivedAddress.Line1 != tc.newLine1 {
+					t.Errorf("\taddress line1 not updated: got - %s expected - %s", retreivedAddress.Line1, tc.newLine1)
+				}
+			}
+		})
+	}
 }
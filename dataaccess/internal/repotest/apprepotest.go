@@ -2,10 +2,15 @@ package repotest
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
+	coreerrors "github.com/calvine/goauth/core/errors"
 	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/nullable"
 	repo "github.com/calvine/goauth/core/repositories"
+	"github.com/calvine/goauth/internal/testutils"
 	"github.com/calvine/richerror/errors"
 )
 
@@ -35,6 +40,9 @@ func testAppRepo(t *testing.T, testHarness RepoTestHarnessInput) {
 	t.Run("UpdateApp", func(t *testing.T) {
 		_testUpdateApp(t, *testHarness.AppRepo)
 	})
+	t.Run("ClientSecrets", func(t *testing.T) {
+		_testClientSecrets(t, *testHarness.AppRepo, testHarness.IDGenerator(false))
+	})
 	t.Run("DeleteApp", func(t *testing.T) {
 		_testDeleteApp(t, *testHarness.AppRepo)
 	})
@@ -88,7 +96,7 @@ func _testGetAppsByClientID(t *testing.T, appRepo repo.AppRepo) {
 }
 func _testAddApp(t *testing.T, appRepo repo.AppRepo) {
 	var err errors.RichError
-	anotherTestApp, _, err = models.NewApp("fake owner id", "Uber App", "https://uber.app/callback", "https://uber.app/assets/logo")
+	anotherTestApp, _, err = models.NewApp("fake owner id", "Uber App", []string{"https://uber.app/callback"}, "https://uber.app/assets/logo")
 	if err != nil {
 		t.Log(err.Error())
 		t.Errorf("failed to create app for test: %s", err.GetErrorCode())
@@ -104,8 +112,8 @@ func _testAddApp(t *testing.T, appRepo repo.AppRepo) {
 	if anotherTestApp.ClientID == "" {
 		t.Error(" app client id should not be empty")
 	}
-	if anotherTestApp.ClientSecretHash == "" {
-		t.Error(" app client secret hash should not be empty")
+	if len(anotherTestApp.ClientSecrets) == 0 {
+		t.Error(" app client secrets should not be empty")
 	}
 }
 func _testUpdateApp(t *testing.T, appRepo repo.AppRepo) {
@@ -124,6 +132,117 @@ func _testUpdateApp(t *testing.T, appRepo repo.AppRepo) {
 		t.Errorf("expected app name not correct: got: %s - expected: %s", app.Name, changedAppName)
 	}
 }
+func _testClientSecrets(t *testing.T, appRepo repo.AppRepo, nonExistantAppID string) {
+	initialClientSecretID := anotherTestApp.ClientSecrets[0].ID
+	addedClientSecret, _, err := models.NewClientSecret(nullable.NullableTime{})
+	if err != nil {
+		t.Fatalf("failed to create client secret for test: %s", err.GetErrorCode())
+	}
+	err = appRepo.AddClientSecret(context.TODO(), anotherTestApp.ID, addedClientSecret, appRepoCreatedByID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to add client secret in underlying data store: %s", err.GetErrorCode())
+	}
+	// anotherTestApp does not know about the added client secret, so updating it must not remove the client secret.
+	err = appRepo.UpdateApp(context.TODO(), &anotherTestApp, appRepoCreatedByID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("failed to update app in underlying data store: %s", err.GetErrorCode())
+	}
+	expiredClientSecret, _, err := models.NewClientSecret(nullable.NullableTime{})
+	if err != nil {
+		t.Fatalf("failed to create client secret for test: %s", err.GetErrorCode())
+	}
+	err = appRepo.AddClientSecret(context.TODO(), anotherTestApp.ID, expiredClientSecret, appRepoCreatedByID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to add client secret in underlying data store: %s", err.GetErrorCode())
+	}
+	expirationDate := nullable.NullableTime{HasValue: true, Value: time.Now().Add(-time.Hour)}
+	err = appRepo.SetClientSecretExpiration(context.TODO(), anotherTestApp.ID, expiredClientSecret.ID, expirationDate, appRepoCreatedByID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("failed to set client secret expiration in underlying data store: %s", err.GetErrorCode())
+	}
+	app, err := appRepo.GetAppByID(context.TODO(), anotherTestApp.ID)
+	if err != nil {
+		t.Fatalf("failed to retreive app from underlying app store for comparison")
+	}
+	if len(app.ClientSecrets) != 3 {
+		t.Fatalf("expected the app to have three client secrets: got %d", len(app.ClientSecrets))
+	}
+	if len(app.GetActiveClientSecrets(time.Now())) != 2 {
+		t.Errorf("expected the app to have two active client secrets: got %d", len(app.GetActiveClientSecrets(time.Now())))
+	}
+
+	type testCase struct {
+		name              string
+		appID             string
+		clientSecretID    string
+		expectedErrorCode string
+	}
+	testCases := []testCase{
+		{
+			name:              "GIVEN an app that does not exist EXPECT error no app found",
+			appID:             nonExistantAppID,
+			clientSecretID:    initialClientSecretID,
+			expectedErrorCode: coreerrors.ErrCodeNoAppFound,
+		},
+		{
+			name:              "GIVEN a client secret the app does not have EXPECT error client secret not found",
+			appID:             anotherTestApp.ID,
+			clientSecretID:    "not a client secret id",
+			expectedErrorCode: coreerrors.ErrCodeClientSecretNotFound,
+		},
+		{
+			name:           "GIVEN an expired client secret EXPECT success",
+			appID:          anotherTestApp.ID,
+			clientSecretID: expiredClientSecret.ID,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := appRepo.RevokeClientSecret(context.TODO(), tc.appID, tc.clientSecretID, time.Now(), appRepoCreatedByID)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			}
+		})
+	}
+
+	// two active client secrets revoked concurrently must leave one behind.
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	successCount := 0
+	for _, clientSecretID := range []string{initialClientSecretID, addedClientSecret.ID} {
+		wg.Add(1)
+		go func(clientSecretID string) {
+			defer wg.Done()
+			err := appRepo.RevokeClientSecret(context.TODO(), anotherTestApp.ID, clientSecretID, time.Now(), appRepoCreatedByID)
+			if err == nil {
+				lock.Lock()
+				successCount++
+				lock.Unlock()
+			} else if err.GetErrorCode() != coreerrors.ErrCodeLastActiveClientSecret {
+				t.Errorf("unexpected error revoking client secret: %s", err.GetErrorCode())
+			}
+		}(clientSecretID)
+	}
+	wg.Wait()
+	if successCount != 1 {
+		t.Errorf("only one of the last two active client secrets should be revoked: got %d", successCount)
+	}
+	app, err = appRepo.GetAppByID(context.TODO(), anotherTestApp.ID)
+	if err != nil {
+		t.Fatalf("failed to retreive app from underlying app store for comparison")
+	}
+	if len(app.ClientSecrets) != 1 {
+		t.Errorf("expected the app to have one client secret left: got %d", len(app.ClientSecrets))
+	}
+	anotherTestApp.ClientSecrets = app.ClientSecrets
+}
+
 func _testDeleteApp(t *testing.T, appRepo repo.AppRepo) {
 	err := appRepo.DeleteApp(context.TODO(), &anotherTestApp, appRepoCreatedByID)
 	if err != nil {
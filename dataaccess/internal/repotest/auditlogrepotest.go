@@ -1,9 +1,21 @@
 package repotest
 
 import (
+	"context"
 	"testing"
+
+	"github.com/calvine/goauth/core/models"
 )
 
 func testAuditLogRepo(t *testing.T, testHarness RepoTestHarnessInput) {
-	t.Error("tests not implemented")
+	t.Run("LogMessage", func(t *testing.T) {
+		auditLog := models.NewAuditLog(models.AuditLogCode_ClientSecretCreated, "audit log repo test message", models.AssetType_Application, initialTestApp.ID, map[string]interface{}{
+			"clientSecretId": "test client secret id",
+		})
+		err := (*testHarness.AuditLogRepo).LogMessage(context.TODO(), auditLog)
+		if err != nil {
+			t.Log(err.Error())
+			t.Errorf("failed to log audit message: %s", err.GetErrorCode())
+		}
+	})
 }
@@ -0,0 +1,142 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/calvine/goauth/core/models"
+	repo "github.com/calvine/goauth/core/repositories"
+)
+
+const (
+	consentRepoCreatedByID = "consent repo tests"
+
+	consentRepoTestAppID      = "consent_repo_test_app_id"
+	consentRepoTestOtherAppID = "consent_repo_test_other_app_id"
+)
+
+var (
+	consentRepoTestScopeNames = []string{"consent_scope_1", "consent_scope_2"}
+)
+
+func testConsentRepo(t *testing.T, testHarness RepoTestHarnessInput) {
+	t.Run("AddConsent", func(t *testing.T) {
+		_testAddConsent(t, *testHarness.ConsentRepo)
+	})
+	t.Run("AddExistingConsent", func(t *testing.T) {
+		_testAddExistingConsent(t, *testHarness.ConsentRepo)
+	})
+	t.Run("GetConsentsByUserIDAndAppID", func(t *testing.T) {
+		_testGetConsentsByUserIDAndAppID(t, *testHarness.ConsentRepo)
+	})
+	t.Run("DeleteAppDeletesConsents", func(t *testing.T) {
+		if testHarness.AppRepo == nil || !testHarness.AppRepoDeletesConsents {
+			t.Skip("app repo does not delete consents")
+		}
+		_testDeleteAppDeletesConsents(t, *testHarness.AppRepo, *testHarness.ConsentRepo)
+	})
+}
+
+func _testAddConsent(t *testing.T, consentRepo repo.ConsentRepo) {
+	for _, scopeName := range consentRepoTestScopeNames {
+		consent := models.NewConsent(initialTestUser.ID, consentRepoTestAppID, scopeName)
+		err := consentRepo.AddConsent(context.TODO(), &consent, consentRepoCreatedByID)
+		if err != nil {
+			t.Log(err.Error())
+			t.Errorf("failed to add consent for scope %s: %s", scopeName, err.GetErrorCode())
+		}
+		if consent.ID == "" {
+			t.Errorf("consent id was not populated for scope %s", scopeName)
+		}
+		if consent.AuditData.CreatedByID != consentRepoCreatedByID {
+			t.Errorf("consent created by id does not match expected value: got %s - expected %s", consent.AuditData.CreatedByID, consentRepoCreatedByID)
+		}
+	}
+	otherAppConsent := models.NewConsent(initialTestUser.ID, consentRepoTestOtherAppID, consentRepoTestScopeNames[0])
+	err := consentRepo.AddConsent(context.TODO(), &otherAppConsent, consentRepoCreatedByID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("failed to add consent for other app: %s", err.GetErrorCode())
+	}
+}
+
+func _testAddExistingConsent(t *testing.T, consentRepo repo.ConsentRepo) {
+	existingConsents, err := consentRepo.GetConsentsByUserIDAndAppID(context.TODO(), initialTestUser.ID, consentRepoTestAppID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get consents: %s", err.GetErrorCode())
+	}
+	if len(existingConsents) == 0 {
+		t.Fatal("expected consents to have been added before adding an existing consent")
+	}
+	existingConsent := existingConsents[0]
+	consent := models.NewConsent(existingConsent.UserID, existingConsent.AppID, existingConsent.ScopeName)
+	err = consentRepo.AddConsent(context.TODO(), &consent, "consent repo tests duplicate")
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("failed to add existing consent: %s", err.GetErrorCode())
+	}
+	if consent.ID != existingConsent.ID {
+		t.Errorf("consent id does not match existing consent id: got %s - expected %s", consent.ID, existingConsent.ID)
+	}
+	if consent.AuditData.CreatedByID != existingConsent.AuditData.CreatedByID {
+		t.Errorf("consent created by id does not match existing consent: got %s - expected %s", consent.AuditData.CreatedByID, existingConsent.AuditData.CreatedByID)
+	}
+	// the count of consents for the app is checked in _testGetConsentsByUserIDAndAppID which runs after this.
+}
+
+func _testGetConsentsByUserIDAndAppID(t *testing.T, consentRepo repo.ConsentRepo) {
+	consents, err := consentRepo.GetConsentsByUserIDAndAppID(context.TODO(), initialTestUser.ID, consentRepoTestAppID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("failed to get consents: %s", err.GetErrorCode())
+	}
+	if len(consents) != len(consentRepoTestScopeNames) {
+		t.Fatalf("number of consents does not match expected value: got %d - expected %d", len(consents), len(consentRepoTestScopeNames))
+	}
+	for _, consent := range consents {
+		if consent.UserID != initialTestUser.ID || consent.AppID != consentRepoTestAppID {
+			t.Errorf("consent does not belong to the expected user and app: %v", consent)
+		}
+	}
+	consents, err = consentRepo.GetConsentsByUserIDAndAppID(context.TODO(), "consent_repo_test_unknown_user_id", consentRepoTestAppID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("failed to get consents for user with no consents: %s", err.GetErrorCode())
+	}
+	if len(consents) != 0 {
+		t.Errorf("expected no consents for user with no consents but got %d", len(consents))
+	}
+}
+
+func _testDeleteAppDeletesConsents(t *testing.T, appRepo repo.AppRepo, consentRepo repo.ConsentRepo) {
+	app, _, err := models.NewApp("fake owner id", "Consent App", []string{"https://consent.app/callback"}, "https://consent.app/assets/logo")
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to create app for test: %s", err.GetErrorCode())
+	}
+	err = appRepo.AddApp(context.TODO(), &app, consentRepoCreatedByID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to add app to underlying data store: %s", err.GetErrorCode())
+	}
+	consent := models.NewConsent(initialTestUser.ID, app.ID, consentRepoTestScopeNames[0])
+	err = consentRepo.AddConsent(context.TODO(), &consent, consentRepoCreatedByID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to add consent: %s", err.GetErrorCode())
+	}
+	err = appRepo.DeleteApp(context.TODO(), &app, consentRepoCreatedByID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to delete app: %s", err.GetErrorCode())
+	}
+	consents, err := consentRepo.GetConsentsByUserIDAndAppID(context.TODO(), initialTestUser.ID, app.ID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to retreive consents: %s", err.GetErrorCode())
+	}
+	if len(consents) != 0 {
+		t.Errorf("consents for a deleted app should be deleted with it: got %d", len(consents))
+	}
+}
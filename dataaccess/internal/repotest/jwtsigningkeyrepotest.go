@@ -0,0 +1,122 @@
+package repotest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	repo "github.com/calvine/goauth/core/repositories"
+)
+
+func testJWTSigningKeyRepo(t *testing.T, testHarness RepoTestHarnessInput) {
+	t.Run("GetJWTSigningKeySet", func(t *testing.T) {
+		_testGetEmptyJWTSigningKeySet(t, *testHarness.JWTSigningKeyRepo)
+	})
+	t.Run("SaveJWTSigningKeySet", func(t *testing.T) {
+		_testSaveJWTSigningKeySet(t, *testHarness.JWTSigningKeyRepo)
+	})
+	t.Run("SaveJWTSigningKeySetConcurrently", func(t *testing.T) {
+		_testSaveJWTSigningKeySetConcurrently(t, *testHarness.JWTSigningKeyRepo)
+	})
+}
+
+func newTestJWTSigningKey(keyID, state string) models.JWTSigningKey {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	return models.JWTSigningKey{
+		KeyID:        keyID,
+		Algorithm:    "ES256",
+		State:        state,
+		EncryptedKey: "encrypted_" + keyID,
+		CreatedAt:    now,
+		ActivatedAt:  now,
+	}
+}
+
+func _testGetEmptyJWTSigningKeySet(t *testing.T, jwtSigningKeyRepo repo.JWTSigningKeyRepo) {
+	keySet, err := jwtSigningKeyRepo.GetJWTSigningKeySet(context.TODO())
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get jwt signing key set: %s", err.GetErrorCode())
+	}
+	if keySet.Version != 0 || len(keySet.Keys) != 0 {
+		t.Errorf("expected an empty key set with version 0 before any key set is stored: got %v", keySet)
+	}
+}
+
+func _testSaveJWTSigningKeySet(t *testing.T, jwtSigningKeyRepo repo.JWTSigningKeyRepo) {
+	keySet := models.JWTSigningKeySet{
+		Keys: []models.JWTSigningKey{newTestJWTSigningKey("jwt_key_1", "active")},
+	}
+	err := jwtSigningKeyRepo.SaveJWTSigningKeySet(context.TODO(), &keySet)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to save jwt signing key set: %s", err.GetErrorCode())
+	}
+	if keySet.Version != 1 {
+		t.Errorf("key set version does not match expected value: got %d - expected %d", keySet.Version, 1)
+	}
+	staleKeySet := models.JWTSigningKeySet{
+		Keys: []models.JWTSigningKey{newTestJWTSigningKey("jwt_key_stale", "active")},
+	}
+	err = jwtSigningKeyRepo.SaveJWTSigningKeySet(context.TODO(), &staleKeySet)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeJWTSigningKeySetConflict {
+		t.Errorf("expected conflict error saving a key set with a stale version: got %v", err)
+	}
+	keySet.Keys = append(keySet.Keys, newTestJWTSigningKey("jwt_key_2", "pending"))
+	err = jwtSigningKeyRepo.SaveJWTSigningKeySet(context.TODO(), &keySet)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to save jwt signing key set: %s", err.GetErrorCode())
+	}
+	storedKeySet, err := jwtSigningKeyRepo.GetJWTSigningKeySet(context.TODO())
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get jwt signing key set: %s", err.GetErrorCode())
+	}
+	if storedKeySet.Version != 2 {
+		t.Errorf("stored key set version does not match expected value: got %d - expected %d", storedKeySet.Version, 2)
+	}
+	if len(storedKeySet.Keys) != len(keySet.Keys) {
+		t.Fatalf("number of stored keys does not match expected value: got %d - expected %d", len(storedKeySet.Keys), len(keySet.Keys))
+	}
+	for i, key := range keySet.Keys {
+		storedKey := storedKeySet.Keys[i]
+		if storedKey.KeyID != key.KeyID || storedKey.State != key.State || storedKey.EncryptedKey != key.EncryptedKey || !storedKey.CreatedAt.Equal(key.CreatedAt) {
+			t.Errorf("stored key does not match expected value: got %v - expected %v", storedKey, key)
+		}
+	}
+}
+
+func _testSaveJWTSigningKeySetConcurrently(t *testing.T, jwtSigningKeyRepo repo.JWTSigningKeyRepo) {
+	keySet, err := jwtSigningKeyRepo.GetJWTSigningKeySet(context.TODO())
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get jwt signing key set: %s", err.GetErrorCode())
+	}
+	const numSavers = 10
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	numSaved := 0
+	for i := 0; i < numSavers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			keySetCopy := models.JWTSigningKeySet{Version: keySet.Version, Keys: append([]models.JWTSigningKey(nil), keySet.Keys...)}
+			err := jwtSigningKeyRepo.SaveJWTSigningKeySet(context.TODO(), &keySetCopy)
+			if err == nil {
+				lock.Lock()
+				numSaved++
+				lock.Unlock()
+			} else if err.GetErrorCode() != coreerrors.ErrCodeJWTSigningKeySetConflict {
+				t.Errorf("unexpected error saving jwt signing key set concurrently: %s", err.GetErrorCode())
+			}
+		}()
+	}
+	wg.Wait()
+	if numSaved != 1 {
+		t.Errorf("expected exactly one concurrent save of the same version to succeed: got %d", numSaved)
+	}
+}
@@ -1,9 +1,161 @@
 package repotest
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	repo "github.com/calvine/goauth/core/repositories"
+	"github.com/calvine/goauth/internal/testutils"
+)
+
+const (
+	profileRepoCreatedBy = "profile repo tests"
+)
+
+var (
+	newProfile            models.Profile
+	noMatchingUserProfile models.Profile
 )
 
+func setupProfileTestData(t *testing.T, testHarness RepoTestHarnessInput) {
+	newProfile = models.NewProfile(initialTestUser.ID, "Jane", "", "Doe", time.Date(1990, time.January, 2, 0, 0, 0, 0, time.UTC))
+
+	noMatchingUserProfile = models.NewProfile(testHarness.IDGenerator(false), "John", "", "Doe", time.Time{})
+
+	nonExistantUserID = testHarness.IDGenerator(false)
+}
+
 func testProfileRepo(t *testing.T, testHarness RepoTestHarnessInput) {
-	t.Error("tests not implemented")
+	setupProfileTestData(t, testHarness)
+	t.Run("AddProfile", func(t *testing.T) {
+		_testAddProfile(t, *testHarness.ProfileRepo)
+	})
+	t.Run("GetProfileByUserID", func(t *testing.T) {
+		_testGetProfileByUserID(t, *testHarness.ProfileRepo)
+	})
+	t.Run("UpdateUserProfile", func(t *testing.T) {
+		_testUpdateUserProfile(t, *testHarness.ProfileRepo, testHarness.IDGenerator(false))
+	})
+}
+
+func _testAddProfile(t *testing.T, profileRepo repo.ProfileRepo) {
+	type testCase struct {
+		name              string
+		profileToAdd      *models.Profile
+		expectedErrorCode string
+	}
+	testCases := []testCase{
+		{
+			name:         "GIVEN profile to insert EXPECT profile to be inserted",
+			profileToAdd: &newProfile,
+		},
+		{
+			name:              "GIVEN profile with non existant user id EXPECT no user found error code",
+			profileToAdd:      &noMatchingUserProfile,
+			expectedErrorCode: coreerrors.ErrCodeNoUserFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := profileRepo.AddProfile(context.TODO(), tc.profileToAdd, profileRepoCreatedBy)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			} else if tc.profileToAdd.ID == "" {
+				t.Error("\tprofile id is blank")
+			}
+		})
+	}
+}
+
+func _testGetProfileByUserID(t *testing.T, profileRepo repo.ProfileRepo) {
+	type testCase struct {
+		name              string
+		userID            string
+		expectedProfile   models.Profile
+		expectedErrorCode string
+	}
+	testCases := []testCase{
+		{
+			name:            "GIVEN a user with a profile EXPECT the profile to be returned",
+			userID:          initialTestUser.ID,
+			expectedProfile: newProfile,
+		},
+		{
+			name:              "GIVEN a non existant user id EXPECT no profile found error code",
+			userID:            nonExistantUserID,
+			expectedErrorCode: coreerrors.ErrCodeNoProfileFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			profile, err := profileRepo.GetProfileByUserID(context.TODO(), tc.userID)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			} else {
+				if profile.ID != tc.expectedProfile.ID {
+					t.Errorf("\tprofile id not expected: got - %s expected - %s", profile.ID, tc.expectedProfile.ID)
+				}
+				if profile.FirstName != tc.expectedProfile.FirstName {
+					t.Errorf("\tprofile first name not expected: got - %v expected - %v", profile.FirstName, tc.expectedProfile.FirstName)
+				}
+				if profile.MiddleName != tc.expectedProfile.MiddleName {
+					t.Errorf("\tprofile middle name not expected: got - %v expected - %v", profile.MiddleName, tc.expectedProfile.MiddleName)
+				}
+				if !profile.DateOfBirth.Value.Equal(tc.expectedProfile.DateOfBirth.Value) {
+					t.Errorf("\tprofile date of birth not expected: got - %v expected - %v", profile.DateOfBirth, tc.expectedProfile.DateOfBirth)
+				}
+			}
+		})
+	}
+}
+
+func _testUpdateUserProfile(t *testing.T, profileRepo repo.ProfileRepo, nonExistantProfileID string) {
+	type testCase struct {
+		name              string
+		profileToUpdate   *models.Profile
+		newLastName       string
+		expectedErrorCode string
+	}
+	nonExistantProfile := newProfile
+	nonExistantProfile.ID = nonExistantProfileID
+	testCases := []testCase{
+		{
+			name:            "GIVEN an existing profile EXPECT the profile to be updated",
+			profileToUpdate: &newProfile,
+			newLastName:     "Smith",
+		},
+		{
+			name:              "GIVEN a non existant profile EXPECT no profile found error code",
+			profileToUpdate:   &nonExistantProfile,
+			newLastName:       "Jones",
+			expectedErrorCode: coreerrors.ErrCodeNoProfileFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.profileToUpdate.LastName.Set(tc.newLastName)
+			err := profileRepo.UpdateUserProfile(context.TODO(), tc.profileToUpdate, profileRepoCreatedBy)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			} else {
+				retreivedProfile, err := profileRepo.GetProfileByUserID(context.TODO(), tc.profileToUpdate.UserID)
+				if err != nil {
+					t.Log(err.Error())
+					t.Fatalf("\tfailed to retreive updated profile: %s", err.GetErrorCode())
+				}
+				if retreivedProfile.LastName.Value != tc.newLastName {
+					t.Errorf("\tprofile last name not updated: got - %s expected - %s", retreivedProfile.LastName.Value, tc.newLastName)
+				}
+			}
+		})
+	}
 }
@@ -46,10 +46,17 @@ type RepoTestHarnessInput struct {
 	ProfileRepo           *repo.ProfileRepo
 	AppRepo               *repo.AppRepo
 	TokenRepo             *repo.TokenRepo
+	ConsentRepo           *repo.ConsentRepo
 	AuditLogRepo          *repo.AuditLogRepo
+	JWTSigningKeyRepo     *repo.JWTSigningKeyRepo
 	IDGenerator           func(getZeroId bool) string
 	SetupTestDataSource   func(t *testing.T, input RepoTestHarnessInput)
 	CleanupTestDataSource func(t *testing.T, input RepoTestHarnessInput)
+
+	// TokenRepoExpiresTokens should be set when the token repo removes expired tokens on its own, in which case an expired token may no longer be found.
+	TokenRepoExpiresTokens bool
+	// AppRepoDeletesConsents should be set when deleting an app with the app repo also deletes the consents given to it, which requires the app and consent repos to share a data store.
+	AppRepoDeletesConsents bool
 }
 
 // NOTE: The way I created the repo test harness the tests need to run
@@ -115,6 +122,14 @@ func RunReposTestHarness(t *testing.T, input RepoTestHarnessInput) {
 		}
 	})
 
+	t.Run("consentRepo", func(t *testing.T) {
+		if input.ConsentRepo != nil {
+			testConsentRepo(t, input)
+		} else {
+			t.Skip("no implementation for provided for consentRepo")
+		}
+	})
+
 	t.Run("auditLogRepo", func(t *testing.T) {
 		if input.AuditLogRepo != nil {
 			testAuditLogRepo(t, input)
@@ -122,6 +137,14 @@ func RunReposTestHarness(t *testing.T, input RepoTestHarnessInput) {
 			t.Skip("no implementation for provided for auditLogRepo")
 		}
 	})
+
+	t.Run("jwtSigningKeyRepo", func(t *testing.T) {
+		if input.JWTSigningKeyRepo != nil {
+			testJWTSigningKeyRepo(t, input)
+		} else {
+			t.Skip("no implementation for provided for jwtSigningKeyRepo")
+		}
+	})
 }
 
 func setupTestHarnessData(t *testing.T, input RepoTestHarnessInput) {
@@ -131,38 +154,42 @@ func setupTestHarnessData(t *testing.T, input RepoTestHarnessInput) {
 	initialTestUser = models.NewUser()
 	initialTestUser.PasswordHash = "passwordhash2"
 	initialTestUser.LastLoginDate = nullable.NullableTime{HasValue: true, Value: time.Now().UTC()}
-	// add a test user
-	err = (*input.UserRepo).AddUser(context.TODO(), &initialTestUser, createdByID)
-	if err != nil {
-		t.Log(err.Error())
-		t.Errorf("setup failed to add user to database: %s", err.GetErrorCode())
+	if input.UserRepo != nil {
+		// add a test user
+		err = (*input.UserRepo).AddUser(context.TODO(), &initialTestUser, createdByID)
+		if err != nil {
+			t.Log(err.Error())
+			t.Errorf("setup failed to add user to database: %s", err.GetErrorCode())
+		}
 	}
 
-	// create test contact
-	initialTestConfirmedPrimaryContact = models.NewContact(initialTestUser.ID, "", "InitialTestUser@email.com", core.CONTACT_TYPE_EMAIL, true)
-	initialTestConfirmedPrimaryContact.ConfirmedDate.Set(time.Now().UTC().Add(time.Second * -1))
-	// add a test contact for the test user.
-	err = (*input.ContactRepo).AddContact(context.TODO(), &initialTestConfirmedPrimaryContact, createdByID)
-	if err != nil {
-		t.Log(err.Error())
-		t.Errorf("setup failed to add contact to database: %s", err.GetErrorCode())
-	}
-	initialTestUnconfirmedContact = models.NewContact(initialTestUser.ID, "", "InitialTestUser2@email.com", core.CONTACT_TYPE_EMAIL, false)
-	// add a test contact for the test user.
-	err = (*input.ContactRepo).AddContact(context.TODO(), &initialTestUnconfirmedContact, createdByID)
-	if err != nil {
-		t.Log(err.Error())
-		t.Errorf("setup failed to add contact to database: %s", err.GetErrorCode())
+	if input.ContactRepo != nil {
+		// create test contact
+		initialTestConfirmedPrimaryContact = models.NewContact(initialTestUser.ID, "", "InitialTestUser@email.com", core.CONTACT_TYPE_EMAIL, true)
+		initialTestConfirmedPrimaryContact.ConfirmedDate.Set(time.Now().UTC().Add(time.Second * -1))
+		// add a test contact for the test user.
+		err = (*input.ContactRepo).AddContact(context.TODO(), &initialTestConfirmedPrimaryContact, createdByID)
+		if err != nil {
+			t.Log(err.Error())
+			t.Errorf("setup failed to add contact to database: %s", err.GetErrorCode())
+		}
+		initialTestUnconfirmedContact = models.NewContact(initialTestUser.ID, "", "InitialTestUser2@email.com", core.CONTACT_TYPE_EMAIL, false)
+		// add a test contact for the test user.
+		err = (*input.ContactRepo).AddContact(context.TODO(), &initialTestUnconfirmedContact, createdByID)
+		if err != nil {
+			t.Log(err.Error())
+			t.Errorf("setup failed to add contact to database: %s", err.GetErrorCode())
+		}
 	}
 
 	if input.AppRepo != nil {
 		// create test apps
-		initialTestApp, _, err = models.NewApp(initialTestUser.ID, "test app 1", "https://my.app/callback", "https://my.app/assets/logo.png")
+		initialTestApp, _, err = models.NewApp(initialTestUser.ID, "test app 1", []string{"https://my.app/callback"}, "https://my.app/assets/logo.png")
 		if err != nil {
 			t.Log(err.Error())
 			t.Errorf("setup failed to create app: %s", err.GetErrorCode())
 		}
-		initialTestApp2, _, err = models.NewApp(initialTestUser.ID, "test app 1", "https://my.app/callback", "https://my.app/assets/logo.png")
+		initialTestApp2, _, err = models.NewApp(initialTestUser.ID, "test app 1", []string{"https://my.app/callback"}, "https://my.app/assets/logo.png")
 		if err != nil {
 			t.Log(err.Error())
 			t.Errorf("setup failed to create app: %s", err.GetErrorCode())
@@ -2,6 +2,8 @@ package repotest
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -34,7 +36,22 @@ func testTokenRepo(t *testing.T, testHarness RepoTestHarnessInput) {
 		_testDeleteToken(t, *testHarness.TokenRepo)
 	})
 	t.Run("GetToken", func(t *testing.T) {
-		_testGetToken(t, *testHarness.TokenRepo)
+		_testGetToken(t, *testHarness.TokenRepo, testHarness.TokenRepoExpiresTokens)
+	})
+	t.Run("DeleteTokensByTargetID", func(t *testing.T) {
+		_testDeleteTokensByTargetID(t, *testHarness.TokenRepo)
+	})
+	t.Run("DeleteTokensByClientID", func(t *testing.T) {
+		_testDeleteTokensByClientID(t, *testHarness.TokenRepo)
+	})
+	t.Run("ConsumeToken", func(t *testing.T) {
+		_testConsumeToken(t, *testHarness.TokenRepo)
+	})
+	t.Run("MarkTokenRotated", func(t *testing.T) {
+		_testMarkTokenRotated(t, *testHarness.TokenRepo)
+	})
+	t.Run("MarkTokenRotatedConcurrently", func(t *testing.T) {
+		_testMarkTokenRotatedConcurrently(t, *testHarness.TokenRepo)
 	})
 }
 
@@ -94,17 +111,19 @@ func _testDeleteToken(t *testing.T, tokenRepo repo.TokenRepo) {
 	}
 }
 
-func _testGetToken(t *testing.T, tokenRepo repo.TokenRepo) {
+func _testGetToken(t *testing.T, tokenRepo repo.TokenRepo, repoExpiresTokens bool) {
 	_, err := tokenRepo.GetToken(context.TODO(), testPasswordResetToken.Value)
 	if err.GetErrorCode() != errors.ErrCodeInvalidToken {
 		t.Error("testPasswordResetToken found inspite of being deleted in the tokenRepo.DeleteToken test...")
 	}
 	expiredToken, err := tokenRepo.GetToken(context.TODO(), testExpiredToken.Value)
 	if err != nil {
-		t.Log(err.Error())
-		t.Errorf("failed to get testExpiredToken from repo: %s", err.GetErrorCode())
-	}
-	if !expiredToken.IsExpired() {
+		// a repo that expires tokens on its own is allowed to have already removed the expired token.
+		if !repoExpiresTokens || err.GetErrorCode() != errors.ErrCodeInvalidToken {
+			t.Log(err.Error())
+			t.Errorf("failed to get testExpiredToken from repo: %s", err.GetErrorCode())
+		}
+	} else if !expiredToken.IsExpired() {
 		t.Errorf("testExpiredToken should be expired but expiration is: %s and it is now %s", expiredToken.Expiration.UTC().String(), time.Now().UTC().String())
 	}
 	csrfToken, err := tokenRepo.GetToken(context.TODO(), testCSRFToken.Value)
@@ -143,3 +162,176 @@ func _testGetToken(t *testing.T, tokenRepo repo.TokenRepo) {
 		t.Errorf("testSessionToken expected metadata for key %s does not match expected value: got: %s - expected: %s", ARBITRARY_DATA_KEY, arbitraryValue, ARBITRARY_DATA_VALUE)
 	}
 }
+
+func _testDeleteTokensByTargetID(t *testing.T, tokenRepo repo.TokenRepo) {
+	targetID := "delete_by_target_id_user"
+	sessionToken := _putNewToken(t, tokenRepo, targetID, models.TokenTypeSession, nil)
+	accessToken := _putNewToken(t, tokenRepo, targetID, models.TokenTypeAccessToken, nil)
+	confirmContactToken := _putNewToken(t, tokenRepo, targetID, models.TokenTypeConfirmContact, nil)
+	otherUserSessionToken := _putNewToken(t, tokenRepo, "delete_by_target_id_other_user", models.TokenTypeSession, nil)
+	err := tokenRepo.DeleteTokensByTargetID(context.TODO(), targetID, []models.TokenType{models.TokenTypeSession, models.TokenTypeAccessToken})
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("failed to delete tokens by target id: %s", err.GetErrorCode())
+	}
+	for _, token := range []models.Token{sessionToken, accessToken} {
+		_, err = tokenRepo.GetToken(context.TODO(), token.Value)
+		if err == nil || err.GetErrorCode() != errors.ErrCodeInvalidToken {
+			t.Errorf("token of type %s found inspite of being deleted by target id", token.TokenType.String())
+		}
+	}
+	for _, token := range []models.Token{confirmContactToken, otherUserSessionToken} {
+		_, err = tokenRepo.GetToken(context.TODO(), token.Value)
+		if err != nil {
+			t.Log(err.Error())
+			t.Errorf("token of type %s for target %s should not have been deleted: %s", token.TokenType.String(), token.TargetID, err.GetErrorCode())
+		}
+	}
+}
+
+func _testDeleteTokensByClientID(t *testing.T, tokenRepo repo.TokenRepo) {
+	clientID := "delete_by_client_id_client"
+	accessToken := _putNewToken(t, tokenRepo, "delete_by_client_id_user", models.TokenTypeAccessToken, map[string]string{models.TokenMetaDataKeyClientID: clientID})
+	refreshToken := _putNewToken(t, tokenRepo, "delete_by_client_id_user", models.TokenTypeRefreshToken, map[string]string{models.TokenMetaDataKeyClientID: clientID})
+	otherClientToken := _putNewToken(t, tokenRepo, "delete_by_client_id_user", models.TokenTypeRefreshToken, map[string]string{models.TokenMetaDataKeyClientID: "delete_by_client_id_other_client"})
+	err := tokenRepo.DeleteTokensByClientID(context.TODO(), clientID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("failed to delete tokens by client id: %s", err.GetErrorCode())
+	}
+	for _, token := range []models.Token{accessToken, refreshToken} {
+		_, err = tokenRepo.GetToken(context.TODO(), token.Value)
+		if err == nil || err.GetErrorCode() != errors.ErrCodeInvalidToken {
+			t.Errorf("token of type %s found inspite of being deleted by client id", token.TokenType.String())
+		}
+	}
+	_, err = tokenRepo.GetToken(context.TODO(), otherClientToken.Value)
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("token issued to another client should not have been deleted: %s", err.GetErrorCode())
+	}
+}
+
+func _testConsumeToken(t *testing.T, tokenRepo repo.TokenRepo) {
+	authorizationCode := _putNewToken(t, tokenRepo, "consume_token_user", models.TokenTypeAuthorizationCode, map[string]string{models.TokenMetaDataKeyClientID: "consume_token_client"})
+	consumedToken, err := tokenRepo.ConsumeToken(context.TODO(), authorizationCode.Value)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to consume token: %s", err.GetErrorCode())
+	}
+	if consumedToken.TokenType != authorizationCode.TokenType {
+		t.Errorf("consumed token type does not match expected value: got: %s - expected: %s", consumedToken.TokenType.String(), authorizationCode.TokenType.String())
+	}
+	if consumedToken.TargetID != authorizationCode.TargetID {
+		t.Errorf("consumed token target id does not match expected value: got: %s - expected: %s", consumedToken.TargetID, authorizationCode.TargetID)
+	}
+	if consumedToken.MetaData[models.TokenMetaDataKeyClientID] != authorizationCode.MetaData[models.TokenMetaDataKeyClientID] {
+		t.Errorf("consumed token client id does not match expected value: got: %s - expected: %s", consumedToken.MetaData[models.TokenMetaDataKeyClientID], authorizationCode.MetaData[models.TokenMetaDataKeyClientID])
+	}
+	_, err = tokenRepo.ConsumeToken(context.TODO(), authorizationCode.Value)
+	if err == nil || err.GetErrorCode() != errors.ErrCodeInvalidToken {
+		t.Error("token was consumed a second time")
+	}
+	_, err = tokenRepo.GetToken(context.TODO(), authorizationCode.Value)
+	if err == nil || err.GetErrorCode() != errors.ErrCodeInvalidToken {
+		t.Error("token found inspite of being consumed")
+	}
+}
+
+func _testMarkTokenRotated(t *testing.T, tokenRepo repo.TokenRepo) {
+	refreshToken := _putNewToken(t, tokenRepo, "rotate_token_user", models.TokenTypeRefreshToken, map[string]string{models.TokenMetaDataKeyClientID: "rotate_token_client"})
+	expiration := refreshToken.Expiration.Add(time.Minute).Truncate(time.Millisecond)
+	previousToken, err := tokenRepo.MarkTokenRotated(context.TODO(), refreshToken.Value, "next_token", expiration)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to mark token rotated: %s", err.GetErrorCode())
+	}
+	if previousToken.MetaData[models.TokenMetaDataKeyRotatedTo] != "" {
+		t.Errorf("token should be returned as it was before it was rotated: got rotated to %s", previousToken.MetaData[models.TokenMetaDataKeyRotatedTo])
+	}
+	if previousToken.MetaData[models.TokenMetaDataKeyClientID] != "rotate_token_client" {
+		t.Errorf("token client id does not match expected value: got: %s - expected: rotate_token_client", previousToken.MetaData[models.TokenMetaDataKeyClientID])
+	}
+	storedToken, err := tokenRepo.GetToken(context.TODO(), refreshToken.Value)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get rotated token: %s", err.GetErrorCode())
+	}
+	if storedToken.MetaData[models.TokenMetaDataKeyRotatedTo] != "next_token" {
+		t.Errorf("stored token rotated to does not match expected value: got: %s - expected: next_token", storedToken.MetaData[models.TokenMetaDataKeyRotatedTo])
+	}
+	if storedToken.MetaData[models.TokenMetaDataKeyClientID] != "rotate_token_client" {
+		t.Errorf("stored token client id should be kept: got: %s", storedToken.MetaData[models.TokenMetaDataKeyClientID])
+	}
+	if !storedToken.Expiration.Equal(expiration) {
+		t.Errorf("stored token expiration does not match expected value: got: %s - expected: %s", storedToken.Expiration, expiration)
+	}
+	previousToken, err = tokenRepo.MarkTokenRotated(context.TODO(), refreshToken.Value, "other_token", expiration)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to mark token rotated a second time: %s", err.GetErrorCode())
+	}
+	if previousToken.MetaData[models.TokenMetaDataKeyRotatedTo] != "next_token" {
+		t.Errorf("already rotated token should be returned with its rotated to value: got: %s - expected: next_token", previousToken.MetaData[models.TokenMetaDataKeyRotatedTo])
+	}
+	storedToken, _ = tokenRepo.GetToken(context.TODO(), refreshToken.Value)
+	if storedToken.MetaData[models.TokenMetaDataKeyRotatedTo] != "next_token" {
+		t.Errorf("already rotated token should not be changed: got rotated to %s", storedToken.MetaData[models.TokenMetaDataKeyRotatedTo])
+	}
+	_, err = tokenRepo.MarkTokenRotated(context.TODO(), "not_a_real_token", "next_token", expiration)
+	if err == nil || err.GetErrorCode() != errors.ErrCodeInvalidToken {
+		t.Error("expected invalid token error when marking a token that does not exist as rotated")
+	}
+}
+
+func _testMarkTokenRotatedConcurrently(t *testing.T, tokenRepo repo.TokenRepo) {
+	refreshToken := _putNewToken(t, tokenRepo, "rotate_token_concurrent_user", models.TokenTypeRefreshToken, map[string]string{models.TokenMetaDataKeyClientID: "rotate_token_client"})
+	const numCallers = 10
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	winners := make([]string, 0, 1)
+	for i := 0; i < numCallers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rotatedTo := fmt.Sprintf("next_token_%d", i)
+			previousToken, err := tokenRepo.MarkTokenRotated(context.TODO(), refreshToken.Value, rotatedTo, refreshToken.Expiration)
+			if err != nil {
+				t.Errorf("failed to mark token rotated: %s", err.GetErrorCode())
+				return
+			}
+			if previousToken.MetaData[models.TokenMetaDataKeyRotatedTo] == "" {
+				lock.Lock()
+				winners = append(winners, rotatedTo)
+				lock.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(winners) != 1 {
+		t.Fatalf("exactly one caller should rotate the token: got %d", len(winners))
+	}
+	storedToken, err := tokenRepo.GetToken(context.TODO(), refreshToken.Value)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get rotated token: %s", err.GetErrorCode())
+	}
+	if storedToken.MetaData[models.TokenMetaDataKeyRotatedTo] != winners[0] {
+		t.Errorf("stored token should be rotated to the token of the caller that rotated it: got: %s - expected: %s", storedToken.MetaData[models.TokenMetaDataKeyRotatedTo], winners[0])
+	}
+}
+
+func _putNewToken(t *testing.T, tokenRepo repo.TokenRepo, targetID string, tokenType models.TokenType, metaData map[string]string) models.Token {
+	token, err := models.NewToken(targetID, tokenType, time.Second*20)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to create token of type %s: %s", tokenType.String(), err.GetErrorCode())
+	}
+	token.WithMetaData(metaData)
+	err = tokenRepo.PutToken(context.TODO(), token)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to put token of type %s: %s", tokenType.String(), err.GetErrorCode())
+	}
+	return token
+}
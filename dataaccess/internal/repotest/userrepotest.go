@@ -2,6 +2,7 @@ package repotest
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -32,9 +33,15 @@ func testUserRepo(t *testing.T, testHarness RepoTestHarnessInput) {
 	t.Run("UpdateUser", func(t *testing.T) {
 		_testUpdateUser(t, *testHarness.UserRepo)
 	})
+	t.Run("UpdateTOTPLastUsedTimeStep", func(t *testing.T) {
+		_testUpdateTOTPLastUsedTimeStep(t, *testHarness.UserRepo)
+	})
 	t.Run("GetUserByID", func(t *testing.T) {
 		_testGetUserByID(t, *testHarness.UserRepo)
 	})
+	t.Run("GetFullUserByID", func(t *testing.T) {
+		_testGetFullUserByID(t, *testHarness.UserRepo)
+	})
 	t.Run("GetUserByPrimaryContact", func(t *testing.T) {
 		_testGetUserByPrimaryContact(t, *testHarness.UserRepo)
 	})
@@ -59,7 +66,12 @@ func _testAddUser(t *testing.T, userRepo repo.UserRepo) {
 func _testUpdateUser(t *testing.T, userRepo repo.UserRepo) {
 	preUpdateDate := time.Now().UTC()
 	newPasswordHash := "another secure password hash"
+	newTOTPSecret := "an encrypted totp secret"
+	var newTOTPLastUsedTimeStep int64 = 55555555
 	testUser1.PasswordHash = newPasswordHash
+	testUser1.TOTPSecret = newTOTPSecret
+	testUser1.TOTPEnabled = true
+	testUser1.TOTPLastUsedTimeStep = newTOTPLastUsedTimeStep
 	err := userRepo.UpdateUser(context.TODO(), &testUser1, testUser1.ID)
 	if err != nil {
 		t.Log(err.Error())
@@ -76,6 +88,95 @@ func _testUpdateUser(t *testing.T, userRepo repo.UserRepo) {
 	if retreivedUser.PasswordHash != newPasswordHash {
 		t.Error("password hash did not update.", retreivedUser.PasswordHash, newPasswordHash)
 	}
+	if retreivedUser.TOTPSecret != newTOTPSecret || !retreivedUser.TOTPEnabled || retreivedUser.TOTPLastUsedTimeStep != newTOTPLastUsedTimeStep {
+		t.Error("totp fields did not update.", retreivedUser.TOTPSecret, retreivedUser.TOTPEnabled, retreivedUser.TOTPLastUsedTimeStep)
+	}
+}
+
+func _testUpdateTOTPLastUsedTimeStep(t *testing.T, userRepo repo.UserRepo) {
+	// _testUpdateUser leaves testUser1 with a time step of 55555555
+	var usedTimeStep int64 = 55555555
+	type testCase struct {
+		name              string
+		userID            string
+		timeStep          int64
+		expectedErrorCode string
+	}
+	testCases := []testCase{
+		{
+			name:              "GIVEN the time step already used EXPECT error code totp code already used",
+			userID:            testUser1.ID,
+			timeStep:          usedTimeStep,
+			expectedErrorCode: coreerrors.ErrCodeTOTPCodeAlreadyUsed,
+		},
+		{
+			name:              "GIVEN an earlier time step EXPECT error code totp code already used",
+			userID:            testUser1.ID,
+			timeStep:          usedTimeStep - 1,
+			expectedErrorCode: coreerrors.ErrCodeTOTPCodeAlreadyUsed,
+		},
+		{
+			name:     "GIVEN a later time step EXPECT success",
+			userID:   testUser1.ID,
+			timeStep: usedTimeStep + 1,
+		},
+		{
+			name:              "GIVEN a user that does not exist EXPECT error code no user found",
+			userID:            nonExistantUserID,
+			timeStep:          usedTimeStep + 2,
+			expectedErrorCode: coreerrors.ErrCodeNoUserFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := userRepo.UpdateTOTPLastUsedTimeStep(context.TODO(), tc.userID, tc.timeStep, testUser1.ID)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			}
+		})
+	}
+	// a stale copy of the user must not lower the time step.
+	staleUser := testUser1
+	staleUser.TOTPLastUsedTimeStep = usedTimeStep
+	err := userRepo.UpdateUser(context.TODO(), &staleUser, testUser1.ID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Error("failed to update user", err.GetErrorCode())
+	}
+	retreivedUser, err := userRepo.GetUserByID(context.TODO(), testUser1.ID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatal("failed to retreive user", err.GetErrorCode())
+	}
+	if retreivedUser.TOTPLastUsedTimeStep != usedTimeStep+1 {
+		t.Errorf("UpdateUser should not lower the totp last used time step: got %d - expected %d", retreivedUser.TOTPLastUsedTimeStep, usedTimeStep+1)
+	}
+	// the same time step used concurrently can only be recorded once.
+	concurrentTimeStep := usedTimeStep + 10
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	successCount := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := userRepo.UpdateTOTPLastUsedTimeStep(context.TODO(), testUser1.ID, concurrentTimeStep, testUser1.ID)
+			if err == nil {
+				lock.Lock()
+				successCount++
+				lock.Unlock()
+			} else if err.GetErrorCode() != coreerrors.ErrCodeTOTPCodeAlreadyUsed {
+				t.Errorf("unexpected error recording totp time step: %s", err.GetErrorCode())
+			}
+		}()
+	}
+	wg.Wait()
+	if successCount != 1 {
+		t.Errorf("the same totp time step should only be recorded once: got %d", successCount)
+	}
+	testUser1.TOTPLastUsedTimeStep = concurrentTimeStep
 }
 
 func _testGetUserByID(t *testing.T, userRepo repo.UserRepo) {
@@ -90,6 +191,36 @@ func _testGetUserByID(t *testing.T, userRepo repo.UserRepo) {
 	}
 }
 
+func _testGetFullUserByID(t *testing.T, userRepo repo.UserRepo) {
+	userID := initialTestUser.ID
+	retreivedFullUser, err := userRepo.GetFullUserByID(context.TODO(), userID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Error("error getting full user with id", userID, err.GetErrorCode())
+	}
+	if retreivedFullUser.ID != initialTestUser.ID {
+		t.Error("expected retreivedFullUser and initialTestUser ID to match", retreivedFullUser.ID, initialTestUser.ID)
+	}
+	primaryContactFound := false
+	for _, contact := range retreivedFullUser.Contacts {
+		if contact.ID == initialTestConfirmedPrimaryContact.ID {
+			primaryContactFound = true
+			if contact.UserID != userID {
+				t.Error("expected contact user id to match the user id", contact.UserID, userID)
+			}
+		}
+	}
+	if !primaryContactFound {
+		t.Error("expected retreivedFullUser to include the primary contact", initialTestConfirmedPrimaryContact.ID, retreivedFullUser.Contacts)
+	}
+	_, err = userRepo.GetFullUserByID(context.TODO(), nonExistantUserID)
+	if err == nil {
+		t.Error("expected an error getting a full user that does not exist", nonExistantUserID)
+	} else {
+		testutils.HandleTestError(t, err, coreerrors.ErrCodeNoUserFound)
+	}
+}
+
 func _testGetUserByPrimaryContact(t *testing.T, userRepo repo.UserRepo) {
 	contactType, principal := core.CONTACT_TYPE_EMAIL, initialTestConfirmedPrimaryContact.Principal
 	retreivedUser, err := userRepo.GetUserByPrimaryContact(context.TODO(), contactType, principal)
@@ -3,6 +3,7 @@ package memory
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/calvine/goauth/core/apptelemetry"
@@ -15,31 +16,32 @@ import (
 )
 
 type appRepo struct {
-	apps      *map[string]models.App
-	appScopes *map[string][]models.Scope
+	lock      sync.RWMutex
+	apps      map[string]models.App
+	appScopes map[string][]models.Scope
 }
 
 func NewMemoryAppRepo() repo.AppRepo {
-	apps = make(map[string]models.App)
-	appScopes = make(map[string][]models.Scope)
-	return appRepo{
-		apps:      &apps,
-		appScopes: &appScopes,
+	return &appRepo{
+		apps:      make(map[string]models.App),
+		appScopes: make(map[string][]models.Scope),
 	}
 }
 
-func (appRepo) GetName() string {
+func (*appRepo) GetName() string {
 	return "appRepo"
 }
 
-func (appRepo) GetType() string {
+func (*appRepo) GetType() string {
 	return dataSourceType
 }
 
-func (ar appRepo) GetAppByID(ctx context.Context, id string) (models.App, errors.RichError) {
+func (ar *appRepo) GetAppByID(ctx context.Context, id string) (models.App, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppByID", ar.GetType())
 	defer span.End()
-	app, ok := (*ar.apps)[id]
+	ar.lock.RLock()
+	defer ar.lock.RUnlock()
+	app, ok := ar.apps[id]
 	if !ok {
 		fields := map[string]interface{}{"id": id}
 		err := coreerrors.NewNoAppFoundError(fields, true)
@@ -51,11 +53,13 @@ func (ar appRepo) GetAppByID(ctx context.Context, id string) (models.App, errors
 	return app, nil
 }
 
-func (ar appRepo) GetAppByClientID(ctx context.Context, clientID string) (models.App, errors.RichError) {
+func (ar *appRepo) GetAppByClientID(ctx context.Context, clientID string) (models.App, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppByClientID", ar.GetType())
 	defer span.End()
+	ar.lock.RLock()
+	defer ar.lock.RUnlock()
 	var app models.App
-	for _, a := range *ar.apps {
+	for _, a := range ar.apps {
 		if a.ClientID == clientID {
 			app = a
 			break
@@ -65,11 +69,13 @@ func (ar appRepo) GetAppByClientID(ctx context.Context, clientID string) (models
 	return app, nil
 }
 
-func (ar appRepo) GetAppsByOwnerID(ctx context.Context, ownerID string) ([]models.App, errors.RichError) {
+func (ar *appRepo) GetAppsByOwnerID(ctx context.Context, ownerID string) ([]models.App, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppsByOwnerID", ar.GetType())
 	defer span.End()
+	ar.lock.RLock()
+	defer ar.lock.RUnlock()
 	apps := make([]models.App, 0)
-	for _, app := range *ar.apps {
+	for _, app := range ar.apps {
 		if app.OwnerID == ownerID {
 			apps = append(apps, app)
 		}
@@ -85,15 +91,17 @@ func (ar appRepo) GetAppsByOwnerID(ctx context.Context, ownerID string) ([]model
 	return apps, nil
 }
 
-func (ar appRepo) GetAppAndScopesByClientID(ctx context.Context, clientID string) (models.App, []models.Scope, errors.RichError) {
+func (ar *appRepo) GetAppAndScopesByClientID(ctx context.Context, clientID string) (models.App, []models.Scope, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppAndScopesByClientID", ar.GetType())
 	defer span.End()
+	ar.lock.RLock()
+	defer ar.lock.RUnlock()
 	var app models.App
 	var scopes []models.Scope
-	for _, a := range *ar.apps {
+	for _, a := range ar.apps {
 		if a.ClientID == clientID {
 			app = a
-			scopes = (*ar.appScopes)[a.ID]
+			scopes = copyScopes(ar.appScopes[a.ID])
 			span.AddEvent("app and scopes retreived")
 			return app, scopes, nil
 		}
@@ -105,34 +113,133 @@ func (ar appRepo) GetAppAndScopesByClientID(ctx context.Context, clientID string
 	return app, scopes, err
 }
 
-func (ar appRepo) AddApp(ctx context.Context, app *models.App, createdBy string) errors.RichError {
+func (ar *appRepo) AddApp(ctx context.Context, app *models.App, createdBy string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "AddApp", ar.GetType())
 	defer span.End()
+	ar.lock.Lock()
+	defer ar.lock.Unlock()
 	app.AuditData.CreatedByID = createdBy
 	app.AuditData.CreatedOnDate = time.Now().UTC()
 	if app.ID == "" {
 		app.ID = uuid.Must(uuid.NewRandom()).String()
 	}
-	(*ar.apps)[app.ID] = *app
-	(*ar.appScopes)[app.ID] = make([]models.Scope, 0, 5)
+	ar.apps[app.ID] = *app
+	ar.appScopes[app.ID] = make([]models.Scope, 0, 5)
 	span.AddEvent("app stored")
 	return nil
 }
 
-func (ar appRepo) UpdateApp(ctx context.Context, app *models.App, modifiedBy string) errors.RichError {
+func (ar *appRepo) UpdateApp(ctx context.Context, app *models.App, modifiedBy string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "UpdateApp", ar.GetType())
 	defer span.End()
+	ar.lock.Lock()
+	defer ar.lock.Unlock()
 	app.AuditData.ModifiedByID = nullable.NullableString{HasValue: true, Value: modifiedBy}
 	app.AuditData.ModifiedOnDate = nullable.NullableTime{HasValue: true, Value: time.Now().UTC()}
-	(*ar.apps)[app.ID] = *app
+	updatedApp := *app
+	if storedApp, ok := ar.apps[app.ID]; ok {
+		updatedApp.ClientSecrets = storedApp.ClientSecrets
+	}
+	ar.apps[app.ID] = updatedApp
 	span.AddEvent("app updated")
 	return nil
 }
 
-func (ar appRepo) DeleteApp(ctx context.Context, app *models.App, deletedBy string) errors.RichError {
+func (ar *appRepo) AddClientSecret(ctx context.Context, appID string, clientSecret models.ClientSecret, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "AddClientSecret", ar.GetType())
+	defer span.End()
+	ar.lock.Lock()
+	defer ar.lock.Unlock()
+	app, ok := ar.apps[appID]
+	if !ok {
+		fields := map[string]interface{}{"id": appID}
+		err := coreerrors.NewNoAppFoundError(fields, true)
+		evtString := fmt.Sprintf("no app found with id: %s", appID)
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	// the stored slice is copied so apps handed out earlier are not changed underneath their callers.
+	clientSecrets := make([]models.ClientSecret, 0, len(app.ClientSecrets)+1)
+	clientSecrets = append(clientSecrets, app.ClientSecrets...)
+	app.ClientSecrets = append(clientSecrets, clientSecret)
+	setAppModified(&app, modifiedBy)
+	ar.apps[appID] = app
+	span.AddEvent("client secret added")
+	return nil
+}
+
+func (ar *appRepo) SetClientSecretExpiration(ctx context.Context, appID, clientSecretID string, expirationDate nullable.NullableTime, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "SetClientSecretExpiration", ar.GetType())
+	defer span.End()
+	ar.lock.Lock()
+	defer ar.lock.Unlock()
+	app, ok := ar.apps[appID]
+	if !ok {
+		fields := map[string]interface{}{"id": appID}
+		err := coreerrors.NewNoAppFoundError(fields, true)
+		evtString := fmt.Sprintf("no app found with id: %s", appID)
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	index := findClientSecretIndex(app, clientSecretID)
+	if index == -1 {
+		err := coreerrors.NewClientSecretNotFoundError(appID, clientSecretID, true)
+		evtString := fmt.Sprintf("no client secret found for app: %s", appID)
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	clientSecrets := make([]models.ClientSecret, len(app.ClientSecrets))
+	copy(clientSecrets, app.ClientSecrets)
+	clientSecrets[index].ExpirationDate = expirationDate
+	app.ClientSecrets = clientSecrets
+	setAppModified(&app, modifiedBy)
+	ar.apps[appID] = app
+	span.AddEvent("client secret expiration set")
+	return nil
+}
+
+func (ar *appRepo) RevokeClientSecret(ctx context.Context, appID, clientSecretID string, now time.Time, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "RevokeClientSecret", ar.GetType())
+	defer span.End()
+	ar.lock.Lock()
+	defer ar.lock.Unlock()
+	app, ok := ar.apps[appID]
+	if !ok {
+		fields := map[string]interface{}{"id": appID}
+		err := coreerrors.NewNoAppFoundError(fields, true)
+		evtString := fmt.Sprintf("no app found with id: %s", appID)
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	index := findClientSecretIndex(app, clientSecretID)
+	if index == -1 {
+		err := coreerrors.NewClientSecretNotFoundError(appID, clientSecretID, true)
+		evtString := fmt.Sprintf("no client secret found for app: %s", appID)
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	clientSecrets := make([]models.ClientSecret, 0, len(app.ClientSecrets)-1)
+	clientSecrets = append(clientSecrets, app.ClientSecrets[:index]...)
+	clientSecrets = append(clientSecrets, app.ClientSecrets[index+1:]...)
+	if len(models.App{ClientSecrets: clientSecrets}.GetActiveClientSecrets(now)) == 0 {
+		err := coreerrors.NewLastActiveClientSecretError(appID, true)
+		evtString := fmt.Sprintf("cannot revoke the last active client secret for app: %s", appID)
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	app.ClientSecrets = clientSecrets
+	setAppModified(&app, modifiedBy)
+	ar.apps[appID] = app
+	span.AddEvent("client secret revoked")
+	return nil
+}
+
+func (ar *appRepo) DeleteApp(ctx context.Context, app *models.App, deletedBy string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "DeleteApp", ar.GetType())
 	defer span.End()
-	_, ok := (*ar.apps)[app.ID]
+	ar.lock.Lock()
+	defer ar.lock.Unlock()
+	_, ok := ar.apps[app.ID]
 	if !ok {
 		fields := map[string]interface{}{"id": app.ID}
 		err := coreerrors.NewNoAppFoundError(fields, true)
@@ -140,18 +247,20 @@ func (ar appRepo) DeleteApp(ctx context.Context, app *models.App, deletedBy stri
 		apptelemetry.SetSpanOriginalError(&span, err, evtString)
 		return err
 	}
-	delete(*ar.apps, app.ID)
-	delete(*ar.appScopes, app.ID)
+	delete(ar.apps, app.ID)
+	delete(ar.appScopes, app.ID)
 	span.AddEvent("app and scopes deleted")
 	return nil
 }
 
-func (ar appRepo) GetScopeByID(ctx context.Context, id string) (models.Scope, errors.RichError) {
+func (ar *appRepo) GetScopeByID(ctx context.Context, id string) (models.Scope, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetScopeByID", ar.GetType())
 	defer span.End()
+	ar.lock.RLock()
+	defer ar.lock.RUnlock()
 	var scope models.Scope
 	found := false
-	for _, appScopes := range *ar.appScopes {
+	for _, appScopes := range ar.appScopes {
 		for _, s := range appScopes {
 			if s.ID == id {
 				scope = s
@@ -174,10 +283,12 @@ func (ar appRepo) GetScopeByID(ctx context.Context, id string) (models.Scope, er
 	return scope, nil
 }
 
-func (ar appRepo) GetScopesByAppID(ctx context.Context, appID string) ([]models.Scope, errors.RichError) {
+func (ar *appRepo) GetScopesByAppID(ctx context.Context, appID string) ([]models.Scope, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetScopesByAppID", ar.GetType())
 	defer span.End()
-	scopes, ok := (*ar.appScopes)[appID]
+	ar.lock.RLock()
+	defer ar.lock.RUnlock()
+	scopes, ok := ar.appScopes[appID]
 	if !ok {
 		fields := map[string]interface{}{"appID": appID}
 		err := coreerrors.NewNoScopeFoundError(fields, true)
@@ -186,19 +297,21 @@ func (ar appRepo) GetScopesByAppID(ctx context.Context, appID string) ([]models.
 		return scopes, err
 	}
 	span.AddEvent("scopes retrevied")
-	return scopes, nil
+	return copyScopes(scopes), nil
 }
 
-func (ar appRepo) AddScope(ctx context.Context, scope *models.Scope, createdBy string) errors.RichError {
+func (ar *appRepo) AddScope(ctx context.Context, scope *models.Scope, createdBy string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "AddScope", ar.GetType())
 	defer span.End()
+	ar.lock.Lock()
+	defer ar.lock.Unlock()
 	appID := scope.AppID
 	scope.AuditData.CreatedByID = createdBy
 	scope.AuditData.CreatedOnDate = time.Now().UTC()
 	if scope.ID == "" {
 		scope.ID = uuid.Must(uuid.NewRandom()).String()
 	}
-	scopes, ok := (*ar.appScopes)[appID]
+	scopes, ok := ar.appScopes[appID]
 	if !ok {
 		fields := map[string]interface{}{"ID": scope.ID, "AppID": scope.AppID}
 		err := coreerrors.NewNoAppFoundError(fields, true)
@@ -207,17 +320,19 @@ func (ar appRepo) AddScope(ctx context.Context, scope *models.Scope, createdBy s
 		return err
 	}
 	scopes = append(scopes, *scope)
-	(*ar.appScopes)[appID] = scopes
+	ar.appScopes[appID] = scopes
 	span.AddEvent("scope stored")
 	return nil
 }
 
-func (ar appRepo) UpdateScope(ctx context.Context, scope *models.Scope, modifiedBy string) errors.RichError {
+func (ar *appRepo) UpdateScope(ctx context.Context, scope *models.Scope, modifiedBy string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "UpdateScope", ar.GetType())
 	defer span.End()
+	ar.lock.Lock()
+	defer ar.lock.Unlock()
 	appID := scope.AppID
 	scopeID := scope.ID
-	scopes, ok := (*ar.appScopes)[appID]
+	scopes, ok := ar.appScopes[appID]
 	if !ok {
 		fields := map[string]interface{}{"appID": appID}
 		err := coreerrors.NewNoScopeFoundError(fields, true)
@@ -231,7 +346,7 @@ func (ar appRepo) UpdateScope(ctx context.Context, scope *models.Scope, modified
 			scope.AuditData.ModifiedByID = nullable.NullableString{HasValue: true, Value: modifiedBy}
 			scope.AuditData.ModifiedOnDate = nullable.NullableTime{HasValue: true, Value: time.Now().UTC()}
 			scopes[i] = *scope
-			(*ar.appScopes)[appID] = scopes
+			ar.appScopes[appID] = scopes
 			scopeFound = true
 			break
 		}
@@ -247,12 +362,14 @@ func (ar appRepo) UpdateScope(ctx context.Context, scope *models.Scope, modified
 	return nil
 }
 
-func (ar appRepo) DeleteScope(ctx context.Context, scope *models.Scope, deletedBy string) errors.RichError {
+func (ar *appRepo) DeleteScope(ctx context.Context, scope *models.Scope, deletedBy string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "DeleteScope", ar.GetType())
 	defer span.End()
+	ar.lock.Lock()
+	defer ar.lock.Unlock()
 	appID := scope.AppID
 	scopeID := scope.ID
-	scopes, ok := (*ar.appScopes)[appID]
+	scopes, ok := ar.appScopes[appID]
 	if !ok {
 		fields := map[string]interface{}{"appID": appID}
 		err := coreerrors.NewNoScopeFoundError(fields, true)
@@ -269,7 +386,7 @@ func (ar appRepo) DeleteScope(ctx context.Context, scope *models.Scope, deletedB
 			// same, but would preserve order
 			// scopes = append(scopes[:i], scopes[i+1:]...)
 			scope = nil
-			(*ar.appScopes)[appID] = scopes
+			ar.appScopes[appID] = scopes
 			scopeFound = true
 			break
 		}
@@ -284,3 +401,26 @@ func (ar appRepo) DeleteScope(ctx context.Context, scope *models.Scope, deletedB
 	span.AddEvent("scope deleted")
 	return nil
 }
+
+// copyScopes copies the stored scopes of an app before they are returned, because scopes are updated in place while the lock is held and callers read them without it.
+func copyScopes(scopes []models.Scope) []models.Scope {
+	scopesCopy := make([]models.Scope, len(scopes))
+	copy(scopesCopy, scopes)
+	return scopesCopy
+}
+
+// setAppModified sets the modified audit data of an app that is about to be stored.
+func setAppModified(app *models.App, modifiedBy string) {
+	app.AuditData.ModifiedByID = nullable.NullableString{HasValue: true, Value: modifiedBy}
+	app.AuditData.ModifiedOnDate = nullable.NullableTime{HasValue: true, Value: time.Now().UTC()}
+}
+
+// findClientSecretIndex returns the index of the client secret of the app with the given id, or -1 if the app does not have it.
+func findClientSecretIndex(app models.App, clientSecretID string) int {
+	for i, clientSecret := range app.ClientSecrets {
+		if clientSecret.ID == clientSecretID {
+			return i
+		}
+	}
+	return -1
+}
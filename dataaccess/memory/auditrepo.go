@@ -3,6 +3,7 @@ package memory
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/calvine/goauth/core/apptelemetry"
 	"github.com/calvine/goauth/core/models"
@@ -11,27 +12,31 @@ import (
 )
 
 type auditLogRepo struct {
+	lock          sync.Mutex
 	logMessages   []models.AuditLog
 	printToStdOut bool
 }
 
 func NewMemoryAuditLogRepo(printToStdOut bool) repo.AuditLogRepo {
-	auditLog := make([]models.AuditLog, 0)
-	return &auditLogRepo{auditLog, printToStdOut}
-
+	return &auditLogRepo{
+		logMessages:   make([]models.AuditLog, 0),
+		printToStdOut: printToStdOut,
+	}
 }
 
-func (auditLogRepo) GetName() string {
+func (*auditLogRepo) GetName() string {
 	return "auditLogRepo"
 }
 
-func (auditLogRepo) GetType() string {
+func (*auditLogRepo) GetType() string {
 	return dataSourceType
 }
 
 func (alr *auditLogRepo) LogMessage(ctx context.Context, message models.AuditLog) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, alr.GetName(), "LogMessage", alr.GetType())
 	defer span.End()
+	alr.lock.Lock()
+	defer alr.lock.Unlock()
 	alr.logMessages = append(alr.logMessages, message)
 	if alr.printToStdOut {
 		fmt.Printf("AUDIT LOG MESSAGE: %v\n\n", message)
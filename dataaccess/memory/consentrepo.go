@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	"github.com/calvine/goauth/core/models"
+	repo "github.com/calvine/goauth/core/repositories"
+	"github.com/calvine/richerror/errors"
+	"github.com/google/uuid"
+)
+
+type consentRepo struct {
+	lock       sync.RWMutex
+	consentMap map[string]models.Consent
+}
+
+func NewMemoryConsentRepo() repo.ConsentRepo {
+	return &consentRepo{
+		consentMap: make(map[string]models.Consent),
+	}
+}
+
+func (*consentRepo) GetName() string {
+	return "consentRepo"
+}
+
+func (*consentRepo) GetType() string {
+	return dataSourceType
+}
+
+func (cr *consentRepo) GetConsentsByUserIDAndAppID(ctx context.Context, userID, appID string) ([]models.Consent, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetConsentsByUserIDAndAppID", cr.GetType())
+	defer span.End()
+	cr.lock.RLock()
+	defer cr.lock.RUnlock()
+	consents := make([]models.Consent, 0)
+	for _, consent := range cr.consentMap {
+		if consent.UserID == userID && consent.AppID == appID {
+			consents = append(consents, consent)
+		}
+	}
+	span.AddEvent("consents retreived")
+	return consents, nil
+}
+
+func (cr *consentRepo) AddConsent(ctx context.Context, consent *models.Consent, createdByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "AddConsent", cr.GetType())
+	defer span.End()
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	for _, existingConsent := range cr.consentMap {
+		if existingConsent.UserID == consent.UserID && existingConsent.AppID == consent.AppID && existingConsent.ScopeName == consent.ScopeName {
+			*consent = existingConsent
+			span.AddEvent("consent already exists")
+			return nil
+		}
+	}
+	consent.AuditData.CreatedByID = createdByID
+	consent.AuditData.CreatedOnDate = time.Now().UTC()
+	if consent.ID == "" {
+		consent.ID = uuid.Must(uuid.NewRandom()).String()
+	}
+	cr.consentMap[consent.ID] = *consent
+	span.AddEvent("consent stored")
+	return nil
+}
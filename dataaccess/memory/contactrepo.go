@@ -15,20 +15,15 @@ import (
 )
 
 type contactRepo struct {
-	users    *map[string]models.User
-	contacts *map[string]models.Contact
+	store *UserStore
 }
 
-func NewMemoryContactRepo(users *map[string]models.User, contacts *map[string]models.Contact) (repo.ContactRepo, errors.RichError) {
-	if users == nil {
-		return contactRepo{}, coreerrors.NewNilParameterNotAllowedError("NewMemoryContactRepo", "users", true)
-	}
-	if contacts == nil {
-		return contactRepo{}, coreerrors.NewNilNotAllowedError(true)
+func NewMemoryContactRepo(store *UserStore) (repo.ContactRepo, errors.RichError) {
+	if store == nil {
+		return contactRepo{}, coreerrors.NewNilParameterNotAllowedError("NewMemoryContactRepo", "store", true)
 	}
 	return contactRepo{
-		users:    users,
-		contacts: contacts,
+		store: store,
 	}, nil
 }
 
@@ -43,7 +38,9 @@ func (contactRepo) GetType() string {
 func (cr contactRepo) GetContactByID(ctx context.Context, id string) (models.Contact, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetContactByID", cr.GetType())
 	defer span.End()
-	contact, ok := (*cr.contacts)[id]
+	cr.store.lock.RLock()
+	defer cr.store.lock.RUnlock()
+	contact, ok := cr.store.contacts[id]
 	if !ok {
 		fields := map[string]interface{}{"id": id}
 		err := coreerrors.NewNoContactFoundError(fields, true)
@@ -58,9 +55,11 @@ func (cr contactRepo) GetContactByID(ctx context.Context, id string) (models.Con
 func (cr contactRepo) GetPrimaryContactByUserID(ctx context.Context, userID string, contactType string) (models.Contact, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetPrimaryContactByUserID", cr.GetType())
 	defer span.End()
+	cr.store.lock.RLock()
+	defer cr.store.lock.RUnlock()
 	var contact models.Contact
 	contactFound := false
-	for _, c := range *cr.contacts {
+	for _, c := range cr.store.contacts {
 		if c.UserID == userID &&
 			c.IsPrimary &&
 			c.Type == contactType {
@@ -71,7 +70,7 @@ func (cr contactRepo) GetPrimaryContactByUserID(ctx context.Context, userID stri
 	}
 	if !contactFound {
 		// what if there is no primary contact of the type found? new error?
-		_, ok := (*cr.users)[userID]
+		_, ok := cr.store.users[userID]
 		if !ok {
 			fields := map[string]interface{}{
 				"UserID":    userID,
@@ -90,8 +89,10 @@ func (cr contactRepo) GetPrimaryContactByUserID(ctx context.Context, userID stri
 func (cr contactRepo) GetContactsByUserID(ctx context.Context, userID string) ([]models.Contact, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetContactsByUserID", cr.GetType())
 	defer span.End()
+	cr.store.lock.RLock()
+	defer cr.store.lock.RUnlock()
 	contacts := make([]models.Contact, 0)
-	for _, c := range *cr.contacts {
+	for _, c := range cr.store.contacts {
 		if c.UserID == userID {
 			contacts = append(contacts, c)
 		}
@@ -112,15 +113,17 @@ func (cr contactRepo) GetContactsByUserID(ctx context.Context, userID string) ([
 func (cr contactRepo) GetContactsByUserIDAndType(ctx context.Context, userID string, contactType string) ([]models.Contact, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetContactsByUserID", cr.GetType())
 	defer span.End()
+	cr.store.lock.RLock()
+	defer cr.store.lock.RUnlock()
 	contacts := make([]models.Contact, 0)
-	for _, c := range *cr.contacts {
+	for _, c := range cr.store.contacts {
 		if c.UserID == userID &&
 			c.Type == contactType {
 			contacts = append(contacts, c)
 		}
 	}
 	if len(contacts) == 0 {
-		_, ok := (*cr.users)[userID]
+		_, ok := cr.store.users[userID]
 		if !ok {
 			// if we get here the user does not exist
 			fields := map[string]interface{}{
@@ -139,7 +142,9 @@ func (cr contactRepo) GetContactsByUserIDAndType(ctx context.Context, userID str
 func (cr contactRepo) AddContact(ctx context.Context, contact *models.Contact, createdByID string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "AddContact", cr.GetType())
 	defer span.End()
-	_, userFound := (*cr.users)[contact.UserID]
+	cr.store.lock.Lock()
+	defer cr.store.lock.Unlock()
+	_, userFound := cr.store.users[contact.UserID]
 	if !userFound {
 		fields := map[string]interface{}{
 			"UserID":    contact.UserID,
@@ -155,7 +160,7 @@ func (cr contactRepo) AddContact(ctx context.Context, contact *models.Contact, c
 	if contact.ID == "" {
 		contact.ID = uuid.Must(uuid.NewRandom()).String()
 	}
-	(*cr.contacts)[contact.ID] = *contact
+	cr.store.contacts[contact.ID] = *contact
 	span.AddEvent("contact added")
 	return nil
 }
@@ -163,9 +168,11 @@ func (cr contactRepo) AddContact(ctx context.Context, contact *models.Contact, c
 func (cr contactRepo) UpdateContact(ctx context.Context, contact *models.Contact, modifiedByID string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "UpdateContact", cr.GetType())
 	defer span.End()
+	cr.store.lock.Lock()
+	defer cr.store.lock.Unlock()
 	contact.AuditData.ModifiedByID = nullable.NullableString{HasValue: true, Value: modifiedByID}
 	contact.AuditData.ModifiedOnDate = nullable.NullableTime{HasValue: true, Value: time.Now().UTC()}
-	(*cr.contacts)[contact.ID] = *contact
+	cr.store.contacts[contact.ID] = *contact
 	span.AddEvent("contact updated")
 	return nil
 }
@@ -173,8 +180,10 @@ func (cr contactRepo) UpdateContact(ctx context.Context, contact *models.Contact
 func (cr contactRepo) GetExistingConfirmedContactsCountByPrincipalAndType(ctx context.Context, contactType, contactPrincipal string) (int64, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetExistingConfirmedContactsCountByPrincipalAndType", cr.GetType())
 	defer span.End()
+	cr.store.lock.RLock()
+	defer cr.store.lock.RUnlock()
 	numConfirmedContacts := int64(0)
-	for _, c := range *cr.contacts {
+	for _, c := range cr.store.contacts {
 		if c.IsConfirmed() &&
 			c.Type == contactType &&
 			c.Principal == contactPrincipal {
@@ -188,14 +197,16 @@ func (cr contactRepo) GetExistingConfirmedContactsCountByPrincipalAndType(ctx co
 func (cr contactRepo) SwapPrimaryContacts(ctx context.Context, previousPrimaryContact, newPrimaryContact *models.Contact, modifiedBy string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "SwapPrimaryContacts", cr.GetType())
 	defer span.End()
+	cr.store.lock.Lock()
+	defer cr.store.lock.Unlock()
 	previousPrimaryContact.IsPrimary = false
 	previousPrimaryContact.AuditData.ModifiedOnDate.Set(time.Now().UTC())
 	previousPrimaryContact.AuditData.ModifiedByID.Set(modifiedBy)
-	(*cr.contacts)[previousPrimaryContact.ID] = *previousPrimaryContact
+	cr.store.contacts[previousPrimaryContact.ID] = *previousPrimaryContact
 	newPrimaryContact.IsPrimary = true
 	newPrimaryContact.AuditData.ModifiedOnDate.Set(time.Now().UTC())
 	newPrimaryContact.AuditData.ModifiedByID.Set(modifiedBy)
-	(*cr.contacts)[newPrimaryContact.ID] = *newPrimaryContact
+	cr.store.contacts[newPrimaryContact.ID] = *newPrimaryContact
 	span.AddEvent("contact primary states set")
 	return nil
 }
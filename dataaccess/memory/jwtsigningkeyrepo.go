@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	repo "github.com/calvine/goauth/core/repositories"
+	"github.com/calvine/richerror/errors"
+)
+
+type jwtSigningKeyRepo struct {
+	lock   sync.Mutex
+	keySet models.JWTSigningKeySet
+}
+
+func NewMemoryJWTSigningKeyRepo() repo.JWTSigningKeyRepo {
+	return &jwtSigningKeyRepo{}
+}
+
+func (*jwtSigningKeyRepo) GetName() string {
+	return "jwtSigningKeyRepo"
+}
+
+func (*jwtSigningKeyRepo) GetType() string {
+	return dataSourceType
+}
+
+func (kr *jwtSigningKeyRepo) GetJWTSigningKeySet(ctx context.Context) (models.JWTSigningKeySet, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, kr.GetName(), "GetJWTSigningKeySet", kr.GetType())
+	defer span.End()
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+	keySet := models.JWTSigningKeySet{
+		Version: kr.keySet.Version,
+		Keys:    append([]models.JWTSigningKey(nil), kr.keySet.Keys...),
+	}
+	span.AddEvent("jwt signing key set retreived")
+	return keySet, nil
+}
+
+func (kr *jwtSigningKeyRepo) SaveJWTSigningKeySet(ctx context.Context, keySet *models.JWTSigningKeySet) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, kr.GetName(), "SaveJWTSigningKeySet", kr.GetType())
+	defer span.End()
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+	if kr.keySet.Version != keySet.Version {
+		rErr := coreerrors.NewJWTSigningKeySetConflictError(keySet.Version, true)
+		apptelemetry.SetSpanOriginalError(&span, rErr, "jwt signing key set was changed by another instance")
+		return rErr
+	}
+	keySet.Version++
+	kr.keySet = models.JWTSigningKeySet{
+		Version: keySet.Version,
+		Keys:    append([]models.JWTSigningKey(nil), keySet.Keys...),
+	}
+	span.AddEvent("jwt signing key set stored")
+	return nil
+}
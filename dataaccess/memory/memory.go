@@ -1,10 +1,21 @@
 package memory
 
-import "github.com/calvine/goauth/core/models"
+import (
+	"sync"
 
-var (
-	users     map[string]models.User
-	contacts  map[string]models.Contact
-	apps      map[string]models.App
-	appScopes map[string][]models.Scope
+	"github.com/calvine/goauth/core/models"
 )
+
+// UserStore holds the users and contacts for a memory user repo and contact repo. The two repos need to see the same users and contacts, so they are created from the same store, which guards the data with a single lock.
+type UserStore struct {
+	lock     sync.RWMutex
+	users    map[string]models.User
+	contacts map[string]models.Contact
+}
+
+func NewUserStore() *UserStore {
+	return &UserStore{
+		users:    make(map[string]models.User),
+		contacts: make(map[string]models.Contact),
+	}
+}
@@ -1,16 +1,128 @@
 package memory
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/calvine/goauth/core"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
 )
 
 func TestMemoryRepositories(t *testing.T) {
 
 }
 
-// func initTestData() {
-// 	usr1 := models.User{}
-// 	cont1 := models.Contact{}
-// 	app1 := models.App{}
-// 	sco1 := models.Scope{}
-// }
+// TestConcurrentAccess exercises the repos from many goroutines at once so the race detector can catch unguarded access.
+func TestConcurrentAccess(t *testing.T) {
+	userStore := NewUserStore()
+	userRepo, err := NewMemoryUserRepo(userStore)
+	if err != nil {
+		t.Fatalf("failed to create user repo: %s", err.GetErrorCode())
+	}
+	contactRepo, err := NewMemoryContactRepo(userStore)
+	if err != nil {
+		t.Fatalf("failed to create contact repo: %s", err.GetErrorCode())
+	}
+	tokenRepo := NewMemoryTokenRepo(context.TODO())
+	const numWorkers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := models.NewUser()
+			if err := userRepo.AddUser(context.TODO(), &user, "concurrency test"); err != nil {
+				t.Errorf("failed to add user: %s", err.GetErrorCode())
+				return
+			}
+			contact := models.NewContact(user.ID, "", fmt.Sprintf("user%d@test.com", i), core.CONTACT_TYPE_EMAIL, true)
+			if err := contactRepo.AddContact(context.TODO(), &contact, "concurrency test"); err != nil {
+				t.Errorf("failed to add contact: %s", err.GetErrorCode())
+				return
+			}
+			if _, err := userRepo.GetFullUserByID(context.TODO(), user.ID); err != nil {
+				t.Errorf("failed to get full user: %s", err.GetErrorCode())
+			}
+			token, err := models.NewToken(user.ID, models.TokenTypeSession, time.Minute)
+			if err != nil {
+				t.Errorf("failed to create token: %s", err.GetErrorCode())
+				return
+			}
+			if err := tokenRepo.PutToken(context.TODO(), token); err != nil {
+				t.Errorf("failed to put token: %s", err.GetErrorCode())
+			}
+			if _, err := tokenRepo.ConsumeToken(context.TODO(), token.Value); err != nil {
+				t.Errorf("failed to consume token: %s", err.GetErrorCode())
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestInstancesDoNotShareState(t *testing.T) {
+	appRepo1 := NewMemoryAppRepo()
+	appRepo2 := NewMemoryAppRepo()
+	app := models.App{ClientID: "instance test client"}
+	if err := appRepo1.AddApp(context.TODO(), &app, "instance test"); err != nil {
+		t.Fatalf("failed to add app: %s", err.GetErrorCode())
+	}
+	_, err := appRepo2.GetAppByID(context.TODO(), app.ID)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeNoAppFound {
+		t.Error("expected app added to one repo to not be found in another repo")
+	}
+}
+
+func TestTokenRepoSweepsExpiredTokens(t *testing.T) {
+	tokenRepo := newTokenRepo()
+	expiredToken, err := models.NewToken("sweep_user", models.TokenTypeSession, time.Second)
+	if err != nil {
+		t.Fatalf("failed to create token: %s", err.GetErrorCode())
+	}
+	liveToken, err := models.NewToken("sweep_user", models.TokenTypeSession, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create token: %s", err.GetErrorCode())
+	}
+	for _, token := range []models.Token{expiredToken, liveToken} {
+		if err := tokenRepo.PutToken(context.TODO(), token); err != nil {
+			t.Fatalf("failed to put token: %s", err.GetErrorCode())
+		}
+	}
+	deleted := tokenRepo.sweepExpiredTokens(time.Now().Add(time.Minute))
+	if deleted != 1 {
+		t.Errorf("expected one token to be swept: got - %d", deleted)
+	}
+	if _, err := tokenRepo.GetToken(context.TODO(), expiredToken.Value); err == nil {
+		t.Error("expected expired token to be swept")
+	}
+	if _, err := tokenRepo.GetToken(context.TODO(), liveToken.Value); err != nil {
+		t.Errorf("expected live token to remain: %s", err.GetErrorCode())
+	}
+}
+
+func TestTokenRepoSweeperStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tokenRepo := NewMemoryTokenRepoWithSweepInterval(ctx, time.Millisecond*10)
+	token, err := models.NewToken("sweeper_user", models.TokenTypeSession, time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create token: %s", err.GetErrorCode())
+	}
+	if err := tokenRepo.PutToken(context.TODO(), token); err != nil {
+		t.Fatalf("failed to put token: %s", err.GetErrorCode())
+	}
+	deadline := time.Now().Add(time.Second * 2)
+	for {
+		_, err := tokenRepo.GetToken(context.TODO(), token.Value)
+		if err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired token was not swept in the background")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	cancel()
+}
@@ -1,33 +1,36 @@
 package memory
 
 import (
+	"context"
 	"testing"
 
-	"github.com/calvine/goauth/core/models"
 	"github.com/calvine/goauth/dataaccess/internal/repotest"
 	"github.com/google/uuid"
 )
 
 func TestMemoryRepos(t *testing.T) {
-	users := make(map[string]models.User)
-	contacts := make(map[string]models.Contact)
-	userRepo, err := NewMemoryUserRepo(&users, &contacts)
+	userStore := NewUserStore()
+	userRepo, err := NewMemoryUserRepo(userStore)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
 	}
-	contactRepo, err := NewMemoryContactRepo(&users, &contacts)
+	contactRepo, err := NewMemoryContactRepo(userStore)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
 	}
 	appRepo := NewMemoryAppRepo()
-	tokenRepo := NewMemoryTokenRepo()
+	tokenRepo := NewMemoryTokenRepo(context.TODO())
+	consentRepo := NewMemoryConsentRepo()
+	jwtSigningKeyRepo := NewMemoryJWTSigningKeyRepo()
 	testHarnessInput := repotest.RepoTestHarnessInput{
-		UserRepo:    &userRepo,
-		ContactRepo: &contactRepo,
-		AppRepo:     &appRepo,
-		TokenRepo:   &tokenRepo,
+		UserRepo:          &userRepo,
+		ContactRepo:       &contactRepo,
+		AppRepo:           &appRepo,
+		TokenRepo:         &tokenRepo,
+		ConsentRepo:       &consentRepo,
+		JWTSigningKeyRepo: &jwtSigningKeyRepo,
 		IDGenerator: func(getZeroId bool) string {
 			if getZeroId {
 				return uuid.UUID{}.String()
@@ -3,6 +3,8 @@ package memory
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/calvine/goauth/core/apptelemetry"
 	coreerrors "github.com/calvine/goauth/core/errors"
@@ -12,25 +14,44 @@ import (
 )
 
 type tokenRepo struct {
+	lock     sync.RWMutex
 	tokenMap map[string]models.Token
 }
 
-func NewMemoryTokenRepo() repo.TokenRepo {
-	tokenMap := make(map[string]models.Token)
-	return &tokenRepo{tokenMap}
+// defaultSweepInterval is how often NewMemoryTokenRepo removes expired tokens.
+const defaultSweepInterval = time.Minute
+
+// NewMemoryTokenRepo creates a memory token repo that removes expired tokens in the background, so a long running process does not hold on to every token it has issued. The sweeper stops when the context is done.
+func NewMemoryTokenRepo(ctx context.Context) repo.TokenRepo {
+	return NewMemoryTokenRepoWithSweepInterval(ctx, defaultSweepInterval)
+}
+
+// NewMemoryTokenRepoWithSweepInterval creates a memory token repo that removes expired tokens in the background every sweep interval. The sweeper stops when the context is done.
+func NewMemoryTokenRepoWithSweepInterval(ctx context.Context, sweepInterval time.Duration) repo.TokenRepo {
+	tr := newTokenRepo()
+	go tr.runSweeper(ctx, sweepInterval)
+	return tr
 }
 
-func (tokenRepo) GetName() string {
+func newTokenRepo() *tokenRepo {
+	return &tokenRepo{
+		tokenMap: make(map[string]models.Token),
+	}
+}
+
+func (*tokenRepo) GetName() string {
 	return "tokenRepo"
 }
 
-func (tokenRepo) GetType() string {
+func (*tokenRepo) GetType() string {
 	return dataSourceType
 }
 
 func (ltr *tokenRepo) GetToken(ctx context.Context, tokenValue string) (models.Token, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ltr.GetName(), "GetToken", ltr.GetType())
 	defer span.End()
+	ltr.lock.RLock()
+	defer ltr.lock.RUnlock()
 	token, ok := ltr.tokenMap[tokenValue]
 	if !ok {
 		evtString := fmt.Sprintf("token not found: %s", tokenValue)
@@ -45,6 +66,8 @@ func (ltr *tokenRepo) GetToken(ctx context.Context, tokenValue string) (models.T
 func (ltr *tokenRepo) PutToken(ctx context.Context, token models.Token) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ltr.GetName(), "PutToken", ltr.GetType())
 	defer span.End()
+	ltr.lock.Lock()
+	defer ltr.lock.Unlock()
 	ltr.tokenMap[token.Value] = token
 	span.AddEvent("token stored")
 	return nil
@@ -53,6 +76,8 @@ func (ltr *tokenRepo) PutToken(ctx context.Context, token models.Token) errors.R
 func (ltr *tokenRepo) DeleteToken(ctx context.Context, tokenValue string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ltr.GetName(), "DeleteToken", ltr.GetType())
 	defer span.End()
+	ltr.lock.Lock()
+	defer ltr.lock.Unlock()
 	_, ok := ltr.tokenMap[tokenValue]
 	if !ok {
 		evtString := fmt.Sprintf("token not found: %s", tokenValue)
@@ -64,3 +89,117 @@ func (ltr *tokenRepo) DeleteToken(ctx context.Context, tokenValue string) errors
 	span.AddEvent("token deleted")
 	return nil
 }
+
+func (ltr *tokenRepo) ConsumeToken(ctx context.Context, tokenValue string) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ltr.GetName(), "ConsumeToken", ltr.GetType())
+	defer span.End()
+	ltr.lock.Lock()
+	defer ltr.lock.Unlock()
+	token, ok := ltr.tokenMap[tokenValue]
+	if !ok {
+		evtString := fmt.Sprintf("token not found: %s", tokenValue)
+		err := coreerrors.NewInvalidTokenError(tokenValue, true)
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return token, err
+	}
+	delete(ltr.tokenMap, tokenValue)
+	span.AddEvent("token consumed")
+	return token, nil
+}
+
+func (ltr *tokenRepo) MarkTokenRotated(ctx context.Context, tokenValue, rotatedTo string, expiration time.Time) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ltr.GetName(), "MarkTokenRotated", ltr.GetType())
+	defer span.End()
+	ltr.lock.Lock()
+	defer ltr.lock.Unlock()
+	token, ok := ltr.tokenMap[tokenValue]
+	if !ok {
+		evtString := fmt.Sprintf("token not found: %s", tokenValue)
+		err := coreerrors.NewInvalidTokenError(tokenValue, true)
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return token, err
+	}
+	if token.MetaData[models.TokenMetaDataKeyRotatedTo] != "" {
+		span.AddEvent("token already rotated")
+		return token, nil
+	}
+	// the meta data is copied so the token returned to the caller is not changed by the update.
+	rotatedToken := token
+	rotatedToken.MetaData = make(map[string]string, len(token.MetaData)+1)
+	for key, value := range token.MetaData {
+		rotatedToken.MetaData[key] = value
+	}
+	rotatedToken.MetaData[models.TokenMetaDataKeyRotatedTo] = rotatedTo
+	rotatedToken.Expiration = expiration
+	ltr.tokenMap[tokenValue] = rotatedToken
+	span.AddEvent("token marked rotated")
+	return token, nil
+}
+
+func (ltr *tokenRepo) DeleteTokensByTargetID(ctx context.Context, targetID string, tokenTypes []models.TokenType) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ltr.GetName(), "DeleteTokensByTargetID", ltr.GetType())
+	defer span.End()
+	ltr.lock.Lock()
+	defer ltr.lock.Unlock()
+	deleted := 0
+	for tokenValue, token := range ltr.tokenMap {
+		if token.TargetID == targetID && containsTokenType(tokenTypes, token.TokenType) {
+			delete(ltr.tokenMap, tokenValue)
+			deleted++
+		}
+	}
+	span.AddEvent(fmt.Sprintf("%d tokens deleted", deleted))
+	return nil
+}
+
+func (ltr *tokenRepo) DeleteTokensByClientID(ctx context.Context, clientID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ltr.GetName(), "DeleteTokensByClientID", ltr.GetType())
+	defer span.End()
+	ltr.lock.Lock()
+	defer ltr.lock.Unlock()
+	deleted := 0
+	for tokenValue, token := range ltr.tokenMap {
+		if token.MetaData[models.TokenMetaDataKeyClientID] == clientID {
+			delete(ltr.tokenMap, tokenValue)
+			deleted++
+		}
+	}
+	span.AddEvent(fmt.Sprintf("%d tokens deleted", deleted))
+	return nil
+}
+
+func (ltr *tokenRepo) runSweeper(ctx context.Context, sweepInterval time.Duration) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			ltr.sweepExpiredTokens(now)
+		}
+	}
+}
+
+// sweepExpiredTokens removes every token that expired before now and returns how many were removed.
+func (ltr *tokenRepo) sweepExpiredTokens(now time.Time) int {
+	ltr.lock.Lock()
+	defer ltr.lock.Unlock()
+	deleted := 0
+	for tokenValue, token := range ltr.tokenMap {
+		if token.Expiration.Before(now) {
+			delete(ltr.tokenMap, tokenValue)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+func containsTokenType(tokenTypes []models.TokenType, tokenType models.TokenType) bool {
+	for _, t := range tokenTypes {
+		if t == tokenType {
+			return true
+		}
+	}
+	return false
+}
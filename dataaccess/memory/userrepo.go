@@ -8,6 +8,7 @@ import (
 	"github.com/calvine/goauth/core/apptelemetry"
 	coreerrors "github.com/calvine/goauth/core/errors"
 	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/models/aggregate"
 	"github.com/calvine/goauth/core/nullable"
 	repo "github.com/calvine/goauth/core/repositories"
 	"github.com/calvine/richerror/errors"
@@ -15,20 +16,15 @@ import (
 )
 
 type userRepo struct {
-	users    *map[string]models.User
-	contacts *map[string]models.Contact
+	store *UserStore
 }
 
-func NewMemoryUserRepo(users *map[string]models.User, contacts *map[string]models.Contact) (repo.UserRepo, errors.RichError) {
-	if users == nil {
-		return userRepo{}, coreerrors.NewNilNotAllowedError(true)
-	}
-	if contacts == nil {
-		return userRepo{}, coreerrors.NewNilNotAllowedError(true)
+func NewMemoryUserRepo(store *UserStore) (repo.UserRepo, errors.RichError) {
+	if store == nil {
+		return userRepo{}, coreerrors.NewNilParameterNotAllowedError("NewMemoryUserRepo", "store", true)
 	}
 	return userRepo{
-		users:    users,
-		contacts: contacts,
+		store: store,
 	}, nil
 }
 
@@ -43,7 +39,9 @@ func (userRepo) GetType() string {
 func (ur userRepo) GetUserByID(ctx context.Context, id string) (models.User, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "GetUserByID", ur.GetType())
 	defer span.End()
-	user, ok := (*ur.users)[id]
+	ur.store.lock.RLock()
+	defer ur.store.lock.RUnlock()
+	user, ok := ur.store.users[id]
 	if !ok {
 		fields := map[string]interface{}{"id": id}
 		err := coreerrors.NewNoUserFoundError(fields, true)
@@ -55,15 +53,42 @@ func (ur userRepo) GetUserByID(ctx context.Context, id string) (models.User, err
 	return user, nil
 }
 
+// GetFullUserByID gets a user and their contacts. Profiles and addresses are not stored by the memory repos, so they are always empty.
+func (ur userRepo) GetFullUserByID(ctx context.Context, id string) (aggregate.FullUser, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "GetFullUserByID", ur.GetType())
+	defer span.End()
+	ur.store.lock.RLock()
+	defer ur.store.lock.RUnlock()
+	user, ok := ur.store.users[id]
+	if !ok {
+		fields := map[string]interface{}{"id": id}
+		err := coreerrors.NewNoUserFoundError(fields, true)
+		evtString := fmt.Sprintf("no user found with id: %s", id)
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return aggregate.FullUser{}, err
+	}
+	contacts := make([]models.Contact, 0)
+	for _, contact := range ur.store.contacts {
+		if contact.UserID == id {
+			contacts = append(contacts, contact)
+		}
+	}
+	profile := models.Profile{UserID: id}
+	span.AddEvent("retreived full user")
+	return aggregate.NewFullUserWithData(user, nil, contacts, &profile), nil
+}
+
 func (ur userRepo) AddUser(ctx context.Context, user *models.User, createdByID string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "AddUser", ur.GetType())
 	defer span.End()
+	ur.store.lock.Lock()
+	defer ur.store.lock.Unlock()
 	user.AuditData.CreatedByID = createdByID
 	user.AuditData.CreatedOnDate = time.Now().UTC()
 	if user.ID == "" {
 		user.ID = uuid.Must(uuid.NewRandom()).String()
 	}
-	(*ur.users)[user.ID] = *user
+	ur.store.users[user.ID] = *user
 	span.AddEvent("user added")
 	return nil
 }
@@ -71,20 +96,56 @@ func (ur userRepo) AddUser(ctx context.Context, user *models.User, createdByID s
 func (ur userRepo) UpdateUser(ctx context.Context, user *models.User, modifiedByID string) errors.RichError {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "UpdateUser", ur.GetType())
 	defer span.End()
+	ur.store.lock.Lock()
+	defer ur.store.lock.Unlock()
 	user.AuditData.ModifiedByID = nullable.NullableString{HasValue: true, Value: modifiedByID}
 	user.AuditData.ModifiedOnDate = nullable.NullableTime{HasValue: true, Value: time.Now().UTC()}
-	(*ur.users)[user.ID] = *user
+	if existingUser, ok := ur.store.users[user.ID]; ok && existingUser.TOTPLastUsedTimeStep > user.TOTPLastUsedTimeStep {
+		user.TOTPLastUsedTimeStep = existingUser.TOTPLastUsedTimeStep
+	}
+	ur.store.users[user.ID] = *user
 	span.AddEvent("user updated")
 	return nil
 }
 
+func (ur userRepo) UpdateTOTPLastUsedTimeStep(ctx context.Context, userID string, timeStep int64, modifiedByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "UpdateTOTPLastUsedTimeStep", ur.GetType())
+	defer span.End()
+	ur.store.lock.Lock()
+	defer ur.store.lock.Unlock()
+	user, ok := ur.store.users[userID]
+	if !ok {
+		fields := map[string]interface{}{
+			"id": userID,
+		}
+		err := coreerrors.NewNoUserFoundError(fields, true)
+		evtString := fmt.Sprintf("no user found with id: %s", userID)
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	if user.TOTPLastUsedTimeStep >= timeStep {
+		err := coreerrors.NewTOTPCodeAlreadyUsedError(userID, timeStep, true)
+		evtString := fmt.Sprintf("totp time step %d already used by user: %s", timeStep, userID)
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	user.TOTPLastUsedTimeStep = timeStep
+	user.AuditData.ModifiedByID = nullable.NullableString{HasValue: true, Value: modifiedByID}
+	user.AuditData.ModifiedOnDate = nullable.NullableTime{HasValue: true, Value: time.Now().UTC()}
+	ur.store.users[userID] = user
+	span.AddEvent("totp last used time step updated")
+	return nil
+}
+
 func (ur userRepo) GetUserByPrimaryContact(ctx context.Context, contactPrincipalType, contactPrincipal string) (models.User, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "GetUserByPrimaryContact", ur.GetType())
 	defer span.End()
+	ur.store.lock.RLock()
+	defer ur.store.lock.RUnlock()
 	var user models.User
 	var contact models.Contact
 	contactFound := false
-	for _, c := range *ur.contacts {
+	for _, c := range ur.store.contacts {
 		if c.Principal == contactPrincipal &&
 			c.Type == contactPrincipalType &&
 			c.IsPrimary {
@@ -104,7 +165,7 @@ func (ur userRepo) GetUserByPrimaryContact(ctx context.Context, contactPrincipal
 		apptelemetry.SetSpanOriginalError(&span, err, evtString)
 		return user, err
 	}
-	user, ok := (*ur.users)[contact.UserID]
+	user, ok := ur.store.users[contact.UserID]
 	if !ok {
 		// this should not be able to happen...
 		fields := map[string]interface{}{
@@ -124,10 +185,12 @@ func (ur userRepo) GetUserByPrimaryContact(ctx context.Context, contactPrincipal
 func (ur userRepo) GetUserAndContactByConfirmedContact(ctx context.Context, contactType, contactPrincipal string) (models.User, models.Contact, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "GetUserAndContactByConfirmedContact", ur.GetType())
 	defer span.End()
+	ur.store.lock.RLock()
+	defer ur.store.lock.RUnlock()
 	var user models.User
 	var contact models.Contact
 	contactFound := false
-	for _, c := range *ur.contacts {
+	for _, c := range ur.store.contacts {
 		if c.Principal == contactPrincipal &&
 			c.Type == contactType &&
 			c.IsConfirmed() {
@@ -146,7 +209,7 @@ func (ur userRepo) GetUserAndContactByConfirmedContact(ctx context.Context, cont
 		apptelemetry.SetSpanOriginalError(&span, err, evtString)
 		return user, contact, err
 	}
-	user, ok := (*ur.users)[contact.UserID]
+	user, ok := ur.store.users[contact.UserID]
 	if !ok {
 		// this should not be able to happen...
 		fields := map[string]interface{}{
@@ -0,0 +1,680 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/nullable"
+	repoModels "github.com/calvine/goauth/dataaccess/mongo/internal/models"
+	"github.com/calvine/richerror/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// appRepo is the repository struct for apps and their scopes. Scopes are kept in their own collection so they can be looked up by id without loading the app.
+type appRepo struct {
+	mongoClient         *mongo.Client
+	dbName              string
+	appCollectionName   string
+	scopeCollectionName string
+	// consentCollectionName is the collection of the consent repo, the consents given to an app are deleted with it.
+	consentCollectionName string
+}
+
+func NewAppRepo(client *mongo.Client) appRepo {
+	return appRepo{client, DB_NAME, APP_COLLECTION, SCOPE_COLLECTION, CONSENT_COLLECTION}
+}
+
+func NewAppRepoWithNames(client *mongo.Client, dbName, appCollectionName, scopeCollectionName, consentCollectionName string) appRepo {
+	return appRepo{client, dbName, appCollectionName, scopeCollectionName, consentCollectionName}
+}
+
+func (appRepo) GetName() string {
+	return "appRepo"
+}
+
+func (appRepo) GetType() string {
+	return dataSourceType
+}
+
+// EnsureIndexes creates the indexes the app repo relies on. Client ids must be unique because apps are looked up by them when they authenticate.
+func (ar appRepo) EnsureIndexes(ctx context.Context) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "EnsureIndexes", ar.GetType())
+	defer span.End()
+	appIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "clientId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "ownerId", Value: 1}},
+		},
+	}
+	_, err := ar.appCollection().Indexes().CreateMany(ctx, appIndexes)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	scopeIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "appId", Value: 1}},
+	}
+	_, err = ar.scopeCollection().Indexes().CreateOne(ctx, scopeIndex)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("indexes created")
+	return nil
+}
+
+func (ar appRepo) GetAppByID(ctx context.Context, id string) (models.App, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppByID", ar.GetType())
+	defer span.End()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		rErr := coreerrors.NewFailedToParseObjectIDError(id, err, true)
+		evtString := fmt.Sprintf("%s: %s", rErr.GetErrorMessage(), id)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.App{}, rErr
+	}
+	app, rErr := ar.findApp(ctx, bson.M{"_id": oid})
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to find app with id: %s", id)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.App{}, rErr
+	}
+	span.AddEvent("app retreived")
+	return app, nil
+}
+
+func (ar appRepo) GetAppsByOwnerID(ctx context.Context, ownerID string) ([]models.App, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppsByOwnerID", ar.GetType())
+	defer span.End()
+	filter := bson.M{"ownerId": ownerID}
+	cursor, err := ar.appCollection().Find(ctx, filter)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	var repoApps []repoModels.RepoApp
+	err = cursor.All(ctx, &repoApps)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	if len(repoApps) == 0 {
+		fields := map[string]interface{}{"ownerId": ownerID}
+		rErr := coreerrors.NewNoAppFoundError(fields, true)
+		evtString := fmt.Sprintf("no apps found for owner id: %s", ownerID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	apps := make([]models.App, 0, len(repoApps))
+	for _, repoApp := range repoApps {
+		apps = append(apps, repoApp.ToCoreApp())
+	}
+	span.AddEvent("apps retreived")
+	return apps, nil
+}
+
+func (ar appRepo) GetAppByClientID(ctx context.Context, clientID string) (models.App, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppByClientID", ar.GetType())
+	defer span.End()
+	app, rErr := ar.findApp(ctx, bson.M{"clientId": clientID})
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to find app with client id: %s", clientID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.App{}, rErr
+	}
+	span.AddEvent("app retreived")
+	return app, nil
+}
+
+func (ar appRepo) GetAppAndScopesByClientID(ctx context.Context, clientID string) (models.App, []models.Scope, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppAndScopesByClientID", ar.GetType())
+	defer span.End()
+	app, rErr := ar.findApp(ctx, bson.M{"clientId": clientID})
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to find app with client id: %s", clientID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.App{}, nil, rErr
+	}
+	span.AddEvent("app retreived")
+	appOID, _ := primitive.ObjectIDFromHex(app.ID)
+	scopes, rErr := ar.findScopes(ctx, bson.M{"appId": appOID})
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to find scopes for app with client id: %s", clientID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.App{}, nil, rErr
+	}
+	span.AddEvent("app and scopes retreived")
+	return app, scopes, nil
+}
+
+func (ar appRepo) AddApp(ctx context.Context, app *models.App, createdBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "AddApp", ar.GetType())
+	defer span.End()
+	app.AuditData.CreatedByID = createdBy
+	app.AuditData.CreatedOnDate = time.Now().UTC()
+	repoApp := repoModels.CoreApp(*app).ToRepoAppWithoutID()
+	repoApp.ObjectID = primitive.NewObjectID()
+	_, err := ar.appCollection().InsertOne(ctx, &repoApp)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	app.ID = repoApp.ObjectID.Hex()
+	span.AddEvent("app added")
+	return nil
+}
+
+func (ar appRepo) UpdateApp(ctx context.Context, app *models.App, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "UpdateApp", ar.GetType())
+	defer span.End()
+	app.AuditData.ModifiedByID.Set(modifiedBy)
+	app.AuditData.ModifiedOnDate.Set(time.Now().UTC())
+	repoApp, rErr := repoModels.CoreApp(*app).ToRepoApp()
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to convert app to repo app: %s", rErr.GetErrorMessage())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	filter := bson.M{
+		"_id": bson.M{
+			"$eq": repoApp.ObjectID,
+		},
+	}
+	// the client id and the created audit data never change, so they are left out of the update.
+	// the client secrets are changed one at a time by the client secret functions, so they are left out as well.
+	update := bson.M{
+		"$set": bson.M{
+			"ownerId":        repoApp.OwnerID,
+			"name":           repoApp.Name,
+			"redirectUris":   repoApp.RedirectURIs,
+			"allowedOrigins": repoApp.AllowedOrigins,
+			"isDisabled":     repoApp.IsDisabled,
+			"policy":         repoApp.Policy,
+			"logoUri":        repoApp.LogoURI,
+			"modifiedById":   repoApp.AuditData.ModifiedByID.GetPointerCopy(),
+			"modifiedOnDate": repoApp.AuditData.ModifiedOnDate.GetPointerCopy(),
+		},
+	}
+	result, err := ar.appCollection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if result.MatchedCount == 0 {
+		fields := map[string]interface{}{"_id": app.ID}
+		rErr := coreerrors.NewNoAppFoundError(fields, true)
+		evtString := fmt.Sprintf("no app found with id: %s", app.ID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("app updated")
+	return nil
+}
+
+func (ar appRepo) AddClientSecret(ctx context.Context, appID string, clientSecret models.ClientSecret, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "AddClientSecret", ar.GetType())
+	defer span.End()
+	oid, err := primitive.ObjectIDFromHex(appID)
+	if err != nil {
+		rErr := coreerrors.NewFailedToParseObjectIDError(appID, err, true)
+		evtString := fmt.Sprintf("%s: %s", rErr.GetErrorMessage(), appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	update := bson.M{
+		"$push": bson.M{
+			"clientSecrets": &clientSecret,
+		},
+		"$set": appModifiedFields(modifiedBy),
+	}
+	result, err := ar.appCollection().UpdateOne(ctx, bson.M{"_id": oid}, update)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if result.MatchedCount == 0 {
+		fields := map[string]interface{}{"_id": appID}
+		rErr := coreerrors.NewNoAppFoundError(fields, true)
+		evtString := fmt.Sprintf("no app found with id: %s", appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("client secret added")
+	return nil
+}
+
+func (ar appRepo) SetClientSecretExpiration(ctx context.Context, appID, clientSecretID string, expirationDate nullable.NullableTime, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "SetClientSecretExpiration", ar.GetType())
+	defer span.End()
+	oid, err := primitive.ObjectIDFromHex(appID)
+	if err != nil {
+		rErr := coreerrors.NewFailedToParseObjectIDError(appID, err, true)
+		evtString := fmt.Sprintf("%s: %s", rErr.GetErrorMessage(), appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	filter := bson.M{
+		"_id":              oid,
+		"clientSecrets.id": clientSecretID,
+	}
+	set := appModifiedFields(modifiedBy)
+	set["clientSecrets.$.expirationDate"] = expirationDate.GetPointerCopy()
+	result, err := ar.appCollection().UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if result.MatchedCount == 0 {
+		exists, rErr := ar.appExists(ctx, oid)
+		if rErr == nil && !exists {
+			fields := map[string]interface{}{"_id": appID}
+			rErr = coreerrors.NewNoAppFoundError(fields, true)
+		} else if rErr == nil {
+			rErr = coreerrors.NewClientSecretNotFoundError(appID, clientSecretID, true)
+		}
+		evtString := fmt.Sprintf("failed to set expiration of client secret %s for app with id: %s", clientSecretID, appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("client secret expiration set")
+	return nil
+}
+
+// RevokeClientSecret only matches the app when another of its client secrets is active at now, so the check and the removal are a single atomic update of the app document.
+func (ar appRepo) RevokeClientSecret(ctx context.Context, appID, clientSecretID string, now time.Time, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "RevokeClientSecret", ar.GetType())
+	defer span.End()
+	oid, err := primitive.ObjectIDFromHex(appID)
+	if err != nil {
+		rErr := coreerrors.NewFailedToParseObjectIDError(appID, err, true)
+		evtString := fmt.Sprintf("%s: %s", rErr.GetErrorMessage(), appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	filter := bson.M{
+		"_id":              oid,
+		"clientSecrets.id": clientSecretID,
+		"clientSecrets": bson.M{
+			"$elemMatch": bson.M{
+				"id": bson.M{"$ne": clientSecretID},
+				"$or": bson.A{
+					bson.M{"expirationDate": nil},
+					bson.M{"expirationDate": bson.M{"$gt": now.UTC()}},
+				},
+			},
+		},
+	}
+	update := bson.M{
+		"$pull": bson.M{
+			"clientSecrets": bson.M{"id": clientSecretID},
+		},
+		"$set": appModifiedFields(modifiedBy),
+	}
+	result, err := ar.appCollection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if result.MatchedCount == 0 {
+		// the app is read back to tell a missing app or client secret apart from the client secret being the last active one.
+		app, rErr := ar.findApp(ctx, bson.M{"_id": oid})
+		if rErr == nil {
+			rErr = coreerrors.NewClientSecretNotFoundError(appID, clientSecretID, true)
+			for _, clientSecret := range app.ClientSecrets {
+				if clientSecret.ID == clientSecretID {
+					rErr = coreerrors.NewLastActiveClientSecretError(appID, true)
+					break
+				}
+			}
+		}
+		evtString := fmt.Sprintf("failed to revoke client secret %s for app with id: %s", clientSecretID, appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("client secret revoked")
+	return nil
+}
+
+// DeleteApp deletes the app and all of its scopes in a single transaction so scopes are never left behind for an app that no longer exists.
+func (ar appRepo) DeleteApp(ctx context.Context, app *models.App, deletedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "DeleteApp", ar.GetType())
+	defer span.End()
+	oid, err := primitive.ObjectIDFromHex(app.ID)
+	if err != nil {
+		rErr := coreerrors.NewFailedToParseObjectIDError(app.ID, err, true)
+		evtString := fmt.Sprintf("%s: %s", rErr.GetErrorMessage(), app.ID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	wc := writeconcern.New(writeconcern.WMajority())
+	rc := readconcern.Snapshot()
+	txnOpts := options.Transaction().SetWriteConcern(wc).SetReadConcern(rc)
+	session, err := ar.mongoClient.StartSession()
+	if err != nil {
+		rErr := coreerrors.NewDatastoreTransactionFailedError(err, true)
+		evtString := fmt.Sprintf("failed to start session: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	defer session.EndSession(context.Background())
+
+	err = mongo.WithSession(ctx, session, func(sessionContext mongo.SessionContext) error {
+		err := session.StartTransaction(txnOpts)
+		if err != nil {
+			return coreerrors.NewDatastoreTransactionFailedError(err, true)
+		}
+		appDeleteResult, err := ar.appCollection().DeleteOne(sessionContext, bson.M{"_id": oid})
+		if err != nil {
+			abortErr := session.AbortTransaction(sessionContext)
+			if abortErr != nil {
+				return coreerrors.NewDatastoreTransactionAbortFailedError(err, abortErr, true)
+			}
+			return coreerrors.NewDatastoreTransactionFailedError(err, true)
+		}
+		if appDeleteResult.DeletedCount == 0 {
+			fields := map[string]interface{}{"_id": app.ID}
+			rErr := coreerrors.NewNoAppFoundError(fields, true)
+			abortErr := session.AbortTransaction(sessionContext)
+			if abortErr != nil {
+				return coreerrors.NewDatastoreTransactionAbortFailedError(rErr, abortErr, true)
+			}
+			return rErr
+		}
+		_, err = ar.scopeCollection().DeleteMany(sessionContext, bson.M{"appId": oid})
+		if err != nil {
+			abortErr := session.AbortTransaction(sessionContext)
+			if abortErr != nil {
+				return coreerrors.NewDatastoreTransactionAbortFailedError(err, abortErr, true)
+			}
+			return coreerrors.NewDatastoreTransactionFailedError(err, true)
+		}
+		// consents store the app id as a string rather than an object id.
+		_, err = ar.consentCollection().DeleteMany(sessionContext, bson.M{"appId": app.ID})
+		if err != nil {
+			abortErr := session.AbortTransaction(sessionContext)
+			if abortErr != nil {
+				return coreerrors.NewDatastoreTransactionAbortFailedError(err, abortErr, true)
+			}
+			return coreerrors.NewDatastoreTransactionFailedError(err, true)
+		}
+		err = session.CommitTransaction(sessionContext)
+		if err != nil {
+			return coreerrors.NewDatastoreTransactionFailedError(err, true)
+		}
+		return nil
+	})
+	if err != nil {
+		// the function above always returns a RichError, so we can skip additional error code handling here.
+		rErr := err.(errors.RichError)
+		evtString := fmt.Sprintf("failed to delete app with id: %s", app.ID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("app and scopes deleted")
+	return nil
+}
+
+func (ar appRepo) GetScopeByID(ctx context.Context, id string) (models.Scope, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetScopeByID", ar.GetType())
+	defer span.End()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		rErr := coreerrors.NewFailedToParseObjectIDError(id, err, true)
+		evtString := fmt.Sprintf("%s: %s", rErr.GetErrorMessage(), id)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Scope{}, rErr
+	}
+	var repoScope repoModels.RepoScope
+	err = ar.scopeCollection().FindOne(ctx, bson.M{"_id": oid}).Decode(&repoScope)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			fields := map[string]interface{}{"_id": id}
+			rErr := coreerrors.NewNoScopeFoundError(fields, true)
+			evtString := fmt.Sprintf("no scope found with id: %s", id)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.Scope{}, rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Scope{}, rErr
+	}
+	span.AddEvent("scope retreived")
+	return repoScope.ToCoreScope(), nil
+}
+
+func (ar appRepo) GetScopesByAppID(ctx context.Context, appID string) ([]models.Scope, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetScopesByAppID", ar.GetType())
+	defer span.End()
+	appOID, err := primitive.ObjectIDFromHex(appID)
+	if err != nil {
+		rErr := coreerrors.NewFailedToParseObjectIDError(appID, err, true)
+		evtString := fmt.Sprintf("%s: %s", rErr.GetErrorMessage(), appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	scopes, rErr := ar.findScopes(ctx, bson.M{"appId": appOID})
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to find scopes for app id: %s", appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	if len(scopes) == 0 {
+		// an app without scopes is valid, so the app is only checked when there are no scopes to tell an unknown app apart.
+		exists, rErr := ar.appExists(ctx, appOID)
+		if rErr != nil {
+			evtString := fmt.Sprintf("failed to check app exists for app id: %s", appID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return nil, rErr
+		}
+		if !exists {
+			fields := map[string]interface{}{"appId": appID}
+			rErr := coreerrors.NewNoScopeFoundError(fields, true)
+			evtString := fmt.Sprintf("no scopes found with app id: %s", appID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return nil, rErr
+		}
+	}
+	span.AddEvent("scopes retreived")
+	return scopes, nil
+}
+
+func (ar appRepo) AddScope(ctx context.Context, scope *models.Scope, createdBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "AddScope", ar.GetType())
+	defer span.End()
+	scope.AuditData.CreatedByID = createdBy
+	scope.AuditData.CreatedOnDate = time.Now().UTC()
+	repoScope, rErr := repoModels.CoreScope(*scope).ToRepoScopeWithoutID()
+	if rErr != nil {
+		evtString := fmt.Sprintf("%s app id: %s", rErr.GetErrorMessage(), scope.AppID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	exists, rErr := ar.appExists(ctx, repoScope.AppObjectID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to check app exists for app id: %s", scope.AppID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if !exists {
+		fields := map[string]interface{}{"ID": scope.ID, "AppID": scope.AppID}
+		rErr := coreerrors.NewNoAppFoundError(fields, true)
+		evtString := fmt.Sprintf("no app found with app id: %s", scope.AppID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	repoScope.ObjectID = primitive.NewObjectID()
+	_, err := ar.scopeCollection().InsertOne(ctx, &repoScope)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	scope.ID = repoScope.ObjectID.Hex()
+	span.AddEvent("scope added")
+	return nil
+}
+
+func (ar appRepo) UpdateScope(ctx context.Context, scope *models.Scope, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "UpdateScope", ar.GetType())
+	defer span.End()
+	scope.AuditData.ModifiedByID.Set(modifiedBy)
+	scope.AuditData.ModifiedOnDate.Set(time.Now().UTC())
+	repoScope, rErr := repoModels.CoreScope(*scope).ToRepoScope()
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to convert scope to repo scope: %s", rErr.GetErrorMessage())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	filter := bson.M{
+		"_id":   repoScope.ObjectID,
+		"appId": repoScope.AppObjectID,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"name":           repoScope.Name,
+			"description":    repoScope.Description,
+			"modifiedById":   repoScope.AuditData.ModifiedByID.GetPointerCopy(),
+			"modifiedOnDate": repoScope.AuditData.ModifiedOnDate.GetPointerCopy(),
+		},
+	}
+	result, err := ar.scopeCollection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if result.MatchedCount == 0 {
+		fields := map[string]interface{}{"ID": scope.ID, "appID": scope.AppID}
+		rErr := coreerrors.NewNoScopeFoundError(fields, true)
+		evtString := fmt.Sprintf("no scope found with id: %s", scope.ID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("scope updated")
+	return nil
+}
+
+func (ar appRepo) DeleteScope(ctx context.Context, scope *models.Scope, deletedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "DeleteScope", ar.GetType())
+	defer span.End()
+	repoScope, rErr := repoModels.CoreScope(*scope).ToRepoScope()
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to convert scope to repo scope: %s", rErr.GetErrorMessage())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	filter := bson.M{
+		"_id":   repoScope.ObjectID,
+		"appId": repoScope.AppObjectID,
+	}
+	result, err := ar.scopeCollection().DeleteOne(ctx, filter)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if result.DeletedCount == 0 {
+		fields := map[string]interface{}{"ID": scope.ID, "appID": scope.AppID}
+		rErr := coreerrors.NewNoScopeFoundError(fields, true)
+		evtString := fmt.Sprintf("no scope found with id: %s", scope.ID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("scope deleted")
+	return nil
+}
+
+func (ar appRepo) appCollection() *mongo.Collection {
+	return ar.mongoClient.Database(ar.dbName).Collection(ar.appCollectionName)
+}
+
+func (ar appRepo) scopeCollection() *mongo.Collection {
+	return ar.mongoClient.Database(ar.dbName).Collection(ar.scopeCollectionName)
+}
+
+func (ar appRepo) consentCollection() *mongo.Collection {
+	return ar.mongoClient.Database(ar.dbName).Collection(ar.consentCollectionName)
+}
+
+// findApp finds a single app matching the filter, returning a NoAppFound error if there is none.
+func (ar appRepo) findApp(ctx context.Context, filter bson.M) (models.App, errors.RichError) {
+	var repoApp repoModels.RepoApp
+	err := ar.appCollection().FindOne(ctx, filter).Decode(&repoApp)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			fields := map[string]interface{}(filter)
+			return models.App{}, coreerrors.NewNoAppFoundError(fields, true)
+		}
+		return models.App{}, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	return repoApp.ToCoreApp(), nil
+}
+
+// findScopes finds every scope matching the filter.
+func (ar appRepo) findScopes(ctx context.Context, filter bson.M) ([]models.Scope, errors.RichError) {
+	cursor, err := ar.scopeCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	var repoScopes []repoModels.RepoScope
+	err = cursor.All(ctx, &repoScopes)
+	if err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	scopes := make([]models.Scope, 0, len(repoScopes))
+	for _, repoScope := range repoScopes {
+		scopes = append(scopes, repoScope.ToCoreScope())
+	}
+	return scopes, nil
+}
+
+// appModifiedFields returns the fields to set on an app document when it is modified.
+func appModifiedFields(modifiedBy string) bson.M {
+	return bson.M{
+		"modifiedById":   modifiedBy,
+		"modifiedOnDate": time.Now().UTC(),
+	}
+}
+
+// appExists returns true if an app with the object id exists.
+func (ar appRepo) appExists(ctx context.Context, appOID primitive.ObjectID) (bool, errors.RichError) {
+	count, err := ar.appCollection().CountDocuments(ctx, bson.M{"_id": appOID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,117 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	repoModels "github.com/calvine/goauth/dataaccess/mongo/internal/models"
+	"github.com/calvine/richerror/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// consentRepo is the repository struct for consents. A unique index on user, app and scope keeps concurrent grants of the same scope from storing it twice.
+type consentRepo struct {
+	mongoClient    *mongo.Client
+	dbName         string
+	collectionName string
+}
+
+func NewConsentRepo(client *mongo.Client) consentRepo {
+	return consentRepo{client, DB_NAME, CONSENT_COLLECTION}
+}
+
+func NewConsentRepoWithNames(client *mongo.Client, dbName, collectionName string) consentRepo {
+	return consentRepo{client, dbName, collectionName}
+}
+
+func (consentRepo) GetName() string {
+	return "consentRepo"
+}
+
+func (consentRepo) GetType() string {
+	return dataSourceType
+}
+
+// EnsureIndexes creates the unique index on user, app and scope that consents are looked up and deduplicated by.
+func (cr consentRepo) EnsureIndexes(ctx context.Context) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "EnsureIndexes", cr.GetType())
+	defer span.End()
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "appId", Value: 1}, {Key: "scopeName", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := cr.collection().Indexes().CreateOne(ctx, index)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("indexes created")
+	return nil
+}
+
+func (cr consentRepo) GetConsentsByUserIDAndAppID(ctx context.Context, userID, appID string) ([]models.Consent, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetConsentsByUserIDAndAppID", cr.GetType())
+	defer span.End()
+	cursor, err := cr.collection().Find(ctx, bson.M{"userId": userID, "appId": appID})
+	if err == nil {
+		var repoConsents []repoModels.RepoConsent
+		err = cursor.All(ctx, &repoConsents)
+		if err == nil {
+			consents := make([]models.Consent, 0, len(repoConsents))
+			for _, repoConsent := range repoConsents {
+				consents = append(consents, repoConsent.ToCoreConsent())
+			}
+			span.AddEvent("consents retreived")
+			return consents, nil
+		}
+	}
+	rErr := coreerrors.NewRepoQueryFailedError(err, true)
+	evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+	apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+	return nil, rErr
+}
+
+// AddConsent upserts on user, app and scope with set on insert, so an existing consent is left as is and returned instead.
+func (cr consentRepo) AddConsent(ctx context.Context, consent *models.Consent, createdByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "AddConsent", cr.GetType())
+	defer span.End()
+	filter := bson.M{
+		"userId":    consent.UserID,
+		"appId":     consent.AppID,
+		"scopeName": consent.ScopeName,
+	}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"_id":            primitive.NewObjectID(),
+			"createdById":    createdByID,
+			"createdOnDate":  time.Now().UTC(),
+			"modifiedById":   nil,
+			"modifiedOnDate": nil,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var repoConsent repoModels.RepoConsent
+	err := cr.collection().FindOneAndUpdate(ctx, filter, update, opts).Decode(&repoConsent)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	*consent = repoConsent.ToCoreConsent()
+	span.AddEvent("consent stored")
+	return nil
+}
+
+func (cr consentRepo) collection() *mongo.Collection {
+	return cr.mongoClient.Database(cr.dbName).Collection(cr.collectionName)
+}
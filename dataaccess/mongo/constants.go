@@ -3,8 +3,13 @@ package mongo
 const (
 	DB_NAME = "goauth"
 
-	USER_COLLECTION     = "users"
-	AUDITLOG_COLLECTION = "auditlog"
+	USER_COLLECTION            = "users"
+	AUDITLOG_COLLECTION        = "auditlog"
+	APP_COLLECTION             = "apps"
+	SCOPE_COLLECTION           = "scopes"
+	TOKEN_COLLECTION           = "tokens"
+	CONSENT_COLLECTION         = "consents"
+	JWT_SIGNING_KEY_COLLECTION = "jwtsigningkeys"
 
 	dataSourceType = "mongo"
 )
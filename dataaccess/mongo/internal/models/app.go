@@ -0,0 +1,43 @@
+package models
+
+import (
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CoreApp models.App
+
+// RepoApp is an app document. The owner id is stored as a string because apps can be owned by things other than users.
+type RepoApp struct {
+	ObjectID primitive.ObjectID `bson:"_id"`
+	OwnerID  string             `bson:"ownerId"`
+	CoreApp  `bson:",inline"`
+}
+
+func (ra RepoApp) ToCoreApp() models.App {
+	ra.CoreApp.ID = ra.ObjectID.Hex()
+	ra.CoreApp.OwnerID = ra.OwnerID
+
+	return models.App(ra.CoreApp)
+}
+
+func (ca CoreApp) ToRepoApp() (RepoApp, errors.RichError) {
+	oid, err := primitive.ObjectIDFromHex(ca.ID)
+	if err != nil {
+		return RepoApp{}, coreerrors.NewFailedToParseObjectIDError(ca.ID, err, true)
+	}
+	return RepoApp{
+		ObjectID: oid,
+		OwnerID:  ca.OwnerID,
+		CoreApp:  ca,
+	}, nil
+}
+
+func (ca CoreApp) ToRepoAppWithoutID() RepoApp {
+	return RepoApp{
+		OwnerID: ca.OwnerID,
+		CoreApp: ca,
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"github.com/calvine/goauth/core/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CoreConsent models.Consent
+
+// RepoConsent is a consent document. The user and app ids are kept as they were given rather than as object ids so consents do not depend on how the user and app repos store their ids.
+type RepoConsent struct {
+	ObjectID    primitive.ObjectID `bson:"_id"`
+	CoreConsent `bson:",inline"`
+}
+
+func (rc RepoConsent) ToCoreConsent() models.Consent {
+	rc.CoreConsent.ID = rc.ObjectID.Hex()
+
+	return models.Consent(rc.CoreConsent)
+}
@@ -0,0 +1,9 @@
+package models
+
+import "github.com/calvine/goauth/core/models"
+
+// RepoJWTSigningKeySet is the jwt signing key set document. There is only one key set, so the document has a fixed id.
+type RepoJWTSigningKeySet struct {
+	ID                      string `bson:"_id"`
+	models.JWTSigningKeySet `bson:",inline"`
+}
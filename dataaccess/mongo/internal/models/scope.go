@@ -0,0 +1,48 @@
+package models
+
+import (
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CoreScope models.Scope
+
+// RepoScope is a scope document. Scopes are kept in their own collection and reference their app by its object id.
+type RepoScope struct {
+	ObjectID    primitive.ObjectID `bson:"_id"`
+	AppObjectID primitive.ObjectID `bson:"appId"`
+	CoreScope   `bson:",inline"`
+}
+
+func (rs RepoScope) ToCoreScope() models.Scope {
+	rs.CoreScope.ID = rs.ObjectID.Hex()
+	rs.CoreScope.AppID = rs.AppObjectID.Hex()
+
+	return models.Scope(rs.CoreScope)
+}
+
+func (cs CoreScope) ToRepoScope() (RepoScope, errors.RichError) {
+	oid, err := primitive.ObjectIDFromHex(cs.ID)
+	if err != nil {
+		return RepoScope{}, coreerrors.NewFailedToParseObjectIDError(cs.ID, err, true)
+	}
+	repoScope, rErr := cs.ToRepoScopeWithoutID()
+	if rErr != nil {
+		return RepoScope{}, rErr
+	}
+	repoScope.ObjectID = oid
+	return repoScope, nil
+}
+
+func (cs CoreScope) ToRepoScopeWithoutID() (RepoScope, errors.RichError) {
+	appOID, err := primitive.ObjectIDFromHex(cs.AppID)
+	if err != nil {
+		return RepoScope{}, coreerrors.NewFailedToParseObjectIDError(cs.AppID, err, true)
+	}
+	return RepoScope{
+		AppObjectID: appOID,
+		CoreScope:   cs,
+	}, nil
+}
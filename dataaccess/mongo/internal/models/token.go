@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/calvine/goauth/core/models"
+)
+
+type CoreToken models.Token
+
+// RepoToken is a token document. The value is the lookup key for a token and has a unique index, and the expiration has a TTL index so mongo removes tokens once they expire.
+type RepoToken struct {
+	Value      string            `bson:"value"`
+	TokenType  models.TokenType  `bson:"tokenType"`
+	Expiration time.Time         `bson:"expiration"`
+	TargetID   string            `bson:"targetId"`
+	MetaData   map[string]string `bson:"metaData"`
+}
+
+func (rt RepoToken) ToCoreToken() models.Token {
+	return models.Token{
+		Value:      rt.Value,
+		TokenType:  rt.TokenType,
+		Expiration: rt.Expiration,
+		TargetID:   rt.TargetID,
+		MetaData:   rt.MetaData,
+	}
+}
+
+func (ct CoreToken) ToRepoToken() RepoToken {
+	return RepoToken{
+		Value:      ct.Value,
+		TokenType:  ct.TokenType,
+		Expiration: ct.Expiration,
+		TargetID:   ct.TargetID,
+		MetaData:   ct.MetaData,
+	}
+}
@@ -3,6 +3,7 @@ package models
 import (
 	coreerrors "github.com/calvine/goauth/core/errors"
 	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/models/aggregate"
 	"github.com/calvine/richerror/errors"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -37,3 +38,30 @@ func (cu CoreUser) ToRepoUserWithoutID() RepoUser {
 		CoreUser: cu,
 	}
 }
+
+// RepoFullUser is a user document along with the profile, contacts and addresses embedded in it.
+type RepoFullUser struct {
+	RepoUser  `bson:",inline"`
+	Addresses []RepoAddress  `bson:"addresses"`
+	Contacts  []RepoContact  `bson:"contacts"`
+	Profile   models.Profile `bson:"profile"`
+}
+
+func (rfu RepoFullUser) ToCoreFullUser() aggregate.FullUser {
+	user := rfu.ToCoreUser()
+	addresses := make([]models.Address, 0, len(rfu.Addresses))
+	for _, repoAddress := range rfu.Addresses {
+		address := repoAddress.ToCoreAddress()
+		address.UserID = user.ID
+		addresses = append(addresses, address)
+	}
+	contacts := make([]models.Contact, 0, len(rfu.Contacts))
+	for _, repoContact := range rfu.Contacts {
+		contact := repoContact.ToCoreContact()
+		contact.UserID = user.ID
+		contacts = append(contacts, contact)
+	}
+	profile := rfu.Profile
+	profile.UserID = user.ID
+	return aggregate.NewFullUserWithData(user, addresses, contacts, &profile)
+}
@@ -0,0 +1,107 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	repoModels "github.com/calvine/goauth/dataaccess/mongo/internal/models"
+	"github.com/calvine/richerror/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// jwtSigningKeySetID is the id of the only jwt signing key set document.
+const jwtSigningKeySetID = "jwtSigningKeySet"
+
+// jwtSigningKeyRepo is the repository struct for the jwt signing key set shared by every instance. The whole key set is one document so it can be replaced with a single compare and swap on the version.
+type jwtSigningKeyRepo struct {
+	mongoClient    *mongo.Client
+	dbName         string
+	collectionName string
+}
+
+func NewJWTSigningKeyRepo(client *mongo.Client) jwtSigningKeyRepo {
+	return jwtSigningKeyRepo{client, DB_NAME, JWT_SIGNING_KEY_COLLECTION}
+}
+
+func NewJWTSigningKeyRepoWithNames(client *mongo.Client, dbName, collectionName string) jwtSigningKeyRepo {
+	return jwtSigningKeyRepo{client, dbName, collectionName}
+}
+
+func (jwtSigningKeyRepo) GetName() string {
+	return "jwtSigningKeyRepo"
+}
+
+func (jwtSigningKeyRepo) GetType() string {
+	return dataSourceType
+}
+
+func (kr jwtSigningKeyRepo) GetJWTSigningKeySet(ctx context.Context) (models.JWTSigningKeySet, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, kr.GetName(), "GetJWTSigningKeySet", kr.GetType())
+	defer span.End()
+	var repoKeySet repoModels.RepoJWTSigningKeySet
+	err := kr.collection().FindOne(ctx, bson.M{"_id": jwtSigningKeySetID}).Decode(&repoKeySet)
+	if err == mongo.ErrNoDocuments {
+		span.AddEvent("no jwt signing key set stored")
+		return models.JWTSigningKeySet{}, nil
+	}
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.JWTSigningKeySet{}, rErr
+	}
+	span.AddEvent("jwt signing key set retreived")
+	return repoKeySet.JWTSigningKeySet, nil
+}
+
+// SaveJWTSigningKeySet inserts the key set when none has been stored yet, and otherwise updates it with a filter on the version.
+func (kr jwtSigningKeyRepo) SaveJWTSigningKeySet(ctx context.Context, keySet *models.JWTSigningKeySet) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, kr.GetName(), "SaveJWTSigningKeySet", kr.GetType())
+	defer span.End()
+	conflict := false
+	var err error
+	if keySet.Version == 0 {
+		repoKeySet := repoModels.RepoJWTSigningKeySet{
+			ID: jwtSigningKeySetID,
+			JWTSigningKeySet: models.JWTSigningKeySet{
+				Version: 1,
+				Keys:    keySet.Keys,
+			},
+		}
+		_, err = kr.collection().InsertOne(ctx, repoKeySet)
+		if mongo.IsDuplicateKeyError(err) {
+			conflict = true
+			err = nil
+		}
+	} else {
+		filter := bson.M{"_id": jwtSigningKeySetID, "version": keySet.Version}
+		update := bson.M{"$set": bson.M{"version": keySet.Version + 1, "keys": keySet.Keys}}
+		var result *mongo.UpdateResult
+		result, err = kr.collection().UpdateOne(ctx, filter, update)
+		if err == nil && result.MatchedCount == 0 {
+			conflict = true
+		}
+	}
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if conflict {
+		rErr := coreerrors.NewJWTSigningKeySetConflictError(keySet.Version, true)
+		apptelemetry.SetSpanOriginalError(&span, rErr, "jwt signing key set was changed by another instance")
+		return rErr
+	}
+	keySet.Version++
+	span.AddEvent("jwt signing key set stored")
+	return nil
+}
+
+func (kr jwtSigningKeyRepo) collection() *mongo.Collection {
+	return kr.mongoClient.Database(kr.dbName).Collection(kr.collectionName)
+}
@@ -45,16 +45,63 @@ func TestMongoRepos(t *testing.T) {
 		testUserRepo := NewUserRepoWithNames(client, "test_goauth", USER_COLLECTION)
 		var userRepo repo.UserRepo = testUserRepo
 		var contactRepo repo.ContactRepo = testUserRepo
+		testAppRepo := NewAppRepoWithNames(client, "test_goauth", APP_COLLECTION, SCOPE_COLLECTION, CONSENT_COLLECTION)
+		var appRepo repo.AppRepo = testAppRepo
+		testTokenRepo := NewTokenRepoWithNames(client, "test_goauth", TOKEN_COLLECTION)
+		var tokenRepo repo.TokenRepo = testTokenRepo
+		testConsentRepo := NewConsentRepoWithNames(client, "test_goauth", CONSENT_COLLECTION)
+		var consentRepo repo.ConsentRepo = testConsentRepo
+		testJWTSigningKeyRepo := NewJWTSigningKeyRepoWithNames(client, "test_goauth", JWT_SIGNING_KEY_COLLECTION)
+		var jwtSigningKeyRepo repo.JWTSigningKeyRepo = testJWTSigningKeyRepo
 		cleanUpDataSource := func(t *testing.T, _ repotest.RepoTestHarnessInput) {
 			err := testUserRepo.mongoClient.Database(testUserRepo.dbName).Collection(testUserRepo.collectionName).Drop(context.TODO())
 			if err != nil {
 				t.Error("failed to cleanup database", err)
 			}
+			err = testAppRepo.appCollection().Drop(context.TODO())
+			if err != nil {
+				t.Error("failed to cleanup database", err)
+			}
+			err = testAppRepo.scopeCollection().Drop(context.TODO())
+			if err != nil {
+				t.Error("failed to cleanup database", err)
+			}
+			rErr := testAppRepo.EnsureIndexes(context.TODO())
+			if rErr != nil {
+				t.Error("failed to create app repo indexes", rErr)
+			}
+			err = testTokenRepo.collection().Drop(context.TODO())
+			if err != nil {
+				t.Error("failed to cleanup database", err)
+			}
+			rErr = testTokenRepo.EnsureIndexes(context.TODO())
+			if rErr != nil {
+				t.Error("failed to create token repo indexes", rErr)
+			}
+			err = testConsentRepo.collection().Drop(context.TODO())
+			if err != nil {
+				t.Error("failed to cleanup database", err)
+			}
+			rErr = testConsentRepo.EnsureIndexes(context.TODO())
+			if rErr != nil {
+				t.Error("failed to create consent repo indexes", rErr)
+			}
+			err = testJWTSigningKeyRepo.collection().Drop(context.TODO())
+			if err != nil {
+				t.Error("failed to cleanup database", err)
+			}
 		}
 		testHarnessInput := repotest.RepoTestHarnessInput{
 			UserRepo:            &userRepo,
 			ContactRepo:         &contactRepo,
+			AppRepo:             &appRepo,
+			TokenRepo:           &tokenRepo,
+			ConsentRepo:         &consentRepo,
+			JWTSigningKeyRepo:   &jwtSigningKeyRepo,
 			SetupTestDataSource: cleanUpDataSource,
+			// the TTL monitor may remove expired tokens before they are read back.
+			TokenRepoExpiresTokens: true,
+			AppRepoDeletesConsents: true,
 			IDGenerator: func(getZeroId bool) string {
 				if getZeroId {
 					return primitive.NilObjectID.Hex()
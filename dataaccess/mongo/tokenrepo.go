@@ -0,0 +1,214 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	repoModels "github.com/calvine/goauth/dataaccess/mongo/internal/models"
+	"github.com/calvine/richerror/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// tokenRepo is the repository struct for tokens. Expired tokens are removed by mongo through a TTL index, so they may still be returned for a short time after they expire until the TTL monitor runs.
+type tokenRepo struct {
+	mongoClient    *mongo.Client
+	dbName         string
+	collectionName string
+}
+
+func NewTokenRepo(client *mongo.Client) tokenRepo {
+	return tokenRepo{client, DB_NAME, TOKEN_COLLECTION}
+}
+
+func NewTokenRepoWithNames(client *mongo.Client, dbName, collectionName string) tokenRepo {
+	return tokenRepo{client, dbName, collectionName}
+}
+
+func (tokenRepo) GetName() string {
+	return "tokenRepo"
+}
+
+func (tokenRepo) GetType() string {
+	return dataSourceType
+}
+
+// EnsureIndexes creates the indexes the token repo relies on. Token values must be unique because tokens are looked up by them, and the TTL index on expiration has mongo delete tokens once they expire.
+func (tr tokenRepo) EnsureIndexes(ctx context.Context) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "EnsureIndexes", tr.GetType())
+	defer span.End()
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "value", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiration", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "targetId", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "metaData." + models.TokenMetaDataKeyClientID, Value: 1}},
+		},
+	}
+	_, err := tr.collection().Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("indexes created")
+	return nil
+}
+
+func (tr tokenRepo) GetToken(ctx context.Context, tokenValue string) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "GetToken", tr.GetType())
+	defer span.End()
+	var repoToken repoModels.RepoToken
+	err := tr.collection().FindOne(ctx, bson.M{"value": tokenValue}).Decode(&repoToken)
+	if err != nil {
+		rErr := tokenQueryError(err, tokenValue)
+		apptelemetry.SetSpanOriginalError(&span, rErr, fmt.Sprintf("failed to get token: %s", tokenValue))
+		return models.Token{}, rErr
+	}
+	span.AddEvent("token retreived")
+	return repoToken.ToCoreToken(), nil
+}
+
+func (tr tokenRepo) PutToken(ctx context.Context, token models.Token) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "PutToken", tr.GetType())
+	defer span.End()
+	repoToken := repoModels.CoreToken(token).ToRepoToken()
+	_, err := tr.collection().ReplaceOne(ctx, bson.M{"value": token.Value}, repoToken, options.Replace().SetUpsert(true))
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("token stored")
+	return nil
+}
+
+func (tr tokenRepo) DeleteToken(ctx context.Context, tokenValue string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "DeleteToken", tr.GetType())
+	defer span.End()
+	result, err := tr.collection().DeleteOne(ctx, bson.M{"value": tokenValue})
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if result.DeletedCount == 0 {
+		evtString := fmt.Sprintf("token not found: %s", tokenValue)
+		rErr := coreerrors.NewInvalidTokenError(tokenValue, true)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("token deleted")
+	return nil
+}
+
+// ConsumeToken uses find one and delete so that when the same token is consumed concurrently only one caller gets it back.
+func (tr tokenRepo) ConsumeToken(ctx context.Context, tokenValue string) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "ConsumeToken", tr.GetType())
+	defer span.End()
+	var repoToken repoModels.RepoToken
+	err := tr.collection().FindOneAndDelete(ctx, bson.M{"value": tokenValue}).Decode(&repoToken)
+	if err != nil {
+		rErr := tokenQueryError(err, tokenValue)
+		apptelemetry.SetSpanOriginalError(&span, rErr, fmt.Sprintf("failed to consume token: %s", tokenValue))
+		return models.Token{}, rErr
+	}
+	span.AddEvent("token consumed")
+	return repoToken.ToCoreToken(), nil
+}
+
+// MarkTokenRotated uses find one and update with a filter on the rotated to meta data, so that when the same token is rotated concurrently only one caller updates it.
+func (tr tokenRepo) MarkTokenRotated(ctx context.Context, tokenValue, rotatedTo string, expiration time.Time) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "MarkTokenRotated", tr.GetType())
+	defer span.End()
+	rotatedToField := "metaData." + models.TokenMetaDataKeyRotatedTo
+	filter := bson.M{
+		"value": tokenValue,
+		// a null match also matches tokens without the field.
+		rotatedToField: bson.M{"$in": bson.A{nil, ""}},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			rotatedToField: rotatedTo,
+			"expiration":   expiration,
+		},
+	}
+	var repoToken repoModels.RepoToken
+	err := tr.collection().FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&repoToken)
+	if err == mongo.ErrNoDocuments {
+		// the token either does not exist or was already rotated, the stored token tells the caller which.
+		err = tr.collection().FindOne(ctx, bson.M{"value": tokenValue}).Decode(&repoToken)
+		if err == nil {
+			span.AddEvent("token already rotated")
+			return repoToken.ToCoreToken(), nil
+		}
+	}
+	if err != nil {
+		rErr := tokenQueryError(err, tokenValue)
+		apptelemetry.SetSpanOriginalError(&span, rErr, fmt.Sprintf("failed to mark token rotated: %s", tokenValue))
+		return models.Token{}, rErr
+	}
+	span.AddEvent("token marked rotated")
+	return repoToken.ToCoreToken(), nil
+}
+
+func (tr tokenRepo) DeleteTokensByTargetID(ctx context.Context, targetID string, tokenTypes []models.TokenType) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "DeleteTokensByTargetID", tr.GetType())
+	defer span.End()
+	filter := bson.M{
+		"targetId":  targetID,
+		"tokenType": bson.M{"$in": tokenTypes},
+	}
+	result, err := tr.collection().DeleteMany(ctx, filter)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent(fmt.Sprintf("%d tokens deleted", result.DeletedCount))
+	return nil
+}
+
+func (tr tokenRepo) DeleteTokensByClientID(ctx context.Context, clientID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "DeleteTokensByClientID", tr.GetType())
+	defer span.End()
+	filter := bson.M{"metaData." + models.TokenMetaDataKeyClientID: clientID}
+	result, err := tr.collection().DeleteMany(ctx, filter)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent(fmt.Sprintf("%d tokens deleted", result.DeletedCount))
+	return nil
+}
+
+func (tr tokenRepo) collection() *mongo.Collection {
+	return tr.mongoClient.Database(tr.dbName).Collection(tr.collectionName)
+}
+
+// tokenQueryError maps a failed single token query to an invalid token error when no token was found, so callers see the same error as the other token repos.
+func tokenQueryError(err error, tokenValue string) errors.RichError {
+	if err == mongo.ErrNoDocuments {
+		return coreerrors.NewInvalidTokenError(tokenValue, true)
+	}
+	return coreerrors.NewRepoQueryFailedError(err, true)
+}
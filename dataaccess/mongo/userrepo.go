@@ -8,6 +8,7 @@ import (
 	"github.com/calvine/goauth/core/apptelemetry"
 	coreerrors "github.com/calvine/goauth/core/errors"
 	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/models/aggregate"
 	repoModels "github.com/calvine/goauth/dataaccess/mongo/internal/models"
 	"github.com/calvine/richerror/errors"
 	"go.mongodb.org/mongo-driver/bson"
@@ -23,6 +24,9 @@ var (
 		"consecutiveFailedLoginAttempts": 1,
 		"lockedOutUntil":                 1,
 		"lastLoginDate":                  1,
+		"totpSecret":                     1,
+		"totpEnabled":                    1,
+		"totpLastUsedTimeStep":           1,
 	}
 	ProjUserWithSpecificContact = bson.M{
 		"_id":                            1,
@@ -30,6 +34,9 @@ var (
 		"consecutiveFailedLoginAttempts": 1,
 		"lockedOutUntil":                 1,
 		"lastLoginDate":                  1,
+		"totpSecret":                     1,
+		"totpEnabled":                    1,
+		"totpLastUsedTimeStep":           1,
 		"contacts.$":                     1,
 	}
 )
@@ -93,6 +100,39 @@ func (ur userRepo) GetUserByID(ctx context.Context, id string) (models.User, err
 	return user, nil
 }
 
+func (ur userRepo) GetFullUserByID(ctx context.Context, id string) (aggregate.FullUser, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "GetFullUserByID", ur.GetType())
+	defer span.End()
+	var repoFullUser repoModels.RepoFullUser
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		rErr := coreerrors.NewFailedToParseObjectIDError(id, err, true)
+		evtString := fmt.Sprintf("%s: %s", rErr.GetErrorMessage(), id)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return aggregate.FullUser{}, rErr
+	}
+	filter := bson.M{"_id": oid}
+	err = ur.mongoClient.Database(ur.dbName).Collection(ur.collectionName).FindOne(ctx, filter).Decode(&repoFullUser)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			fields := map[string]interface{}{
+				"_id": id,
+			}
+			rErr := coreerrors.NewNoUserFoundError(fields, true)
+			evtString := fmt.Sprintf("%s: %s", rErr.GetErrorMessage(), id)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return aggregate.FullUser{}, rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return aggregate.FullUser{}, rErr
+	}
+	fullUser := repoFullUser.ToCoreFullUser()
+	span.AddEvent("full user retreived")
+	return fullUser, nil
+}
+
 func (ur userRepo) GetUserAndContactByConfirmedContact(ctx context.Context, contactType, contactPrincipal string) (models.User, models.Contact, errors.RichError) {
 	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "GetUserAndContactByConfirmedContact", ur.GetType())
 	defer span.End()
@@ -229,9 +269,15 @@ func (ur userRepo) UpdateUser(ctx context.Context, user *models.User, modifiedBy
 			"consecutiveFailedLoginAttempts": repoUser.ConsecutiveFailedLoginAttempts,
 			"lockedOutUntil":                 repoUser.LockedOutUntil.GetPointerCopy(),
 			"lastLoginDate":                  repoUser.LastLoginDate.GetPointerCopy(),
+			"totpSecret":                     repoUser.TOTPSecret,
+			"totpEnabled":                    repoUser.TOTPEnabled,
 			"modifiedById":                   repoUser.AuditData.ModifiedByID.GetPointerCopy(),
 			"modifiedOnDate":                 repoUser.AuditData.ModifiedOnDate.GetPointerCopy(),
 		},
+		// the time step is never lowered so a stale copy of the user cannot undo UpdateTOTPLastUsedTimeStep.
+		"$max": bson.M{
+			"totpLastUsedTimeStep": repoUser.TOTPLastUsedTimeStep,
+		},
 	}
 	result, updateErr := ur.mongoClient.Database(ur.dbName).Collection(ur.collectionName).UpdateOne(ctx, filter, update)
 	if updateErr != nil {
@@ -252,3 +298,63 @@ func (ur userRepo) UpdateUser(ctx context.Context, user *models.User, modifiedBy
 	span.AddEvent("user updated")
 	return nil
 }
+
+// UpdateTOTPLastUsedTimeStep filters on the stored time step being earlier, and checks whether the user exists when nothing matched to tell the two failures apart.
+func (ur userRepo) UpdateTOTPLastUsedTimeStep(ctx context.Context, userID string, timeStep int64, modifiedByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "UpdateTOTPLastUsedTimeStep", ur.GetType())
+	defer span.End()
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		rErr := coreerrors.NewFailedToParseObjectIDError(userID, err, true)
+		evtString := fmt.Sprintf("%s: %s", rErr.GetErrorMessage(), userID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	collection := ur.mongoClient.Database(ur.dbName).Collection(ur.collectionName)
+	// users stored before totp was added do not have the field at all.
+	filter := bson.M{
+		"_id": oid,
+		"$or": bson.A{
+			bson.M{"totpLastUsedTimeStep": bson.M{"$lt": timeStep}},
+			bson.M{"totpLastUsedTimeStep": bson.M{"$exists": false}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"totpLastUsedTimeStep": timeStep,
+			"modifiedById":         modifiedByID,
+			"modifiedOnDate":       time.Now().UTC(),
+		},
+	}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if result.MatchedCount == 0 {
+		count, err := collection.CountDocuments(ctx, bson.M{"_id": oid})
+		if err != nil {
+			rErr := coreerrors.NewRepoQueryFailedError(err, true)
+			evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+		if count == 0 {
+			fields := map[string]interface{}{
+				"_id": userID,
+			}
+			rErr := coreerrors.NewNoUserFoundError(fields, true)
+			evtString := fmt.Sprintf("no user found with id: %s", userID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+		rErr := coreerrors.NewTOTPCodeAlreadyUsedError(userID, timeStep, true)
+		evtString := fmt.Sprintf("totp time step %d already used by user: %s", timeStep, userID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("totp last used time step updated")
+	return nil
+}
@@ -0,0 +1,17 @@
+package redis
+
+const (
+	DEFAULT_KEY_PREFIX = "goauth:"
+
+	tokenKeyPrefix       = "token:"
+	targetIndexKeyPrefix = "tokens:target:"
+	clientIndexKeyPrefix = "tokens:client:"
+
+	tokenFieldTokenType  = "tokenType"
+	tokenFieldExpiration = "expiration"
+	tokenFieldTargetID   = "targetId"
+	// tokenFieldMetaDataPrefix prefixes each meta data key so meta data can be stored in the same hash as the token fields.
+	tokenFieldMetaDataPrefix = "metaData:"
+
+	dataSourceType = "redis"
+)
@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	repo "github.com/calvine/goauth/core/repositories"
+	"github.com/calvine/goauth/dataaccess/internal/repotest"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+func TestRedisRepos(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	defer client.Close()
+	var tokenRepo repo.TokenRepo = NewTokenRepoWithPrefix(client, "test_goauth:")
+	testHarnessInput := repotest.RepoTestHarnessInput{
+		TokenRepo:              &tokenRepo,
+		TokenRepoExpiresTokens: true,
+		IDGenerator: func(getZeroId bool) string {
+			if getZeroId {
+				return uuid.UUID{}.String()
+			}
+			return uuid.Must(uuid.NewRandom()).String()
+		},
+	}
+	repotest.RunReposTestHarness(t, testHarnessInput)
+}
+
+func TestTokenRepoExpiration(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	defer client.Close()
+	tokenRepo := NewTokenRepoWithPrefix(client, "test_goauth:")
+	token, err := models.NewToken("expiration_user", models.TokenTypeSession, time.Second*20)
+	if err != nil {
+		t.Fatalf("failed to create token: %s", err.GetErrorCode())
+	}
+	token.AddMetaData(models.TokenMetaDataKeyClientID, "expiration_client")
+	err = tokenRepo.PutToken(context.TODO(), token)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to put token: %s", err.GetErrorCode())
+	}
+	for _, key := range []string{tokenRepo.tokenKey(token.Value), tokenRepo.targetIndexKey(token.TargetID), tokenRepo.clientIndexKey("expiration_client")} {
+		ttl := server.TTL(key)
+		if ttl <= 0 || ttl > time.Second*20 {
+			t.Errorf("expected key %s to expire with the token but ttl is %s", key, ttl.String())
+		}
+	}
+	server.FastForward(time.Second * 21)
+	_, err = tokenRepo.GetToken(context.TODO(), token.Value)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeInvalidToken {
+		t.Error("token found inspite of having expired")
+	}
+	for _, key := range []string{tokenRepo.targetIndexKey(token.TargetID), tokenRepo.clientIndexKey("expiration_client")} {
+		if server.Exists(key) {
+			t.Errorf("expected index key %s to expire with the token", key)
+		}
+	}
+}
+
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *goredis.Client) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal("failed to start in process redis server", err)
+	}
+	client := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+	return server, client
+}
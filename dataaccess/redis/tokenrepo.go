@@ -0,0 +1,354 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// extendExpiryScript pushes the expiration of an index set out to the given number of milliseconds if it would otherwise expire sooner, so an index lives as long as the longest lived token in it.
+const extendExpiryScript = `
+local validFor = tonumber(ARGV[1])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < validFor then
+	redis.call('PEXPIRE', KEYS[1], validFor)
+end
+return 1
+`
+
+// markRotatedScript sets the rotated to field and expiration of a token hash only if the rotated to field is not already set. The hash is returned as it was before the update, or empty if the token does not exist.
+const markRotatedScript = `
+local fields = redis.call('HGETALL', KEYS[1])
+if #fields == 0 then
+	return fields
+end
+local rotatedTo = redis.call('HGET', KEYS[1], ARGV[1])
+if not rotatedTo or rotatedTo == '' then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2], ARGV[3], ARGV[4])
+	redis.call('PEXPIREAT', KEYS[1], ARGV[5])
+end
+return fields
+`
+
+// tokenRepo is the repository struct for tokens. Each token is stored as a hash that expires with the token. Tokens are also added to sets keyed by their target id and client id so they can be deleted together, members of these sets whose token has expired are pruned when the set is read.
+type tokenRepo struct {
+	client    goredis.UniversalClient
+	keyPrefix string
+}
+
+func NewTokenRepo(client goredis.UniversalClient) tokenRepo {
+	return tokenRepo{client, DEFAULT_KEY_PREFIX}
+}
+
+func NewTokenRepoWithPrefix(client goredis.UniversalClient, keyPrefix string) tokenRepo {
+	return tokenRepo{client, keyPrefix}
+}
+
+func (tokenRepo) GetName() string {
+	return "tokenRepo"
+}
+
+func (tokenRepo) GetType() string {
+	return dataSourceType
+}
+
+func (tr tokenRepo) GetToken(ctx context.Context, tokenValue string) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "GetToken", tr.GetType())
+	defer span.End()
+	fields, err := tr.client.HGetAll(ctx, tr.tokenKey(tokenValue)).Result()
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Token{}, rErr
+	}
+	token, rErr := hashToToken(tokenValue, fields)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to get token: %s", tokenValue)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Token{}, rErr
+	}
+	span.AddEvent("token retreived")
+	return token, nil
+}
+
+func (tr tokenRepo) PutToken(ctx context.Context, token models.Token) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "PutToken", tr.GetType())
+	defer span.End()
+	key := tr.tokenKey(token.Value)
+	validFor := time.Until(token.Expiration).Milliseconds()
+	_, err := tr.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, tokenToHash(token))
+		// an expiration in the past has redis remove the token right away.
+		pipe.PExpireAt(ctx, key, token.Expiration)
+		if validFor > 0 {
+			if token.TargetID != "" {
+				tr.addToIndex(ctx, pipe, tr.targetIndexKey(token.TargetID), token.Value, validFor)
+			}
+			if clientID := token.MetaData[models.TokenMetaDataKeyClientID]; clientID != "" {
+				tr.addToIndex(ctx, pipe, tr.clientIndexKey(clientID), token.Value, validFor)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("token stored")
+	return nil
+}
+
+func (tr tokenRepo) DeleteToken(ctx context.Context, tokenValue string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "DeleteToken", tr.GetType())
+	defer span.End()
+	deleted, err := tr.client.Del(ctx, tr.tokenKey(tokenValue)).Result()
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if deleted == 0 {
+		evtString := fmt.Sprintf("token not found: %s", tokenValue)
+		rErr := coreerrors.NewInvalidTokenError(tokenValue, true)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("token deleted")
+	return nil
+}
+
+// ConsumeToken reads and deletes the token in a single transaction so that when the same token is consumed concurrently only one caller gets it back.
+func (tr tokenRepo) ConsumeToken(ctx context.Context, tokenValue string) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "ConsumeToken", tr.GetType())
+	defer span.End()
+	key := tr.tokenKey(tokenValue)
+	var getCmd *goredis.StringStringMapCmd
+	_, err := tr.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		getCmd = pipe.HGetAll(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Token{}, rErr
+	}
+	token, rErr := hashToToken(tokenValue, getCmd.Val())
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to consume token: %s", tokenValue)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Token{}, rErr
+	}
+	span.AddEvent("token consumed")
+	return token, nil
+}
+
+// MarkTokenRotated checks and updates the token in a single script so that when the same token is rotated concurrently only one caller marks it.
+func (tr tokenRepo) MarkTokenRotated(ctx context.Context, tokenValue, rotatedTo string, expiration time.Time) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "MarkTokenRotated", tr.GetType())
+	defer span.End()
+	result, err := tr.client.Eval(ctx, markRotatedScript, []string{tr.tokenKey(tokenValue)},
+		tokenFieldMetaDataPrefix+models.TokenMetaDataKeyRotatedTo,
+		rotatedTo,
+		tokenFieldExpiration,
+		expiration.UTC().Format(time.RFC3339Nano),
+		expiration.UnixNano()/int64(time.Millisecond),
+	).Result()
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Token{}, rErr
+	}
+	values, _ := result.([]interface{})
+	fields := make(map[string]string, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		field, _ := values[i].(string)
+		value, _ := values[i+1].(string)
+		fields[field] = value
+	}
+	token, rErr := hashToToken(tokenValue, fields)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to mark token rotated: %s", tokenValue)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Token{}, rErr
+	}
+	if token.MetaData[models.TokenMetaDataKeyRotatedTo] != "" {
+		span.AddEvent("token already rotated")
+		return token, nil
+	}
+	// the index sets must live as long as the token now does.
+	validFor := time.Until(expiration).Milliseconds()
+	if validFor > 0 {
+		_, err = tr.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+			if token.TargetID != "" {
+				tr.addToIndex(ctx, pipe, tr.targetIndexKey(token.TargetID), tokenValue, validFor)
+			}
+			if clientID := token.MetaData[models.TokenMetaDataKeyClientID]; clientID != "" {
+				tr.addToIndex(ctx, pipe, tr.clientIndexKey(clientID), tokenValue, validFor)
+			}
+			return nil
+		})
+		if err != nil {
+			rErr := coreerrors.NewRepoQueryFailedError(err, true)
+			evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.Token{}, rErr
+		}
+	}
+	span.AddEvent("token marked rotated")
+	return token, nil
+}
+
+func (tr tokenRepo) DeleteTokensByTargetID(ctx context.Context, targetID string, tokenTypes []models.TokenType) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "DeleteTokensByTargetID", tr.GetType())
+	defer span.End()
+	deleted, err := tr.deleteIndexedTokens(ctx, tr.targetIndexKey(targetID), func(token models.Token) bool {
+		return token.TargetID == targetID && containsTokenType(tokenTypes, token.TokenType)
+	})
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent(fmt.Sprintf("%d tokens deleted", deleted))
+	return nil
+}
+
+func (tr tokenRepo) DeleteTokensByClientID(ctx context.Context, clientID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "DeleteTokensByClientID", tr.GetType())
+	defer span.End()
+	deleted, err := tr.deleteIndexedTokens(ctx, tr.clientIndexKey(clientID), func(token models.Token) bool {
+		return token.MetaData[models.TokenMetaDataKeyClientID] == clientID
+	})
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent(fmt.Sprintf("%d tokens deleted", deleted))
+	return nil
+}
+
+// deleteIndexedTokens deletes the tokens in the index set that match. Tokens are checked against the match func because a token could have been stored again under the same value with different data, and members whose token no longer exists are pruned from the set.
+func (tr tokenRepo) deleteIndexedTokens(ctx context.Context, indexKey string, match func(token models.Token) bool) (int, error) {
+	tokenValues, err := tr.client.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(tokenValues) == 0 {
+		return 0, nil
+	}
+	getCmds := make([]*goredis.StringStringMapCmd, len(tokenValues))
+	_, err = tr.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, tokenValue := range tokenValues {
+			getCmds[i] = pipe.HGetAll(ctx, tr.tokenKey(tokenValue))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	_, err = tr.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, tokenValue := range tokenValues {
+			token, rErr := hashToToken(tokenValue, getCmds[i].Val())
+			if rErr != nil {
+				pipe.SRem(ctx, indexKey, tokenValue)
+				continue
+			}
+			if !match(token) {
+				continue
+			}
+			pipe.Del(ctx, tr.tokenKey(tokenValue))
+			pipe.SRem(ctx, indexKey, tokenValue)
+			deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (tr tokenRepo) addToIndex(ctx context.Context, pipe goredis.Pipeliner, indexKey, tokenValue string, validFor int64) {
+	pipe.SAdd(ctx, indexKey, tokenValue)
+	pipe.Eval(ctx, extendExpiryScript, []string{indexKey}, validFor)
+}
+
+func (tr tokenRepo) tokenKey(tokenValue string) string {
+	return tr.keyPrefix + tokenKeyPrefix + tokenValue
+}
+
+func (tr tokenRepo) targetIndexKey(targetID string) string {
+	return tr.keyPrefix + targetIndexKeyPrefix + targetID
+}
+
+func (tr tokenRepo) clientIndexKey(clientID string) string {
+	return tr.keyPrefix + clientIndexKeyPrefix + clientID
+}
+
+func tokenToHash(token models.Token) map[string]interface{} {
+	fields := map[string]interface{}{
+		tokenFieldTokenType:  int(token.TokenType),
+		tokenFieldExpiration: token.Expiration.UTC().Format(time.RFC3339Nano),
+		tokenFieldTargetID:   token.TargetID,
+	}
+	for key, value := range token.MetaData {
+		fields[tokenFieldMetaDataPrefix+key] = value
+	}
+	return fields
+}
+
+// hashToToken builds a token from its stored hash. An empty hash means the token does not exist or has expired.
+func hashToToken(tokenValue string, fields map[string]string) (models.Token, errors.RichError) {
+	if len(fields) == 0 {
+		return models.Token{}, coreerrors.NewInvalidTokenError(tokenValue, true)
+	}
+	tokenType, err := strconv.Atoi(fields[tokenFieldTokenType])
+	if err != nil {
+		return models.Token{}, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	expiration, err := time.Parse(time.RFC3339Nano, fields[tokenFieldExpiration])
+	if err != nil {
+		return models.Token{}, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	token := models.Token{
+		Value:      tokenValue,
+		TokenType:  models.TokenType(tokenType),
+		Expiration: expiration,
+		TargetID:   fields[tokenFieldTargetID],
+	}
+	for field, value := range fields {
+		if strings.HasPrefix(field, tokenFieldMetaDataPrefix) {
+			token.AddMetaData(strings.TrimPrefix(field, tokenFieldMetaDataPrefix), value)
+		}
+	}
+	return token, nil
+}
+
+func containsTokenType(tokenTypes []models.TokenType, tokenType models.TokenType) bool {
+	for _, t := range tokenTypes {
+		if t == tokenType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,247 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+)
+
+const addressColumns = "a.id, a.user_id, a.name, a.line1, a.line2, a.city, a.state, a.postal_code, a.is_primary, a.created_by_id, a.created_on_date, a.modified_by_id, a.modified_on_date"
+
+// addressRepo is the repository struct for user addresses.
+type addressRepo struct {
+	db *sql.DB
+}
+
+func NewAddressRepo(db *sql.DB) addressRepo {
+	return addressRepo{db}
+}
+
+func (addressRepo) GetName() string {
+	return "addressRepo"
+}
+
+func (addressRepo) GetType() string {
+	return dataSourceType
+}
+
+func (ar addressRepo) GetAddressByID(ctx context.Context, id string) (models.Address, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAddressByID", ar.GetType())
+	defer span.End()
+	query := fmt.Sprintf("SELECT %s FROM %s a WHERE a.id = ?", addressColumns, ADDRESS_TABLE)
+	address, err := scanAddress(ar.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{
+				"address.id": id,
+			}
+			rErr := coreerrors.NewNoAddressFoundError(fields, true)
+			evtString := fmt.Sprintf("no address found with id: %s", id)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.Address{}, rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Address{}, rErr
+	}
+	span.AddEvent("address retreived")
+	return address, nil
+}
+
+func (ar addressRepo) GetPrimaryAddressByUserID(ctx context.Context, userID string) (models.Address, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetPrimaryAddressByUserID", ar.GetType())
+	defer span.End()
+	query := fmt.Sprintf("SELECT %s FROM %s a WHERE a.user_id = ? AND a.is_primary = ?", addressColumns, ADDRESS_TABLE)
+	address, err := scanAddress(ar.db.QueryRowContext(ctx, query, userID, true))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{
+				"userId":              userID,
+				"addresses.isPrimary": true,
+			}
+			rErr := coreerrors.NewNoAddressFoundError(fields, true)
+			evtString := fmt.Sprintf("no primary address found for user id: %s", userID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.Address{}, rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Address{}, rErr
+	}
+	span.AddEvent("primary address retreived")
+	return address, nil
+}
+
+func (ar addressRepo) GetAddressesByUserID(ctx context.Context, userID string) ([]models.Address, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAddressesByUserID", ar.GetType())
+	defer span.End()
+	addresses, rErr := findAddresses(ctx, ar.db, "a.user_id = ?", userID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	if len(addresses) == 0 {
+		// a user without addresses is valid, so the user is only looked up when there are no addresses to tell an unknown user apart.
+		exists, rErr := userExists(ctx, ar.db, userID)
+		if rErr != nil {
+			evtString := fmt.Sprintf("failed to check user exists for user id: %s", userID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return nil, rErr
+		}
+		if !exists {
+			fields := map[string]interface{}{
+				"id": userID,
+			}
+			rErr := coreerrors.NewNoUserFoundError(fields, true)
+			evtString := fmt.Sprintf("no user found with id: %s", userID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return nil, rErr
+		}
+	}
+	span.AddEvent("addresses retreived")
+	return addresses, nil
+}
+
+func (ar addressRepo) AddAddress(ctx context.Context, address *models.Address, createdByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "AddAddress", ar.GetType())
+	defer span.End()
+	exists, rErr := userExists(ctx, ar.db, address.UserID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to check user exists for user id: %s", address.UserID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if !exists {
+		fields := map[string]interface{}{
+			"id": address.UserID,
+		}
+		rErr := coreerrors.NewNoUserFoundError(fields, true)
+		evtString := fmt.Sprintf("no user found with id: %s", address.UserID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	id, rErr := newID()
+	if rErr != nil {
+		evtString := "failed to generate address id"
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	address.CreatedByID = createdByID
+	address.CreatedOnDate = time.Now().UTC()
+	query := fmt.Sprintf("INSERT INTO %s (id, user_id, name, line1, line2, city, state, postal_code, is_primary, created_by_id, created_on_date) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", ADDRESS_TABLE)
+	_, err := ar.db.ExecContext(ctx, query,
+		id,
+		address.UserID,
+		address.Name.SQLValue(),
+		address.Line1,
+		address.Line2.SQLValue(),
+		address.City,
+		address.State,
+		address.PostalCode,
+		address.IsPrimary,
+		address.CreatedByID,
+		address.CreatedOnDate,
+	)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	address.ID = id
+	span.AddEvent("address added")
+	return nil
+}
+
+func (ar addressRepo) UpdateAddress(ctx context.Context, address *models.Address, modifiedByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "UpdateAddress", ar.GetType())
+	defer span.End()
+	address.ModifiedByID.Set(modifiedByID)
+	address.ModifiedOnDate.Set(time.Now().UTC())
+	query := fmt.Sprintf("UPDATE %s SET name = ?, line1 = ?, line2 = ?, city = ?, state = ?, postal_code = ?, is_primary = ?, modified_by_id = ?, modified_on_date = ? WHERE id = ? AND user_id = ?", ADDRESS_TABLE)
+	result, err := ar.db.ExecContext(ctx, query,
+		address.Name.SQLValue(),
+		address.Line1,
+		address.Line2.SQLValue(),
+		address.City,
+		address.State,
+		address.PostalCode,
+		address.IsPrimary,
+		address.ModifiedByID.SQLValue(),
+		address.ModifiedOnDate.SQLValue(),
+		address.ID,
+		address.UserID,
+	)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{
+				"userId":     address.UserID,
+				"address.id": address.ID,
+			}
+			rErr := coreerrors.NewNoAddressFoundError(fields, true)
+			evtString := fmt.Sprintf("no address found with id: %s", address.ID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("address updated")
+	return nil
+}
+
+// findAddresses finds every address matching the where clause.
+func findAddresses(ctx context.Context, q queryer, where string, args ...interface{}) ([]models.Address, errors.RichError) {
+	query := fmt.Sprintf("SELECT %s FROM %s a WHERE %s", addressColumns, ADDRESS_TABLE, where)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	defer rows.Close()
+	addresses := make([]models.Address, 0)
+	for rows.Next() {
+		address, err := scanAddress(rows)
+		if err != nil {
+			return nil, coreerrors.NewRepoQueryFailedError(err, true)
+		}
+		addresses = append(addresses, address)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	return addresses, nil
+}
+
+func scanAddress(row rowScanner) (models.Address, error) {
+	var address models.Address
+	err := row.Scan(
+		&address.ID,
+		&address.UserID,
+		&address.Name,
+		&address.Line1,
+		&address.Line2,
+		&address.City,
+		&address.State,
+		&address.PostalCode,
+		&address.IsPrimary,
+		&address.CreatedByID,
+		&address.CreatedOnDate,
+		&address.ModifiedByID,
+		&address.ModifiedOnDate,
+	)
+	return address, err
+}
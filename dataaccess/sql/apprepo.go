@@ -0,0 +1,697 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/nullable"
+	"github.com/calvine/richerror/errors"
+)
+
+const (
+	appColumns          = "a.id, a.owner_id, a.name, a.client_id, a.redirect_uris, a.allowed_origins, a.is_disabled, a.policy, a.logo_uri, a.created_by_id, a.created_on_date, a.modified_by_id, a.modified_on_date"
+	clientSecretColumns = "cs.app_id, cs.id, cs.hash, cs.expiration_date, cs.created_date"
+	scopeColumns        = "s.id, s.app_id, s.name, s.description, s.created_by_id, s.created_on_date, s.modified_by_id, s.modified_on_date"
+)
+
+// appRepo is the repository struct for apps and their scopes. The client secrets of an app are kept in their own table and loaded with the app, while the redirect uris, allowed origins and policy are stored as JSON on the app row.
+type appRepo struct {
+	db *sql.DB
+}
+
+func NewAppRepo(db *sql.DB) appRepo {
+	return appRepo{db}
+}
+
+func (appRepo) GetName() string {
+	return "appRepo"
+}
+
+func (appRepo) GetType() string {
+	return dataSourceType
+}
+
+func (ar appRepo) GetAppByID(ctx context.Context, id string) (models.App, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppByID", ar.GetType())
+	defer span.End()
+	app, rErr := ar.findApp(ctx, "a.id = ?", id)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to find app with id: %s", id)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.App{}, rErr
+	}
+	span.AddEvent("app retreived")
+	return app, nil
+}
+
+func (ar appRepo) GetAppsByOwnerID(ctx context.Context, ownerID string) ([]models.App, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppsByOwnerID", ar.GetType())
+	defer span.End()
+	apps, rErr := ar.findApps(ctx, "a.owner_id = ?", ownerID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	if len(apps) == 0 {
+		fields := map[string]interface{}{"ownerId": ownerID}
+		rErr := coreerrors.NewNoAppFoundError(fields, true)
+		evtString := fmt.Sprintf("no apps found for owner id: %s", ownerID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	span.AddEvent("apps retreived")
+	return apps, nil
+}
+
+func (ar appRepo) GetAppByClientID(ctx context.Context, clientID string) (models.App, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppByClientID", ar.GetType())
+	defer span.End()
+	app, rErr := ar.findApp(ctx, "a.client_id = ?", clientID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to find app with client id: %s", clientID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.App{}, rErr
+	}
+	span.AddEvent("app retreived")
+	return app, nil
+}
+
+func (ar appRepo) GetAppAndScopesByClientID(ctx context.Context, clientID string) (models.App, []models.Scope, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetAppAndScopesByClientID", ar.GetType())
+	defer span.End()
+	app, rErr := ar.findApp(ctx, "a.client_id = ?", clientID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to find app with client id: %s", clientID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.App{}, nil, rErr
+	}
+	span.AddEvent("app retreived")
+	scopes, rErr := ar.findScopes(ctx, "s.app_id = ?", app.ID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to find scopes for app with client id: %s", clientID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.App{}, nil, rErr
+	}
+	span.AddEvent("app and scopes retreived")
+	return app, scopes, nil
+}
+
+func (ar appRepo) AddApp(ctx context.Context, app *models.App, createdBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "AddApp", ar.GetType())
+	defer span.End()
+	id, rErr := newID()
+	if rErr != nil {
+		evtString := "failed to generate app id"
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	app.AuditData.CreatedByID = createdBy
+	app.AuditData.CreatedOnDate = time.Now().UTC()
+	redirectURIs, allowedOrigins, policy, rErr := appJSONColumns(*app)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to serialize app: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		rErr := coreerrors.NewDatastoreTransactionFailedError(err, true)
+		evtString := fmt.Sprintf("failed to start transaction: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	query := fmt.Sprintf("INSERT INTO %s (id, owner_id, name, client_id, redirect_uris, allowed_origins, is_disabled, policy, logo_uri, created_by_id, created_on_date) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", APP_TABLE)
+	_, err = tx.ExecContext(ctx, query,
+		id,
+		app.OwnerID,
+		app.Name,
+		app.ClientID,
+		redirectURIs,
+		allowedOrigins,
+		app.IsDisabled,
+		policy,
+		app.LogoURI,
+		app.AuditData.CreatedByID,
+		app.AuditData.CreatedOnDate,
+	)
+	if err == nil {
+		err = insertClientSecrets(ctx, tx, id, app.ClientSecrets)
+	}
+	if err != nil {
+		rErr := rollback(tx, err)
+		evtString := fmt.Sprintf("failed to add app: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	err = tx.Commit()
+	if err != nil {
+		rErr := coreerrors.NewDatastoreTransactionFailedError(err, true)
+		evtString := fmt.Sprintf("failed to commit transaction: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	app.ID = id
+	span.AddEvent("app added")
+	return nil
+}
+
+// UpdateApp updates the app row. The client secrets are left alone, they are changed one at a time by the client secret functions.
+func (ar appRepo) UpdateApp(ctx context.Context, app *models.App, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "UpdateApp", ar.GetType())
+	defer span.End()
+	app.AuditData.ModifiedByID.Set(modifiedBy)
+	app.AuditData.ModifiedOnDate.Set(time.Now().UTC())
+	redirectURIs, allowedOrigins, policy, rErr := appJSONColumns(*app)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to serialize app: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		rErr := coreerrors.NewDatastoreTransactionFailedError(err, true)
+		evtString := fmt.Sprintf("failed to start transaction: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	// the client id and the created audit data never change, so they are left out of the update.
+	query := fmt.Sprintf("UPDATE %s SET owner_id = ?, name = ?, redirect_uris = ?, allowed_origins = ?, is_disabled = ?, policy = ?, logo_uri = ?, modified_by_id = ?, modified_on_date = ? WHERE id = ?", APP_TABLE)
+	result, err := tx.ExecContext(ctx, query,
+		app.OwnerID,
+		app.Name,
+		redirectURIs,
+		allowedOrigins,
+		app.IsDisabled,
+		policy,
+		app.LogoURI,
+		app.AuditData.ModifiedByID.SQLValue(),
+		app.AuditData.ModifiedOnDate.SQLValue(),
+		app.ID,
+	)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err == sql.ErrNoRows {
+		fields := map[string]interface{}{"id": app.ID}
+		err = coreerrors.NewNoAppFoundError(fields, true)
+	}
+	if err != nil {
+		rErr := rollback(tx, err)
+		evtString := fmt.Sprintf("failed to update app with id: %s", app.ID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	err = tx.Commit()
+	if err != nil {
+		rErr := coreerrors.NewDatastoreTransactionFailedError(err, true)
+		evtString := fmt.Sprintf("failed to commit transaction: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("app updated")
+	return nil
+}
+
+func (ar appRepo) AddClientSecret(ctx context.Context, appID string, clientSecret models.ClientSecret, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "AddClientSecret", ar.GetType())
+	defer span.End()
+	rErr := ar.changeClientSecrets(ctx, appID, modifiedBy, func(tx *sql.Tx) error {
+		return insertClientSecrets(ctx, tx, appID, []models.ClientSecret{clientSecret})
+	})
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to add client secret to app with id: %s", appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("client secret added")
+	return nil
+}
+
+func (ar appRepo) SetClientSecretExpiration(ctx context.Context, appID, clientSecretID string, expirationDate nullable.NullableTime, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "SetClientSecretExpiration", ar.GetType())
+	defer span.End()
+	rErr := ar.changeClientSecrets(ctx, appID, modifiedBy, func(tx *sql.Tx) error {
+		query := fmt.Sprintf("UPDATE %s SET expiration_date = ? WHERE app_id = ? AND id = ?", CLIENT_SECRET_TABLE)
+		result, err := tx.ExecContext(ctx, query, expirationDate.SQLValue(), appID, clientSecretID)
+		if err == nil {
+			err = checkRowsAffected(result)
+		}
+		if err == sql.ErrNoRows {
+			err = coreerrors.NewClientSecretNotFoundError(appID, clientSecretID, true)
+		}
+		return err
+	})
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to set expiration of client secret %s for app with id: %s", clientSecretID, appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("client secret expiration set")
+	return nil
+}
+
+// RevokeClientSecret checks the remaining client secrets in the same transaction that removes the client secret. The app row is locked first, so a concurrent revocation waits until this one is done and then sees it.
+func (ar appRepo) RevokeClientSecret(ctx context.Context, appID, clientSecretID string, now time.Time, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "RevokeClientSecret", ar.GetType())
+	defer span.End()
+	rErr := ar.changeClientSecrets(ctx, appID, modifiedBy, func(tx *sql.Tx) error {
+		query := fmt.Sprintf("SELECT id, expiration_date FROM %s WHERE app_id = ?", CLIENT_SECRET_TABLE)
+		rows, err := tx.QueryContext(ctx, query, appID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		found := false
+		otherActiveClientSecrets := 0
+		for rows.Next() {
+			var clientSecret models.ClientSecret
+			err = rows.Scan(&clientSecret.ID, &clientSecret.ExpirationDate)
+			if err != nil {
+				return err
+			}
+			if clientSecret.ID == clientSecretID {
+				found = true
+			} else if clientSecret.IsActive(now) {
+				otherActiveClientSecrets++
+			}
+		}
+		err = rows.Err()
+		if err != nil {
+			return err
+		}
+		rows.Close()
+		if !found {
+			return coreerrors.NewClientSecretNotFoundError(appID, clientSecretID, true)
+		}
+		// an app without an active client secret could not authenticate until a new one is added, so it is not allowed.
+		if otherActiveClientSecrets == 0 {
+			return coreerrors.NewLastActiveClientSecretError(appID, true)
+		}
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE app_id = ? AND id = ?", CLIENT_SECRET_TABLE), appID, clientSecretID)
+		return err
+	})
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to revoke client secret %s for app with id: %s", clientSecretID, appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("client secret revoked")
+	return nil
+}
+
+// DeleteApp deletes the app along with its client secrets, scopes and the consents given to it in a single transaction so nothing is left behind for an app that no longer exists.
+func (ar appRepo) DeleteApp(ctx context.Context, app *models.App, deletedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "DeleteApp", ar.GetType())
+	defer span.End()
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		rErr := coreerrors.NewDatastoreTransactionFailedError(err, true)
+		evtString := fmt.Sprintf("failed to start transaction: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	// foreign keys are not enforced by every database, so dependent rows are deleted explicitly.
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE app_id = ?", CLIENT_SECRET_TABLE), app.ID)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE app_id = ?", SCOPE_TABLE), app.ID)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE app_id = ?", CONSENT_TABLE), app.ID)
+	}
+	if err == nil {
+		var result sql.Result
+		result, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ?", APP_TABLE), app.ID)
+		if err == nil {
+			err = checkRowsAffected(result)
+		}
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{"id": app.ID}
+			err = coreerrors.NewNoAppFoundError(fields, true)
+		}
+	}
+	if err != nil {
+		rErr := rollback(tx, err)
+		evtString := fmt.Sprintf("failed to delete app with id: %s", app.ID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	err = tx.Commit()
+	if err != nil {
+		rErr := coreerrors.NewDatastoreTransactionFailedError(err, true)
+		evtString := fmt.Sprintf("failed to commit transaction: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("app and scopes deleted")
+	return nil
+}
+
+func (ar appRepo) GetScopeByID(ctx context.Context, id string) (models.Scope, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetScopeByID", ar.GetType())
+	defer span.End()
+	query := fmt.Sprintf("SELECT %s FROM %s s WHERE s.id = ?", scopeColumns, SCOPE_TABLE)
+	scope, err := scanScope(ar.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{"id": id}
+			rErr := coreerrors.NewNoScopeFoundError(fields, true)
+			evtString := fmt.Sprintf("no scope found with id: %s", id)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.Scope{}, rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Scope{}, rErr
+	}
+	span.AddEvent("scope retreived")
+	return scope, nil
+}
+
+func (ar appRepo) GetScopesByAppID(ctx context.Context, appID string) ([]models.Scope, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "GetScopesByAppID", ar.GetType())
+	defer span.End()
+	scopes, rErr := ar.findScopes(ctx, "s.app_id = ?", appID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to find scopes for app id: %s", appID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	if len(scopes) == 0 {
+		// an app without scopes is valid, so the app is only checked when there are no scopes to tell an unknown app apart.
+		exists, rErr := ar.appExists(ctx, appID)
+		if rErr != nil {
+			evtString := fmt.Sprintf("failed to check app exists for app id: %s", appID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return nil, rErr
+		}
+		if !exists {
+			fields := map[string]interface{}{"appId": appID}
+			rErr := coreerrors.NewNoScopeFoundError(fields, true)
+			evtString := fmt.Sprintf("no scopes found with app id: %s", appID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return nil, rErr
+		}
+	}
+	span.AddEvent("scopes retreived")
+	return scopes, nil
+}
+
+func (ar appRepo) AddScope(ctx context.Context, scope *models.Scope, createdBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "AddScope", ar.GetType())
+	defer span.End()
+	exists, rErr := ar.appExists(ctx, scope.AppID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to check app exists for app id: %s", scope.AppID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if !exists {
+		fields := map[string]interface{}{"ID": scope.ID, "AppID": scope.AppID}
+		rErr := coreerrors.NewNoAppFoundError(fields, true)
+		evtString := fmt.Sprintf("no app found with app id: %s", scope.AppID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	id, rErr := newID()
+	if rErr != nil {
+		evtString := "failed to generate scope id"
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	scope.AuditData.CreatedByID = createdBy
+	scope.AuditData.CreatedOnDate = time.Now().UTC()
+	query := fmt.Sprintf("INSERT INTO %s (id, app_id, name, description, created_by_id, created_on_date) VALUES (?, ?, ?, ?, ?, ?)", SCOPE_TABLE)
+	_, err := ar.db.ExecContext(ctx, query,
+		id,
+		scope.AppID,
+		scope.Name,
+		scope.Description,
+		scope.AuditData.CreatedByID,
+		scope.AuditData.CreatedOnDate,
+	)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	scope.ID = id
+	span.AddEvent("scope added")
+	return nil
+}
+
+func (ar appRepo) UpdateScope(ctx context.Context, scope *models.Scope, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "UpdateScope", ar.GetType())
+	defer span.End()
+	scope.AuditData.ModifiedByID.Set(modifiedBy)
+	scope.AuditData.ModifiedOnDate.Set(time.Now().UTC())
+	query := fmt.Sprintf("UPDATE %s SET name = ?, description = ?, modified_by_id = ?, modified_on_date = ? WHERE id = ? AND app_id = ?", SCOPE_TABLE)
+	result, err := ar.db.ExecContext(ctx, query,
+		scope.Name,
+		scope.Description,
+		scope.AuditData.ModifiedByID.SQLValue(),
+		scope.AuditData.ModifiedOnDate.SQLValue(),
+		scope.ID,
+		scope.AppID,
+	)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{"ID": scope.ID, "appID": scope.AppID}
+			rErr := coreerrors.NewNoScopeFoundError(fields, true)
+			evtString := fmt.Sprintf("no scope found with id: %s", scope.ID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("scope updated")
+	return nil
+}
+
+func (ar appRepo) DeleteScope(ctx context.Context, scope *models.Scope, deletedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "DeleteScope", ar.GetType())
+	defer span.End()
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = ? AND app_id = ?", SCOPE_TABLE)
+	result, err := ar.db.ExecContext(ctx, query, scope.ID, scope.AppID)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{"ID": scope.ID, "appID": scope.AppID}
+			rErr := coreerrors.NewNoScopeFoundError(fields, true)
+			evtString := fmt.Sprintf("no scope found with id: %s", scope.ID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("scope deleted")
+	return nil
+}
+
+// findApp finds a single app matching the where clause, returning a NoAppFound error if there is none.
+func (ar appRepo) findApp(ctx context.Context, where string, args ...interface{}) (models.App, errors.RichError) {
+	apps, rErr := ar.findApps(ctx, where, args...)
+	if rErr != nil {
+		return models.App{}, rErr
+	}
+	if len(apps) == 0 {
+		fields := map[string]interface{}{"where": where, "args": args}
+		return models.App{}, coreerrors.NewNoAppFoundError(fields, true)
+	}
+	return apps[0], nil
+}
+
+// findApps finds every app matching the where clause along with their client secrets.
+func (ar appRepo) findApps(ctx context.Context, where string, args ...interface{}) ([]models.App, errors.RichError) {
+	query := fmt.Sprintf("SELECT %s FROM %s a WHERE %s", appColumns, APP_TABLE, where)
+	rows, err := ar.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	defer rows.Close()
+	apps := make([]models.App, 0)
+	appIndexes := make(map[string]int)
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, coreerrors.NewRepoQueryFailedError(err, true)
+		}
+		appIndexes[app.ID] = len(apps)
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	if len(apps) == 0 {
+		return apps, nil
+	}
+	secretQuery := fmt.Sprintf("SELECT %s FROM %s cs INNER JOIN %s a ON a.id = cs.app_id WHERE %s ORDER BY cs.created_date", clientSecretColumns, CLIENT_SECRET_TABLE, APP_TABLE, where)
+	secretRows, err := ar.db.QueryContext(ctx, secretQuery, args...)
+	if err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	defer secretRows.Close()
+	for secretRows.Next() {
+		var appID string
+		var clientSecret models.ClientSecret
+		err := secretRows.Scan(&appID, &clientSecret.ID, &clientSecret.Hash, &clientSecret.ExpirationDate, &clientSecret.CreatedDate)
+		if err != nil {
+			return nil, coreerrors.NewRepoQueryFailedError(err, true)
+		}
+		if i, ok := appIndexes[appID]; ok {
+			apps[i].ClientSecrets = append(apps[i].ClientSecrets, clientSecret)
+		}
+	}
+	if err := secretRows.Err(); err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	return apps, nil
+}
+
+// findScopes finds every scope matching the where clause.
+func (ar appRepo) findScopes(ctx context.Context, where string, args ...interface{}) ([]models.Scope, errors.RichError) {
+	query := fmt.Sprintf("SELECT %s FROM %s s WHERE %s", scopeColumns, SCOPE_TABLE, where)
+	rows, err := ar.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	defer rows.Close()
+	scopes := make([]models.Scope, 0)
+	for rows.Next() {
+		scope, err := scanScope(rows)
+		if err != nil {
+			return nil, coreerrors.NewRepoQueryFailedError(err, true)
+		}
+		scopes = append(scopes, scope)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	return scopes, nil
+}
+
+// changeClientSecrets marks the app as modified and then runs change in the same transaction.
+// Updating the app row first locks it, so changes to the client secrets of the same app are applied one at a time.
+func (ar appRepo) changeClientSecrets(ctx context.Context, appID, modifiedBy string, change func(tx *sql.Tx) error) errors.RichError {
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		return coreerrors.NewDatastoreTransactionFailedError(err, true)
+	}
+	query := fmt.Sprintf("UPDATE %s SET modified_by_id = ?, modified_on_date = ? WHERE id = ?", APP_TABLE)
+	result, err := tx.ExecContext(ctx, query, modifiedBy, time.Now().UTC(), appID)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err == sql.ErrNoRows {
+		fields := map[string]interface{}{"id": appID}
+		err = coreerrors.NewNoAppFoundError(fields, true)
+	}
+	if err == nil {
+		err = change(tx)
+	}
+	if err != nil {
+		return rollback(tx, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return coreerrors.NewDatastoreTransactionFailedError(err, true)
+	}
+	return nil
+}
+
+// appExists returns true if an app with the id exists.
+func (ar appRepo) appExists(ctx context.Context, appID string) (bool, errors.RichError) {
+	return exists(ctx, ar.db, "SELECT 1 FROM "+APP_TABLE+" WHERE id = ?", appID)
+}
+
+func insertClientSecrets(ctx context.Context, q queryer, appID string, clientSecrets []models.ClientSecret) error {
+	query := fmt.Sprintf("INSERT INTO %s (app_id, id, hash, expiration_date, created_date) VALUES (?, ?, ?, ?, ?)", CLIENT_SECRET_TABLE)
+	for _, clientSecret := range clientSecrets {
+		_, err := q.ExecContext(ctx, query, appID, clientSecret.ID, clientSecret.Hash, clientSecret.ExpirationDate.SQLValue(), clientSecret.CreatedDate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appJSONColumns serializes the app fields that are stored as JSON.
+func appJSONColumns(app models.App) (string, string, string, errors.RichError) {
+	redirectURIs, rErr := toJSON(app.RedirectURIs)
+	if rErr != nil {
+		return "", "", "", rErr
+	}
+	allowedOrigins, rErr := toJSON(app.AllowedOrigins)
+	if rErr != nil {
+		return "", "", "", rErr
+	}
+	policy, rErr := toJSON(app.Policy)
+	if rErr != nil {
+		return "", "", "", rErr
+	}
+	return redirectURIs, allowedOrigins, policy, nil
+}
+
+func scanApp(row rowScanner) (models.App, error) {
+	var app models.App
+	var redirectURIs, allowedOrigins, policy string
+	err := row.Scan(
+		&app.ID,
+		&app.OwnerID,
+		&app.Name,
+		&app.ClientID,
+		&redirectURIs,
+		&allowedOrigins,
+		&app.IsDisabled,
+		&policy,
+		&app.LogoURI,
+		&app.AuditData.CreatedByID,
+		&app.AuditData.CreatedOnDate,
+		&app.AuditData.ModifiedByID,
+		&app.AuditData.ModifiedOnDate,
+	)
+	if err != nil {
+		return app, err
+	}
+	if err := fromJSON(redirectURIs, &app.RedirectURIs); err != nil {
+		return app, err
+	}
+	if err := fromJSON(allowedOrigins, &app.AllowedOrigins); err != nil {
+		return app, err
+	}
+	err = fromJSON(policy, &app.Policy)
+	return app, err
+}
+
+func scanScope(row rowScanner) (models.Scope, error) {
+	var scope models.Scope
+	err := row.Scan(
+		&scope.ID,
+		&scope.AppID,
+		&scope.Name,
+		&scope.Description,
+		&scope.AuditData.CreatedByID,
+		&scope.AuditData.CreatedOnDate,
+		&scope.AuditData.ModifiedByID,
+		&scope.AuditData.ModifiedOnDate,
+	)
+	return scope, err
+}
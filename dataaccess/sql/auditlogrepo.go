@@ -0,0 +1,68 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+)
+
+// auditLogRepo is the repository struct for audit logs. The data of an audit log is stored as JSON.
+type auditLogRepo struct {
+	db *sql.DB
+}
+
+func NewAuditLogRepo(db *sql.DB) auditLogRepo {
+	return auditLogRepo{db}
+}
+
+func (auditLogRepo) GetName() string {
+	return "auditLogRepo"
+}
+
+func (auditLogRepo) GetType() string {
+	return dataSourceType
+}
+
+func (ar auditLogRepo) LogMessage(ctx context.Context, message models.AuditLog) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ar.GetName(), "LogMessage", ar.GetType())
+	defer span.End()
+	id := message.ID
+	if id == "" {
+		var rErr errors.RichError
+		id, rErr = newID()
+		if rErr != nil {
+			evtString := "failed to generate audit log id"
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+	}
+	data, rErr := toJSON(message.Data)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to serialize audit log data: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	query := fmt.Sprintf("INSERT INTO %s (id, message, code, asset_type, asset_id, audit_log_date, data) VALUES (?, ?, ?, ?, ?, ?, ?)", AUDITLOG_TABLE)
+	_, err := ar.db.ExecContext(ctx, query,
+		id,
+		message.Message,
+		message.Code,
+		message.AssetType,
+		message.AssetID,
+		message.AuditLogDate.UTC(),
+		data,
+	)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("audit log added")
+	return nil
+}
@@ -0,0 +1,128 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+)
+
+const (
+	consentColumns = "id, user_id, app_id, scope_name, created_by_id, created_on_date, modified_by_id, modified_on_date"
+)
+
+// consentRepo is the repository struct for consents. The table has a unique key on user, app and scope so concurrent grants of the same scope cannot store it twice.
+type consentRepo struct {
+	db *sql.DB
+}
+
+func NewConsentRepo(db *sql.DB) consentRepo {
+	return consentRepo{db}
+}
+
+func (consentRepo) GetName() string {
+	return "consentRepo"
+}
+
+func (consentRepo) GetType() string {
+	return dataSourceType
+}
+
+func (cr consentRepo) GetConsentsByUserIDAndAppID(ctx context.Context, userID, appID string) ([]models.Consent, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetConsentsByUserIDAndAppID", cr.GetType())
+	defer span.End()
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE user_id = ? AND app_id = ?", consentColumns, CONSENT_TABLE)
+	rows, err := cr.db.QueryContext(ctx, query, userID, appID)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	defer rows.Close()
+	consents := make([]models.Consent, 0)
+	for rows.Next() {
+		consent, err := scanConsent(rows)
+		if err != nil {
+			rErr := coreerrors.NewRepoQueryFailedError(err, true)
+			evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return nil, rErr
+		}
+		consents = append(consents, consent)
+	}
+	if err := rows.Err(); err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	span.AddEvent("consents retreived")
+	return consents, nil
+}
+
+// AddConsent ignores the insert when the user already consented to the scope for the app and reads back the existing consent instead.
+func (cr consentRepo) AddConsent(ctx context.Context, consent *models.Consent, createdByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "AddConsent", cr.GetType())
+	defer span.End()
+	id, rErr := newID()
+	if rErr != nil {
+		evtString := "failed to generate consent id"
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	createdOnDate := time.Now().UTC()
+	query := fmt.Sprintf("INSERT INTO %s (id, user_id, app_id, scope_name, created_by_id, created_on_date) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (user_id, app_id, scope_name) DO NOTHING", CONSENT_TABLE)
+	result, err := cr.db.ExecContext(ctx, query,
+		id,
+		consent.UserID,
+		consent.AppID,
+		consent.ScopeName,
+		createdByID,
+		createdOnDate,
+	)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err == sql.ErrNoRows {
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE user_id = ? AND app_id = ? AND scope_name = ?", consentColumns, CONSENT_TABLE)
+		var existingConsent models.Consent
+		existingConsent, err = scanConsent(cr.db.QueryRowContext(ctx, query, consent.UserID, consent.AppID, consent.ScopeName))
+		if err == nil {
+			*consent = existingConsent
+			span.AddEvent("consent already exists")
+			return nil
+		}
+	}
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	consent.ID = id
+	consent.AuditData.CreatedByID = createdByID
+	consent.AuditData.CreatedOnDate = createdOnDate
+	span.AddEvent("consent stored")
+	return nil
+}
+
+func scanConsent(row rowScanner) (models.Consent, error) {
+	var consent models.Consent
+	err := row.Scan(
+		&consent.ID,
+		&consent.UserID,
+		&consent.AppID,
+		&consent.ScopeName,
+		&consent.AuditData.CreatedByID,
+		&consent.AuditData.CreatedOnDate,
+		&consent.AuditData.ModifiedByID,
+		&consent.AuditData.ModifiedOnDate,
+	)
+	return consent, err
+}
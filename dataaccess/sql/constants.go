@@ -0,0 +1,18 @@
+package sql
+
+const (
+	USER_TABLE            = "users"
+	CONTACT_TABLE         = "contacts"
+	ADDRESS_TABLE         = "addresses"
+	PROFILE_TABLE         = "profiles"
+	APP_TABLE             = "apps"
+	CLIENT_SECRET_TABLE   = "client_secrets"
+	SCOPE_TABLE           = "scopes"
+	TOKEN_TABLE           = "tokens"
+	AUDITLOG_TABLE        = "audit_log"
+	CONSENT_TABLE         = "consents"
+	JWT_SIGNING_KEY_TABLE = "jwt_signing_key_sets"
+	SCHEMA_VERSION_TABLE  = "schema_migrations"
+
+	dataSourceType = "sql"
+)
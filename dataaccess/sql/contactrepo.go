@@ -0,0 +1,330 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+)
+
+const contactColumns = "c.id, c.user_id, c.name, c.raw_principal, c.principal, c.type, c.is_primary, c.confirmed_date, c.created_by_id, c.created_on_date, c.modified_by_id, c.modified_on_date"
+
+// contactRepo is the repository struct for user contacts.
+type contactRepo struct {
+	db *sql.DB
+}
+
+func NewContactRepo(db *sql.DB) contactRepo {
+	return contactRepo{db}
+}
+
+func (contactRepo) GetName() string {
+	return "contactRepo"
+}
+
+func (contactRepo) GetType() string {
+	return dataSourceType
+}
+
+func (cr contactRepo) GetContactByID(ctx context.Context, id string) (models.Contact, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetContactByID", cr.GetType())
+	defer span.End()
+	query := fmt.Sprintf("SELECT %s FROM %s c WHERE c.id = ?", contactColumns, CONTACT_TABLE)
+	contact, err := scanContact(cr.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{
+				"contact.id": id,
+			}
+			rErr := coreerrors.NewNoContactFoundError(fields, true)
+			evtString := fmt.Sprintf("no contact found with id: %s", id)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.Contact{}, rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Contact{}, rErr
+	}
+	span.AddEvent("contact retreived")
+	return contact, nil
+}
+
+func (cr contactRepo) GetPrimaryContactByUserID(ctx context.Context, userID string, contactType string) (models.Contact, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetPrimaryContactByUserID", cr.GetType())
+	defer span.End()
+	query := fmt.Sprintf("SELECT %s FROM %s c WHERE c.user_id = ? AND c.type = ? AND c.is_primary = ?", contactColumns, CONTACT_TABLE)
+	contact, err := scanContact(cr.db.QueryRowContext(ctx, query, userID, contactType, true))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{
+				"userId":             userID,
+				"contacts.isPrimary": true,
+				"contacts.type":      contactType,
+			}
+			rErr := coreerrors.NewNoContactFoundError(fields, true)
+			evtString := fmt.Sprintf("no primary contact found for user id: %s", userID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.Contact{}, rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Contact{}, rErr
+	}
+	span.AddEvent("primary contact retreived")
+	return contact, nil
+}
+
+func (cr contactRepo) GetContactsByUserID(ctx context.Context, userID string) ([]models.Contact, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetContactsByUserID", cr.GetType())
+	defer span.End()
+	contacts, rErr := findContacts(ctx, cr.db, "c.user_id = ?", userID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	if len(contacts) == 0 {
+		// the user is only looked up when there are no contacts to tell an unknown user apart.
+		exists, rErr := userExists(ctx, cr.db, userID)
+		if rErr != nil {
+			evtString := fmt.Sprintf("failed to check user exists for user id: %s", userID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return nil, rErr
+		}
+		if !exists {
+			fields := map[string]interface{}{
+				"id": userID,
+			}
+			rErr := coreerrors.NewNoUserFoundError(fields, true)
+			evtString := fmt.Sprintf("no user found with id: %s", userID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return nil, rErr
+		}
+	}
+	span.AddEvent("contacts retreived")
+	return contacts, nil
+}
+
+func (cr contactRepo) GetContactsByUserIDAndType(ctx context.Context, userID string, contactType string) ([]models.Contact, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetContactsByUserIDAndType", cr.GetType())
+	defer span.End()
+	contacts, rErr := findContacts(ctx, cr.db, "c.user_id = ? AND c.type = ?", userID, contactType)
+	if rErr != nil {
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return nil, rErr
+	}
+	span.AddEvent("contacts of type retreived")
+	return contacts, nil
+}
+
+func (cr contactRepo) AddContact(ctx context.Context, contact *models.Contact, createdByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "AddContact", cr.GetType())
+	defer span.End()
+	exists, rErr := userExists(ctx, cr.db, contact.UserID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to check user exists for user id: %s", contact.UserID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if !exists {
+		fields := map[string]interface{}{
+			"id": contact.UserID,
+		}
+		rErr := coreerrors.NewNoUserFoundError(fields, true)
+		evtString := fmt.Sprintf("no user found with id: %s", contact.UserID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	id, rErr := newID()
+	if rErr != nil {
+		evtString := "failed to generate contact id"
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	contact.AuditData.CreatedByID = createdByID
+	contact.AuditData.CreatedOnDate = time.Now().UTC()
+	query := fmt.Sprintf("INSERT INTO %s (id, user_id, name, raw_principal, principal, type, is_primary, confirmed_date, created_by_id, created_on_date) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", CONTACT_TABLE)
+	_, err := cr.db.ExecContext(ctx, query,
+		id,
+		contact.UserID,
+		contact.Name.SQLValue(),
+		contact.RawPrincipal,
+		contact.Principal,
+		contact.Type,
+		contact.IsPrimary,
+		contact.ConfirmedDate.SQLValue(),
+		contact.AuditData.CreatedByID,
+		contact.AuditData.CreatedOnDate,
+	)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	contact.ID = id
+	span.AddEvent("contact added")
+	return nil
+}
+
+func (cr contactRepo) UpdateContact(ctx context.Context, contact *models.Contact, modifiedByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "UpdateContact", cr.GetType())
+	defer span.End()
+	contact.AuditData.ModifiedByID.Set(modifiedByID)
+	contact.AuditData.ModifiedOnDate.Set(time.Now().UTC())
+	query := fmt.Sprintf("UPDATE %s SET name = ?, raw_principal = ?, principal = ?, type = ?, is_primary = ?, confirmed_date = ?, modified_by_id = ?, modified_on_date = ? WHERE id = ? AND user_id = ?", CONTACT_TABLE)
+	result, err := cr.db.ExecContext(ctx, query,
+		contact.Name.SQLValue(),
+		contact.RawPrincipal,
+		contact.Principal,
+		contact.Type,
+		contact.IsPrimary,
+		contact.ConfirmedDate.SQLValue(),
+		contact.AuditData.ModifiedByID.SQLValue(),
+		contact.AuditData.ModifiedOnDate.SQLValue(),
+		contact.ID,
+		contact.UserID,
+	)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{
+				"userId":     contact.UserID,
+				"contact.id": contact.ID,
+			}
+			rErr := coreerrors.NewNoContactFoundError(fields, true)
+			evtString := fmt.Sprintf("no contact found with id: %s", contact.ID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("contact updated")
+	return nil
+}
+
+func (cr contactRepo) GetExistingConfirmedContactsCountByPrincipalAndType(ctx context.Context, contactType, contactPrincipal string) (int64, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "GetExistingConfirmedContactsCountByPrincipalAndType", cr.GetType())
+	defer span.End()
+	// users are counted rather than contacts to match the other repos, where contacts are stored on their user.
+	query := fmt.Sprintf("SELECT COUNT(DISTINCT user_id) FROM %s WHERE type = ? AND principal = ? AND confirmed_date IS NOT NULL", CONTACT_TABLE)
+	var numConfirmedContacts int64
+	err := cr.db.QueryRowContext(ctx, query, contactType, contactPrincipal).Scan(&numConfirmedContacts)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return 0, rErr
+	}
+	span.AddEvent("number of confirmed contacts retreived")
+	return numConfirmedContacts, nil
+}
+
+func (cr contactRepo) SwapPrimaryContacts(ctx context.Context, previousPrimaryContact, newPrimaryContact *models.Contact, modifiedBy string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, cr.GetName(), "SwapPrimaryContacts", cr.GetType())
+	defer span.End()
+	tx, err := cr.db.BeginTx(ctx, nil)
+	if err != nil {
+		rErr := coreerrors.NewDatastoreTransactionFailedError(err, true)
+		evtString := fmt.Sprintf("failed to start transaction: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	modifiedOnDate := time.Now().UTC()
+	query := fmt.Sprintf("UPDATE %s SET is_primary = ?, modified_by_id = ?, modified_on_date = ? WHERE id = ? AND user_id = ?", CONTACT_TABLE)
+	result, err := tx.ExecContext(ctx, query, false, modifiedBy, modifiedOnDate, previousPrimaryContact.ID, previousPrimaryContact.UserID)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("previous primary contact not found: %s", previousPrimaryContact.ID)
+		}
+		rErr := rollback(tx, err)
+		evtString := fmt.Sprintf("failed to unset previous primary contact: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	result, err = tx.ExecContext(ctx, query, true, modifiedBy, modifiedOnDate, newPrimaryContact.ID, newPrimaryContact.UserID)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("new primary contact not found: %s", newPrimaryContact.ID)
+		}
+		rErr := rollback(tx, err)
+		evtString := fmt.Sprintf("failed to set new primary contact: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	err = tx.Commit()
+	if err != nil {
+		rErr := coreerrors.NewDatastoreTransactionFailedError(err, true)
+		evtString := fmt.Sprintf("failed to commit transaction: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	previousPrimaryContact.IsPrimary = false
+	previousPrimaryContact.AuditData.ModifiedByID.Set(modifiedBy)
+	previousPrimaryContact.AuditData.ModifiedOnDate.Set(modifiedOnDate)
+	newPrimaryContact.IsPrimary = true
+	newPrimaryContact.AuditData.ModifiedByID.Set(modifiedBy)
+	newPrimaryContact.AuditData.ModifiedOnDate.Set(modifiedOnDate)
+	span.AddEvent("contact primary states set")
+	return nil
+}
+
+// findContacts finds every contact matching the where clause.
+func findContacts(ctx context.Context, q queryer, where string, args ...interface{}) ([]models.Contact, errors.RichError) {
+	query := fmt.Sprintf("SELECT %s FROM %s c WHERE %s", contactColumns, CONTACT_TABLE, where)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	defer rows.Close()
+	contacts := make([]models.Contact, 0)
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, coreerrors.NewRepoQueryFailedError(err, true)
+		}
+		contacts = append(contacts, contact)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	return contacts, nil
+}
+
+func scanContact(row rowScanner) (models.Contact, error) {
+	var contact models.Contact
+	err := row.Scan(
+		&contact.ID,
+		&contact.UserID,
+		&contact.Name,
+		&contact.RawPrincipal,
+		&contact.Principal,
+		&contact.Type,
+		&contact.IsPrimary,
+		&contact.ConfirmedDate,
+		&contact.AuditData.CreatedByID,
+		&contact.AuditData.CreatedOnDate,
+		&contact.AuditData.ModifiedByID,
+		&contact.AuditData.ModifiedOnDate,
+	)
+	return contact, err
+}
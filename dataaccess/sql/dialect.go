@@ -0,0 +1,37 @@
+package sql
+
+import (
+	"database/sql"
+	"embed"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/richerror/errors"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+// Dialect describes a database the sql repos can run against. Queries are written with ? placeholders, so every dialect must accept them.
+type Dialect struct {
+	name          string
+	driverName    string
+	migrationsDir string
+}
+
+func (d Dialect) GetName() string {
+	return d.name
+}
+
+// Open opens a database for the dialect and checks that it can be reached.
+func Open(dialect Dialect, dataSourceName string) (*sql.DB, errors.RichError) {
+	db, err := sql.Open(dialect.driverName, dataSourceName)
+	if err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	return db, nil
+}
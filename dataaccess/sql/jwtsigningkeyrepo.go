@@ -0,0 +1,94 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+)
+
+// jwtSigningKeySetID is the id of the only row in the jwt signing key table, the whole key set is stored as JSON in that row so it can be replaced with a single compare and swap on the version.
+const jwtSigningKeySetID = 1
+
+// jwtSigningKeyRepo is the repository struct for the jwt signing key set shared by every instance.
+type jwtSigningKeyRepo struct {
+	db *sql.DB
+}
+
+func NewJWTSigningKeyRepo(db *sql.DB) jwtSigningKeyRepo {
+	return jwtSigningKeyRepo{db}
+}
+
+func (jwtSigningKeyRepo) GetName() string {
+	return "jwtSigningKeyRepo"
+}
+
+func (jwtSigningKeyRepo) GetType() string {
+	return dataSourceType
+}
+
+func (kr jwtSigningKeyRepo) GetJWTSigningKeySet(ctx context.Context) (models.JWTSigningKeySet, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, kr.GetName(), "GetJWTSigningKeySet", kr.GetType())
+	defer span.End()
+	var keySet models.JWTSigningKeySet
+	var keys string
+	query := fmt.Sprintf("SELECT version, keys FROM %s WHERE id = ?", JWT_SIGNING_KEY_TABLE)
+	err := kr.db.QueryRowContext(ctx, query, jwtSigningKeySetID).Scan(&keySet.Version, &keys)
+	if err == sql.ErrNoRows {
+		span.AddEvent("no jwt signing key set stored")
+		return models.JWTSigningKeySet{}, nil
+	}
+	if err == nil {
+		err = fromJSON(keys, &keySet.Keys)
+	}
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.JWTSigningKeySet{}, rErr
+	}
+	span.AddEvent("jwt signing key set retreived")
+	return keySet, nil
+}
+
+// SaveJWTSigningKeySet inserts the key set when none has been stored yet, and otherwise updates it where the version still matches.
+func (kr jwtSigningKeyRepo) SaveJWTSigningKeySet(ctx context.Context, keySet *models.JWTSigningKeySet) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, kr.GetName(), "SaveJWTSigningKeySet", kr.GetType())
+	defer span.End()
+	keys, rErr := toJSON(keySet.Keys)
+	if rErr != nil {
+		evtString := "failed to serialize jwt signing keys"
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	var result sql.Result
+	var err error
+	if keySet.Version == 0 {
+		query := fmt.Sprintf("INSERT INTO %s (id, version, keys) VALUES (?, 1, ?) ON CONFLICT (id) DO NOTHING", JWT_SIGNING_KEY_TABLE)
+		result, err = kr.db.ExecContext(ctx, query, jwtSigningKeySetID, keys)
+	} else {
+		query := fmt.Sprintf("UPDATE %s SET version = version + 1, keys = ? WHERE id = ? AND version = ?", JWT_SIGNING_KEY_TABLE)
+		result, err = kr.db.ExecContext(ctx, query, keys, jwtSigningKeySetID, keySet.Version)
+	}
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			rErr := coreerrors.NewJWTSigningKeySetConflictError(keySet.Version, true)
+			apptelemetry.SetSpanOriginalError(&span, rErr, "jwt signing key set was changed by another instance")
+			return rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	keySet.Version++
+	span.AddEvent("jwt signing key set stored")
+	return nil
+}
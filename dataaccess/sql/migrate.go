@@ -0,0 +1,112 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/richerror/errors"
+)
+
+// migration is a versioned schema change. Migrations are named like 0001_description.sql and are applied in version order.
+type migration struct {
+	version int
+	name    string
+	script  string
+}
+
+// Migrate applies every migration for the dialect that has not been applied to the database yet. Each migration runs in its own transaction along with recording its version, so a failed migration leaves the database at the previous version.
+func Migrate(ctx context.Context, db *sql.DB, dialect Dialect) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, "sql", "Migrate")
+	defer span.End()
+	migrations, rErr := loadMigrations(dialect)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to load migrations for dialect: %s", dialect.GetName())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	createVersionTable := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version INTEGER PRIMARY KEY, applied_on_date TIMESTAMP NOT NULL)", SCHEMA_VERSION_TABLE)
+	_, err := db.ExecContext(ctx, createVersionTable)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	var currentVersion int
+	err = db.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", SCHEMA_VERSION_TABLE)).Scan(&currentVersion)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	for _, m := range migrations {
+		if m.version <= currentVersion {
+			continue
+		}
+		err := applyMigration(ctx, db, m)
+		if err != nil {
+			rErr := coreerrors.NewSchemaMigrationFailedError(m.version, err, true)
+			evtString := fmt.Sprintf("failed to apply migration %s: %s", m.name, err.Error())
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+		span.AddEvent(fmt.Sprintf("migration applied: %s", m.name))
+	}
+	span.AddEvent("schema migrated")
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, m.script)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version, applied_on_date) VALUES (?, ?)", SCHEMA_VERSION_TABLE), m.version, time.Now().UTC())
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func loadMigrations(dialect Dialect) ([]migration, errors.RichError) {
+	entries, err := migrationsFS.ReadDir(dialect.migrationsDir)
+	if err != nil {
+		return nil, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		versionString := strings.SplitN(name, "_", 2)[0]
+		version, err := strconv.Atoi(versionString)
+		if err != nil {
+			return nil, coreerrors.NewSchemaMigrationFailedError(0, fmt.Errorf("migration %s does not start with a version number", name), true)
+		}
+		script, err := migrationsFS.ReadFile(path.Join(dialect.migrationsDir, name))
+		if err != nil {
+			return nil, coreerrors.NewSchemaMigrationFailedError(version, err, true)
+		}
+		migrations = append(migrations, migration{version, name, string(script)})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
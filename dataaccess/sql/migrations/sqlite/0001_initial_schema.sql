@@ -0,0 +1,125 @@
+CREATE TABLE users (
+    id TEXT PRIMARY KEY,
+    password_hash TEXT NOT NULL,
+    consecutive_failed_login_attempts INTEGER NOT NULL DEFAULT 0,
+    locked_out_until TIMESTAMP NULL,
+    last_login_date TIMESTAMP NULL,
+    created_by_id TEXT NOT NULL,
+    created_on_date TIMESTAMP NOT NULL,
+    modified_by_id TEXT NULL,
+    modified_on_date TIMESTAMP NULL
+);
+
+CREATE TABLE contacts (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id),
+    name TEXT NULL,
+    raw_principal TEXT NOT NULL,
+    principal TEXT NOT NULL,
+    type TEXT NOT NULL,
+    is_primary BOOLEAN NOT NULL,
+    confirmed_date TIMESTAMP NULL,
+    created_by_id TEXT NOT NULL,
+    created_on_date TIMESTAMP NOT NULL,
+    modified_by_id TEXT NULL,
+    modified_on_date TIMESTAMP NULL
+);
+
+CREATE INDEX contacts_user_id ON contacts (user_id);
+CREATE INDEX contacts_principal_type ON contacts (principal, type);
+
+CREATE TABLE addresses (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id),
+    name TEXT NULL,
+    line1 TEXT NOT NULL,
+    line2 TEXT NULL,
+    city TEXT NOT NULL,
+    state TEXT NOT NULL,
+    postal_code TEXT NOT NULL,
+    is_primary BOOLEAN NOT NULL,
+    created_by_id TEXT NOT NULL,
+    created_on_date TIMESTAMP NOT NULL,
+    modified_by_id TEXT NULL,
+    modified_on_date TIMESTAMP NULL
+);
+
+CREATE INDEX addresses_user_id ON addresses (user_id);
+
+CREATE TABLE profiles (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL UNIQUE REFERENCES users (id),
+    first_name TEXT NULL,
+    middle_name TEXT NULL,
+    last_name TEXT NULL,
+    date_of_birth TIMESTAMP NULL,
+    created_by_id TEXT NOT NULL,
+    created_on_date TIMESTAMP NOT NULL,
+    modified_by_id TEXT NULL,
+    modified_on_date TIMESTAMP NULL
+);
+
+CREATE TABLE apps (
+    id TEXT PRIMARY KEY,
+    owner_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    client_id TEXT NOT NULL UNIQUE,
+    redirect_uris TEXT NOT NULL,
+    allowed_origins TEXT NOT NULL,
+    is_disabled BOOLEAN NOT NULL,
+    policy TEXT NOT NULL,
+    logo_uri TEXT NOT NULL,
+    created_by_id TEXT NOT NULL,
+    created_on_date TIMESTAMP NOT NULL,
+    modified_by_id TEXT NULL,
+    modified_on_date TIMESTAMP NULL
+);
+
+CREATE INDEX apps_owner_id ON apps (owner_id);
+
+CREATE TABLE client_secrets (
+    app_id TEXT NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    id TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    expiration_date TIMESTAMP NULL,
+    created_date TIMESTAMP NOT NULL,
+    PRIMARY KEY (app_id, id)
+);
+
+CREATE TABLE scopes (
+    id TEXT PRIMARY KEY,
+    app_id TEXT NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    created_by_id TEXT NOT NULL,
+    created_on_date TIMESTAMP NOT NULL,
+    modified_by_id TEXT NULL,
+    modified_on_date TIMESTAMP NULL
+);
+
+CREATE INDEX scopes_app_id ON scopes (app_id);
+
+CREATE TABLE tokens (
+    value TEXT PRIMARY KEY,
+    token_type INTEGER NOT NULL,
+    expiration TIMESTAMP NOT NULL,
+    target_id TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    meta_data TEXT NOT NULL
+);
+
+CREATE INDEX tokens_target_id ON tokens (target_id);
+CREATE INDEX tokens_client_id ON tokens (client_id);
+CREATE INDEX tokens_expiration ON tokens (expiration);
+
+CREATE TABLE audit_log (
+    id TEXT PRIMARY KEY,
+    message TEXT NOT NULL,
+    code TEXT NOT NULL,
+    asset_type TEXT NOT NULL,
+    asset_id TEXT NOT NULL,
+    audit_log_date TIMESTAMP NOT NULL,
+    data TEXT NOT NULL
+);
+
+CREATE INDEX audit_log_asset ON audit_log (asset_type, asset_id);
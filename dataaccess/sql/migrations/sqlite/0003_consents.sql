@@ -0,0 +1,11 @@
+CREATE TABLE consents (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    app_id TEXT NOT NULL,
+    scope_name TEXT NOT NULL,
+    created_by_id TEXT NOT NULL,
+    created_on_date TIMESTAMP NOT NULL,
+    modified_by_id TEXT NULL,
+    modified_on_date TIMESTAMP NULL,
+    UNIQUE (user_id, app_id, scope_name)
+);
@@ -0,0 +1,5 @@
+CREATE TABLE jwt_signing_key_sets (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    version INTEGER NOT NULL,
+    keys TEXT NOT NULL
+);
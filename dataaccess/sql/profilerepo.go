@@ -0,0 +1,160 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+)
+
+const profileColumns = "p.id, p.user_id, p.first_name, p.middle_name, p.last_name, p.date_of_birth, p.created_by_id, p.created_on_date, p.modified_by_id, p.modified_on_date"
+
+// profileRepo is the repository struct for user profiles. A user has at most one profile.
+type profileRepo struct {
+	db *sql.DB
+}
+
+func NewProfileRepo(db *sql.DB) profileRepo {
+	return profileRepo{db}
+}
+
+func (profileRepo) GetName() string {
+	return "profileRepo"
+}
+
+func (profileRepo) GetType() string {
+	return dataSourceType
+}
+
+func (pr profileRepo) GetProfileByUserID(ctx context.Context, userID string) (models.Profile, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, pr.GetName(), "GetProfileByUserID", pr.GetType())
+	defer span.End()
+	query := fmt.Sprintf("SELECT %s FROM %s p WHERE p.user_id = ?", profileColumns, PROFILE_TABLE)
+	profile, err := scanProfile(pr.db.QueryRowContext(ctx, query, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{
+				"userId": userID,
+			}
+			rErr := coreerrors.NewNoProfileFoundError(fields, true)
+			evtString := fmt.Sprintf("no profile found for user id: %s", userID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.Profile{}, rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Profile{}, rErr
+	}
+	span.AddEvent("profile retreived")
+	return profile, nil
+}
+
+func (pr profileRepo) AddProfile(ctx context.Context, profile *models.Profile, createdByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, pr.GetName(), "AddProfile", pr.GetType())
+	defer span.End()
+	exists, rErr := userExists(ctx, pr.db, profile.UserID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to check user exists for user id: %s", profile.UserID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if !exists {
+		fields := map[string]interface{}{
+			"id": profile.UserID,
+		}
+		rErr := coreerrors.NewNoUserFoundError(fields, true)
+		evtString := fmt.Sprintf("no user found with id: %s", profile.UserID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	id, rErr := newID()
+	if rErr != nil {
+		evtString := "failed to generate profile id"
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	profile.CreatedByID = createdByID
+	profile.CreatedOnDate = time.Now().UTC()
+	query := fmt.Sprintf("INSERT INTO %s (id, user_id, first_name, middle_name, last_name, date_of_birth, created_by_id, created_on_date) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", PROFILE_TABLE)
+	_, err := pr.db.ExecContext(ctx, query,
+		id,
+		profile.UserID,
+		profile.FirstName.SQLValue(),
+		profile.MiddleName.SQLValue(),
+		profile.LastName.SQLValue(),
+		profile.DateOfBirth.SQLValue(),
+		profile.CreatedByID,
+		profile.CreatedOnDate,
+	)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	profile.ID = id
+	span.AddEvent("profile added")
+	return nil
+}
+
+func (pr profileRepo) UpdateUserProfile(ctx context.Context, profile *models.Profile, modifiedByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, pr.GetName(), "UpdateUserProfile", pr.GetType())
+	defer span.End()
+	profile.ModifiedByID.Set(modifiedByID)
+	profile.ModifiedOnDate.Set(time.Now().UTC())
+	query := fmt.Sprintf("UPDATE %s SET first_name = ?, middle_name = ?, last_name = ?, date_of_birth = ?, modified_by_id = ?, modified_on_date = ? WHERE id = ? AND user_id = ?", PROFILE_TABLE)
+	result, err := pr.db.ExecContext(ctx, query,
+		profile.FirstName.SQLValue(),
+		profile.MiddleName.SQLValue(),
+		profile.LastName.SQLValue(),
+		profile.DateOfBirth.SQLValue(),
+		profile.ModifiedByID.SQLValue(),
+		profile.ModifiedOnDate.SQLValue(),
+		profile.ID,
+		profile.UserID,
+	)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{
+				"userId":     profile.UserID,
+				"profile.id": profile.ID,
+			}
+			rErr := coreerrors.NewNoProfileFoundError(fields, true)
+			evtString := fmt.Sprintf("no profile found with id: %s", profile.ID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("profile updated")
+	return nil
+}
+
+func scanProfile(row rowScanner) (models.Profile, error) {
+	var profile models.Profile
+	err := row.Scan(
+		&profile.ID,
+		&profile.UserID,
+		&profile.FirstName,
+		&profile.MiddleName,
+		&profile.LastName,
+		&profile.DateOfBirth,
+		&profile.CreatedByID,
+		&profile.CreatedOnDate,
+		&profile.ModifiedByID,
+		&profile.ModifiedOnDate,
+	)
+	return profile, err
+}
@@ -0,0 +1,84 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	repo "github.com/calvine/goauth/core/repositories"
+	"github.com/calvine/goauth/dataaccess/internal/repotest"
+	"github.com/google/uuid"
+)
+
+func TestSQLiteRepos(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	var userRepo repo.UserRepo = NewUserRepo(db)
+	var contactRepo repo.ContactRepo = NewContactRepo(db)
+	var addressRepo repo.AddressRepo = NewAddressRepo(db)
+	var profileRepo repo.ProfileRepo = NewProfileRepo(db)
+	var appRepo repo.AppRepo = NewAppRepo(db)
+	var tokenRepo repo.TokenRepo = NewTokenRepo(db)
+	var auditLogRepo repo.AuditLogRepo = NewAuditLogRepo(db)
+	var consentRepo repo.ConsentRepo = NewConsentRepo(db)
+	var jwtSigningKeyRepo repo.JWTSigningKeyRepo = NewJWTSigningKeyRepo(db)
+	testHarnessInput := repotest.RepoTestHarnessInput{
+		UserRepo:          &userRepo,
+		ContactRepo:       &contactRepo,
+		AddressRepo:       &addressRepo,
+		ProfileRepo:       &profileRepo,
+		AppRepo:           &appRepo,
+		TokenRepo:         &tokenRepo,
+		AuditLogRepo:      &auditLogRepo,
+		ConsentRepo:       &consentRepo,
+		JWTSigningKeyRepo: &jwtSigningKeyRepo,
+		// apps and consents are in the same database, so consents are deleted with their app.
+		AppRepoDeletesConsents: true,
+		IDGenerator: func(getZeroId bool) string {
+			if getZeroId {
+				return uuid.UUID{}.String()
+			}
+			return uuid.Must(uuid.NewRandom()).String()
+		},
+	}
+	repotest.RunReposTestHarness(t, testHarnessInput)
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	err := Migrate(context.TODO(), db, SQLite)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to run migrations a second time: %s", err.GetErrorCode())
+	}
+	migrations, err := loadMigrations(SQLite)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %s", err.GetErrorCode())
+	}
+	var numApplied int
+	scanErr := db.QueryRow("SELECT COUNT(*) FROM " + SCHEMA_VERSION_TABLE).Scan(&numApplied)
+	if scanErr != nil {
+		t.Fatalf("failed to count applied migrations: %s", scanErr.Error())
+	}
+	if numApplied != len(migrations) {
+		t.Errorf("expected each migration to be applied once: got - %d expected - %d", numApplied, len(migrations))
+	}
+}
+
+// newTestDB creates a migrated sqlite database in a temp directory. A file is used rather than an in memory database because each connection in the pool would get its own in memory database.
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := Open(SQLite, filepath.Join(t.TempDir(), "goauth.db"))
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to open test database: %s", err.GetErrorCode())
+	}
+	err = Migrate(context.TODO(), db, SQLite)
+	if err != nil {
+		db.Close()
+		t.Log(err.Error())
+		t.Fatalf("failed to migrate test database: %s", err.GetErrorCode())
+	}
+	return db
+}
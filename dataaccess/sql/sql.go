@@ -0,0 +1,90 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/richerror/errors"
+	"github.com/google/uuid"
+)
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows so the scan functions can be used for single and multiple row queries.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx so queries can be shared between transactional and non transactional code.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func newID() (string, errors.RichError) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", coreerrors.NewGenerateUUIDFailedError(err, true)
+	}
+	return id.String(), nil
+}
+
+// exists returns true if the query returns a row.
+func exists(ctx context.Context, q queryer, query string, args ...interface{}) (bool, errors.RichError) {
+	var found int
+	err := q.QueryRowContext(ctx, query, args...).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	return true, nil
+}
+
+// placeholders returns a comma separated list of n placeholders for use in an IN clause.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// toJSON marshals values that are stored in a single text column like lists and maps.
+func toJSON(value interface{}) (string, errors.RichError) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", coreerrors.NewRepoQueryFailedError(err, true)
+	}
+	return string(data), nil
+}
+
+func fromJSON(data string, value interface{}) error {
+	return json.Unmarshal([]byte(data), value)
+}
+
+// rollback rolls back a failed transaction and returns the error that caused it, wrapped in the appropriate transaction error.
+func rollback(tx *sql.Tx, err error) errors.RichError {
+	rollbackErr := tx.Rollback()
+	if rollbackErr != nil {
+		return coreerrors.NewDatastoreTransactionAbortFailedError(err, rollbackErr, true)
+	}
+	if rErr, ok := err.(errors.RichError); ok {
+		return rErr
+	}
+	return coreerrors.NewDatastoreTransactionFailedError(err, true)
+}
+
+// checkRowsAffected returns sql.ErrNoRows when a statement did not affect any rows, so callers can handle missing rows the same way for queries and updates.
+func checkRowsAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func userExists(ctx context.Context, q queryer, userID string) (bool, errors.RichError) {
+	return exists(ctx, q, "SELECT 1 FROM "+USER_TABLE+" WHERE id = ?", userID)
+}
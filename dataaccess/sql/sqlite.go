@@ -0,0 +1,13 @@
+package sql
+
+import (
+	// registers the sqlite3 database/sql driver.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLite is the dialect for SQLite databases. SQLite does not enforce foreign keys unless they are turned on for the connection, so the repos remove dependent rows themselves.
+var SQLite = Dialect{
+	name:          "sqlite",
+	driverName:    "sqlite3",
+	migrationsDir: "migrations/sqlite",
+}
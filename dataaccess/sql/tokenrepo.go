@@ -0,0 +1,246 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+)
+
+const tokenColumns = "value, token_type, expiration, target_id, meta_data"
+
+// tokenRepo is the repository struct for tokens. The client id is copied out of the token meta data into its own column so tokens can be deleted by client id. Expired tokens are not removed by the database, so callers must check the expiration of returned tokens.
+type tokenRepo struct {
+	db *sql.DB
+}
+
+func NewTokenRepo(db *sql.DB) tokenRepo {
+	return tokenRepo{db}
+}
+
+func (tokenRepo) GetName() string {
+	return "tokenRepo"
+}
+
+func (tokenRepo) GetType() string {
+	return dataSourceType
+}
+
+func (tr tokenRepo) GetToken(ctx context.Context, tokenValue string) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "GetToken", tr.GetType())
+	defer span.End()
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE value = ?", tokenColumns, TOKEN_TABLE)
+	token, err := scanToken(tr.db.QueryRowContext(ctx, query, tokenValue))
+	if err != nil {
+		rErr := tokenQueryError(err, tokenValue)
+		apptelemetry.SetSpanOriginalError(&span, rErr, fmt.Sprintf("failed to get token: %s", tokenValue))
+		return models.Token{}, rErr
+	}
+	span.AddEvent("token retreived")
+	return token, nil
+}
+
+func (tr tokenRepo) PutToken(ctx context.Context, token models.Token) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "PutToken", tr.GetType())
+	defer span.End()
+	metaData, rErr := toJSON(token.MetaData)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to serialize token meta data: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	query := fmt.Sprintf("INSERT INTO %s (value, token_type, expiration, target_id, client_id, meta_data) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (value) DO UPDATE SET token_type = excluded.token_type, expiration = excluded.expiration, target_id = excluded.target_id, client_id = excluded.client_id, meta_data = excluded.meta_data", TOKEN_TABLE)
+	_, err := tr.db.ExecContext(ctx, query,
+		token.Value,
+		int(token.TokenType),
+		token.Expiration.UTC(),
+		token.TargetID,
+		token.MetaData[models.TokenMetaDataKeyClientID],
+		metaData,
+	)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("token stored")
+	return nil
+}
+
+func (tr tokenRepo) DeleteToken(ctx context.Context, tokenValue string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "DeleteToken", tr.GetType())
+	defer span.End()
+	result, err := tr.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE value = ?", TOKEN_TABLE), tokenValue)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err != nil {
+		rErr := tokenQueryError(err, tokenValue)
+		apptelemetry.SetSpanOriginalError(&span, rErr, fmt.Sprintf("failed to delete token: %s", tokenValue))
+		return rErr
+	}
+	span.AddEvent("token deleted")
+	return nil
+}
+
+// ConsumeToken reads and deletes the token in a single transaction. The token is only returned if this call deleted it, so when the same token is consumed concurrently only one caller gets it back.
+func (tr tokenRepo) ConsumeToken(ctx context.Context, tokenValue string) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "ConsumeToken", tr.GetType())
+	defer span.End()
+	tx, err := tr.db.BeginTx(ctx, nil)
+	if err != nil {
+		rErr := coreerrors.NewDatastoreTransactionFailedError(err, true)
+		evtString := fmt.Sprintf("failed to start transaction: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Token{}, rErr
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE value = ?", tokenColumns, TOKEN_TABLE)
+	token, err := scanToken(tx.QueryRowContext(ctx, query, tokenValue))
+	if err == nil {
+		var result sql.Result
+		result, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE value = ?", TOKEN_TABLE), tokenValue)
+		if err == nil {
+			err = checkRowsAffected(result)
+		}
+	}
+	if err != nil {
+		rErr := rollback(tx, tokenQueryError(err, tokenValue))
+		apptelemetry.SetSpanOriginalError(&span, rErr, fmt.Sprintf("failed to consume token: %s", tokenValue))
+		return models.Token{}, rErr
+	}
+	err = tx.Commit()
+	if err != nil {
+		rErr := coreerrors.NewDatastoreTransactionFailedError(err, true)
+		evtString := fmt.Sprintf("failed to commit transaction: %s", err.Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.Token{}, rErr
+	}
+	span.AddEvent("token consumed")
+	return token, nil
+}
+
+// MarkTokenRotated only updates the token if its meta data has not changed since it was read. When the same token is rotated concurrently only one update applies, and the other callers read the token again and find it already rotated.
+func (tr tokenRepo) MarkTokenRotated(ctx context.Context, tokenValue, rotatedTo string, expiration time.Time) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "MarkTokenRotated", tr.GetType())
+	defer span.End()
+	selectQuery := fmt.Sprintf("SELECT %s FROM %s WHERE value = ?", tokenColumns, TOKEN_TABLE)
+	updateQuery := fmt.Sprintf("UPDATE %s SET meta_data = ?, expiration = ? WHERE value = ? AND meta_data = ?", TOKEN_TABLE)
+	for {
+		token, storedMetaData, err := scanTokenWithRawMetaData(tr.db.QueryRowContext(ctx, selectQuery, tokenValue))
+		if err != nil {
+			rErr := tokenQueryError(err, tokenValue)
+			apptelemetry.SetSpanOriginalError(&span, rErr, fmt.Sprintf("failed to get token: %s", tokenValue))
+			return models.Token{}, rErr
+		}
+		if token.MetaData[models.TokenMetaDataKeyRotatedTo] != "" {
+			span.AddEvent("token already rotated")
+			return token, nil
+		}
+		rotatedMetaData := make(map[string]string, len(token.MetaData)+1)
+		for key, value := range token.MetaData {
+			rotatedMetaData[key] = value
+		}
+		rotatedMetaData[models.TokenMetaDataKeyRotatedTo] = rotatedTo
+		metaData, rErr := toJSON(rotatedMetaData)
+		if rErr != nil {
+			evtString := fmt.Sprintf("failed to serialize token meta data: %s", rErr.GetErrors()[0].Error())
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.Token{}, rErr
+		}
+		result, err := tr.db.ExecContext(ctx, updateQuery, metaData, expiration.UTC(), tokenValue, storedMetaData)
+		if err == nil {
+			err = checkRowsAffected(result)
+			if err == sql.ErrNoRows {
+				// the token changed after it was read, so it is read again to see if it was rotated.
+				continue
+			}
+		}
+		if err != nil {
+			rErr := coreerrors.NewRepoQueryFailedError(err, true)
+			evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.Token{}, rErr
+		}
+		span.AddEvent("token marked rotated")
+		return token, nil
+	}
+}
+
+func (tr tokenRepo) DeleteTokensByTargetID(ctx context.Context, targetID string, tokenTypes []models.TokenType) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "DeleteTokensByTargetID", tr.GetType())
+	defer span.End()
+	if len(tokenTypes) == 0 {
+		span.AddEvent("0 tokens deleted")
+		return nil
+	}
+	args := make([]interface{}, 0, len(tokenTypes)+1)
+	args = append(args, targetID)
+	for _, tokenType := range tokenTypes {
+		args = append(args, int(tokenType))
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE target_id = ? AND token_type IN (%s)", TOKEN_TABLE, placeholders(len(tokenTypes)))
+	result, err := tr.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	deleted, _ := result.RowsAffected()
+	span.AddEvent(fmt.Sprintf("%d tokens deleted", deleted))
+	return nil
+}
+
+func (tr tokenRepo) DeleteTokensByClientID(ctx context.Context, clientID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, tr.GetName(), "DeleteTokensByClientID", tr.GetType())
+	defer span.End()
+	result, err := tr.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE client_id = ?", TOKEN_TABLE), clientID)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	deleted, _ := result.RowsAffected()
+	span.AddEvent(fmt.Sprintf("%d tokens deleted", deleted))
+	return nil
+}
+
+// tokenQueryError maps a failed single token query to an invalid token error when no token was found, so callers see the same error as the other token repos.
+func tokenQueryError(err error, tokenValue string) errors.RichError {
+	if err == sql.ErrNoRows {
+		return coreerrors.NewInvalidTokenError(tokenValue, true)
+	}
+	return coreerrors.NewRepoQueryFailedError(err, true)
+}
+
+func scanToken(row rowScanner) (models.Token, error) {
+	token, _, err := scanTokenWithRawMetaData(row)
+	return token, err
+}
+
+// scanTokenWithRawMetaData also returns the meta data as it is stored, so it can be compared when the token is updated.
+func scanTokenWithRawMetaData(row rowScanner) (models.Token, string, error) {
+	var token models.Token
+	var tokenType int
+	var metaData string
+	err := row.Scan(
+		&token.Value,
+		&tokenType,
+		&token.Expiration,
+		&token.TargetID,
+		&metaData,
+	)
+	if err != nil {
+		return token, metaData, err
+	}
+	token.TokenType = models.TokenType(tokenType)
+	err = fromJSON(metaData, &token.MetaData)
+	return token, metaData, err
+}
@@ -0,0 +1,282 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/models/aggregate"
+	"github.com/calvine/richerror/errors"
+)
+
+const userColumns = "u.id, u.password_hash, u.consecutive_failed_login_attempts, u.locked_out_until, u.last_login_date, u.totp_secret, u.totp_enabled, u.totp_last_used_time_step, u.created_by_id, u.created_on_date, u.modified_by_id, u.modified_on_date"
+
+// userRepo is the repository struct for users.
+type userRepo struct {
+	db *sql.DB
+}
+
+func NewUserRepo(db *sql.DB) userRepo {
+	return userRepo{db}
+}
+
+func (userRepo) GetName() string {
+	return "userRepo"
+}
+
+func (userRepo) GetType() string {
+	return dataSourceType
+}
+
+func (ur userRepo) GetUserByID(ctx context.Context, id string) (models.User, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "GetUserByID", ur.GetType())
+	defer span.End()
+	query := fmt.Sprintf("SELECT %s FROM %s u WHERE u.id = ?", userColumns, USER_TABLE)
+	user, err := scanUser(ur.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{
+				"id": id,
+			}
+			rErr := coreerrors.NewNoUserFoundError(fields, true)
+			evtString := fmt.Sprintf("%s: %s", rErr.GetErrorMessage(), id)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.User{}, rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.User{}, rErr
+	}
+	span.AddEvent("user retreived")
+	return user, nil
+}
+
+func (ur userRepo) GetFullUserByID(ctx context.Context, id string) (aggregate.FullUser, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "GetFullUserByID", ur.GetType())
+	defer span.End()
+	user, rErr := ur.GetUserByID(ctx, id)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to get user with id: %s", id)
+		apptelemetry.SetSpanError(&span, rErr, evtString)
+		return aggregate.FullUser{}, rErr
+	}
+	contacts, rErr := findContacts(ctx, ur.db, "c.user_id = ?", id)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to get contacts for user with id: %s", id)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return aggregate.FullUser{}, rErr
+	}
+	addresses, rErr := findAddresses(ctx, ur.db, "a.user_id = ?", id)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to get addresses for user with id: %s", id)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return aggregate.FullUser{}, rErr
+	}
+	profileQuery := fmt.Sprintf("SELECT %s FROM %s p WHERE p.user_id = ?", profileColumns, PROFILE_TABLE)
+	profile, err := scanProfile(ur.db.QueryRowContext(ctx, profileQuery, id))
+	// a user does not need to have a profile.
+	if err != nil && err != sql.ErrNoRows {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return aggregate.FullUser{}, rErr
+	}
+	fullUser := aggregate.NewFullUserWithData(user, addresses, contacts, &profile)
+	span.AddEvent("full user retreived")
+	return fullUser, nil
+}
+
+func (ur userRepo) GetUserAndContactByConfirmedContact(ctx context.Context, contactType, contactPrincipal string) (models.User, models.Contact, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "GetUserAndContactByConfirmedContact", ur.GetType())
+	defer span.End()
+	contacts, rErr := findContacts(ctx, ur.db, "c.type = ? AND c.principal = ? AND c.confirmed_date IS NOT NULL", contactType, contactPrincipal)
+	if rErr != nil {
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.User{}, models.Contact{}, rErr
+	}
+	if len(contacts) == 0 {
+		fields := map[string]interface{}{
+			"contacts.type":      contactType,
+			"contacts.principal": contactPrincipal,
+		}
+		rErr := coreerrors.NewNoUserFoundError(fields, true)
+		evtString := fmt.Sprintf("no user found with contact %s of type %s", contactPrincipal, contactType)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.User{}, models.Contact{}, rErr
+	}
+	contact := contacts[0]
+	user, rErr := ur.GetUserByID(ctx, contact.UserID)
+	if rErr != nil {
+		evtString := fmt.Sprintf("failed to get user with id: %s", contact.UserID)
+		apptelemetry.SetSpanError(&span, rErr, evtString)
+		return models.User{}, models.Contact{}, rErr
+	}
+	span.AddEvent("user and contact retreived")
+	return user, contact, nil
+}
+
+func (ur userRepo) GetUserByPrimaryContact(ctx context.Context, contactType, contactPrincipal string) (models.User, errors.RichError) {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "GetUserByPrimaryContact", ur.GetType())
+	defer span.End()
+	query := fmt.Sprintf("SELECT %s FROM %s u INNER JOIN %s c ON c.user_id = u.id WHERE c.is_primary = ? AND c.type = ? AND c.principal = ?", userColumns, USER_TABLE, CONTACT_TABLE)
+	user, err := scanUser(ur.db.QueryRowContext(ctx, query, true, contactType, contactPrincipal))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{
+				"contacts.isPrimary": true,
+				"contacts.type":      contactType,
+				"contacts.principal": contactPrincipal,
+			}
+			rErr := coreerrors.NewNoUserFoundError(fields, true)
+			evtString := fmt.Sprintf("no user found with primary contact %s of type %s", contactPrincipal, contactType)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return models.User{}, rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return models.User{}, rErr
+	}
+	span.AddEvent("user retreived")
+	return user, nil
+}
+
+func (ur userRepo) AddUser(ctx context.Context, user *models.User, createdByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "AddUser", ur.GetType())
+	defer span.End()
+	id, rErr := newID()
+	if rErr != nil {
+		evtString := "failed to generate user id"
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	user.AuditData.CreatedByID = createdByID
+	user.AuditData.CreatedOnDate = time.Now().UTC()
+	query := fmt.Sprintf("INSERT INTO %s (id, password_hash, consecutive_failed_login_attempts, locked_out_until, last_login_date, totp_secret, totp_enabled, totp_last_used_time_step, created_by_id, created_on_date) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", USER_TABLE)
+	_, err := ur.db.ExecContext(ctx, query,
+		id,
+		user.PasswordHash,
+		user.ConsecutiveFailedLoginAttempts,
+		user.LockedOutUntil.SQLValue(),
+		user.LastLoginDate.SQLValue(),
+		user.TOTPSecret,
+		user.TOTPEnabled,
+		user.TOTPLastUsedTimeStep,
+		user.AuditData.CreatedByID,
+		user.AuditData.CreatedOnDate,
+	)
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	user.ID = id
+	span.AddEvent("user added")
+	return nil
+}
+
+func (ur userRepo) UpdateUser(ctx context.Context, user *models.User, modifiedByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "UpdateUser", ur.GetType())
+	defer span.End()
+	user.AuditData.ModifiedByID.Set(modifiedByID)
+	user.AuditData.ModifiedOnDate.Set(time.Now().UTC())
+	query := fmt.Sprintf("UPDATE %s SET password_hash = ?, consecutive_failed_login_attempts = ?, locked_out_until = ?, last_login_date = ?, totp_secret = ?, totp_enabled = ?, totp_last_used_time_step = MAX(totp_last_used_time_step, ?), modified_by_id = ?, modified_on_date = ? WHERE id = ?", USER_TABLE)
+	result, err := ur.db.ExecContext(ctx, query,
+		user.PasswordHash,
+		user.ConsecutiveFailedLoginAttempts,
+		user.LockedOutUntil.SQLValue(),
+		user.LastLoginDate.SQLValue(),
+		user.TOTPSecret,
+		user.TOTPEnabled,
+		user.TOTPLastUsedTimeStep,
+		user.AuditData.ModifiedByID.SQLValue(),
+		user.AuditData.ModifiedOnDate.SQLValue(),
+		user.ID,
+	)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fields := map[string]interface{}{
+				"id": user.ID,
+			}
+			rErr := coreerrors.NewNoUserFoundError(fields, true)
+			evtString := fmt.Sprintf("no user found with id: %s", user.ID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("user updated")
+	return nil
+}
+
+// UpdateTOTPLastUsedTimeStep only updates the user when the stored time step is earlier, and checks whether the user exists when nothing was updated to tell the two failures apart.
+func (ur userRepo) UpdateTOTPLastUsedTimeStep(ctx context.Context, userID string, timeStep int64, modifiedByID string) errors.RichError {
+	span := apptelemetry.CreateRepoFunctionSpan(ctx, ur.GetName(), "UpdateTOTPLastUsedTimeStep", ur.GetType())
+	defer span.End()
+	query := fmt.Sprintf("UPDATE %s SET totp_last_used_time_step = ?, modified_by_id = ?, modified_on_date = ? WHERE id = ? AND totp_last_used_time_step < ?", USER_TABLE)
+	result, err := ur.db.ExecContext(ctx, query, timeStep, modifiedByID, time.Now().UTC(), userID, timeStep)
+	if err == nil {
+		err = checkRowsAffected(result)
+	}
+	if err == sql.ErrNoRows {
+		found, rErr := userExists(ctx, ur.db, userID)
+		if rErr != nil {
+			evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+		if !found {
+			fields := map[string]interface{}{
+				"id": userID,
+			}
+			rErr := coreerrors.NewNoUserFoundError(fields, true)
+			evtString := fmt.Sprintf("no user found with id: %s", userID)
+			apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+			return rErr
+		}
+		rErr = coreerrors.NewTOTPCodeAlreadyUsedError(userID, timeStep, true)
+		evtString := fmt.Sprintf("totp time step %d already used by user: %s", timeStep, userID)
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	if err != nil {
+		rErr := coreerrors.NewRepoQueryFailedError(err, true)
+		evtString := fmt.Sprintf("repo query failed: %s", rErr.GetErrors()[0].Error())
+		apptelemetry.SetSpanOriginalError(&span, rErr, evtString)
+		return rErr
+	}
+	span.AddEvent("totp last used time step updated")
+	return nil
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID,
+		&user.PasswordHash,
+		&user.ConsecutiveFailedLoginAttempts,
+		&user.LockedOutUntil,
+		&user.LastLoginDate,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.TOTPLastUsedTimeStep,
+		&user.AuditData.CreatedByID,
+		&user.AuditData.CreatedOnDate,
+		&user.AuditData.ModifiedByID,
+		&user.AuditData.ModifiedOnDate,
+	)
+	return user, err
+}
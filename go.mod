@@ -3,9 +3,12 @@ module github.com/calvine/goauth
 go 1.16
 
 require (
+	github.com/alicebob/miniredis/v2 v2.16.0
 	github.com/calvine/richerror v0.0.4
 	github.com/go-chi/chi/v5 v5.0.4
+	github.com/go-redis/redis/v8 v8.11.4
 	github.com/google/uuid v1.3.0
+	github.com/mattn/go-sqlite3 v1.14.9
 	go.mongodb.org/mongo-driver v1.7.4
 	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.24.0
 	go.opentelemetry.io/otel v1.0.0
@@ -20,4 +23,5 @@ require (
 	go.uber.org/zap v1.19.1
 	golang.org/x/crypto v0.0.0-20210813211128-0a44fdfbc16e
 	golang.org/x/sys v0.0.0-20210910150752-751e447fb3d0 // indirect
+	rsc.io/qr v0.2.0
 )
@@ -0,0 +1,246 @@
+package http
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/calvine/goauth/core"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/utilities/ctxpropagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const consentApproved = "approve"
+
+func (s *server) handleAuthorizeGet() http.HandlerFunc {
+	const initiator = "authorize get handler"
+	var (
+		once            sync.Once
+		consentTemplate *template.Template
+		templateErr     error
+		templatePath    string = "http/templates/consent.tmpl"
+	)
+	type requestData struct {
+		CSRFToken            string
+		AppName              string
+		LogoURI              string
+		Scopes               []models.Scope
+		AuthorizationRequest models.AuthorizationRequest
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			templateFileData, err := s.templateFS.ReadFile(templatePath)
+			templateErr = err
+			if templateErr == nil {
+				consentTemplate, templateErr = template.New("consentPage").Parse(string(templateFileData))
+			}
+		})
+		if templateErr != nil {
+			http.Error(rw, templateErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		authorizationRequest := getAuthorizationRequest(r.URL.Query())
+		// errors with the client or redirect uri must not redirect back to the client per https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2.1
+		app, appScopes, redirectURI, err := s.oauthService.ValidateAuthorizationClient(ctx, logger, authorizationRequest.ClientID, authorizationRequest.RedirectURI, initiator)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		authentication, ok := s.getSessionAuthentication(ctx, logger, r)
+		if !ok {
+			loginURL := fmt.Sprintf("/auth/login?return_url=%s", url.QueryEscape(r.URL.RequestURI()))
+			http.Redirect(rw, r, loginURL, http.StatusFound)
+			return
+		}
+		requestedScopes, err := s.oauthService.ValidateAuthorizationRequest(ctx, logger, app, appScopes, authorizationRequest, initiator)
+		if err != nil {
+			span.RecordError(err)
+			s.redirectWithParams(rw, r, redirectURI, map[string]string{
+				"error":             getOAuthErrorCode(err),
+				"error_description": err.GetErrorMessage(),
+				"state":             authorizationRequest.State,
+			})
+			return
+		}
+		consentRequired := authorizationRequest.HasPrompt(core.OIDC_PROMPT_CONSENT)
+		if !consentRequired {
+			missingConsent, err := s.oauthService.GetMissingConsent(ctx, logger, authentication.UserID, app, requestedScopes, initiator)
+			if err != nil {
+				span.RecordError(err)
+				s.redirectWithParams(rw, r, redirectURI, map[string]string{
+					"error": oauthErrorServerError,
+					"state": authorizationRequest.State,
+				})
+				return
+			}
+			consentRequired = len(missingConsent) > 0
+		}
+		if !consentRequired {
+			s.redirectWithAuthorizationCode(rw, r, app, authentication, authorizationRequest, redirectURI, initiator)
+			return
+		}
+		// TODO: make CSRF token life span configurable
+		// the csrf token is tied to the user so a consent form rendered for one user cannot be submitted for another.
+		token, err := models.NewToken(authentication.UserID, models.TokenTypeCSRF, time.Minute*10)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = s.tokenService.PutToken(ctx, logger, token)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := requestData{
+			CSRFToken:            token.Value,
+			AppName:              app.Name,
+			LogoURI:              app.LogoURI,
+			Scopes:               requestedScopes,
+			AuthorizationRequest: authorizationRequest,
+		}
+		templateRenderError := consentTemplate.Execute(rw, data)
+		if templateRenderError != nil {
+			span.RecordError(templateRenderError)
+			err = coreerrors.NewTemplateRenderErrorError(templatePath, templateRenderError, true)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// handleAuthorizePost handles the submission of the consent page. The authorization request is validated again because the form values come from the browser.
+func (s *server) handleAuthorizePost() http.HandlerFunc {
+	const initiator = "authorize post handler"
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		if err := r.ParseForm(); err != nil {
+			span.RecordError(err)
+			http.Error(rw, "request body could not be parsed", http.StatusBadRequest)
+			return
+		}
+		authorizationRequest := getAuthorizationRequest(r.PostForm)
+		app, appScopes, redirectURI, err := s.oauthService.ValidateAuthorizationClient(ctx, logger, authorizationRequest.ClientID, authorizationRequest.RedirectURI, initiator)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		authentication, ok := s.getSessionAuthentication(ctx, logger, r)
+		if !ok {
+			// the session ended while the consent page was open, so the user logs in again and is sent back to the authorize endpoint.
+			query := url.Values{}
+			for key, values := range r.PostForm {
+				if key != "csrf_token" && key != "consent" {
+					query[key] = values
+				}
+			}
+			loginURL := fmt.Sprintf("/auth/login?return_url=%s", url.QueryEscape("/auth/authorize?"+query.Encode()))
+			http.Redirect(rw, r, loginURL, http.StatusFound)
+			return
+		}
+		csrfTokenValue := r.PostForm.Get("csrf_token")
+		csrfToken, err := s.tokenService.GetToken(ctx, logger, csrfTokenValue, models.TokenTypeCSRF)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		if csrfToken.TargetID != authentication.UserID {
+			http.Error(rw, "csrf token was not issued to the current user", http.StatusBadRequest)
+			return
+		}
+		err = s.tokenService.DeleteToken(ctx, logger, csrfTokenValue)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusInternalServerError)
+			return
+		}
+		requestedScopes, err := s.oauthService.ValidateAuthorizationRequest(ctx, logger, app, appScopes, authorizationRequest, initiator)
+		if err != nil {
+			span.RecordError(err)
+			s.redirectWithParams(rw, r, redirectURI, map[string]string{
+				"error":             getOAuthErrorCode(err),
+				"error_description": err.GetErrorMessage(),
+				"state":             authorizationRequest.State,
+			})
+			return
+		}
+		if r.PostForm.Get("consent") != consentApproved {
+			s.redirectWithParams(rw, r, redirectURI, map[string]string{
+				"error":             oauthErrorAccessDenied,
+				"error_description": "the user denied the request",
+				"state":             authorizationRequest.State,
+			})
+			return
+		}
+		err = s.oauthService.GrantConsent(ctx, logger, authentication.UserID, app, requestedScopes, initiator)
+		if err != nil {
+			span.RecordError(err)
+			s.redirectWithParams(rw, r, redirectURI, map[string]string{
+				"error": oauthErrorServerError,
+				"state": authorizationRequest.State,
+			})
+			return
+		}
+		s.redirectWithAuthorizationCode(rw, r, app, authentication, authorizationRequest, redirectURI, initiator)
+	}
+}
+
+// getAuthorizationRequest reads the parameters of an authorization request from the query string or a consent form submission.
+func getAuthorizationRequest(values url.Values) models.AuthorizationRequest {
+	return models.AuthorizationRequest{
+		ClientID:     values.Get("client_id"),
+		RedirectURI:  values.Get("redirect_uri"),
+		ResponseType: values.Get("response_type"),
+		Scope:        values.Get("scope"),
+		State:        values.Get("state"),
+		// PKCE parameters
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+		// OIDC parameters
+		Nonce:  values.Get("nonce"),
+		Prompt: values.Get("prompt"),
+	}
+}
+
+// redirectWithAuthorizationCode issues an authorization code for the authenticated user and redirects back to the client with it.
+func (s *server) redirectWithAuthorizationCode(rw http.ResponseWriter, r *http.Request, app models.App, authentication models.Authentication, authorizationRequest models.AuthorizationRequest, redirectURI, initiator string) {
+	ctx := r.Context()
+	logger := ctxpropagation.GetLoggerFromContext(ctx)
+	span := trace.SpanFromContext(ctx)
+	authorizationCode, err := s.oauthService.IssueAuthorizationCode(ctx, logger, app, authentication, authorizationRequest, initiator)
+	if err != nil {
+		span.RecordError(err)
+		s.redirectWithParams(rw, r, redirectURI, map[string]string{
+			"error": oauthErrorServerError,
+			"state": authorizationRequest.State,
+		})
+		return
+	}
+	s.redirectWithParams(rw, r, redirectURI, map[string]string{
+		"code":  authorizationCode.Value,
+		"state": authorizationRequest.State,
+	})
+}
+
+func (s *server) redirectWithParams(rw http.ResponseWriter, r *http.Request, redirectURI string, params map[string]string) {
+	redirectURL, err := buildRedirectURL(redirectURI, params)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(rw, r, redirectURL, http.StatusFound)
+}
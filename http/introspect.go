@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/calvine/goauth/core/utilities/ctxpropagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func (s *server) handleIntrospectPost() http.HandlerFunc {
+	const initiator = "introspect post handler"
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		if err := r.ParseForm(); err != nil {
+			span.RecordError(err)
+			writeJSONResponse(rw, http.StatusBadRequest, oauthErrorResponse{
+				Error:            oauthErrorInvalidRequest,
+				ErrorDescription: "request body could not be parsed",
+			})
+			return
+		}
+		clientID, clientSecret, usedBasicAuth, rErr := getClientCredentials(r)
+		if rErr != nil {
+			span.RecordError(rErr)
+			writeOAuthErrorResponse(rw, rErr, usedBasicAuth)
+			return
+		}
+		app, _, rErr := s.oauthService.AuthenticateClient(ctx, logger, clientID, clientSecret, initiator)
+		if rErr != nil {
+			span.RecordError(rErr)
+			writeOAuthErrorResponse(rw, rErr, usedBasicAuth)
+			return
+		}
+		introspectionResponse, rErr := s.oauthService.IntrospectToken(ctx, logger, app, r.PostForm.Get("token"), r.PostForm.Get("token_type_hint"), initiator)
+		if rErr != nil {
+			span.RecordError(rErr)
+			writeOAuthErrorResponse(rw, rErr, usedBasicAuth)
+			return
+		}
+		writeJSONResponse(rw, http.StatusOK, introspectionResponse)
+	}
+}
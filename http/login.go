@@ -15,6 +15,13 @@ import (
 
 const loginCookieName = "x-goauth-session"
 
+const (
+	// TODO: make mfa challenge life span configurable
+	mfaChallengeDuration = time.Minute * 5
+	// mfaChallengeMetaDataKeyReturnURL is the meta data key for where to send the user once they complete the mfa challenge.
+	mfaChallengeMetaDataKeyReturnURL = "return_url"
+)
+
 func (s *server) handleLoginGet() http.HandlerFunc {
 	var (
 		once          sync.Once
@@ -24,6 +31,7 @@ func (s *server) handleLoginGet() http.HandlerFunc {
 	)
 	type requestData struct {
 		CSRFToken string
+		ReturnURL string
 	}
 	return func(rw http.ResponseWriter, r *http.Request) {
 		once.Do(func() {
@@ -53,7 +61,7 @@ func (s *server) handleLoginGet() http.HandlerFunc {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		templateRenderError := loginTemplate.Execute(rw, requestData{token.Value})
+		templateRenderError := loginTemplate.Execute(rw, requestData{token.Value, r.URL.Query().Get("return_url")})
 		if templateRenderError != nil {
 			span.RecordError(err)
 			err = coreerrors.NewTemplateRenderErrorError(templatePath, templateRenderError, true)
@@ -63,25 +71,43 @@ func (s *server) handleLoginGet() http.HandlerFunc {
 	}
 }
 
+// handleLoginPost checks the users password. Users with TOTP enabled are shown the mfa page to enter a code before their session is started.
 func (s *server) handleLoginPost() http.HandlerFunc {
-	// var (
-	// 	once          sync.Once
-	// 	loginTemplate *template.Template
-	// 	templateErr   error
-	// 	templatePath  string = "http/templates/login.tmpl"
-	// )
+	var (
+		once         sync.Once
+		mfaTemplate  *template.Template
+		templateErr  error
+		templatePath string = "http/templates/mfa.tmpl"
+	)
 	type requestData struct {
 		CSRFToken string
 		Email     string
 		Password  string
+		ReturnURL string
+	}
+	type mfaData struct {
+		CSRFToken         string
+		MFAChallengeToken string
 	}
 	return func(rw http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			templateFileData, err := s.templateFS.ReadFile(templatePath)
+			templateErr = err
+			if templateErr == nil {
+				mfaTemplate, templateErr = template.New("mfaPage").Parse(string(templateFileData))
+			}
+		})
+		if templateErr != nil {
+			http.Error(rw, templateErr.Error(), http.StatusInternalServerError)
+			return
+		}
 		data := requestData{}
 		ctx := r.Context()
 		logger := ctxpropagation.GetLoggerFromContext(ctx)
 		data.CSRFToken = r.FormValue("csrf_token")
 		data.Email = r.FormValue("email")
 		data.Password = r.FormValue("password")
+		data.ReturnURL = r.FormValue("return_url")
 
 		_, err := s.tokenService.GetToken(ctx, logger, data.CSRFToken, models.TokenTypeCSRF)
 		if err != nil {
@@ -92,52 +118,123 @@ func (s *server) handleLoginPost() http.HandlerFunc {
 		if err != nil {
 			// uh of the token was not deleted! need to log this...
 		}
-		_, err = s.loginService.LoginWithPrimaryContact(ctx, s.logger, data.Email, core.CONTACT_TYPE_EMAIL, data.Password, "login post handler")
+		user, err := s.loginService.LoginWithPrimaryContact(ctx, s.logger, data.Email, core.CONTACT_TYPE_EMAIL, data.Password, "login post handler")
+		if err != nil && err.GetErrorCode() == coreerrors.ErrCodeMFARequired {
+			span := trace.SpanFromContext(ctx)
+			// the challenge token proves the password was confirmed, so the mfa page cannot be used to skip it.
+			challengeToken, err := models.NewToken(user.ID, models.TokenTypeMFAChallenge, mfaChallengeDuration)
+			if err != nil {
+				span.RecordError(err)
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if isSafeReturnURL(data.ReturnURL) {
+				challengeToken.AddMetaData(mfaChallengeMetaDataKeyReturnURL, data.ReturnURL)
+			}
+			err = s.tokenService.PutToken(ctx, logger, challengeToken)
+			if err != nil {
+				span.RecordError(err)
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// TODO: make CSRF token life span configurable
+			csrfToken, err := models.NewToken(user.ID, models.TokenTypeCSRF, time.Minute*10)
+			if err != nil {
+				span.RecordError(err)
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			err = s.tokenService.PutToken(ctx, logger, csrfToken)
+			if err != nil {
+				span.RecordError(err)
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			templateRenderError := mfaTemplate.Execute(rw, mfaData{csrfToken.Value, challengeToken.Value})
+			if templateRenderError != nil {
+				span.RecordError(templateRenderError)
+				err = coreerrors.NewTemplateRenderErrorError(templatePath, templateRenderError, true)
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			return
+		}
 		if err != nil {
 			http.Error(rw, err.GetErrorMessage(), http.StatusUnauthorized)
 			return
 		}
-		rw.Header().Add("test-header", "JWT")
+		err = s.startSession(ctx, logger, rw, user.ID, core.OIDC_ACR_PASSWORD)
+		if err != nil {
+			http.Error(rw, err.GetErrorMessage(), http.StatusInternalServerError)
+			return
+		}
+		if isSafeReturnURL(data.ReturnURL) {
+			http.Redirect(rw, r, data.ReturnURL, http.StatusFound)
+			return
+		}
 		http.Redirect(rw, r, "/static/hooray.html", http.StatusFound)
 	}
 }
 
-// func (s *server) handleAuthGet() http.HandlerFunc {
-// 	var (
-// 		once          sync.Once
-// 		loginTemplate *template.Template
-// 		templateErr   error
-// 		templatePath  string = "http/templates/login.tmpl"
-// 	)
-// 	type requestData struct {
-// 		clientID      string
-// 		codeChallenge string // PKCE
-// 		redirectURI   string
-// 		responseType  string
-// 		scope         string
-// 		state         string
-// 	}
-// 	return func(rw http.ResponseWriter, r *http.Request) {
-// 		once.Do(func() {
-// 			templateFileData, err := s.templateFS.ReadFile(templatePath)
-// 			templateErr = err
-// 			if templateErr == nil {
-// 				loginTemplate, templateErr = template.New("loginPage").Parse(string(templateFileData))
-// 			}
-// 		})
-// 		cookie, err := r.Cookie(loginCookieName)
-// 		if err == http.ErrNoCookie {
-// 			// handle not logged in
-// 		}
-
-// 		http.SetCookie(rw, &http.Cookie{
-// 			Name:     loginCookieName,
-// 			Value:    "session token here",
-// 			Expires:  time.Now().Add(time.Hour * 24 * 7),
-// 			SameSite: http.SameSiteLaxMode,
-// 			Secure:   true,
-// 			HttpOnly: true,
-// 		})
-
-// 	}
-// }
+// handleLoginMFAPost handles the submission of the mfa page and starts the users session when the code is valid.
+func (s *server) handleLoginMFAPost() http.HandlerFunc {
+	const initiator = "login mfa post handler"
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		if err := r.ParseForm(); err != nil {
+			span.RecordError(err)
+			http.Error(rw, "request body could not be parsed", http.StatusBadRequest)
+			return
+		}
+		challengeTokenValue := r.PostForm.Get("mfa_challenge_token")
+		challengeToken, err := s.tokenService.GetToken(ctx, logger, challengeTokenValue, models.TokenTypeMFAChallenge)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		csrfTokenValue := r.PostForm.Get("csrf_token")
+		csrfToken, err := s.tokenService.GetToken(ctx, logger, csrfTokenValue, models.TokenTypeCSRF)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		if csrfToken.TargetID != challengeToken.TargetID {
+			http.Error(rw, "csrf token was not issued for this login", http.StatusBadRequest)
+			return
+		}
+		err = s.tokenService.DeleteToken(ctx, logger, csrfTokenValue)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusInternalServerError)
+			return
+		}
+		// the challenge is consumed before the code is checked so each password login allows a single code to be tried.
+		_, err = s.tokenService.ConsumeToken(ctx, logger, challengeTokenValue, models.TokenTypeMFAChallenge)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		user, err := s.loginService.LoginWithTOTP(ctx, logger, challengeToken.TargetID, r.PostForm.Get("code"), initiator)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusUnauthorized)
+			return
+		}
+		err = s.startSession(ctx, logger, rw, user.ID, core.OIDC_ACR_MFA)
+		if err != nil {
+			http.Error(rw, err.GetErrorMessage(), http.StatusInternalServerError)
+			return
+		}
+		returnURL := challengeToken.MetaData[mfaChallengeMetaDataKeyReturnURL]
+		if isSafeReturnURL(returnURL) {
+			http.Redirect(rw, r, returnURL, http.StatusFound)
+			return
+		}
+		http.Redirect(rw, r, "/static/hooray.html", http.StatusFound)
+	}
+}
@@ -0,0 +1,205 @@
+package http
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/utilities/ctxpropagation"
+	"go.opentelemetry.io/otel/trace"
+	"rsc.io/qr"
+)
+
+const (
+	totpEnrollmentPath      = "/user/mfa/totp"
+	totpEnrollmentStartPath = "/user/mfa/totp/start"
+)
+
+// handleTOTPEnrollmentGet shows the logged in user the QR code for their pending TOTP enrollment, or a button to start one.
+// The secret is only generated by handleTOTPEnrollmentStartPost, so reloading this page or a cross site GET cannot replace it.
+func (s *server) handleTOTPEnrollmentGet() http.HandlerFunc {
+	const initiator = "totp enrollment get handler"
+	var (
+		once                   sync.Once
+		totpEnrollmentTemplate *template.Template
+		templateErr            error
+		templatePath           string = "http/templates/totpenrollment.tmpl"
+	)
+	type requestData struct {
+		CSRFToken string
+		// Pending is set when enrollment has been started, otherwise the page only offers to start it.
+		Pending bool
+		// QRCode is a data uri so the secret is never sent to a third party to render.
+		QRCode template.URL
+		Secret string
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			templateFileData, err := s.templateFS.ReadFile(templatePath)
+			templateErr = err
+			if templateErr == nil {
+				totpEnrollmentTemplate, templateErr = template.New("totpEnrollmentPage").Parse(string(templateFileData))
+			}
+		})
+		if templateErr != nil {
+			http.Error(rw, templateErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		authentication, ok := s.getSessionAuthentication(ctx, logger, r)
+		if !ok {
+			loginURL := fmt.Sprintf("/auth/login?return_url=%s", url.QueryEscape(totpEnrollmentPath))
+			http.Redirect(rw, r, loginURL, http.StatusFound)
+			return
+		}
+		var data requestData
+		provisioningURI, err := s.loginService.GetTOTPEnrollment(ctx, logger, authentication.UserID, initiator)
+		if err != nil && err.GetErrorCode() != coreerrors.ErrCodeTOTPNotEnrolled {
+			span.RecordError(err)
+			status := http.StatusInternalServerError
+			if err.GetErrorCode() == coreerrors.ErrCodeTOTPAlreadyEnabled {
+				status = http.StatusConflict
+			}
+			http.Error(rw, err.GetErrorMessage(), status)
+			return
+		}
+		if err == nil {
+			parsedURI, parseErr := url.Parse(provisioningURI)
+			if parseErr != nil {
+				span.RecordError(parseErr)
+				http.Error(rw, parseErr.Error(), http.StatusInternalServerError)
+				return
+			}
+			qrCode, qrErr := qr.Encode(provisioningURI, qr.M)
+			if qrErr != nil {
+				span.RecordError(qrErr)
+				http.Error(rw, qrErr.Error(), http.StatusInternalServerError)
+				return
+			}
+			data.Pending = true
+			data.QRCode = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(qrCode.PNG()))
+			data.Secret = parsedURI.Query().Get("secret")
+		}
+		// TODO: make CSRF token life span configurable
+		token, err := models.NewToken(authentication.UserID, models.TokenTypeCSRF, time.Minute*10)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = s.tokenService.PutToken(ctx, logger, token)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data.CSRFToken = token.Value
+		templateRenderError := totpEnrollmentTemplate.Execute(rw, data)
+		if templateRenderError != nil {
+			span.RecordError(templateRenderError)
+			err = coreerrors.NewTemplateRenderErrorError(templatePath, templateRenderError, true)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// handleTOTPEnrollmentStartPost generates a new TOTP secret for the logged in user and sends them back to the set up page to scan it.
+func (s *server) handleTOTPEnrollmentStartPost() http.HandlerFunc {
+	const initiator = "totp enrollment start post handler"
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		authentication, ok := s.getSessionAuthentication(ctx, logger, r)
+		if !ok {
+			http.Error(rw, "a login session is required to set up two-factor authentication", http.StatusUnauthorized)
+			return
+		}
+		if !s.consumeTOTPEnrollmentCSRFToken(rw, r, authentication.UserID) {
+			return
+		}
+		_, err := s.loginService.StartTOTPEnrollment(ctx, logger, authentication.UserID, initiator)
+		if err != nil {
+			span.RecordError(err)
+			status := http.StatusInternalServerError
+			if err.GetErrorCode() == coreerrors.ErrCodeTOTPAlreadyEnabled {
+				status = http.StatusConflict
+			}
+			http.Error(rw, err.GetErrorMessage(), status)
+			return
+		}
+		http.Redirect(rw, r, totpEnrollmentPath, http.StatusFound)
+	}
+}
+
+// handleTOTPEnrollmentPost turns on TOTP for the logged in user once they enter a valid code from their authenticator app.
+func (s *server) handleTOTPEnrollmentPost() http.HandlerFunc {
+	const initiator = "totp enrollment post handler"
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		authentication, ok := s.getSessionAuthentication(ctx, logger, r)
+		if !ok {
+			http.Error(rw, "a login session is required to set up two-factor authentication", http.StatusUnauthorized)
+			return
+		}
+		if !s.consumeTOTPEnrollmentCSRFToken(rw, r, authentication.UserID) {
+			return
+		}
+		err := s.loginService.ConfirmTOTPEnrollment(ctx, logger, authentication.UserID, r.PostForm.Get("code"), initiator)
+		if err != nil {
+			span.RecordError(err)
+			status := http.StatusInternalServerError
+			switch err.GetErrorCode() {
+			case coreerrors.ErrCodeInvalidTOTPCode, coreerrors.ErrCodeTOTPNotEnrolled:
+				status = http.StatusBadRequest
+			case coreerrors.ErrCodeTOTPAlreadyEnabled:
+				status = http.StatusConflict
+			}
+			http.Error(rw, err.GetErrorMessage(), status)
+			return
+		}
+		http.Redirect(rw, r, "/static/totpenabled.html", http.StatusFound)
+	}
+}
+
+// consumeTOTPEnrollmentCSRFToken parses the form and deletes the csrf token posted from the TOTP set up page once it is checked against the user.
+// It writes the error response and returns false when the token is not valid.
+func (s *server) consumeTOTPEnrollmentCSRFToken(rw http.ResponseWriter, r *http.Request, userID string) bool {
+	ctx := r.Context()
+	logger := ctxpropagation.GetLoggerFromContext(ctx)
+	span := trace.SpanFromContext(ctx)
+	if err := r.ParseForm(); err != nil {
+		span.RecordError(err)
+		http.Error(rw, "request body could not be parsed", http.StatusBadRequest)
+		return false
+	}
+	csrfTokenValue := r.PostForm.Get("csrf_token")
+	csrfToken, err := s.tokenService.GetToken(ctx, logger, csrfTokenValue, models.TokenTypeCSRF)
+	if err != nil {
+		span.RecordError(err)
+		http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+		return false
+	}
+	if csrfToken.TargetID != userID {
+		http.Error(rw, "csrf token was not issued for this user", http.StatusBadRequest)
+		return false
+	}
+	err = s.tokenService.DeleteToken(ctx, logger, csrfTokenValue)
+	if err != nil {
+		span.RecordError(err)
+		http.Error(rw, err.GetErrorMessage(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/calvine/goauth/core/models"
+)
+
+const (
+	corsMaxAge = time.Hour
+	// browser based clients send the access token in the authorization header and form encoded bodies to the oauth endpoints.
+	corsAllowedHeaders = "Authorization, Content-Type"
+)
+
+type corsContextKey int
+
+const corsOriginContextKey corsContextKey = iota + 1
+
+// corsOrigin is the origin of a cross origin request along with the response writer to allow it on.
+type corsOrigin struct {
+	origin string
+	rw     http.ResponseWriter
+}
+
+// CORS allows browser based clients to call an endpoint from the origins registered for their app.
+// The app is not known until the handler authenticates the client or verifies the access token, so the origin is only allowed once the handler calls AllowOriginForApp.
+// Preflight requests do not identify the app, so they are answered for any origin. The browser still cannot read the response unless the origin is allowed for the app.
+func CORS(allowedMethods ...string) func(next http.Handler) http.Handler {
+	methods := strings.Join(allowedMethods, ", ")
+	return func(next http.Handler) http.Handler {
+		fn := func(rw http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(rw, r)
+				return
+			}
+			rw.Header().Add("Vary", "Origin")
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				rw.Header().Set("Access-Control-Allow-Origin", origin)
+				rw.Header().Set("Access-Control-Allow-Methods", methods)
+				rw.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+				rw.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+				rw.WriteHeader(http.StatusNoContent)
+				return
+			}
+			ctx := context.WithValue(r.Context(), corsOriginContextKey, corsOrigin{origin: origin, rw: rw})
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// IsCORSRequest returns true when the request is a cross origin request that went through the CORS middleware, so handlers can skip looking up the app otherwise.
+func IsCORSRequest(ctx context.Context) bool {
+	_, ok := ctx.Value(corsOriginContextKey).(corsOrigin)
+	return ok
+}
+
+// AllowOriginForApp lets the browser read the response when the request came from an origin allowed for the app.
+// It must be called before the response is written, and does nothing for requests that did not go through the CORS middleware or are not cross origin.
+func AllowOriginForApp(ctx context.Context, app models.App) {
+	corsOrigin, ok := ctx.Value(corsOriginContextKey).(corsOrigin)
+	if !ok || !app.IsAllowedOrigin(corsOrigin.origin) {
+		return
+	}
+	corsOrigin.rw.Header().Set("Access-Control-Allow-Origin", corsOrigin.origin)
+}
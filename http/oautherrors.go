@@ -0,0 +1,165 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/richerror/errors"
+)
+
+// error codes defined in https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2.1 and https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+const (
+	oauthErrorInvalidRequest          = "invalid_request"
+	oauthErrorUnauthorizedClient      = "unauthorized_client"
+	oauthErrorAccessDenied            = "access_denied"
+	oauthErrorUnsupportedResponseType = "unsupported_response_type"
+	oauthErrorInvalidScope            = "invalid_scope"
+	oauthErrorServerError             = "server_error"
+	oauthErrorInvalidClient           = "invalid_client"
+	oauthErrorInvalidGrant            = "invalid_grant"
+	oauthErrorUnsupportedGrantType    = "unsupported_grant_type"
+)
+
+// error codes for requests authenticated with a bearer token defined in https://datatracker.ietf.org/doc/html/rfc6750#section-3.1
+const (
+	bearerErrorInvalidToken      = "invalid_token"
+	bearerErrorInsufficientScope = "insufficient_scope"
+)
+
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// getOAuthErrorCode maps an error to the error code returned to the client per the OAuth 2.0 spec.
+func getOAuthErrorCode(err errors.RichError) string {
+	switch err.GetErrorCode() {
+	case coreerrors.ErrCodeMissingRequiredParameter,
+		coreerrors.ErrCodeUnsupportedCodeChallengeMethod,
+		coreerrors.ErrCodeInvalidCodeChallenge,
+		coreerrors.ErrCodePKCERequired:
+		return oauthErrorInvalidRequest
+	case coreerrors.ErrCodeUnsupportedResponseType:
+		return oauthErrorUnsupportedResponseType
+	case coreerrors.ErrCodeInvalidScope:
+		return oauthErrorInvalidScope
+	case coreerrors.ErrCodeAppDisabled,
+		coreerrors.ErrCodeGrantTypeNotAllowed,
+		coreerrors.ErrCodeResponseTypeNotAllowed:
+		return oauthErrorUnauthorizedClient
+	case coreerrors.ErrCodeInvalidClientCredentials:
+		return oauthErrorInvalidClient
+	case coreerrors.ErrCodeInvalidToken,
+		coreerrors.ErrCodeExpiredToken,
+		coreerrors.ErrCodeWrongTokenType,
+		coreerrors.ErrCodeAuthorizationCodeClientMismatch,
+		coreerrors.ErrCodeAuthorizationCodeRedirectURIMismatch,
+		coreerrors.ErrCodeInvalidCodeVerifier,
+		coreerrors.ErrCodeRefreshTokenClientMismatch,
+		coreerrors.ErrCodeRefreshTokenReused,
+		coreerrors.ErrCodeTokenClientMismatch:
+		return oauthErrorInvalidGrant
+	case coreerrors.ErrCodeUnsupportedGrantType:
+		return oauthErrorUnsupportedGrantType
+	default:
+		return oauthErrorServerError
+	}
+}
+
+// buildRedirectURL adds the provided query parameters to the redirect uri, preserving any query parameters already present on it.
+func buildRedirectURL(redirectURI string, params map[string]string) (string, error) {
+	parsedURL, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", err
+	}
+	query := parsedURL.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	parsedURL.RawQuery = query.Encode()
+	return parsedURL.String(), nil
+}
+
+// writeJSONResponse writes the value as a json response body with the given status code.
+func writeJSONResponse(rw http.ResponseWriter, statusCode int, value interface{}) {
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	rw.WriteHeader(statusCode)
+	// the status has already been written so there is nothing useful to do with an encoding error here.
+	_ = json.NewEncoder(rw).Encode(value)
+}
+
+// writeOAuthErrorResponse writes an error response from the token endpoint as described in https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+func writeOAuthErrorResponse(rw http.ResponseWriter, err errors.RichError, usedBasicAuth bool) {
+	errorCode := getOAuthErrorCode(err)
+	statusCode := http.StatusBadRequest
+	switch errorCode {
+	case oauthErrorInvalidClient:
+		statusCode = http.StatusUnauthorized
+		if usedBasicAuth {
+			rw.Header().Set("WWW-Authenticate", `Basic realm="goauth"`)
+		}
+	case oauthErrorServerError:
+		statusCode = http.StatusInternalServerError
+	}
+	response := oauthErrorResponse{
+		Error: errorCode,
+	}
+	if statusCode != http.StatusInternalServerError {
+		response.ErrorDescription = err.GetErrorMessage()
+	}
+	writeJSONResponse(rw, statusCode, response)
+}
+
+// writeBearerErrorResponse writes an error response for a request authenticated with a bearer token as described in https://datatracker.ietf.org/doc/html/rfc6750#section-3
+// When no access token was provided the WWW-Authenticate header does not include an error code.
+func writeBearerErrorResponse(rw http.ResponseWriter, err errors.RichError) {
+	var errorCode string
+	statusCode := http.StatusUnauthorized
+	switch err.GetErrorCode() {
+	case coreerrors.ErrCodeMissingRequiredParameter:
+		// no access token was provided, so the client is only told how to authenticate per https://datatracker.ietf.org/doc/html/rfc6750#section-3.1
+	case coreerrors.ErrCodeMultipleAccessTokenMethods:
+		errorCode = oauthErrorInvalidRequest
+		statusCode = http.StatusBadRequest
+	case coreerrors.ErrCodeInsufficientScope:
+		errorCode = bearerErrorInsufficientScope
+		statusCode = http.StatusForbidden
+	case coreerrors.ErrCodeMalformedJWT,
+		coreerrors.ErrCodeUnsupportedJWTAlgorithm,
+		coreerrors.ErrCodeInvalidJWTSignature,
+		coreerrors.ErrCodeJWTAlgorithmMismatch,
+		coreerrors.ErrCodeNoSigningKeyFound,
+		coreerrors.ErrCodeJWTExpired,
+		coreerrors.ErrCodeJWTNotYetValid,
+		coreerrors.ErrCodeInvalidToken,
+		coreerrors.ErrCodeExpiredToken,
+		coreerrors.ErrCodeWrongTokenType,
+		coreerrors.ErrCodeNoUserFound:
+		errorCode = bearerErrorInvalidToken
+	default:
+		errorCode = oauthErrorServerError
+		statusCode = http.StatusInternalServerError
+	}
+	if statusCode == http.StatusInternalServerError {
+		writeJSONResponse(rw, statusCode, oauthErrorResponse{Error: errorCode})
+		return
+	}
+	authenticate := `Bearer realm="goauth"`
+	if errorCode != "" {
+		authenticate = fmt.Sprintf(`%s, error="%s", error_description="%s"`, authenticate, errorCode, err.GetErrorMessage())
+	}
+	rw.Header().Set("WWW-Authenticate", authenticate)
+	if errorCode == "" {
+		rw.WriteHeader(statusCode)
+		return
+	}
+	writeJSONResponse(rw, statusCode, oauthErrorResponse{
+		Error:            errorCode,
+		ErrorDescription: err.GetErrorMessage(),
+	})
+}
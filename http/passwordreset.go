@@ -1,11 +1,231 @@
 package http
 
-import "net/http"
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/calvine/goauth/core"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/utilities/ctxpropagation"
+	"github.com/calvine/richerror/errors"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func (s *server) handleForgotPasswordGet() http.HandlerFunc {
+	var (
+		once                   sync.Once
+		forgotPasswordTemplate *template.Template
+		templateErr            error
+		templatePath           string = "http/templates/forgotpassword.tmpl"
+	)
+	type requestData struct {
+		CSRFToken string
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			templateFileData, err := s.templateFS.ReadFile(templatePath)
+			templateErr = err
+			if templateErr == nil {
+				forgotPasswordTemplate, templateErr = template.New("forgotPasswordPage").Parse(string(templateFileData))
+			}
+		})
+		if templateErr != nil {
+			http.Error(rw, templateErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		// TODO: make CSRF token life span configurable
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		token, err := models.NewToken("", models.TokenTypeCSRF, time.Minute*10)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = s.tokenService.PutToken(ctx, logger, token)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		templateRenderError := forgotPasswordTemplate.Execute(rw, requestData{token.Value})
+		if templateRenderError != nil {
+			span.RecordError(templateRenderError)
+			err = coreerrors.NewTemplateRenderErrorError(templatePath, templateRenderError, true)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// handleForgotPasswordPost starts a password reset for the email submitted on the forgot password page.
+func (s *server) handleForgotPasswordPost() http.HandlerFunc {
+	const initiator = "forgot password post handler"
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		if err := r.ParseForm(); err != nil {
+			span.RecordError(err)
+			http.Error(rw, "request body could not be parsed", http.StatusBadRequest)
+			return
+		}
+		csrfTokenValue := r.PostForm.Get("csrf_token")
+		_, err := s.tokenService.GetToken(ctx, logger, csrfTokenValue, models.TokenTypeCSRF)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		err = s.tokenService.DeleteToken(ctx, logger, csrfTokenValue)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusInternalServerError)
+			return
+		}
+		email := r.PostForm.Get("email")
+		if email == "" {
+			http.Error(rw, "email is required", http.StatusBadRequest)
+			return
+		}
+		err = s.loginService.StartPasswordResetByPrimaryContact(ctx, logger, email, core.CONTACT_TYPE_EMAIL, initiator)
+		if err != nil {
+			// the user is sent to the same page either way so the form cannot be used to find out which emails have accounts.
+			span.RecordError(err)
+		}
+		http.Redirect(rw, r, "/static/passwordresetsent.html", http.StatusFound)
+	}
+}
 
 func (s *server) handlePasswordResetGet() http.HandlerFunc {
-	return func(rw http.ResponseWriter, r *http.Request) {}
+	var (
+		once                  sync.Once
+		passwordResetTemplate *template.Template
+		templateErr           error
+		templatePath          string = "http/templates/resetpassword.tmpl"
+	)
+	type requestData struct {
+		CSRFToken          string
+		PasswordResetToken string
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			templateFileData, err := s.templateFS.ReadFile(templatePath)
+			templateErr = err
+			if templateErr == nil {
+				passwordResetTemplate, templateErr = template.New("passwordResetPage").Parse(string(templateFileData))
+			}
+		})
+		if templateErr != nil {
+			http.Error(rw, templateErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		// the password reset token is checked up front so the user is not asked for a new password for a link that cannot work.
+		passwordResetToken, err := s.tokenService.GetToken(ctx, logger, chi.URLParam(r, "passwordResetToken"), models.TokenTypePasswordReset)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		// TODO: make CSRF token life span configurable
+		// the csrf token is tied to the user so the form cannot be submitted with a password reset token for another user.
+		token, err := models.NewToken(passwordResetToken.TargetID, models.TokenTypeCSRF, time.Minute*10)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = s.tokenService.PutToken(ctx, logger, token)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		templateRenderError := passwordResetTemplate.Execute(rw, requestData{token.Value, passwordResetToken.Value})
+		if templateRenderError != nil {
+			span.RecordError(templateRenderError)
+			err = coreerrors.NewTemplateRenderErrorError(templatePath, templateRenderError, true)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 }
 
+// handlePasswordResetPost handles the submission of the password reset page. A successful reset signs the user out everywhere, so they are sent to log in again.
 func (s *server) handlePasswordResetPost() http.HandlerFunc {
-	return func(rw http.ResponseWriter, r *http.Request) {}
+	const initiator = "password reset post handler"
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		if err := r.ParseForm(); err != nil {
+			span.RecordError(err)
+			http.Error(rw, "request body could not be parsed", http.StatusBadRequest)
+			return
+		}
+		password := r.PostForm.Get("password")
+		if password != r.PostForm.Get("confirm_password") {
+			http.Error(rw, "passwords do not match", http.StatusBadRequest)
+			return
+		}
+		passwordResetTokenValue := r.PostForm.Get("password_reset_token")
+		passwordResetToken, err := s.tokenService.GetToken(ctx, logger, passwordResetTokenValue, models.TokenTypePasswordReset)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		csrfTokenValue := r.PostForm.Get("csrf_token")
+		csrfToken, err := s.tokenService.GetToken(ctx, logger, csrfTokenValue, models.TokenTypeCSRF)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		if csrfToken.TargetID != passwordResetToken.TargetID {
+			http.Error(rw, "csrf token was not issued for this password reset link", http.StatusBadRequest)
+			return
+		}
+		err = s.tokenService.DeleteToken(ctx, logger, csrfTokenValue)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusInternalServerError)
+			return
+		}
+		err = s.loginService.ResetPassword(ctx, logger, passwordResetTokenValue, password, initiator)
+		if err != nil {
+			span.RecordError(err)
+			status := http.StatusBadRequest
+			if err.GetErrorCode() == coreerrors.ErrCodeRepoQueryFailed {
+				status = http.StatusInternalServerError
+			}
+			http.Error(rw, getPasswordErrorMessage(err), status)
+			return
+		}
+		http.Redirect(rw, r, "/auth/login", http.StatusFound)
+	}
+}
+
+// getPasswordErrorMessage adds the reasons a new password was rejected to the error message, so the user knows what to change.
+func getPasswordErrorMessage(err errors.RichError) string {
+	if err.GetErrorCode() != coreerrors.ErrCodePasswordPolicyViolation {
+		return err.GetErrorMessage()
+	}
+	reasons := make([]string, 0, len(err.GetMetaData()))
+	for _, reason := range err.GetMetaData() {
+		reasons = append(reasons, fmt.Sprint(reason))
+	}
+	sort.Strings(reasons)
+	return fmt.Sprintf("%s: %s", err.GetErrorMessage(), strings.Join(reasons, ", "))
 }
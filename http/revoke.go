@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/calvine/goauth/core/utilities/ctxpropagation"
+	mymiddleware "github.com/calvine/goauth/http/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func (s *server) handleRevokePost() http.HandlerFunc {
+	const initiator = "revoke post handler"
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		if err := r.ParseForm(); err != nil {
+			span.RecordError(err)
+			writeJSONResponse(rw, http.StatusBadRequest, oauthErrorResponse{
+				Error:            oauthErrorInvalidRequest,
+				ErrorDescription: "request body could not be parsed",
+			})
+			return
+		}
+		clientID, clientSecret, usedBasicAuth, rErr := getClientCredentials(r)
+		if rErr != nil {
+			span.RecordError(rErr)
+			writeOAuthErrorResponse(rw, rErr, usedBasicAuth)
+			return
+		}
+		app, _, rErr := s.oauthService.AuthenticateClient(ctx, logger, clientID, clientSecret, initiator)
+		if rErr != nil {
+			span.RecordError(rErr)
+			writeOAuthErrorResponse(rw, rErr, usedBasicAuth)
+			return
+		}
+		mymiddleware.AllowOriginForApp(ctx, app)
+		rErr = s.oauthService.RevokeToken(ctx, logger, app, r.PostForm.Get("token"), r.PostForm.Get("token_type_hint"), initiator)
+		if rErr != nil {
+			span.RecordError(rErr)
+			writeOAuthErrorResponse(rw, rErr, usedBasicAuth)
+			return
+		}
+		// per https://datatracker.ietf.org/doc/html/rfc7009#section-2.2 the content of the response body is ignored by the client.
+		rw.WriteHeader(http.StatusOK)
+	}
+}
@@ -3,8 +3,10 @@ package http
 import (
 	"embed"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/calvine/goauth/core/jwt"
 	"github.com/calvine/goauth/core/services"
 	mymiddleware "github.com/calvine/goauth/http/middleware"
 	"github.com/go-chi/chi/v5"
@@ -16,16 +18,49 @@ import (
 type server struct {
 	logger       *zap.Logger
 	loginService services.LoginService
+	userService  services.UserService
 	emailService services.EmailService
 	tokenService services.TokenService
+	appService   services.AppService
+	oauthService services.OAuthService
+	keyProvider  jwt.KeyProvider
+	issuer       string
 	staticFS     *http.FileSystem
 	templateFS   *embed.FS
 	Mux          *chi.Mux
 }
 
-func NewServer(logger *zap.Logger, loginService services.LoginService, emailService services.EmailService, tokenService services.TokenService, staticFS *http.FileSystem, templateFS *embed.FS) server {
+type ServerOptions struct {
+	Logger       *zap.Logger
+	LoginService services.LoginService
+	UserService  services.UserService
+	EmailService services.EmailService
+	TokenService services.TokenService
+	AppService   services.AppService
+	OAuthService services.OAuthService
+	KeyProvider  jwt.KeyProvider
+	// Issuer is the base url goauth is served from. It is used to build the urls in the discovery document.
+	Issuer     string
+	StaticFS   *http.FileSystem
+	TemplateFS *embed.FS
+}
+
+func NewServer(options ServerOptions) server {
 	mux := chi.NewRouter()
-	return server{logger, loginService, emailService, tokenService, staticFS, templateFS, mux}
+	return server{
+		logger:       options.Logger,
+		loginService: options.LoginService,
+		userService:  options.UserService,
+		emailService: options.EmailService,
+		tokenService: options.TokenService,
+		appService:   options.AppService,
+		oauthService: options.OAuthService,
+		keyProvider:  options.KeyProvider,
+		issuer:       strings.TrimSuffix(options.Issuer, "/"),
+		staticFS:     options.StaticFS,
+		templateFS:   options.TemplateFS,
+		Mux:          mux,
+	}
 }
 
 func (hh *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -41,24 +76,75 @@ func (hh *server) BuildRoutes() {
 	)
 	hh.Mux.Route("/auth", func(r chi.Router) {
 		r.Use(middleware.NoCache)
+		// this is the authorization endpoint for the oauth authorization code flow
+		r.Get("/authorize", otelhttp.NewHandler(hh.handleAuthorizeGet(), "GET /auth/authorize").ServeHTTP)
+		// this is the post target for the consent page
+		r.Post("/authorize", otelhttp.NewHandler(hh.handleAuthorizePost(), "POST /auth/authorize").ServeHTTP)
 		r.Route("/login", func(r chi.Router) {
 			// this is the route for the login page
 			r.Get("/", otelhttp.NewHandler(hh.handleLoginGet(), "GET /auth/login").ServeHTTP) //addTrace(hh.handleLoginGet(), "GET /auth/login"))
 			// this is the post target for the login page
 			r.Post("/", otelhttp.NewHandler(hh.handleLoginPost(), "POST /auth/login").ServeHTTP)
+			// this is the post target for the mfa page shown after the password is confirmed for users with two-factor authentication
+			r.Post("/mfa", otelhttp.NewHandler(hh.handleLoginMFAPost(), "POST /auth/login/mfa").ServeHTTP)
 		})
 		r.Route("/resetpassword", func(r chi.Router) {
+			// this is the route for the forgot password page
+			r.Get("/", otelhttp.NewHandler(hh.handleForgotPasswordGet(), "GET /auth/resetpassword").ServeHTTP)
+			// this is the post target for the forgot password page, it emails the user a password reset link
+			r.Post("/", otelhttp.NewHandler(hh.handleForgotPasswordPost(), "POST /auth/resetpassword").ServeHTTP)
 			// this is the route for the password reset page
 			r.Get("/{passwordResetToken}", otelhttp.NewHandler(hh.handlePasswordResetGet(), "GET /resetpassword/{passwordResetToken}").ServeHTTP)
 			// this is the post endpoint for the password reset page
 			r.Post("/submitpasswordreset", otelhttp.NewHandler(hh.handlePasswordResetPost(), "POST /resetpassword/submitpasswordreset").ServeHTTP)
 		})
 	})
+	hh.Mux.Route("/.well-known", func(r chi.Router) {
+		// this is the public keys used to verify jwts issued by goauth
+		r.Get("/jwks.json", otelhttp.NewHandler(hh.handleJWKSGet(), "GET /.well-known/jwks.json").ServeHTTP)
+		// this is the openid connect discovery document
+		r.Get("/openid-configuration", otelhttp.NewHandler(hh.handleOpenIDConfigurationGet(), "GET /.well-known/openid-configuration").ServeHTTP)
+	})
+	hh.Mux.Route("/oauth", func(r chi.Router) {
+		r.Use(middleware.NoCache)
+		// the CORS middleware answers preflight requests, so the options routes only handle requests that are not cross origin.
+		noContent := func(rw http.ResponseWriter, _ *http.Request) { rw.WriteHeader(http.StatusNoContent) }
+		// this is the token endpoint for the oauth grant types, browser based clients can call it from the origins allowed for their app
+		r.With(mymiddleware.CORS(http.MethodPost)).Route("/token", func(r chi.Router) {
+			r.Post("/", otelhttp.NewHandler(hh.handleTokenPost(), "POST /oauth/token").ServeHTTP)
+			r.Options("/", noContent)
+		})
+		// this is the token introspection endpoint for confidential clients
+		r.Post("/introspect", otelhttp.NewHandler(hh.handleIntrospectPost(), "POST /oauth/introspect").ServeHTTP)
+		// this is the token revocation endpoint, browser based clients can call it from the origins allowed for their app
+		r.With(mymiddleware.CORS(http.MethodPost)).Route("/revoke", func(r chi.Router) {
+			r.Post("/", otelhttp.NewHandler(hh.handleRevokePost(), "POST /oauth/revoke").ServeHTTP)
+			r.Options("/", noContent)
+		})
+		// this is the OIDC userinfo endpoint, browser based clients can call it from the origins allowed for their app
+		r.With(mymiddleware.CORS(http.MethodGet, http.MethodPost)).Route("/userinfo", func(r chi.Router) {
+			r.Get("/", otelhttp.NewHandler(hh.handleUserInfo(), "GET /oauth/userinfo").ServeHTTP)
+			r.Post("/", otelhttp.NewHandler(hh.handleUserInfo(), "POST /oauth/userinfo").ServeHTTP)
+			r.Options("/", noContent)
+		})
+	})
 	hh.Mux.Route("/user", func(r chi.Router) {
+		r.Use(middleware.NoCache)
+		// this is the route for the registration page
 		r.Get("/register", otelhttp.NewHandler(hh.handleRegisterGet(), "GET /user/register").ServeHTTP)
+		// this is the post target for the registration page
 		r.Post("/register", otelhttp.NewHandler(hh.handleRegisterPost(), "POST /user/register").ServeHTTP)
 
+		// this is the link sent to a newly registered user to confirm their contact and set their password
 		r.Get("/confirmcontact/{confirmationToken}", otelhttp.NewHandler(hh.handleConfirmContactGet(), "GET /user/confirmcontact/{confirmationToken}").ServeHTTP)
+		// this is the post target for the confirm contact page
+		r.Post("/confirmcontact", otelhttp.NewHandler(hh.handleConfirmContactPost(), "POST /user/confirmcontact").ServeHTTP)
+		// this is the route for the page the logged in user sets up TOTP two-factor authentication on
+		r.Get("/mfa/totp", otelhttp.NewHandler(hh.handleTOTPEnrollmentGet(), "GET /user/mfa/totp").ServeHTTP)
+		// this is the post target for the TOTP set up page
+		r.Post("/mfa/totp", otelhttp.NewHandler(hh.handleTOTPEnrollmentPost(), "POST /user/mfa/totp").ServeHTTP)
+		// this is the post target for starting TOTP set up, which generates the secret shown on the set up page
+		r.Post("/mfa/totp/start", otelhttp.NewHandler(hh.handleTOTPEnrollmentStartPost(), "POST /user/mfa/totp/start").ServeHTTP)
 	})
 	hh.Mux.Route("/app", func(r chi.Router) {
 		r.Get("/manage", otelhttp.NewHandler(nil, "GET /app/manage").ServeHTTP)
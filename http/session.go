@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/richerror/errors"
+	"go.uber.org/zap"
+)
+
+// TODO: make session life span configurable
+const sessionDuration = time.Hour * 24 * 7
+
+// startSession creates a new session token for the user and sets the session cookie on the response. The time the user authenticated and the acr they authenticated with are kept for id tokens.
+func (s *server) startSession(ctx context.Context, logger *zap.Logger, rw http.ResponseWriter, userID, acr string) errors.RichError {
+	sessionToken, err := models.NewToken(userID, models.TokenTypeSession, sessionDuration)
+	if err != nil {
+		return err
+	}
+	sessionToken.AddMetaData(models.TokenMetaDataKeyAuthTime, strconv.FormatInt(time.Now().Unix(), 10))
+	sessionToken.AddMetaData(models.TokenMetaDataKeyACR, acr)
+	err = s.tokenService.PutToken(ctx, logger, sessionToken)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(rw, &http.Cookie{
+		Name:     loginCookieName,
+		Value:    sessionToken.Value,
+		Path:     "/",
+		Expires:  sessionToken.Expiration,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   true,
+		HttpOnly: true,
+	})
+	return nil
+}
+
+// getSessionAuthentication returns how the user for the session cookie on the request authenticated. If there is no valid session false is returned.
+func (s *server) getSessionAuthentication(ctx context.Context, logger *zap.Logger, r *http.Request) (models.Authentication, bool) {
+	cookie, err := r.Cookie(loginCookieName)
+	if err != nil || cookie.Value == "" {
+		return models.Authentication{}, false
+	}
+	sessionToken, rErr := s.tokenService.GetToken(ctx, logger, cookie.Value, models.TokenTypeSession)
+	if rErr != nil {
+		return models.Authentication{}, false
+	}
+	authTime, err := strconv.ParseInt(sessionToken.MetaData[models.TokenMetaDataKeyAuthTime], 10, 64)
+	if err != nil {
+		// a session without an auth time cannot be used for id tokens, so the user has to log in again.
+		return models.Authentication{}, false
+	}
+	return models.Authentication{
+		UserID:   sessionToken.TargetID,
+		AuthTime: time.Unix(authTime, 0),
+		ACR:      sessionToken.MetaData[models.TokenMetaDataKeyACR],
+	}, true
+}
+
+// isSafeReturnURL ensures that a return url only points back to a path on this server so that it cannot be used as an open redirect.
+func isSafeReturnURL(returnURL string) bool {
+	return strings.HasPrefix(returnURL, "/") && !strings.HasPrefix(returnURL, "//") && !strings.HasPrefix(returnURL, "/\\")
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/calvine/goauth/core"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/utilities/ctxpropagation"
+	mymiddleware "github.com/calvine/goauth/http/middleware"
+	"github.com/calvine/richerror/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func (s *server) handleTokenPost() http.HandlerFunc {
+	const initiator = "token post handler"
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		if err := r.ParseForm(); err != nil {
+			span.RecordError(err)
+			writeJSONResponse(rw, http.StatusBadRequest, oauthErrorResponse{
+				Error:            oauthErrorInvalidRequest,
+				ErrorDescription: "request body could not be parsed",
+			})
+			return
+		}
+		clientID, clientSecret, usedBasicAuth, rErr := getClientCredentials(r)
+		if rErr != nil {
+			span.RecordError(rErr)
+			writeOAuthErrorResponse(rw, rErr, usedBasicAuth)
+			return
+		}
+		app, appScopes, rErr := s.oauthService.AuthenticateClient(ctx, logger, clientID, clientSecret, initiator)
+		if rErr != nil {
+			span.RecordError(rErr)
+			writeOAuthErrorResponse(rw, rErr, usedBasicAuth)
+			return
+		}
+		mymiddleware.AllowOriginForApp(ctx, app)
+		var accessTokenResponse models.AccessTokenResponse
+		grantType := r.PostForm.Get("grant_type")
+		switch grantType {
+		case core.OAUTH_GRANT_TYPE_AUTHORIZATION_CODE:
+			accessTokenResponse, rErr = s.oauthService.ExchangeAuthorizationCode(ctx, logger, app, r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"), initiator)
+		case core.OAUTH_GRANT_TYPE_REFRESH_TOKEN:
+			accessTokenResponse, rErr = s.oauthService.ExchangeRefreshToken(ctx, logger, app, r.PostForm.Get("refresh_token"), r.PostForm.Get("scope"), initiator)
+		case core.OAUTH_GRANT_TYPE_CLIENT_CREDENTIALS:
+			accessTokenResponse, rErr = s.oauthService.IssueClientCredentialsToken(ctx, logger, app, appScopes, r.PostForm.Get("scope"), initiator)
+		case "":
+			rErr = coreerrors.NewMissingRequiredParameterError("grant_type", true)
+		default:
+			rErr = coreerrors.NewUnsupportedGrantTypeError(grantType, true)
+		}
+		if rErr != nil {
+			span.RecordError(rErr)
+			writeOAuthErrorResponse(rw, rErr, usedBasicAuth)
+			return
+		}
+		writeJSONResponse(rw, http.StatusOK, accessTokenResponse)
+	}
+}
+
+// getClientCredentials gets the client id and secret from either the basic auth header (client_secret_basic) or the request body (client_secret_post).
+// Using both methods in a single request is not allowed per https://datatracker.ietf.org/doc/html/rfc6749#section-2.3
+func getClientCredentials(r *http.Request) (string, string, bool, errors.RichError) {
+	basicClientID, basicClientSecret, usedBasicAuth := r.BasicAuth()
+	if usedBasicAuth {
+		if r.PostForm.Get("client_secret") != "" {
+			return "", "", usedBasicAuth, coreerrors.NewInvalidClientCredentialsError(basicClientID, true)
+		}
+		// the client id and secret are form encoded before being added to the basic auth header per https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1
+		clientID, err := url.QueryUnescape(basicClientID)
+		if err != nil {
+			return "", "", usedBasicAuth, coreerrors.NewInvalidClientCredentialsError(basicClientID, true)
+		}
+		clientSecret, err := url.QueryUnescape(basicClientSecret)
+		if err != nil {
+			return "", "", usedBasicAuth, coreerrors.NewInvalidClientCredentialsError(clientID, true)
+		}
+		return clientID, clientSecret, usedBasicAuth, nil
+	}
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), usedBasicAuth, nil
+}
@@ -1,21 +1,218 @@
 package http
 
-import "net/http"
+import (
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/calvine/goauth/core"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/utilities/ctxpropagation"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
+)
 
 func (s *server) handleRegisterGet() http.HandlerFunc {
+	var (
+		once             sync.Once
+		registerTemplate *template.Template
+		templateErr      error
+		templatePath     string = "http/templates/register.tmpl"
+	)
+	type requestData struct {
+		CSRFToken string
+	}
 	return func(rw http.ResponseWriter, r *http.Request) {
-
+		once.Do(func() {
+			templateFileData, err := s.templateFS.ReadFile(templatePath)
+			templateErr = err
+			if templateErr == nil {
+				registerTemplate, templateErr = template.New("registerPage").Parse(string(templateFileData))
+			}
+		})
+		if templateErr != nil {
+			http.Error(rw, templateErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		// TODO: make CSRF token life span configurable
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		token, err := models.NewToken("", models.TokenTypeCSRF, time.Minute*10)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = s.tokenService.PutToken(ctx, logger, token)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		templateRenderError := registerTemplate.Execute(rw, requestData{token.Value})
+		if templateRenderError != nil {
+			span.RecordError(templateRenderError)
+			err = coreerrors.NewTemplateRenderErrorError(templatePath, templateRenderError, true)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 }
 
 func (s *server) handleRegisterPost() http.HandlerFunc {
+	const initiator = "register post handler"
 	return func(rw http.ResponseWriter, r *http.Request) {
-
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		if err := r.ParseForm(); err != nil {
+			span.RecordError(err)
+			http.Error(rw, "request body could not be parsed", http.StatusBadRequest)
+			return
+		}
+		csrfTokenValue := r.PostForm.Get("csrf_token")
+		_, err := s.tokenService.GetToken(ctx, logger, csrfTokenValue, models.TokenTypeCSRF)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		err = s.tokenService.DeleteToken(ctx, logger, csrfTokenValue)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusInternalServerError)
+			return
+		}
+		email := r.PostForm.Get("email")
+		if email == "" {
+			http.Error(rw, "email is required", http.StatusBadRequest)
+			return
+		}
+		err = s.userService.RegisterUserAndPrimaryContact(ctx, logger, core.CONTACT_TYPE_EMAIL, email, initiator)
+		if err != nil {
+			span.RecordError(err)
+			// the user is sent to the same page when the contact is already registered so the form cannot be used to find out which emails have accounts.
+			// the owner of the contact is notified by the user service instead.
+			if err.GetErrorCode() != coreerrors.ErrCodeRegistrationContactAlreadyConfirmed {
+				http.Error(rw, err.GetErrorMessage(), http.StatusInternalServerError)
+				return
+			}
+		}
+		http.Redirect(rw, r, "/static/registered.html", http.StatusFound)
 	}
 }
 
 func (s *server) handleConfirmContactGet() http.HandlerFunc {
+	var (
+		once                   sync.Once
+		confirmContactTemplate *template.Template
+		templateErr            error
+		templatePath           string = "http/templates/confirmcontact.tmpl"
+	)
+	type requestData struct {
+		CSRFToken         string
+		ConfirmationToken string
+	}
 	return func(rw http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			templateFileData, err := s.templateFS.ReadFile(templatePath)
+			templateErr = err
+			if templateErr == nil {
+				confirmContactTemplate, templateErr = template.New("confirmContactPage").Parse(string(templateFileData))
+			}
+		})
+		if templateErr != nil {
+			http.Error(rw, templateErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		// the confirmation token is checked up front so the user is not asked for a password for a link that cannot work.
+		confirmationToken, err := s.tokenService.GetToken(ctx, logger, chi.URLParam(r, "confirmationToken"), models.TokenTypeConfirmContact)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		// TODO: make CSRF token life span configurable
+		// the csrf token is tied to the contact so the form cannot be submitted with a different confirmation token.
+		token, err := models.NewToken(confirmationToken.TargetID, models.TokenTypeCSRF, time.Minute*10)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = s.tokenService.PutToken(ctx, logger, token)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		templateRenderError := confirmContactTemplate.Execute(rw, requestData{token.Value, confirmationToken.Value})
+		if templateRenderError != nil {
+			span.RecordError(templateRenderError)
+			err = coreerrors.NewTemplateRenderErrorError(templatePath, templateRenderError, true)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
 
+// handleConfirmContactPost handles the submission of the confirm contact page, which confirms the contact and sets the users initial password.
+func (s *server) handleConfirmContactPost() http.HandlerFunc {
+	const initiator = "confirm contact post handler"
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		if err := r.ParseForm(); err != nil {
+			span.RecordError(err)
+			http.Error(rw, "request body could not be parsed", http.StatusBadRequest)
+			return
+		}
+		password := r.PostForm.Get("password")
+		if password != r.PostForm.Get("confirm_password") {
+			http.Error(rw, "passwords do not match", http.StatusBadRequest)
+			return
+		}
+		confirmationTokenValue := r.PostForm.Get("confirmation_token")
+		confirmationToken, err := s.tokenService.GetToken(ctx, logger, confirmationTokenValue, models.TokenTypeConfirmContact)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		csrfTokenValue := r.PostForm.Get("csrf_token")
+		csrfToken, err := s.tokenService.GetToken(ctx, logger, csrfTokenValue, models.TokenTypeCSRF)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusBadRequest)
+			return
+		}
+		if csrfToken.TargetID != confirmationToken.TargetID {
+			http.Error(rw, "csrf token was not issued for this confirmation link", http.StatusBadRequest)
+			return
+		}
+		err = s.tokenService.DeleteToken(ctx, logger, csrfTokenValue)
+		if err != nil {
+			span.RecordError(err)
+			http.Error(rw, err.GetErrorMessage(), http.StatusInternalServerError)
+			return
+		}
+		err = s.userService.ConfirmContactAndSetPassword(ctx, logger, confirmationTokenValue, password, initiator)
+		if err != nil {
+			span.RecordError(err)
+			status := http.StatusBadRequest
+			if err.GetErrorCode() == coreerrors.ErrCodeRepoQueryFailed {
+				status = http.StatusInternalServerError
+			}
+			http.Error(rw, getPasswordErrorMessage(err), status)
+			return
+		}
+		http.Redirect(rw, r, "/auth/login", http.StatusFound)
 	}
 }
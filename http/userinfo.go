@@ -0,0 +1,70 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/utilities/ctxpropagation"
+	mymiddleware "github.com/calvine/goauth/http/middleware"
+	"github.com/calvine/richerror/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const bearerAuthorizationPrefix = "bearer "
+
+// handleUserInfo serves the userinfo endpoint which supports both GET and POST per https://openid.net/specs/openid-connect-core-1_0.html#UserInfoRequest
+func (s *server) handleUserInfo() http.HandlerFunc {
+	const initiator = "userinfo handler"
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := ctxpropagation.GetLoggerFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		accessToken, rErr := getBearerToken(r)
+		if rErr != nil {
+			span.RecordError(rErr)
+			writeBearerErrorResponse(rw, rErr)
+			return
+		}
+		userInfo, rErr := s.oauthService.GetUserInfo(ctx, logger, accessToken, initiator)
+		if rErr != nil {
+			span.RecordError(rErr)
+			writeBearerErrorResponse(rw, rErr)
+			return
+		}
+		if mymiddleware.IsCORSRequest(ctx) {
+			app, rErr := s.appService.GetAppByClientID(ctx, logger, userInfo.ClientID, initiator)
+			if rErr != nil {
+				// the user info is still returned, the browser just cannot read it.
+				span.RecordError(rErr)
+			} else {
+				mymiddleware.AllowOriginForApp(ctx, app)
+			}
+		}
+		writeJSONResponse(rw, http.StatusOK, userInfo)
+	}
+}
+
+// getBearerToken gets the access token from either the authorization header or the form encoded request body as described in https://datatracker.ietf.org/doc/html/rfc6750#section-2
+// Using more than one method in a single request is not allowed.
+func getBearerToken(r *http.Request) (string, errors.RichError) {
+	var bodyAccessToken string
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			return "", coreerrors.NewMissingRequiredParameterError("access_token", true)
+		}
+		bodyAccessToken = r.PostForm.Get("access_token")
+	}
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return bodyAccessToken, nil
+	}
+	if bodyAccessToken != "" {
+		return "", coreerrors.NewMultipleAccessTokenMethodsError(true)
+	}
+	// the authentication scheme is case insensitive per https://datatracker.ietf.org/doc/html/rfc7235#section-2.1
+	if len(authorization) < len(bearerAuthorizationPrefix) || !strings.EqualFold(authorization[:len(bearerAuthorizationPrefix)], bearerAuthorizationPrefix) {
+		return "", coreerrors.NewMissingRequiredParameterError("access_token", true)
+	}
+	return strings.TrimSpace(authorization[len(bearerAuthorizationPrefix):]), nil
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/calvine/goauth/core"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/utilities/ctxpropagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// TODO: make the jwks cache duration configurable. it should be much shorter than the time a key spends in the pending state.
+const jwksCacheControl = "public, max-age=300"
+
+const openIDConfigurationCacheControl = "public, max-age=3600"
+
+// these paths must match the routes registered in BuildRoutes
+const (
+	authorizeEndpointPath     = "/auth/authorize"
+	tokenEndpointPath         = "/oauth/token"
+	userInfoEndpointPath      = "/oauth/userinfo"
+	introspectionEndpointPath = "/oauth/introspect"
+	revocationEndpointPath    = "/oauth/revoke"
+	jwksPath                  = "/.well-known/jwks.json"
+)
+
+func (s *server) handleJWKSGet() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		keySet := s.keyProvider.GetJSONWebKeySet()
+		rw.Header().Set("Cache-Control", jwksCacheControl)
+		writeJSONResponse(rw, http.StatusOK, keySet)
+	}
+}
+
+func (s *server) handleOpenIDConfigurationGet() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		logger := ctxpropagation.GetLoggerFromContext(r.Context())
+		span := trace.SpanFromContext(r.Context())
+		signer, err := s.keyProvider.GetSigner()
+		if err != nil {
+			logger.Error("failed to get signer for discovery document", zap.Reflect("error", err))
+			span.RecordError(err)
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		discoveryDocument := s.buildDiscoveryDocument(signer.Algorithm())
+		rw.Header().Set("Cache-Control", openIDConfigurationCacheControl)
+		writeJSONResponse(rw, http.StatusOK, discoveryDocument)
+	}
+}
+
+func (s *server) buildDiscoveryDocument(signingAlgorithm string) models.DiscoveryDocument {
+	claimsSupported := []string{"iss", "aud", "exp", "iat", "nonce", "auth_time", "acr"}
+	for _, scope := range models.OIDCScopes {
+		claimsSupported = append(claimsSupported, models.OIDCScopeClaims[scope]...)
+	}
+	return models.DiscoveryDocument{
+		Issuer:                           s.issuer,
+		AuthorizationEndpoint:            s.issuer + authorizeEndpointPath,
+		TokenEndpoint:                    s.issuer + tokenEndpointPath,
+		UserInfoEndpoint:                 s.issuer + userInfoEndpointPath,
+		IntrospectionEndpoint:            s.issuer + introspectionEndpointPath,
+		RevocationEndpoint:               s.issuer + revocationEndpointPath,
+		JWKSURI:                          s.issuer + jwksPath,
+		ScopesSupported:                  models.OIDCScopes,
+		ResponseTypesSupported:           models.SupportedResponseTypes,
+		ResponseModesSupported:           []string{"query"},
+		GrantTypesSupported:              models.SupportedGrantTypes,
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{signingAlgorithm},
+		TokenEndpointAuthMethodsSupported: []string{
+			core.OAUTH_CLIENT_AUTH_METHOD_CLIENT_SECRET_BASIC,
+			core.OAUTH_CLIENT_AUTH_METHOD_CLIENT_SECRET_POST,
+		},
+		CodeChallengeMethodsSupported: []string{
+			core.PKCE_CODE_CHALLENGE_METHOD_S256,
+			core.PKCE_CODE_CHALLENGE_METHOD_PLAIN,
+		},
+		ACRValuesSupported: []string{core.OIDC_ACR_PASSWORD, core.OIDC_ACR_MFA},
+		ClaimsSupported:    claimsSupported,
+	}
+}
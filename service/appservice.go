@@ -2,12 +2,15 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/calvine/goauth/core/apptelemetry"
 	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/nullable"
 	repo "github.com/calvine/goauth/core/repositories"
 	"github.com/calvine/goauth/core/services"
 	"github.com/calvine/richerror/errors"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -99,6 +102,12 @@ func (as appService) AddApp(ctx context.Context, logger *zap.Logger, app *models
 		return err
 	}
 	span.AddEvent("app stored")
+	for _, clientSecret := range app.ClientSecrets {
+		err = as.logClientSecretAudit(ctx, logger, &span, models.AuditLogCode_ClientSecretCreated, "client secret created with app", *app, clientSecret, initiator)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -136,6 +145,75 @@ func (as appService) DeleteApp(ctx context.Context, logger *zap.Logger, app *mod
 	return nil
 }
 
+func (as appService) AddClientSecret(ctx context.Context, logger *zap.Logger, app *models.App, expirationDate nullable.NullableTime, initiator string) (string, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, as.GetName(), "AddClientSecret")
+	defer span.End()
+	clientSecret, plainTextClientSecret, err := models.NewClientSecret(expirationDate)
+	if err != nil {
+		evtString := "failed to create new client secret"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return "", err
+	}
+	err = as.appRepo.AddClientSecret(ctx, app.ID, clientSecret, initiator)
+	if err != nil {
+		logger.Error("appRepo.AddClientSecret call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return "", err
+	}
+	app.ClientSecrets = append(app.ClientSecrets, clientSecret)
+	span.AddEvent("client secret added")
+	err = as.logClientSecretAudit(ctx, logger, &span, models.AuditLogCode_ClientSecretCreated, "client secret created", *app, clientSecret, initiator)
+	if err != nil {
+		return "", err
+	}
+	return plainTextClientSecret, nil
+}
+
+func (as appService) SetClientSecretExpiration(ctx context.Context, logger *zap.Logger, app *models.App, clientSecretID string, expirationDate nullable.NullableTime, initiator string) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, as.GetName(), "SetClientSecretExpiration")
+	defer span.End()
+	err := as.appRepo.SetClientSecretExpiration(ctx, app.ID, clientSecretID, expirationDate, initiator)
+	if err != nil {
+		logger.Error("appRepo.SetClientSecretExpiration call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	clientSecret := models.ClientSecret{ID: clientSecretID, ExpirationDate: expirationDate}
+	// the app may be out of date, so it is only updated when it already has the client secret.
+	index := findClientSecretIndex(*app, clientSecretID)
+	if index != -1 {
+		app.ClientSecrets[index].ExpirationDate = expirationDate
+		clientSecret = app.ClientSecrets[index]
+	}
+	span.AddEvent("client secret expiration set")
+	return as.logClientSecretAudit(ctx, logger, &span, models.AuditLogCode_ClientSecretExpirationSet, "client secret expiration set", *app, clientSecret, initiator)
+}
+
+func (as appService) RevokeClientSecret(ctx context.Context, logger *zap.Logger, app *models.App, clientSecretID string, initiator string) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, as.GetName(), "RevokeClientSecret")
+	defer span.End()
+	// the repo checks that another client secret is still active when it removes this one, because an app without an active client secret could not authenticate until a new one is added.
+	err := as.appRepo.RevokeClientSecret(ctx, app.ID, clientSecretID, time.Now(), initiator)
+	if err != nil {
+		logger.Error("appRepo.RevokeClientSecret call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	revokedClientSecret := models.ClientSecret{ID: clientSecretID}
+	// the app may be out of date, so it is only updated when it still has the client secret.
+	index := findClientSecretIndex(*app, clientSecretID)
+	if index != -1 {
+		revokedClientSecret = app.ClientSecrets[index]
+		remainingClientSecrets := make([]models.ClientSecret, 0, len(app.ClientSecrets)-1)
+		remainingClientSecrets = append(remainingClientSecrets, app.ClientSecrets[:index]...)
+		remainingClientSecrets = append(remainingClientSecrets, app.ClientSecrets[index+1:]...)
+		app.ClientSecrets = remainingClientSecrets
+	}
+	span.AddEvent("client secret revoked")
+	return as.logClientSecretAudit(ctx, logger, &span, models.AuditLogCode_ClientSecretRevoked, "client secret revoked", *app, revokedClientSecret, initiator)
+}
+
 func (as appService) GetScopeByID(ctx context.Context, logger *zap.Logger, id string, initiator string) (models.Scope, errors.RichError) {
 	span := apptelemetry.CreateFunctionSpan(ctx, as.GetName(), "GetScopeByID")
 	defer span.End()
@@ -221,3 +299,34 @@ func (as appService) DeleteScope(ctx context.Context, logger *zap.Logger, scope
 	span.AddEvent("scope deleted")
 	return nil
 }
+
+// logClientSecretAudit writes an audit log message for a change to a client secret of an app.
+// The change has already been stored when this is called, so a failure to write the audit log is returned to let the caller know the change was not audited.
+func (as appService) logClientSecretAudit(ctx context.Context, logger *zap.Logger, span *trace.Span, code, message string, app models.App, clientSecret models.ClientSecret, initiator string) errors.RichError {
+	data := map[string]interface{}{
+		"clientId":       app.ClientID,
+		"clientSecretId": clientSecret.ID,
+		"initiator":      initiator,
+	}
+	if clientSecret.ExpirationDate.HasValue {
+		data["expirationDate"] = clientSecret.ExpirationDate.Value
+	}
+	auditLog := models.NewAuditLog(code, message, models.AssetType_Application, app.ID, data)
+	err := as.auditLogRepo.LogMessage(ctx, auditLog)
+	if err != nil {
+		logger.Error("auditLogRepo.LogMessage call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(span, err, "")
+		return err
+	}
+	return nil
+}
+
+// findClientSecretIndex returns the index of the client secret of the app with the given id, or -1 if the app does not have it.
+func findClientSecretIndex(app models.App, clientSecretID string) int {
+	for i, clientSecret := range app.ClientSecrets {
+		if clientSecret.ID == clientSecretID {
+			return i
+		}
+	}
+	return -1
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	coreerrors "github.com/calvine/goauth/core/errors"
 	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/nullable"
 	repo "github.com/calvine/goauth/core/repositories"
 	"github.com/calvine/goauth/core/services"
 	"github.com/calvine/goauth/core/testutilities"
@@ -74,6 +76,10 @@ func TestAppService(t *testing.T) {
 		_testUpdateApp(t, appService)
 	})
 
+	t.Run("ClientSecretRotation", func(t *testing.T) {
+		_testClientSecretRotation(t, appService)
+	})
+
 	t.Run("DeleteApp", func(t *testing.T) {
 		_testDeleteApp(t, appService)
 	})
@@ -106,7 +112,7 @@ func TestAppService(t *testing.T) {
 
 func setupAppServiceTestData(t *testing.T, appRepo repo.AppRepo) {
 	var err errors.RichError
-	testAppOne_One, testAppOne_OneClientSecret, err = models.NewApp(testAppOneOwnerID, "app name 1_1", "https://app11.com/callback", "https://app11.com/assets/logo.png")
+	testAppOne_One, testAppOne_OneClientSecret, err = models.NewApp(testAppOneOwnerID, "app name 1_1", []string{"https://app11.com/callback"}, "https://app11.com/assets/logo.png")
 	if err != nil {
 		t.Log(err.Error())
 		t.Errorf("failed to create test app one: %s", err.GetErrorCode())
@@ -127,7 +133,7 @@ func setupAppServiceTestData(t *testing.T, appRepo repo.AppRepo) {
 		testAppOne_OneScopes = append(testAppOne_OneScopes, scope)
 	}
 
-	testAppOne_Two, testAppOne_TwoClientSecret, err = models.NewApp(testAppOneOwnerID, "app name 1_2", "https://app12.com/callback", "https://app12.com/assets/logo.png")
+	testAppOne_Two, testAppOne_TwoClientSecret, err = models.NewApp(testAppOneOwnerID, "app name 1_2", []string{"https://app12.com/callback"}, "https://app12.com/assets/logo.png")
 	if err != nil {
 		t.Log(err.Error())
 		t.Errorf("failed to create test app one: %s", err.GetErrorCode())
@@ -148,7 +154,7 @@ func setupAppServiceTestData(t *testing.T, appRepo repo.AppRepo) {
 		testAppOne_TwoScopes = append(testAppOne_TwoScopes, scope)
 	}
 
-	testAppOne_Three, testAppOne_ThreeClientSecret, err = models.NewApp(testAppOneOwnerID, "app name 1_3", "https://app13.com/callback", "https://app13.com/assets/logo.png")
+	testAppOne_Three, testAppOne_ThreeClientSecret, err = models.NewApp(testAppOneOwnerID, "app name 1_3", []string{"https://app13.com/callback"}, "https://app13.com/assets/logo.png")
 	if err != nil {
 		t.Log(err.Error())
 		t.Errorf("failed to create test app one: %s", err.GetErrorCode())
@@ -169,7 +175,7 @@ func setupAppServiceTestData(t *testing.T, appRepo repo.AppRepo) {
 		testAppOne_ThreeScopes = append(testAppOne_ThreeScopes, scope)
 	}
 
-	testAppTwo, testAppTwoClientSecret, err = models.NewApp(testAppTwoOwnerID, "app name 2", "https://app2.com/callback", "https://app2.com/assets/logo.png")
+	testAppTwo, testAppTwoClientSecret, err = models.NewApp(testAppTwoOwnerID, "app name 2", []string{"https://app2.com/callback"}, "https://app2.com/assets/logo.png")
 	if err != nil {
 		t.Log(err.Error())
 		t.Errorf("failed to create test app one: %s", err.GetErrorCode())
@@ -408,7 +414,7 @@ func _testAddApp(t *testing.T, appService services.AppService) {
 				Name:          "success",
 			},
 			appToAdd: func(t *testing.T) models.App {
-				app, _, err := models.NewApp("validownerid", "test app", "https://app.com/callack", "https://logo.org/logo.png")
+				app, _, err := models.NewApp("validownerid", "test app", []string{"https://app.com/callack"}, "https://logo.org/logo.png")
 				if err != nil {
 					t.Log(err.Error())
 					t.Fatalf("failed to create app: %s", err.GetErrorCode())
@@ -423,7 +429,7 @@ func _testAddApp(t *testing.T, appService services.AppService) {
 				Name:              "failure no name",
 			},
 			appToAdd: func(t *testing.T) models.App {
-				app, _, err := models.NewApp("validownerid", "", "https://app.com/callack", "https://logo.org/logo.png")
+				app, _, err := models.NewApp("validownerid", "", []string{"https://app.com/callack"}, "https://logo.org/logo.png")
 				if err != nil {
 					t.Log(err.Error())
 					t.Fatalf("failed to create app: %s", err.GetErrorCode())
@@ -438,7 +444,7 @@ func _testAddApp(t *testing.T, appService services.AppService) {
 				Name:              "failure no owner id",
 			},
 			appToAdd: func(t *testing.T) models.App {
-				app, _, err := models.NewApp("", "test app", "https://app.com/callack", "https://logo.org/logo.png")
+				app, _, err := models.NewApp("", "test app", []string{"https://app.com/callack"}, "https://logo.org/logo.png")
 				if err != nil {
 					t.Log(err.Error())
 					t.Fatalf("failed to create app: %s", err.GetErrorCode())
@@ -450,10 +456,10 @@ func _testAddApp(t *testing.T, appService services.AppService) {
 			baseData: testutilities.BaseTestCase{
 				ExpectedError:     true,
 				ExpectedErrorCode: coreerrors.ErrCodeInvalidAppCreation,
-				Name:              "failure no callback uri",
+				Name:              "failure no redirect uris",
 			},
 			appToAdd: func(t *testing.T) models.App {
-				app, _, err := models.NewApp("validownerid", "test app", "", "https://logo.org/logo.png")
+				app, _, err := models.NewApp("validownerid", "test app", nil, "https://logo.org/logo.png")
 				if err != nil {
 					t.Log(err.Error())
 					t.Fatalf("failed to create app: %s", err.GetErrorCode())
@@ -468,7 +474,7 @@ func _testAddApp(t *testing.T, appService services.AppService) {
 				Name:              "failure no logo uri",
 			},
 			appToAdd: func(t *testing.T) models.App {
-				app, _, err := models.NewApp("validownerid", "test app", "https://app.com/callack", "")
+				app, _, err := models.NewApp("validownerid", "test app", []string{"https://app.com/callack"}, "")
 				if err != nil {
 					t.Log(err.Error())
 					t.Fatalf("failed to create app: %s", err.GetErrorCode())
@@ -483,7 +489,7 @@ func _testAddApp(t *testing.T, appService services.AppService) {
 				Name:              "failure no valid props",
 			},
 			appToAdd: func(t *testing.T) models.App {
-				app, _, err := models.NewApp("", "", "", "")
+				app, _, err := models.NewApp("", "", nil, "")
 				if err != nil {
 					t.Log(err.Error())
 					t.Fatalf("failed to create app: %s", err.GetErrorCode())
@@ -519,7 +525,8 @@ func _testUpdateApp(t *testing.T, appService services.AppService) {
 			updateApp: func(t *testing.T, app models.App) models.App {
 				app.OwnerID = "new owner id"
 				app.Name = "new app name"
-				app.CallbackURI = "https://new.app.com/callback"
+				app.RedirectURIs = []string{"https://new.app.com/callback", "http://127.0.0.1/callback", "com.app.new:/callback"}
+				app.AllowedOrigins = []string{"https://new.app.com", "http://localhost:3000"}
 				app.LogoURI = "https://new.app.com/assets/logo.png"
 				return app
 			},
@@ -550,10 +557,32 @@ func _testUpdateApp(t *testing.T, appService services.AppService) {
 			baseData: testutilities.BaseTestCase{
 				ExpectedError:     true,
 				ExpectedErrorCode: coreerrors.ErrCodeInvalidAppCreation,
-				Name:              "failure no callback uri",
+				Name:              "failure no redirect uris",
+			},
+			updateApp: func(t *testing.T, app models.App) models.App {
+				app.RedirectURIs = nil
+				return app
+			},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidAppCreation,
+				Name:              "failure invalid redirect uri",
+			},
+			updateApp: func(t *testing.T, app models.App) models.App {
+				app.RedirectURIs = []string{"https://new.app.com/callback", "http://new.app.com/callback"}
+				return app
+			},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidAppCreation,
+				Name:              "failure invalid allowed origin",
 			},
 			updateApp: func(t *testing.T, app models.App) models.App {
-				app.CallbackURI = ""
+				app.AllowedOrigins = []string{"https://new.app.com/path"}
 				return app
 			},
 		},
@@ -577,7 +606,7 @@ func _testUpdateApp(t *testing.T, appService services.AppService) {
 			updateApp: func(t *testing.T, app models.App) models.App {
 				app.OwnerID = ""
 				app.Name = ""
-				app.CallbackURI = ""
+				app.RedirectURIs = nil
 				app.LogoURI = ""
 				return app
 			},
@@ -596,6 +625,112 @@ func _testUpdateApp(t *testing.T, appService services.AppService) {
 	}
 }
 
+func _testClientSecretRotation(t *testing.T, appService services.AppService) {
+	logger := zaptest.NewLogger(t)
+	app, err := appService.GetAppByID(context.TODO(), logger, testAppTwo.ID, createdByAppService)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get app: %s", err.GetErrorCode())
+	}
+	oldClientSecretID := app.ClientSecrets[0].ID
+	newClientSecret, err := appService.AddClientSecret(context.TODO(), logger, &app, nullable.NullableTime{}, createdByAppService)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to add client secret: %s", err.GetErrorCode())
+	}
+	newClientSecretID := app.ClientSecrets[1].ID
+	app, err = appService.GetAppByID(context.TODO(), logger, testAppTwo.ID, createdByAppService)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get app: %s", err.GetErrorCode())
+	}
+	if len(app.ClientSecrets) != 2 {
+		t.Fatalf("app should have two client secrets: got %d", len(app.ClientSecrets))
+	}
+	now := time.Now()
+	if !app.MatchClientSecret(testAppTwoClientSecret, now) || !app.MatchClientSecret(newClientSecret, now) {
+		t.Error("both client secrets should be accepted while the client secret is being rotated")
+	}
+	expirationDate := nullable.NullableTime{}
+	expirationDate.Set(now.Add(-time.Minute))
+	err = appService.SetClientSecretExpiration(context.TODO(), logger, &app, oldClientSecretID, expirationDate, createdByAppService)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to set client secret expiration: %s", err.GetErrorCode())
+	}
+	if app.MatchClientSecret(testAppTwoClientSecret, now) {
+		t.Error("expired client secret should not be accepted")
+	}
+	testCases := []struct {
+		baseData       testutilities.BaseTestCase
+		clientSecretID string
+	}{
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeClientSecretNotFound,
+				Name:              "failure unknown client secret",
+			},
+			clientSecretID: "not a real client secret id",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeLastActiveClientSecret,
+				Name:              "failure last active client secret",
+			},
+			clientSecretID: newClientSecretID,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError: false,
+				Name:          "success",
+			},
+			clientSecretID: oldClientSecretID,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.baseData.Name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			err := appService.RevokeClientSecret(context.TODO(), logger, &app, tt.clientSecretID, createdByAppService)
+			testutilities.PerformErrorCheck(t, tt.baseData, err)
+			if err == nil {
+				if tt.baseData.ExpectedError {
+					t.Errorf("expected error %s but got none", tt.baseData.ExpectedErrorCode)
+				}
+				storedApp, err := appService.GetAppByID(context.TODO(), logger, app.ID, createdByAppService)
+				if err != nil {
+					t.Log(err.Error())
+					t.Fatalf("failed to get app: %s", err.GetErrorCode())
+				}
+				if len(storedApp.ClientSecrets) != 1 || storedApp.ClientSecrets[0].ID != newClientSecretID {
+					t.Errorf("only the new client secret should remain after the old one is revoked: %v", storedApp.ClientSecrets)
+				}
+			}
+		})
+	}
+	// a client secret added through an out of date copy of the app must not replace the client secrets added since the copy was made.
+	staleApp := app
+	_, err = appService.AddClientSecret(context.TODO(), logger, &app, nullable.NullableTime{}, createdByAppService)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to add client secret: %s", err.GetErrorCode())
+	}
+	_, err = appService.AddClientSecret(context.TODO(), logger, &staleApp, nullable.NullableTime{}, createdByAppService)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to add client secret: %s", err.GetErrorCode())
+	}
+	storedApp, err := appService.GetAppByID(context.TODO(), logger, app.ID, createdByAppService)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get app: %s", err.GetErrorCode())
+	}
+	if len(storedApp.ClientSecrets) != 3 {
+		t.Errorf("no client secret should be lost when they are added from different copies of the app: got %d", len(storedApp.ClientSecrets))
+	}
+}
+
 func _testDeleteApp(t *testing.T, appService services.AppService) {
 	testCases := []struct {
 		baseData testutilities.BaseTestCase
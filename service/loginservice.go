@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/calvine/goauth/core"
@@ -13,13 +14,16 @@ import (
 	coreservices "github.com/calvine/goauth/core/services"
 	"github.com/calvine/goauth/core/utilities"
 	"github.com/calvine/richerror/errors"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
 const (
 	defaultAccountLockoutDuration time.Duration = time.Minute * 15
 	defaultMaxFailedLoginAttempts int           = 10
+
+	// totpIssuer is the name authenticator apps show for goauth accounts.
+	totpIssuer = "goauth"
 )
 
 type loginService struct {
@@ -28,18 +32,30 @@ type loginService struct {
 	emailService           coreservices.EmailService
 	userRepo               repo.UserRepo
 	tokenService           coreservices.TokenService
+	passwordPolicy         coreservices.PasswordPolicy
+	passwordHashers        *utilities.PasswordHashers
+	encryptionKey          []byte
 	maxFailedLoginAttempts int
 	accountLockoutDuration time.Duration
+	issuer                 string
 }
 
 type LoginServiceOptions struct {
-	AuditLogRepo           repo.AuditLogRepo
-	ContactRepo            repo.ContactRepo
-	EmailService           coreservices.EmailService
-	UserRepo               repo.UserRepo
-	TokenService           coreservices.TokenService
+	AuditLogRepo repo.AuditLogRepo
+	ContactRepo  repo.ContactRepo
+	EmailService coreservices.EmailService
+	UserRepo     repo.UserRepo
+	TokenService coreservices.TokenService
+	// PasswordPolicy is checked for password resets and changes. The default policy is used when it is nil.
+	PasswordPolicy coreservices.PasswordPolicy
+	// PasswordHashers hashes new passwords and verifies existing ones. Password hashes that are outdated are replaced on login. The default hashers are used when it is nil.
+	PasswordHashers *utilities.PasswordHashers
+	// EncryptionKey is used to encrypt TOTP secrets stored on users. It must be utilities.EncryptionKeyLength bytes for TOTP enrollment to work.
+	EncryptionKey          []byte
 	MaxFailedLoginAttempts int
 	AccountLockoutDuration time.Duration
+	// Issuer is the base url goauth is served from. It is used to build the password reset link sent to users.
+	Issuer string
 }
 
 func NewLoginService(options LoginServiceOptions) coreservices.LoginService {
@@ -49,14 +65,24 @@ func NewLoginService(options LoginServiceOptions) coreservices.LoginService {
 	if options.AccountLockoutDuration <= 0 {
 		options.AccountLockoutDuration = defaultAccountLockoutDuration
 	}
+	if options.PasswordPolicy == nil {
+		options.PasswordPolicy = defaultPasswordPolicy()
+	}
+	if options.PasswordHashers == nil {
+		options.PasswordHashers = utilities.DefaultPasswordHashers()
+	}
 	return loginService{
 		auditLogRepo:           options.AuditLogRepo,
 		contactRepo:            options.ContactRepo,
 		emailService:           options.EmailService,
 		userRepo:               options.UserRepo,
 		tokenService:           options.TokenService,
+		passwordPolicy:         options.PasswordPolicy,
+		passwordHashers:        options.PasswordHashers,
+		encryptionKey:          options.EncryptionKey,
 		maxFailedLoginAttempts: options.MaxFailedLoginAttempts,
 		accountLockoutDuration: options.AccountLockoutDuration,
+		issuer:                 strings.TrimSuffix(options.Issuer, "/"),
 	}
 }
 
@@ -77,12 +103,9 @@ func (ls loginService) LoginWithPrimaryContact(ctx context.Context, logger *zap.
 	}
 	span.AddEvent("user and contact retreived from repo")
 	now := time.Now().UTC()
-	// is user locked out?
-	if user.LockedOutUntil.HasValue && now.Before(user.LockedOutUntil.Value) {
-		err := coreerrors.NewUserLockedOutError(user.ID, true)
-		logger.Error(err.GetErrorMessage(), zap.Reflect("error", err))
-		evtString := fmt.Sprintf("user is locked out until %s", user.LockedOutUntil.Value.UTC().String())
-		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+	err = checkUserLockedOut(logger, &span, user, now)
+	if err != nil {
+		// additional error stuff handeled in checkUserLockedOut function
 		return models.User{}, err
 	}
 	if !contact.IsPrimary {
@@ -102,8 +125,7 @@ func (ls loginService) LoginWithPrimaryContact(ctx context.Context, logger *zap.
 	}
 	span.AddEvent("contact validated")
 	// check password
-	// hash, bcryptErr := bcrypt.GenerateFromPassword([]byte(password), 10)
-	passwordMatch, err := utilities.BcryptCompareStringAndHash(user.PasswordHash, password, user.ID)
+	passwordMatch, needsRehash, err := ls.passwordHashers.Verify(user.PasswordHash, password, user.ID)
 	if err != nil {
 		evtString := "failed to check users password hash"
 		logger.Error(evtString, zap.Reflect("error", err))
@@ -111,22 +133,37 @@ func (ls loginService) LoginWithPrimaryContact(ctx context.Context, logger *zap.
 		return models.User{}, err
 	}
 	if !passwordMatch {
-		user.ConsecutiveFailedLoginAttempts += 1
-
-		if user.ConsecutiveFailedLoginAttempts >= ls.maxFailedLoginAttempts {
-			user.ConsecutiveFailedLoginAttempts = 0
-			user.LockedOutUntil.Set(now.Add(ls.accountLockoutDuration))
-		}
-		err = ls.userRepo.UpdateUser(ctx, &user, user.ID)
-		if err != nil {
-			logger.Error("update user after consecutive failed login increment failed", zap.Reflect("error", err))
-		}
+		ls.recordFailedLoginAttempt(ctx, logger, &user, now)
 		err = coreerrors.NewLoginFailedWrongPasswordError(user.ID, true)
 		evtString := err.GetErrorMessage()
 		logger.Warn(evtString, zap.Reflect("error", err))
 		apptelemetry.SetSpanOriginalError(&span, err, evtString)
 		return models.User{}, err
 	}
+	if needsRehash {
+		// the password is only available on login, so this is where hashes from an old algorithm or with old parameters are upgraded.
+		newPasswordHash, err := ls.passwordHashers.Hash(password)
+		if err != nil {
+			// the old hash still works, so failing to upgrade it should not stop the user from logging in.
+			logger.Warn("failed to rehash users password", zap.Reflect("error", err))
+		} else {
+			user.PasswordHash = newPasswordHash
+			span.AddEvent("outdated password hash replaced")
+		}
+	}
+	if user.TOTPEnabled {
+		// the login is not recorded and failed login attempts are not reset until the totp code is checked, so the lockout also limits guessing codes.
+		if needsRehash {
+			err = ls.userRepo.UpdateUser(ctx, &user, user.ID)
+			if err != nil {
+				logger.Warn("failed to store rehashed password", zap.Reflect("error", err))
+			}
+		}
+		err = coreerrors.NewMFARequiredError(user.ID, true)
+		logger.Info(err.GetErrorMessage(), zap.String("userId", user.ID))
+		span.AddEvent("password confirmed totp code required")
+		return user, err
+	}
 	user.LastLoginDate.Set(now)
 	user.ConsecutiveFailedLoginAttempts = 0
 	user.LockedOutUntil.Unset()
@@ -141,15 +178,262 @@ func (ls loginService) LoginWithPrimaryContact(ctx context.Context, logger *zap.
 	return user, nil
 }
 
-// TODO: remove string from return and make work like rgistration call. test with stackemailservice
-func (ls loginService) StartPasswordResetByPrimaryContact(ctx context.Context, logger *zap.Logger, principal, principalType string, initiator string) (string, errors.RichError) {
+func (ls loginService) LoginWithTOTP(ctx context.Context, logger *zap.Logger, userID string, code string, initiator string) (models.User, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, ls.GetName(), "LoginWithTOTP")
+	defer span.End()
+	user, err := ls.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		logger.Error("userRepo.GetUserByID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return models.User{}, err
+	}
+	span.AddEvent("user retreived from repo")
+	now := time.Now().UTC()
+	err = checkUserLockedOut(logger, &span, user, now)
+	if err != nil {
+		// additional error stuff handeled in checkUserLockedOut function
+		return models.User{}, err
+	}
+	if !user.TOTPEnabled {
+		err := coreerrors.NewTOTPNotEnrolledError(user.ID, true)
+		evtString := "user does not have totp enabled"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.User{}, err
+	}
+	err = ls.validateTOTPCode(ctx, logger, &span, &user, code, now, initiator)
+	if err != nil {
+		if err.GetErrorCode() == coreerrors.ErrCodeInvalidTOTPCode {
+			ls.recordFailedLoginAttempt(ctx, logger, &user, now)
+		}
+		// additional error stuff handeled in validateTOTPCode function
+		return models.User{}, err
+	}
+	span.AddEvent("totp code confirmed")
+	user.LastLoginDate.Set(now)
+	user.ConsecutiveFailedLoginAttempts = 0
+	user.LockedOutUntil.Unset()
+	err = ls.userRepo.UpdateUser(ctx, &user, user.ID)
+	if err != nil {
+		evtString := "update user after successful login"
+		apptelemetry.SetSpanError(&span, err, evtString)
+		logger.Error(evtString, zap.Reflect("error", err))
+		return models.User{}, err
+	}
+	span.AddEvent("login completed")
+	return user, nil
+}
+
+func (ls loginService) StartTOTPEnrollment(ctx context.Context, logger *zap.Logger, userID string, initiator string) (string, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, ls.GetName(), "StartTOTPEnrollment")
+	defer span.End()
+	user, err := ls.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		logger.Error("userRepo.GetUserByID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return "", err
+	}
+	span.AddEvent("user retreived from repo")
+	if user.TOTPEnabled {
+		err := coreerrors.NewTOTPAlreadyEnabledError(user.ID, true)
+		evtString := "user already has totp enabled"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return "", err
+	}
+	// the primary contact is the account name authenticator apps show, so users can tell their accounts apart.
+	contact, err := ls.contactRepo.GetPrimaryContactByUserID(ctx, user.ID, core.CONTACT_TYPE_EMAIL)
+	if err != nil {
+		logger.Error("contactRepo.GetPrimaryContactByUserID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return "", err
+	}
+	span.AddEvent("primary contact retreived from repo")
+	secret, err := utilities.NewTOTPSecret()
+	if err != nil {
+		evtString := "failed to generate totp secret"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return "", err
+	}
+	// the user id is used as associated data so the encrypted secret cannot be copied to another user.
+	encryptedSecret, err := utilities.EncryptWithAssociatedData(ls.encryptionKey, secret, user.ID)
+	if err != nil {
+		evtString := "failed to encrypt totp secret"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return "", err
+	}
+	span.AddEvent("totp secret generated")
+	// starting enrollment again replaces the secret, so only the most recent QR code shown to the user works.
+	user.TOTPSecret = encryptedSecret
+	err = ls.userRepo.UpdateUser(ctx, &user, initiator)
+	if err != nil {
+		logger.Error("userRepo.UpdateUser call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return "", err
+	}
+	span.AddEvent("totp enrollment started")
+	return utilities.GetTOTPProvisioningURI(secret, totpIssuer, contact.Principal), nil
+}
+
+func (ls loginService) GetTOTPEnrollment(ctx context.Context, logger *zap.Logger, userID string, initiator string) (string, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, ls.GetName(), "GetTOTPEnrollment")
+	defer span.End()
+	user, err := ls.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		logger.Error("userRepo.GetUserByID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return "", err
+	}
+	span.AddEvent("user retreived from repo")
+	if user.TOTPEnabled {
+		err := coreerrors.NewTOTPAlreadyEnabledError(user.ID, true)
+		evtString := "user already has totp enabled"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return "", err
+	}
+	if user.TOTPSecret == "" {
+		err := coreerrors.NewTOTPNotEnrolledError(user.ID, true)
+		evtString := "user has not started totp enrollment"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return "", err
+	}
+	contact, err := ls.contactRepo.GetPrimaryContactByUserID(ctx, user.ID, core.CONTACT_TYPE_EMAIL)
+	if err != nil {
+		logger.Error("contactRepo.GetPrimaryContactByUserID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return "", err
+	}
+	span.AddEvent("primary contact retreived from repo")
+	secret, err := utilities.DecryptWithAssociatedData(ls.encryptionKey, user.TOTPSecret, user.ID)
+	if err != nil {
+		evtString := "failed to decrypt totp secret"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return "", err
+	}
+	span.AddEvent("pending totp enrollment retreived")
+	return utilities.GetTOTPProvisioningURI(secret, totpIssuer, contact.Principal), nil
+}
+
+func (ls loginService) ConfirmTOTPEnrollment(ctx context.Context, logger *zap.Logger, userID string, code string, initiator string) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, ls.GetName(), "ConfirmTOTPEnrollment")
+	defer span.End()
+	user, err := ls.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		logger.Error("userRepo.GetUserByID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	span.AddEvent("user retreived from repo")
+	if user.TOTPEnabled {
+		err := coreerrors.NewTOTPAlreadyEnabledError(user.ID, true)
+		evtString := "user already has totp enabled"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	if user.TOTPSecret == "" {
+		err := coreerrors.NewTOTPNotEnrolledError(user.ID, true)
+		evtString := "user has not started totp enrollment"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	err = ls.validateTOTPCode(ctx, logger, &span, &user, code, time.Now().UTC(), initiator)
+	if err != nil {
+		// additional error stuff handeled in validateTOTPCode function
+		return err
+	}
+	span.AddEvent("totp code confirmed")
+	user.TOTPEnabled = true
+	err = ls.userRepo.UpdateUser(ctx, &user, initiator)
+	if err != nil {
+		logger.Error("userRepo.UpdateUser call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	span.AddEvent("totp enabled")
+	return nil
+}
+
+func checkUserLockedOut(logger *zap.Logger, span *trace.Span, user models.User, now time.Time) errors.RichError {
+	if user.LockedOutUntil.HasValue && now.Before(user.LockedOutUntil.Value) {
+		err := coreerrors.NewUserLockedOutError(user.ID, true)
+		logger.Error(err.GetErrorMessage(), zap.Reflect("error", err))
+		evtString := fmt.Sprintf("user is locked out until %s", user.LockedOutUntil.Value.UTC().String())
+		apptelemetry.SetSpanOriginalError(span, err, evtString)
+		return err
+	}
+	return nil
+}
+
+// recordFailedLoginAttempt increments the users failed login attempts, and locks the user out when there have been too many.
+func (ls loginService) recordFailedLoginAttempt(ctx context.Context, logger *zap.Logger, user *models.User, now time.Time) {
+	user.ConsecutiveFailedLoginAttempts += 1
+
+	if user.ConsecutiveFailedLoginAttempts >= ls.maxFailedLoginAttempts {
+		user.ConsecutiveFailedLoginAttempts = 0
+		user.LockedOutUntil.Set(now.Add(ls.accountLockoutDuration))
+	}
+	err := ls.userRepo.UpdateUser(ctx, user, user.ID)
+	if err != nil {
+		logger.Error("update user after consecutive failed login increment failed", zap.Reflect("error", err))
+	}
+}
+
+// validateTOTPCode checks a code against the users TOTP secret. When it matches the time step of the code is recorded so it cannot be used again.
+// The time step is recorded with a conditional update, so when the same code is submitted concurrently only one submission is accepted and the rest are treated as invalid codes.
+func (ls loginService) validateTOTPCode(ctx context.Context, logger *zap.Logger, span *trace.Span, user *models.User, code string, now time.Time, initiator string) errors.RichError {
+	secret, err := utilities.DecryptWithAssociatedData(ls.encryptionKey, user.TOTPSecret, user.ID)
+	if err != nil {
+		evtString := "failed to decrypt totp secret"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(span, err, evtString)
+		return err
+	}
+	timeStep, valid, err := utilities.ValidateTOTPCode(secret, code, now, user.TOTPLastUsedTimeStep)
+	if err != nil {
+		evtString := "failed to validate totp code"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(span, err, evtString)
+		return err
+	}
+	if !valid {
+		err := coreerrors.NewInvalidTOTPCodeError(user.ID, true)
+		evtString := err.GetErrorMessage()
+		logger.Warn(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(span, err, evtString)
+		return err
+	}
+	err = ls.userRepo.UpdateTOTPLastUsedTimeStep(ctx, user.ID, timeStep, initiator)
+	if err != nil {
+		if err.GetErrorCode() == coreerrors.ErrCodeTOTPCodeAlreadyUsed {
+			err := coreerrors.NewInvalidTOTPCodeError(user.ID, true)
+			evtString := "totp code was used by a concurrent request"
+			logger.Warn(evtString, zap.Reflect("error", err))
+			apptelemetry.SetSpanOriginalError(span, err, evtString)
+			return err
+		}
+		logger.Error("userRepo.UpdateTOTPLastUsedTimeStep call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(span, err, "")
+		return err
+	}
+	user.TOTPLastUsedTimeStep = timeStep
+	return nil
+}
+
+func (ls loginService) StartPasswordResetByPrimaryContact(ctx context.Context, logger *zap.Logger, principal, principalType string, initiator string) errors.RichError {
 	span := apptelemetry.CreateFunctionSpan(ctx, ls.GetName(), "StartPasswordResetByPrimaryContact")
 	defer span.End()
 	user, contact, err := ls.userRepo.GetUserAndContactByConfirmedContact(ctx, principalType, principal)
 	if err != nil {
 		logger.Error("userRepo.GetUserAndContactByContact call failed", zap.Reflect("error", err))
 		apptelemetry.SetSpanError(&span, err, "")
-		return "", err
+		return err
 	}
 	span.AddEvent("user and contact retreived from repo")
 	if !contact.IsPrimary {
@@ -157,7 +441,7 @@ func (ls loginService) StartPasswordResetByPrimaryContact(ctx context.Context, l
 		err := coreerrors.NewPasswordResetContactNotPrimaryError(contact.ID, contact.Principal, contact.Type, true)
 		logger.Error(evtString, zap.Reflect("error", err))
 		apptelemetry.SetSpanOriginalError(&span, err, evtString)
-		return "", err
+		return err
 	}
 	// TODO: make password reset token expiration configurable.
 	token, err := models.NewToken(user.ID, models.TokenTypePasswordReset, time.Minute*15)
@@ -165,7 +449,7 @@ func (ls loginService) StartPasswordResetByPrimaryContact(ctx context.Context, l
 		evtString := "failed to create new password reset token"
 		logger.Error(evtString, zap.Reflect("error", err))
 		apptelemetry.SetSpanOriginalError(&span, err, evtString)
-		return "", err
+		return err
 	}
 	span.AddEvent("new password reset token created")
 	err = ls.tokenService.PutToken(ctx, logger, token)
@@ -173,29 +457,30 @@ func (ls loginService) StartPasswordResetByPrimaryContact(ctx context.Context, l
 		evtString := "failed to store new password reset token"
 		logger.Error(evtString, zap.Reflect("error", err))
 		apptelemetry.SetSpanError(&span, err, evtString)
-		return "", err
+		return err
 	}
 	span.AddEvent("new password reset token stored in repo")
 	switch contact.Type {
 	case core.CONTACT_TYPE_EMAIL:
 		// TODO: create template for this...
-		body := fmt.Sprintf("A Password reset has been initiated. Your password reset token is: %s", token.Value)
+		passwordResetLink := fmt.Sprintf("%s/auth/resetpassword/%s", ls.issuer, token.Value)
+		body := fmt.Sprintf("A password reset has been initiated. Follow this link to choose a new password: %s", passwordResetLink)
 		err = ls.emailService.SendPlainTextEmail(ctx, logger, []string{contact.Principal}, "Password reset", body)
 		if err != nil {
 			evtString := "failed to send password reset notification error occurred"
 			logger.Error(evtString, zap.Reflect("error", err))
 			apptelemetry.SetSpanError(&span, err, evtString)
-			return token.Value, err // TODO: what should we do here???
+			return err // TODO: what should we do here???
 		}
 	default:
 		err := coreerrors.NewComponentNotImplementedError("notification system", fmt.Sprintf("%s notification service", contact.Type), true)
 		evtString := fmt.Sprintf("failed to send notification contact type not supported: %s", contact.Type)
 		logger.Error(evtString, zap.Reflect("error", err))
 		apptelemetry.SetSpanOriginalError(&span, err, evtString)
-		return "", err
+		return err
 	}
 	span.AddEvent("password reset initiated")
-	return token.Value, nil
+	return nil
 }
 
 func (ls loginService) ResetPassword(ctx context.Context, logger *zap.Logger, passwordResetToken string, newPassword string, initiator string) errors.RichError {
@@ -208,7 +493,6 @@ func (ls loginService) ResetPassword(ctx context.Context, logger *zap.Logger, pa
 		apptelemetry.SetSpanOriginalError(&span, err, evtString)
 		return err
 	}
-	// TODO: add password validation logic
 	token, err := ls.tokenService.GetToken(ctx, logger, passwordResetToken, models.TokenTypePasswordReset)
 	if err != nil {
 		logger.Error("tokenService.GetToken call failed", zap.Reflect("error", err))
@@ -223,7 +507,13 @@ func (ls loginService) ResetPassword(ctx context.Context, logger *zap.Logger, pa
 		return err
 	}
 	span.AddEvent("user retreived from repo")
-	newPasswordHash, err := utilities.BcryptHashString(newPassword, bcrypt.DefaultCost)
+	err = validateNewPassword(ctx, logger, &span, ls.passwordPolicy, ls.contactRepo, user.ID, newPassword)
+	if err != nil {
+		// additional error stuff handeled in validateNewPassword function
+		return err
+	}
+	span.AddEvent("new password meets password policy")
+	newPasswordHash, err := ls.passwordHashers.Hash(newPassword)
 	if err != nil {
 		evtString := "failed to hash users new password"
 		logger.Error(evtString, zap.Reflect("error", err))
@@ -231,6 +521,14 @@ func (ls loginService) ResetPassword(ctx context.Context, logger *zap.Logger, pa
 		return err
 	}
 	span.AddEvent("new password hash generated")
+	// the token is consumed before the password is changed so a reset link can only be used once, even by concurrent requests.
+	_, err = ls.tokenService.ConsumeToken(ctx, logger, passwordResetToken, models.TokenTypePasswordReset)
+	if err != nil {
+		logger.Error("tokenService.ConsumeToken call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	span.AddEvent("password reset token consumed")
 	user.PasswordHash = newPasswordHash
 	err = ls.userRepo.UpdateUser(ctx, &user, initiator)
 	if err != nil {
@@ -238,6 +536,93 @@ func (ls loginService) ResetPassword(ctx context.Context, logger *zap.Logger, pa
 		apptelemetry.SetSpanError(&span, err, "")
 		return err
 	}
+	span.AddEvent("user password updated")
+	// the user is signed out everywhere in case the old password was compromised, and any outstanding password reset links or totp challenges can no longer be used.
+	revokedTokenTypes := append([]models.TokenType{models.TokenTypePasswordReset, models.TokenTypeMFAChallenge}, models.SignInTokenTypes...)
+	err = ls.tokenService.DeleteTokensByTargetID(ctx, logger, user.ID, revokedTokenTypes)
+	if err != nil {
+		evtString := "failed to revoke user tokens after password reset"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return err
+	}
+	data := map[string]interface{}{
+		"reason":    "password reset",
+		"initiator": initiator,
+	}
+	auditLog := models.NewAuditLog(models.AuditLogCode_UserTokensRevoked, "user tokens revoked", models.AssetType_User, user.ID, data)
+	err = ls.auditLogRepo.LogMessage(ctx, auditLog)
+	if err != nil {
+		evtString := "failed to write audit log for user tokens revoked after password reset"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return err
+	}
 	span.AddEvent("password reset completed")
 	return nil
 }
+
+func (ls loginService) ChangePassword(ctx context.Context, logger *zap.Logger, userID string, currentPassword string, newPassword string, initiator string) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, ls.GetName(), "ChangePassword")
+	defer span.End()
+	if newPassword == "" {
+		err := coreerrors.NewNoNewPasswordHashProvidedError(true)
+		evtString := "new password is empty string"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	user, err := ls.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		logger.Error("userRepo.GetUserByID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	span.AddEvent("user retreived from repo")
+	passwordMatch, _, err := ls.passwordHashers.Verify(user.PasswordHash, currentPassword, user.ID)
+	if err != nil {
+		evtString := "failed to compare users current password"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	if !passwordMatch {
+		err := coreerrors.NewLoginFailedWrongPasswordError(user.ID, true)
+		evtString := "current password provided does not match"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	span.AddEvent("current password confirmed")
+	err = validateNewPassword(ctx, logger, &span, ls.passwordPolicy, ls.contactRepo, user.ID, newPassword)
+	if err != nil {
+		// additional error stuff handeled in validateNewPassword function
+		return err
+	}
+	span.AddEvent("new password meets password policy")
+	newPasswordHash, err := ls.passwordHashers.Hash(newPassword)
+	if err != nil {
+		evtString := "failed to hash users new password"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	span.AddEvent("new password hash generated")
+	user.PasswordHash = newPasswordHash
+	err = ls.userRepo.UpdateUser(ctx, &user, initiator)
+	if err != nil {
+		logger.Error("userRepo.UpdateUser call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	// outstanding password reset links were sent for the old password, so they can no longer be used.
+	err = ls.tokenService.DeleteTokensByTargetID(ctx, logger, user.ID, []models.TokenType{models.TokenTypePasswordReset})
+	if err != nil {
+		evtString := "failed to revoke password reset tokens after password change"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return err
+	}
+	span.AddEvent("password changed")
+	return nil
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +15,8 @@ import (
 	"github.com/calvine/goauth/core/services"
 	"github.com/calvine/goauth/core/utilities"
 	"github.com/calvine/goauth/dataaccess/memory"
+	"github.com/calvine/goauth/internal/testutils"
+	richerrors "github.com/calvine/richerror/errors"
 	"go.uber.org/zap/zaptest"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -30,6 +34,10 @@ var (
 	loginServiceTest_TestPasswordResetToken string
 
 	loginServiceTest_NonPasswordResetToken models.Token
+
+	loginServiceTest_EmailService services.EmailService
+	loginServiceTest_TokenService services.TokenService
+	loginServiceTest_UserRepo     repo.UserRepo
 )
 
 const (
@@ -46,6 +54,8 @@ const (
 
 	loginServiceTest_NewPasswordPostReset = "anewpasswordhash123"
 
+	loginServiceTest_Issuer = "https://goauth.test"
+
 	loginServiceTest_LockoutAfterFailedLoginAttempts = 10
 
 	loginServiceTest_LockoutDuration time.Duration = time.Millisecond * 500
@@ -71,6 +81,14 @@ func TestLoginService(t *testing.T) {
 	t.Run("LoginWithPrimaryContact", func(t *testing.T) {
 		_testLoginWithPrimaryContact(t, loginService)
 	})
+
+	t.Run("ChangePassword", func(t *testing.T) {
+		_testChangePassword(t, loginService)
+	})
+
+	t.Run("TOTP", func(t *testing.T) {
+		_testTOTP(t, loginService)
+	})
 }
 
 func setupLoginServiceTestData(t *testing.T, userRepo repo.UserRepo, contactRepo repo.ContactRepo, tokenService services.TokenService) {
@@ -204,32 +222,39 @@ func setupLoginServiceTestData(t *testing.T, userRepo repo.UserRepo, contactRepo
 
 func buildLoginService(t *testing.T) services.LoginService {
 	auditLogRepo := memory.NewMemoryAuditLogRepo(false)
-	users := make(map[string]models.User)
-	contacts := make(map[string]models.Contact)
-	userRepo, err := memory.NewMemoryUserRepo(&users, &contacts)
+	userStore := memory.NewUserStore()
+	userRepo, err := memory.NewMemoryUserRepo(userStore)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
 	}
-	contactRepo, err := memory.NewMemoryContactRepo(&users, &contacts)
+	loginServiceTest_UserRepo = userRepo
+	contactRepo, err := memory.NewMemoryContactRepo(userStore)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
 	}
-	tokenRepo := memory.NewMemoryTokenRepo()
-	emailService, _ := NewEmailService(NoOpEmailService, nil)
-	tokenService := NewTokenService(tokenRepo)
+	tokenRepo := memory.NewMemoryTokenRepo(context.TODO())
+	loginServiceTest_EmailService, _ = NewEmailService(StackEmailService, nil)
+	loginServiceTest_TokenService = NewTokenService(tokenRepo)
 
-	setupLoginServiceTestData(t, userRepo, contactRepo, tokenService)
+	setupLoginServiceTestData(t, userRepo, contactRepo, loginServiceTest_TokenService)
+	encryptionKey, err := utilities.NewEncryptionKey()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
 
 	options := LoginServiceOptions{
 		AuditLogRepo:           auditLogRepo,
 		ContactRepo:            contactRepo,
 		UserRepo:               userRepo,
-		EmailService:           emailService,
-		TokenService:           tokenService,
+		EmailService:           loginServiceTest_EmailService,
+		TokenService:           loginServiceTest_TokenService,
+		EncryptionKey:          encryptionKey,
 		MaxFailedLoginAttempts: loginServiceTest_LockoutAfterFailedLoginAttempts,
 		AccountLockoutDuration: loginServiceTest_LockoutDuration,
+		Issuer:                 loginServiceTest_Issuer,
 	}
 
 	return NewLoginService(options)
@@ -257,11 +282,31 @@ func _testStartPasswordResetByPrimaryContact(t *testing.T, loginService services
 
 func __testStartPasswordResetSuccess(t *testing.T, loginService services.LoginService) {
 	logger := zaptest.NewLogger(t)
-	tokenValue, err := loginService.StartPasswordResetByPrimaryContact(context.TODO(), logger, loginServiceTest_ConfirmedPrimaryEmail, core.CONTACT_TYPE_EMAIL, loginServiceTest_CreatedBy)
+	err := loginService.StartPasswordResetByPrimaryContact(context.TODO(), logger, loginServiceTest_ConfirmedPrimaryEmail, core.CONTACT_TYPE_EMAIL, loginServiceTest_CreatedBy)
 	if err != nil {
 		t.Log(err.Error())
 		t.Errorf("received error when attempting to start valid password reset: %s", err.GetErrorCode())
 	}
+	ses, ok := loginServiceTest_EmailService.(*stackEmailService)
+	if !ok {
+		t.Errorf("expected stackEmailService instance of email service but got %s", loginServiceTest_EmailService.GetName())
+		t.FailNow()
+	}
+	lastMessage, ok := ses.PopMessage()
+	if !ok {
+		t.Error("no message found in email stack from password reset")
+		t.FailNow()
+	}
+	if len(lastMessage.To) != 1 || lastMessage.To[0] != loginServiceTest_ConfirmedPrimaryEmail {
+		t.Errorf("password reset message to value not expected: got - %v expected - %s", lastMessage.To, loginServiceTest_ConfirmedPrimaryEmail)
+	}
+	linkPrefix := loginServiceTest_Issuer + "/auth/resetpassword/"
+	linkIndex := strings.Index(lastMessage.Body, linkPrefix)
+	if linkIndex == -1 {
+		t.Errorf("password reset message body does not contain reset link: got - %s expected to contain - %s", lastMessage.Body, linkPrefix)
+		t.FailNow()
+	}
+	tokenValue := lastMessage.Body[linkIndex+len(linkPrefix):]
 	if tokenValue == "" {
 		t.Error("token value should not be an empty string")
 	}
@@ -270,20 +315,31 @@ func __testStartPasswordResetSuccess(t *testing.T, loginService services.LoginSe
 
 func __testStartPasswordResetFailedNotPrimaryContact(t *testing.T, loginService services.LoginService) {
 	logger := zaptest.NewLogger(t)
-	tokenValue, err := loginService.StartPasswordResetByPrimaryContact(context.TODO(), logger, loginServiceTest_ConfirmedSecondaryEmail, core.CONTACT_TYPE_EMAIL, loginServiceTest_CreatedBy)
+	err := loginService.StartPasswordResetByPrimaryContact(context.TODO(), logger, loginServiceTest_ConfirmedSecondaryEmail, core.CONTACT_TYPE_EMAIL, loginServiceTest_CreatedBy)
 	if err == nil {
 		t.Error("expected error due to non primary contact being used for password reset")
+		t.FailNow()
 	}
 	if err.GetErrorCode() != errors.ErrCodePasswordResetContactNotPrimary {
 		t.Log(err.Error())
 		t.Errorf("expected password reset contact not primary error but got: %s", err.GetErrorCode())
 	}
-	if tokenValue != "" {
-		t.Errorf("token value should be empty because the password reset initiation should have failed: %s", tokenValue)
+	ses, ok := loginServiceTest_EmailService.(*stackEmailService)
+	if !ok {
+		t.Errorf("expected stackEmailService instance of email service but got %s", loginServiceTest_EmailService.GetName())
+		t.FailNow()
+	}
+	if message, ok := ses.PopMessage(); ok {
+		t.Errorf("no password reset message should be sent because the password reset initiation should have failed: %v", message.To)
 	}
 }
 
 func _testResetPassword(t *testing.T, loginService services.LoginService) {
+	// password reset failure new password breaks the password policy, this runs first to show the token is not used up by it
+	t.Run("Failure password policy", func(t *testing.T) {
+		__testPasswordResetFailurePasswordPolicy(t, loginService)
+	})
+
 	// password reset successful
 	t.Run("Success", func(t *testing.T) {
 		__testPasswordResetSuccess(t, loginService)
@@ -299,6 +355,11 @@ func _testResetPassword(t *testing.T, loginService services.LoginService) {
 		__testPasswordResetFailureEmptyPasswordHash(t, loginService)
 	})
 
+	// password reset failure token already used
+	t.Run("Failure token reused", func(t *testing.T) {
+		__testPasswordResetFailureTokenReused(t, loginService)
+	})
+
 	// password reset failure non password reset token presented
 	t.Run("Failure wrong token type", func(t *testing.T) {
 		__testPasswordResetFailureWrongTokenType(t, loginService)
@@ -307,11 +368,50 @@ func _testResetPassword(t *testing.T, loginService services.LoginService) {
 
 func __testPasswordResetSuccess(t *testing.T, loginService services.LoginService) {
 	logger := zaptest.NewLogger(t)
-	err := loginService.ResetPassword(context.TODO(), logger, loginServiceTest_TestPasswordResetToken, loginServiceTest_NewPasswordPostReset, loginServiceTest_CreatedBy)
+	sessionToken, err := models.NewToken(loginServiceTest_ConfirmedUser.ID, models.TokenTypeSession, time.Hour)
+	if err != nil {
+		t.Errorf("failed to create session token: %s", err.GetErrorCode())
+		t.FailNow()
+	}
+	err = loginServiceTest_TokenService.PutToken(context.TODO(), logger, sessionToken)
+	if err != nil {
+		t.Errorf("failed to add session token: %s", err.GetErrorCode())
+		t.FailNow()
+	}
+	err = loginService.ResetPassword(context.TODO(), logger, loginServiceTest_TestPasswordResetToken, loginServiceTest_NewPasswordPostReset, loginServiceTest_CreatedBy)
 	if err != nil {
 		t.Log(err.Error())
 		t.Errorf("expected password reset to succeed bug got an an error: %s", err.GetErrorCode())
 	}
+	_, err = loginServiceTest_TokenService.GetToken(context.TODO(), logger, sessionToken.Value, models.TokenTypeSession)
+	if err == nil {
+		t.Error("expected the users sessions to be revoked after the password reset")
+	}
+}
+
+func __testPasswordResetFailurePasswordPolicy(t *testing.T, loginService services.LoginService) {
+	logger := zaptest.NewLogger(t)
+	err := loginService.ResetPassword(context.TODO(), logger, loginServiceTest_TestPasswordResetToken, "my"+loginServiceTest_ConfirmedPrimaryEmail, loginServiceTest_CreatedBy)
+	if err == nil {
+		t.Errorf("expected password reset to fail because the new password contains the users email")
+		t.FailNow()
+	}
+	if err.GetErrorCode() != errors.ErrCodePasswordPolicyViolation {
+		t.Log(err.Error())
+		t.Errorf("expected password policy violation error but got: %s", err.GetErrorCode())
+	}
+}
+
+func __testPasswordResetFailureTokenReused(t *testing.T, loginService services.LoginService) {
+	logger := zaptest.NewLogger(t)
+	err := loginService.ResetPassword(context.TODO(), logger, loginServiceTest_TestPasswordResetToken, "new password hash 4", loginServiceTest_CreatedBy)
+	if err == nil {
+		t.Errorf("expected password reset to fail because the token provided was already used")
+	}
+	if err.GetErrorCode() != errors.ErrCodeInvalidToken {
+		t.Log(err.Error())
+		t.Errorf("expected invalid token error but got: %s", err.GetErrorCode())
+	}
 }
 
 func __testPasswordResetFailureInvalidToken(t *testing.T, loginService services.LoginService) {
@@ -367,6 +467,11 @@ func _testLoginWithPrimaryContact(t *testing.T, loginService services.LoginServi
 		__testFailedLoginSecondaryContactUsed(t, loginService)
 	})
 
+	// test outdated password hash upgraded
+	t.Run("Outdated password hash upgraded", func(t *testing.T) {
+		__testOutdatedPasswordHashUpgraded(t, loginService)
+	})
+
 	// test account lockout
 	t.Run("Account lockout", func(t *testing.T) {
 		__testAccountLockout(t, loginService)
@@ -393,6 +498,38 @@ func __testSuccessfullEmailLogin(t *testing.T, loginService services.LoginServic
 	}
 }
 
+func __testOutdatedPasswordHashUpgraded(t *testing.T, loginService services.LoginService) {
+	logger := zaptest.NewLogger(t)
+	// the other confirmed user was set up with a bcrypt hash, and the login service defaults to argon2id.
+	_, err := loginService.LoginWithPrimaryContact(context.TODO(), logger, loginServiceTest_OtherConfirmedPrimaryEmail, core.CONTACT_TYPE_EMAIL, loginServiceTest_OtherConfirmedUserPassword, loginServiceTest_CreatedBy)
+	if err != nil {
+		testutils.HandleTestError(t, err, "")
+		return
+	}
+	user, err := loginServiceTest_UserRepo.GetUserByID(context.TODO(), loginServiceTest_OtherConfirmedUser.ID)
+	if err != nil {
+		t.Errorf("failed to retreive user from repo for validation: %s - %s", err.GetErrorCode(), err.Error())
+		return
+	}
+	if algorithm := utilities.GetPasswordHashAlgorithm(user.PasswordHash); algorithm != core.PASSWORD_HASH_ALGORITHM_ARGON2ID {
+		t.Errorf("password hash was not upgraded on login: got - %s expected - %s", algorithm, core.PASSWORD_HASH_ALGORITHM_ARGON2ID)
+	}
+	// the upgraded hash is current, so logging in again should keep it.
+	_, err = loginService.LoginWithPrimaryContact(context.TODO(), logger, loginServiceTest_OtherConfirmedPrimaryEmail, core.CONTACT_TYPE_EMAIL, loginServiceTest_OtherConfirmedUserPassword, loginServiceTest_CreatedBy)
+	if err != nil {
+		testutils.HandleTestError(t, err, "")
+		return
+	}
+	userAfterSecondLogin, err := loginServiceTest_UserRepo.GetUserByID(context.TODO(), loginServiceTest_OtherConfirmedUser.ID)
+	if err != nil {
+		t.Errorf("failed to retreive user from repo for validation: %s - %s", err.GetErrorCode(), err.Error())
+		return
+	}
+	if userAfterSecondLogin.PasswordHash != user.PasswordHash {
+		t.Error("current password hash should not be replaced on login")
+	}
+}
+
 func __testFailedLogin(t *testing.T, loginService services.LoginService) {
 	logger := zaptest.NewLogger(t)
 	_, err := loginService.LoginWithPrimaryContact(context.TODO(), logger, loginServiceTest_ConfirmedPrimaryEmail, core.CONTACT_TYPE_EMAIL, "not the right password 12345678904321234567", loginServiceTest_CreatedBy)
@@ -466,3 +603,193 @@ func __testAccountLockoutRelease(t *testing.T, loginService services.LoginServic
 		t.Errorf("expected user locked out until to be unset but got : %s", user.LockedOutUntil.Value.String())
 	}
 }
+
+func _testChangePassword(t *testing.T, loginService services.LoginService) {
+	logger := zaptest.NewLogger(t)
+	const newPassword = "a changed password"
+	type testCase struct {
+		name              string
+		currentPassword   string
+		newPassword       string
+		expectedErrorCode string
+	}
+	testCases := []testCase{
+		{
+			name:              "GIVEN an empty new password EXPECT error code no new password hash provided",
+			currentPassword:   loginServiceTest_NewPasswordPostReset,
+			newPassword:       "",
+			expectedErrorCode: errors.ErrCodeNoNewPasswordHashProvided,
+		},
+		{
+			name:              "GIVEN the wrong current password EXPECT error code login failed wrong password",
+			currentPassword:   "not the current password",
+			newPassword:       newPassword,
+			expectedErrorCode: errors.ErrCodeLoginFailedWrongPassword,
+		},
+		{
+			name:              "GIVEN a new password that is too short EXPECT error code password policy violation",
+			currentPassword:   loginServiceTest_NewPasswordPostReset,
+			newPassword:       "short",
+			expectedErrorCode: errors.ErrCodePasswordPolicyViolation,
+		},
+		{
+			name:              "GIVEN a new password containing the users email EXPECT error code password policy violation",
+			currentPassword:   loginServiceTest_NewPasswordPostReset,
+			newPassword:       "my" + loginServiceTest_ConfirmedPrimaryEmail,
+			expectedErrorCode: errors.ErrCodePasswordPolicyViolation,
+		},
+		{
+			name:            "GIVEN the current password and a valid new password EXPECT success and login with the new password",
+			currentPassword: loginServiceTest_NewPasswordPostReset,
+			newPassword:     newPassword,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := loginService.ChangePassword(context.TODO(), logger, loginServiceTest_ConfirmedUser.ID, tc.currentPassword, tc.newPassword, loginServiceTest_CreatedBy)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			} else {
+				_, err := loginService.LoginWithPrimaryContact(context.TODO(), logger, loginServiceTest_ConfirmedPrimaryEmail, core.CONTACT_TYPE_EMAIL, tc.newPassword, loginServiceTest_CreatedBy)
+				if err != nil {
+					t.Errorf("expected login with the new password to succeed: %s - %s", err.GetErrorCode(), err.Error())
+				}
+			}
+		})
+	}
+}
+
+func _testTOTP(t *testing.T, loginService services.LoginService) {
+	logger := zaptest.NewLogger(t)
+	userID := loginServiceTest_OtherConfirmedUser.ID
+	t.Run("GIVEN totp enrollment has not started EXPECT error code totp not enrolled", func(t *testing.T) {
+		err := loginService.ConfirmTOTPEnrollment(context.TODO(), logger, userID, "123456", loginServiceTest_CreatedBy)
+		__expectTOTPError(t, err, errors.ErrCodeTOTPNotEnrolled)
+		_, err = loginService.LoginWithTOTP(context.TODO(), logger, userID, "123456", loginServiceTest_CreatedBy)
+		__expectTOTPError(t, err, errors.ErrCodeTOTPNotEnrolled)
+		_, err = loginService.GetTOTPEnrollment(context.TODO(), logger, userID, loginServiceTest_CreatedBy)
+		__expectTOTPError(t, err, errors.ErrCodeTOTPNotEnrolled)
+	})
+	var secret string
+	t.Run("GIVEN totp enrollment is started EXPECT a provisioning uri and the secret stored encrypted", func(t *testing.T) {
+		provisioningURI, err := loginService.StartTOTPEnrollment(context.TODO(), logger, userID, loginServiceTest_CreatedBy)
+		if err != nil {
+			testutils.HandleTestError(t, err, "")
+			t.FailNow()
+		}
+		parsedURI, parseErr := url.Parse(provisioningURI)
+		if parseErr != nil {
+			t.Fatalf("failed to parse provisioning uri: %s", parseErr.Error())
+		}
+		secret = parsedURI.Query().Get("secret")
+		if !strings.Contains(parsedURI.Path, loginServiceTest_OtherConfirmedPrimaryEmail) {
+			t.Errorf("provisioning uri should contain the users primary contact: %s", provisioningURI)
+		}
+		user, err := loginServiceTest_UserRepo.GetUserByID(context.TODO(), userID)
+		if err != nil {
+			testutils.HandleTestError(t, err, "")
+			t.FailNow()
+		}
+		if user.TOTPSecret == "" || strings.Contains(user.TOTPSecret, secret) {
+			t.Error("totp secret should be stored encrypted")
+		}
+		if user.TOTPEnabled {
+			t.Error("totp should not be enabled until enrollment is confirmed")
+		}
+		// until enrollment is confirmed the password is enough to log in.
+		_, err = loginService.LoginWithPrimaryContact(context.TODO(), logger, loginServiceTest_OtherConfirmedPrimaryEmail, core.CONTACT_TYPE_EMAIL, loginServiceTest_OtherConfirmedUserPassword, loginServiceTest_CreatedBy)
+		if err != nil {
+			testutils.HandleTestError(t, err, "")
+		}
+		pendingProvisioningURI, err := loginService.GetTOTPEnrollment(context.TODO(), logger, userID, loginServiceTest_CreatedBy)
+		if err != nil {
+			testutils.HandleTestError(t, err, "")
+		} else if pendingProvisioningURI != provisioningURI {
+			t.Errorf("the pending enrollment should have the same provisioning uri: got %s - expected %s", pendingProvisioningURI, provisioningURI)
+		}
+	})
+	t.Run("GIVEN the encrypted totp secret copied to another user EXPECT error code decryption failed", func(t *testing.T) {
+		user, err := loginServiceTest_UserRepo.GetUserByID(context.TODO(), userID)
+		if err != nil {
+			testutils.HandleTestError(t, err, "")
+			t.FailNow()
+		}
+		otherUser, err := loginServiceTest_UserRepo.GetUserByID(context.TODO(), loginServiceTest_ConfirmedUser.ID)
+		if err != nil {
+			testutils.HandleTestError(t, err, "")
+			t.FailNow()
+		}
+		otherUser.TOTPSecret = user.TOTPSecret
+		err = loginServiceTest_UserRepo.UpdateUser(context.TODO(), &otherUser, loginServiceTest_CreatedBy)
+		if err != nil {
+			testutils.HandleTestError(t, err, "")
+			t.FailNow()
+		}
+		_, err = loginService.GetTOTPEnrollment(context.TODO(), logger, otherUser.ID, loginServiceTest_CreatedBy)
+		__expectTOTPError(t, err, errors.ErrCodeDecryptionFailed)
+		otherUser.TOTPSecret = ""
+		err = loginServiceTest_UserRepo.UpdateUser(context.TODO(), &otherUser, loginServiceTest_CreatedBy)
+		if err != nil {
+			testutils.HandleTestError(t, err, "")
+		}
+	})
+	enrollmentTimeStep := utilities.GetTOTPTimeStep(time.Now())
+	t.Run("GIVEN the wrong code to confirm enrollment EXPECT error code invalid totp code", func(t *testing.T) {
+		wrongCode, _ := utilities.GenerateTOTPCode(secret, enrollmentTimeStep-10)
+		err := loginService.ConfirmTOTPEnrollment(context.TODO(), logger, userID, wrongCode, loginServiceTest_CreatedBy)
+		__expectTOTPError(t, err, errors.ErrCodeInvalidTOTPCode)
+	})
+	t.Run("GIVEN a valid code to confirm enrollment EXPECT totp enabled", func(t *testing.T) {
+		code, _ := utilities.GenerateTOTPCode(secret, enrollmentTimeStep)
+		err := loginService.ConfirmTOTPEnrollment(context.TODO(), logger, userID, code, loginServiceTest_CreatedBy)
+		if err != nil {
+			testutils.HandleTestError(t, err, "")
+			t.FailNow()
+		}
+		_, err = loginService.StartTOTPEnrollment(context.TODO(), logger, userID, loginServiceTest_CreatedBy)
+		__expectTOTPError(t, err, errors.ErrCodeTOTPAlreadyEnabled)
+	})
+	t.Run("GIVEN totp is enabled and the right password EXPECT error code mfa required", func(t *testing.T) {
+		user, err := loginService.LoginWithPrimaryContact(context.TODO(), logger, loginServiceTest_OtherConfirmedPrimaryEmail, core.CONTACT_TYPE_EMAIL, loginServiceTest_OtherConfirmedUserPassword, loginServiceTest_CreatedBy)
+		__expectTOTPError(t, err, errors.ErrCodeMFARequired)
+		if user.ID != userID {
+			t.Errorf("the user should be returned with the mfa required error: got %s - expected %s", user.ID, userID)
+		}
+	})
+	t.Run("GIVEN the code used to confirm enrollment EXPECT error code invalid totp code and a failed login attempt", func(t *testing.T) {
+		code, _ := utilities.GenerateTOTPCode(secret, enrollmentTimeStep)
+		_, err := loginService.LoginWithTOTP(context.TODO(), logger, userID, code, loginServiceTest_CreatedBy)
+		__expectTOTPError(t, err, errors.ErrCodeInvalidTOTPCode)
+		user, err := loginServiceTest_UserRepo.GetUserByID(context.TODO(), userID)
+		if err != nil {
+			testutils.HandleTestError(t, err, "")
+			t.FailNow()
+		}
+		if user.ConsecutiveFailedLoginAttempts != 1 {
+			t.Errorf("wrong totp codes should count as failed login attempts: got %d - expected 1", user.ConsecutiveFailedLoginAttempts)
+		}
+	})
+	t.Run("GIVEN a new code EXPECT login completed", func(t *testing.T) {
+		code, _ := utilities.GenerateTOTPCode(secret, enrollmentTimeStep+1)
+		user, err := loginService.LoginWithTOTP(context.TODO(), logger, userID, code, loginServiceTest_CreatedBy)
+		if err != nil {
+			testutils.HandleTestError(t, err, "")
+			t.FailNow()
+		}
+		if !user.LastLoginDate.HasValue || user.ConsecutiveFailedLoginAttempts != 0 {
+			t.Error("a successful totp login should be recorded and reset the failed login attempts")
+		}
+		_, err = loginService.LoginWithTOTP(context.TODO(), logger, userID, code, loginServiceTest_CreatedBy)
+		__expectTOTPError(t, err, errors.ErrCodeInvalidTOTPCode)
+	})
+}
+
+func __expectTOTPError(t *testing.T, err richerrors.RichError, expectedErrorCode string) {
+	if err == nil {
+		t.Errorf("expected an error to occurr: %s", expectedErrorCode)
+		return
+	}
+	testutils.HandleTestError(t, err, expectedErrorCode)
+}
@@ -0,0 +1,1084 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/calvine/goauth/core"
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/jwt"
+	"github.com/calvine/goauth/core/models"
+	repo "github.com/calvine/goauth/core/repositories"
+	coreservices "github.com/calvine/goauth/core/services"
+	"github.com/calvine/goauth/core/utilities"
+	"github.com/calvine/richerror/errors"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAuthorizationCodeDuration time.Duration = time.Minute * 10
+	defaultAccessTokenDuration       time.Duration = time.Hour
+	defaultRefreshTokenDuration      time.Duration = time.Hour * 24 * 30
+)
+
+type oauthService struct {
+	appService                coreservices.AppService
+	userService               coreservices.UserService
+	tokenService              coreservices.TokenService
+	consentRepo               repo.ConsentRepo
+	auditLogRepo              repo.AuditLogRepo
+	keyProvider               jwt.KeyProvider
+	issuer                    string
+	authorizationCodeDuration time.Duration
+	accessTokenDuration       time.Duration
+	refreshTokenDuration      time.Duration
+	maxJWTDuration            time.Duration
+}
+
+type OAuthServiceOptions struct {
+	AppService   coreservices.AppService
+	TokenService coreservices.TokenService
+	// UserService provides the user data for the claims of id tokens.
+	UserService coreservices.UserService
+	// ConsentRepo stores the scopes users have consented to apps being granted.
+	ConsentRepo repo.ConsentRepo
+	// AuditLogRepo records consent grants and tokens revoked in bulk.
+	AuditLogRepo repo.AuditLogRepo
+	// KeyProvider provides the keys used to sign and verify the JWTs issued by the service.
+	KeyProvider jwt.KeyProvider
+	// Issuer is the iss claim for JWTs issued by the service. It should be the base url of the server.
+	Issuer                    string
+	AuthorizationCodeDuration time.Duration
+	AccessTokenDuration       time.Duration
+	RefreshTokenDuration      time.Duration
+	// MaxJWTDuration caps the lifetime of signed access and id tokens, including lifetimes set in app policies, so they never outlive the keys that signed them.
+	// When not set the access token duration is used.
+	MaxJWTDuration time.Duration
+}
+
+func NewOAuthService(options OAuthServiceOptions) coreservices.OAuthService {
+	if options.AuthorizationCodeDuration <= 0 {
+		options.AuthorizationCodeDuration = defaultAuthorizationCodeDuration
+	}
+	if options.AccessTokenDuration <= 0 {
+		options.AccessTokenDuration = defaultAccessTokenDuration
+	}
+	if options.RefreshTokenDuration <= 0 {
+		options.RefreshTokenDuration = defaultRefreshTokenDuration
+	}
+	if options.MaxJWTDuration <= 0 {
+		options.MaxJWTDuration = options.AccessTokenDuration
+	}
+	return oauthService{
+		appService:                options.AppService,
+		userService:               options.UserService,
+		tokenService:              options.TokenService,
+		consentRepo:               options.ConsentRepo,
+		auditLogRepo:              options.AuditLogRepo,
+		keyProvider:               options.KeyProvider,
+		issuer:                    options.Issuer,
+		authorizationCodeDuration: options.AuthorizationCodeDuration,
+		accessTokenDuration:       options.AccessTokenDuration,
+		refreshTokenDuration:      options.RefreshTokenDuration,
+		maxJWTDuration:            options.MaxJWTDuration,
+	}
+}
+
+func (oauthService) GetName() string {
+	return "oauthService"
+}
+
+func (oas oauthService) ValidateAuthorizationClient(ctx context.Context, logger *zap.Logger, clientID, redirectURI string, initiator string) (models.App, []models.Scope, string, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "ValidateAuthorizationClient")
+	defer span.End()
+	if clientID == "" {
+		err := coreerrors.NewMissingRequiredParameterError("client_id", true)
+		evtString := "client id was not provided"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.App{}, nil, "", err
+	}
+	app, scopes, err := oas.appService.GetAppAndScopesByClientID(ctx, logger, clientID, initiator)
+	if err != nil {
+		logger.Error("appService.GetAppAndScopesByClientID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return models.App{}, nil, "", err
+	}
+	span.AddEvent("app and scopes retreived")
+	if redirectURI == "" {
+		// the redirect uri is optional when the app only has one registered per https://datatracker.ietf.org/doc/html/rfc6749#section-3.1.2.3
+		if len(app.RedirectURIs) != 1 {
+			err := coreerrors.NewMissingRequiredParameterError("redirect_uri", true)
+			evtString := fmt.Sprintf("redirect uri is required because the app has %d registered", len(app.RedirectURIs))
+			logger.Error(evtString, zap.Reflect("error", err))
+			apptelemetry.SetSpanOriginalError(&span, err, evtString)
+			return models.App{}, nil, "", err
+		}
+		redirectURI = app.RedirectURIs[0]
+	} else if !app.MatchRedirectURI(redirectURI) {
+		err := coreerrors.NewInvalidRedirectURIError(clientID, redirectURI, true)
+		evtString := fmt.Sprintf("redirect uri is not registered for app: %s", redirectURI)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.App{}, nil, "", err
+	}
+	if app.IsDisabled {
+		err := coreerrors.NewAppDisabledError(clientID, true)
+		evtString := fmt.Sprintf("app is disabled: %s", app.ID)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.App{}, nil, "", err
+	}
+	span.AddEvent("authorization client validated")
+	return app, scopes, redirectURI, nil
+}
+
+func (oas oauthService) ValidateAuthorizationRequest(ctx context.Context, logger *zap.Logger, app models.App, appScopes []models.Scope, authorizationRequest models.AuthorizationRequest, initiator string) ([]models.Scope, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "ValidateAuthorizationRequest")
+	defer span.End()
+	if authorizationRequest.ResponseType == "" {
+		err := coreerrors.NewMissingRequiredParameterError("response_type", true)
+		evtString := "response type was not provided"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return nil, err
+	}
+	if authorizationRequest.ResponseType != core.OAUTH_RESPONSE_TYPE_CODE {
+		err := coreerrors.NewUnsupportedResponseTypeError(authorizationRequest.ResponseType, true)
+		evtString := fmt.Sprintf("response type is not supported: %s", authorizationRequest.ResponseType)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return nil, err
+	}
+	if !app.Policy.AllowsResponseType(authorizationRequest.ResponseType) {
+		err := coreerrors.NewResponseTypeNotAllowedError(app.ClientID, authorizationRequest.ResponseType, true)
+		evtString := fmt.Sprintf("response type is not allowed for app: %s", authorizationRequest.ResponseType)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return nil, err
+	}
+	span.AddEvent("response type validated")
+	err := validateCodeChallenge(app, authorizationRequest)
+	if err != nil {
+		evtString := "code challenge is not valid for app"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return nil, err
+	}
+	span.AddEvent("code challenge validated")
+	requestedScopes, err := findRequestedScopes(app, appScopes, authorizationRequest.RequestedScopes(), true)
+	if err != nil {
+		evtString := "requested scopes are not valid for app"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return nil, err
+	}
+	span.AddEvent("authorization request validated")
+	return requestedScopes, nil
+}
+
+func (oas oauthService) GetMissingConsent(ctx context.Context, logger *zap.Logger, userID string, app models.App, scopes []models.Scope, initiator string) ([]models.Scope, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "GetMissingConsent")
+	defer span.End()
+	consentedScopeNames, err := oas.getConsentedScopeNames(ctx, userID, app.ID)
+	if err != nil {
+		logger.Error("consentRepo.GetConsentsByUserIDAndAppID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return nil, err
+	}
+	missingConsent := make([]models.Scope, 0, len(scopes))
+	for _, scope := range scopes {
+		if !consentedScopeNames[scope.Name] {
+			missingConsent = append(missingConsent, scope)
+		}
+	}
+	evtString := fmt.Sprintf("%d scopes are missing consent", len(missingConsent))
+	span.AddEvent(evtString)
+	return missingConsent, nil
+}
+
+func (oas oauthService) GrantConsent(ctx context.Context, logger *zap.Logger, userID string, app models.App, scopes []models.Scope, initiator string) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "GrantConsent")
+	defer span.End()
+	consentedScopeNames, err := oas.getConsentedScopeNames(ctx, userID, app.ID)
+	if err != nil {
+		logger.Error("consentRepo.GetConsentsByUserIDAndAppID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	grantedScopeNames := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if consentedScopeNames[scope.Name] {
+			continue
+		}
+		consent := models.NewConsent(userID, app.ID, scope.Name)
+		err = oas.consentRepo.AddConsent(ctx, &consent, initiator)
+		if err != nil {
+			logger.Error("consentRepo.AddConsent call failed", zap.Reflect("error", err))
+			apptelemetry.SetSpanError(&span, err, "")
+			return err
+		}
+		consentedScopeNames[scope.Name] = true
+		grantedScopeNames = append(grantedScopeNames, scope.Name)
+	}
+	span.AddEvent("consent granted")
+	if len(grantedScopeNames) == 0 {
+		return nil
+	}
+	data := map[string]interface{}{
+		"appId":     app.ID,
+		"clientId":  app.ClientID,
+		"scopes":    grantedScopeNames,
+		"initiator": initiator,
+	}
+	err = oas.writeAuditLog(ctx, logger, &span, models.AuditLogCode_ConsentGranted, "consent granted", models.AssetType_User, userID, data)
+	if err != nil {
+		return err
+	}
+	span.AddEvent("consent granted audit logged")
+	return nil
+}
+
+func (oas oauthService) IssueAuthorizationCode(ctx context.Context, logger *zap.Logger, app models.App, authentication models.Authentication, authorizationRequest models.AuthorizationRequest, initiator string) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "IssueAuthorizationCode")
+	defer span.End()
+	authorizationCode, err := models.NewToken(authentication.UserID, models.TokenTypeAuthorizationCode, oas.getAuthorizationCodeDuration(app))
+	if err != nil {
+		evtString := "failed to create new authorization code"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.Token{}, err
+	}
+	authorizationCode.AddMetaData(models.TokenMetaDataKeyClientID, app.ClientID)
+	// the redirect uri is stored as it was provided because the token request must include the same value when it was present.
+	authorizationCode.AddMetaData(models.TokenMetaDataKeyRedirectURI, authorizationRequest.RedirectURI)
+	authorizationCode.AddMetaData(models.TokenMetaDataKeyScope, strings.Join(authorizationRequest.RequestedScopes(), " "))
+	if authorizationRequest.CodeChallenge != "" {
+		authorizationCode.AddMetaData(models.TokenMetaDataKeyCodeChallenge, authorizationRequest.CodeChallenge)
+		authorizationCode.AddMetaData(models.TokenMetaDataKeyCodeChallengeMethod, authorizationRequest.GetCodeChallengeMethod())
+	}
+	// the nonce and authentication details are kept for the id token issued when the code is exchanged.
+	if authorizationRequest.Nonce != "" {
+		authorizationCode.AddMetaData(models.TokenMetaDataKeyNonce, authorizationRequest.Nonce)
+	}
+	if !authentication.AuthTime.IsZero() {
+		authorizationCode.AddMetaData(models.TokenMetaDataKeyAuthTime, strconv.FormatInt(authentication.AuthTime.Unix(), 10))
+	}
+	if authentication.ACR != "" {
+		authorizationCode.AddMetaData(models.TokenMetaDataKeyACR, authentication.ACR)
+	}
+	err = oas.tokenService.PutToken(ctx, logger, authorizationCode)
+	if err != nil {
+		evtString := "failed to store new authorization code"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return models.Token{}, err
+	}
+	span.AddEvent("authorization code issued")
+	return authorizationCode, nil
+}
+
+func (oas oauthService) AuthenticateClient(ctx context.Context, logger *zap.Logger, clientID, clientSecret string, initiator string) (models.App, []models.Scope, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "AuthenticateClient")
+	defer span.End()
+	if clientID == "" || clientSecret == "" {
+		err := coreerrors.NewInvalidClientCredentialsError(clientID, true)
+		evtString := "client id or client secret was not provided"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.App{}, nil, err
+	}
+	app, scopes, err := oas.appService.GetAppAndScopesByClientID(ctx, logger, clientID, initiator)
+	if err != nil {
+		if coreerrors.IsNoAppFoundError(err) {
+			// an unknown client is reported the same way as a wrong secret so client ids cannot be probed
+			err = coreerrors.NewInvalidClientCredentialsError(clientID, true)
+			evtString := fmt.Sprintf("no app found for client id: %s", clientID)
+			logger.Error(evtString, zap.Reflect("error", err))
+			apptelemetry.SetSpanOriginalError(&span, err, evtString)
+			return models.App{}, nil, err
+		}
+		logger.Error("appService.GetAppAndScopesByClientID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return models.App{}, nil, err
+	}
+	span.AddEvent("app and scopes retreived")
+	if !app.MatchClientSecret(clientSecret, time.Now()) {
+		err := coreerrors.NewInvalidClientCredentialsError(clientID, true)
+		evtString := fmt.Sprintf("client secret is not valid for app: %s", app.ID)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.App{}, nil, err
+	}
+	if app.IsDisabled {
+		err := coreerrors.NewAppDisabledError(clientID, true)
+		evtString := fmt.Sprintf("app is disabled: %s", app.ID)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.App{}, nil, err
+	}
+	span.AddEvent("client authenticated")
+	return app, scopes, nil
+}
+
+func (oas oauthService) ExchangeAuthorizationCode(ctx context.Context, logger *zap.Logger, app models.App, code, redirectURI, codeVerifier string, initiator string) (models.AccessTokenResponse, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "ExchangeAuthorizationCode")
+	defer span.End()
+	err := validateGrantType(app, core.OAUTH_GRANT_TYPE_AUTHORIZATION_CODE)
+	if err != nil {
+		evtString := "grant type is not allowed for app"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	if code == "" {
+		err := coreerrors.NewMissingRequiredParameterError("code", true)
+		evtString := "authorization code was not provided"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	// authorization codes are single use, so the code is consumed before anything else is checked.
+	authorizationCode, err := oas.tokenService.ConsumeToken(ctx, logger, code, models.TokenTypeAuthorizationCode)
+	if err != nil {
+		logger.Error("tokenService.ConsumeToken call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return models.AccessTokenResponse{}, err
+	}
+	span.AddEvent("authorization code consumed")
+	if authorizationCode.MetaData[models.TokenMetaDataKeyClientID] != app.ClientID {
+		err := coreerrors.NewAuthorizationCodeClientMismatchError(app.ClientID, true)
+		evtString := fmt.Sprintf("authorization code was not issued to client: %s", app.ClientID)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	authorizationRedirectURI := authorizationCode.MetaData[models.TokenMetaDataKeyRedirectURI]
+	if authorizationRedirectURI != "" && authorizationRedirectURI != redirectURI {
+		err := coreerrors.NewAuthorizationCodeRedirectURIMismatchError(app.ClientID, redirectURI, true)
+		evtString := fmt.Sprintf("redirect uri does not match the authorization request: %s", redirectURI)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	err = verifyCodeVerifier(app, authorizationCode, codeVerifier)
+	if err != nil {
+		evtString := "code verifier is not valid for authorization code"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	span.AddEvent("authorization code validated")
+	scope := authorizationCode.MetaData[models.TokenMetaDataKeyScope]
+	var idToken string
+	if scopeNames := strings.Fields(scope); containsScope(scopeNames, core.OIDC_SCOPE_OPENID) {
+		// the id token is created first because it needs the user, so nothing is stored if the user cannot be found.
+		idToken, err = oas.issueIDToken(ctx, logger, app, authorizationCode, scopeNames, initiator)
+		if err != nil {
+			evtString := "failed to issue id token"
+			logger.Error(evtString, zap.Reflect("error", err))
+			apptelemetry.SetSpanError(&span, err, evtString)
+			return models.AccessTokenResponse{}, err
+		}
+		span.AddEvent("id token issued")
+	}
+	accessTokenResponse, accessToken, err := oas.issueAccessToken(ctx, logger, app, authorizationCode.TargetID, scope)
+	if err != nil {
+		evtString := "failed to issue access token"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	span.AddEvent("access token issued")
+	// a refresh token is only useful if the app is allowed to exchange it.
+	if app.Policy.AllowsGrantType(core.OAUTH_GRANT_TYPE_REFRESH_TOKEN) {
+		refreshToken, err := oas.issueRefreshToken(ctx, logger, app, authorizationCode.TargetID, scope, accessToken.Value)
+		if err != nil {
+			evtString := "failed to issue refresh token"
+			logger.Error(evtString, zap.Reflect("error", err))
+			apptelemetry.SetSpanError(&span, err, evtString)
+			return models.AccessTokenResponse{}, err
+		}
+		span.AddEvent("refresh token issued")
+		accessTokenResponse.RefreshToken = refreshToken.Value
+	}
+	accessTokenResponse.IDToken = idToken
+	return accessTokenResponse, nil
+}
+
+func (oas oauthService) IssueClientCredentialsToken(ctx context.Context, logger *zap.Logger, app models.App, appScopes []models.Scope, scope string, initiator string) (models.AccessTokenResponse, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "IssueClientCredentialsToken")
+	defer span.End()
+	err := validateGrantType(app, core.OAUTH_GRANT_TYPE_CLIENT_CREDENTIALS)
+	if err != nil {
+		evtString := "grant type is not allowed for app"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	requestedScopeNames := strings.Fields(scope)
+	// there is no user for the client credentials grant, so the OIDC scopes are not allowed.
+	_, err = findRequestedScopes(app, appScopes, requestedScopeNames, false)
+	if err != nil {
+		evtString := "requested scopes are not valid for app"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	span.AddEvent("requested scopes validated")
+	// the app is the subject of tokens issued with the client credentials grant.
+	accessTokenResponse, _, err := oas.issueAccessToken(ctx, logger, app, app.ClientID, strings.Join(requestedScopeNames, " "))
+	if err != nil {
+		evtString := "failed to issue access token"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	span.AddEvent("access token issued")
+	return accessTokenResponse, nil
+}
+
+func (oas oauthService) ExchangeRefreshToken(ctx context.Context, logger *zap.Logger, app models.App, refreshToken, scope string, initiator string) (models.AccessTokenResponse, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "ExchangeRefreshToken")
+	defer span.End()
+	err := validateGrantType(app, core.OAUTH_GRANT_TYPE_REFRESH_TOKEN)
+	if err != nil {
+		evtString := "grant type is not allowed for app"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	if refreshToken == "" {
+		err := coreerrors.NewMissingRequiredParameterError("refresh_token", true)
+		evtString := "refresh token was not provided"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	currentRefreshToken, err := oas.tokenService.GetToken(ctx, logger, refreshToken, models.TokenTypeRefreshToken)
+	if err != nil {
+		logger.Error("tokenService.GetToken call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return models.AccessTokenResponse{}, err
+	}
+	span.AddEvent("refresh token retreived")
+	if currentRefreshToken.MetaData[models.TokenMetaDataKeyClientID] != app.ClientID {
+		err := coreerrors.NewRefreshTokenClientMismatchError(app.ClientID, true)
+		evtString := fmt.Sprintf("refresh token was not issued to client: %s", app.ClientID)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	grantedScope := currentRefreshToken.MetaData[models.TokenMetaDataKeyScope]
+	requestedScope := grantedScope
+	if scope != "" {
+		err := validateScopeNarrowing(app, grantedScope, scope)
+		if err != nil {
+			evtString := "requested scope exceeds the scope originally granted"
+			logger.Error(evtString, zap.Reflect("error", err))
+			apptelemetry.SetSpanOriginalError(&span, err, evtString)
+			return models.AccessTokenResponse{}, err
+		}
+		requestedScope = strings.Join(strings.Fields(scope), " ")
+	}
+	span.AddEvent("refresh token validated")
+	// the new refresh token keeps the originally granted scope per https://datatracker.ietf.org/doc/html/rfc6749#section-6
+	newRefreshToken, err := oas.buildRefreshToken(app, currentRefreshToken.TargetID, grantedScope)
+	if err != nil {
+		evtString := "failed to create refresh token"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	// the current token is marked as rotated before anything is issued, so when the same refresh token is exchanged concurrently only one exchange can rotate it and the rest are treated as reuse.
+	// the rotated token is kept so reuse can be detected. its expiration is moved to match the new token so the chain to the newest token cannot be broken by an expired link.
+	previousRefreshToken, err := oas.tokenService.MarkTokenRotated(ctx, logger, currentRefreshToken.Value, newRefreshToken.Value, newRefreshToken.Expiration)
+	if err != nil {
+		evtString := "failed to mark refresh token as rotated"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	if previousRefreshToken.MetaData[models.TokenMetaDataKeyRotatedTo] != "" {
+		// the refresh token has been used before, so it may have been stolen. Every token issued from it is revoked to protect the user.
+		oas.revokeRefreshTokenFamily(ctx, logger, previousRefreshToken)
+		data := map[string]interface{}{
+			"clientId":  app.ClientID,
+			"initiator": initiator,
+		}
+		// the reuse error is returned either way, so a failure to write the audit log is only logged.
+		_ = oas.writeAuditLog(ctx, logger, &span, models.AuditLogCode_RefreshTokenReused, "rotated refresh token reused", models.AssetType_User, previousRefreshToken.TargetID, data)
+		err := coreerrors.NewRefreshTokenReusedError(app.ClientID, true)
+		evtString := fmt.Sprintf("rotated refresh token was reused by client: %s", app.ClientID)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	span.AddEvent("refresh token rotated")
+	accessTokenResponse, accessToken, err := oas.issueAccessToken(ctx, logger, app, currentRefreshToken.TargetID, requestedScope)
+	if err != nil {
+		evtString := "failed to issue access token"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	span.AddEvent("access token issued")
+	newRefreshToken.AddMetaData(models.TokenMetaDataKeyAccessToken, accessToken.Value)
+	err = oas.tokenService.PutToken(ctx, logger, newRefreshToken)
+	if err != nil {
+		evtString := "failed to issue refresh token"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	span.AddEvent("refresh token issued")
+	// if a concurrent exchange of the same refresh token revoked the family before the new tokens were stored they would be missed, so they are revoked here instead.
+	_, err = oas.tokenService.GetToken(ctx, logger, currentRefreshToken.Value, models.TokenTypeRefreshToken)
+	if err != nil {
+		oas.revokeRefreshTokenFamily(ctx, logger, newRefreshToken)
+		if err.GetErrorCode() == coreerrors.ErrCodeInvalidToken {
+			err = coreerrors.NewRefreshTokenReusedError(app.ClientID, true)
+		}
+		evtString := "refresh token family was revoked while the refresh token was exchanged"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.AccessTokenResponse{}, err
+	}
+	accessTokenResponse.RefreshToken = newRefreshToken.Value
+	return accessTokenResponse, nil
+}
+
+func (oas oauthService) GetUserInfo(ctx context.Context, logger *zap.Logger, accessToken string, initiator string) (models.UserInfo, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "GetUserInfo")
+	defer span.End()
+	if accessToken == "" {
+		err := coreerrors.NewMissingRequiredParameterError("access_token", true)
+		evtString := "access token was not provided"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.UserInfo{}, err
+	}
+	storedToken, err := oas.verifyAccessToken(ctx, logger, accessToken)
+	if err != nil {
+		evtString := "access token is not valid"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return models.UserInfo{}, err
+	}
+	span.AddEvent("access token verified")
+	scopeNames := strings.Fields(storedToken.MetaData[models.TokenMetaDataKeyScope])
+	if !containsScope(scopeNames, core.OIDC_SCOPE_OPENID) {
+		err := coreerrors.NewInsufficientScopeError(core.OIDC_SCOPE_OPENID, true)
+		evtString := "access token was not granted the openid scope"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.UserInfo{}, err
+	}
+	fullUser, err := oas.userService.GetFullUserByID(ctx, logger, storedToken.TargetID, initiator)
+	if err != nil {
+		logger.Error("userService.GetFullUserByID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return models.UserInfo{}, err
+	}
+	span.AddEvent("user info retreived")
+	return models.UserInfo{
+		Subject:    storedToken.TargetID,
+		ClientID:   storedToken.MetaData[models.TokenMetaDataKeyClientID],
+		UserClaims: fullUser.GetUserClaims(scopeNames),
+	}, nil
+}
+
+func (oas oauthService) IntrospectToken(ctx context.Context, logger *zap.Logger, app models.App, token, tokenTypeHint string, initiator string) (models.IntrospectionResponse, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "IntrospectToken")
+	defer span.End()
+	if token == "" {
+		err := coreerrors.NewMissingRequiredParameterError("token", true)
+		evtString := "token was not provided"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.IntrospectionResponse{}, err
+	}
+	// any authenticated app can introspect a token because resource servers need to introspect tokens issued to other apps.
+	storedToken, err := oas.findToken(ctx, logger, token, tokenTypeHint)
+	if err != nil {
+		if isInactiveTokenError(err) {
+			span.AddEvent("token is not active")
+			return models.IntrospectionResponse{Active: false}, nil
+		}
+		logger.Error("failed to find token", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return models.IntrospectionResponse{}, err
+	}
+	if storedToken.MetaData[models.TokenMetaDataKeyRotatedTo] != "" {
+		span.AddEvent("refresh token has been rotated")
+		return models.IntrospectionResponse{Active: false}, nil
+	}
+	tokenType := core.OAUTH_TOKEN_TYPE_BEARER
+	if storedToken.TokenType == models.TokenTypeRefreshToken {
+		tokenType = core.OAUTH_TOKEN_TYPE_HINT_REFRESH_TOKEN
+	}
+	span.AddEvent("token introspected")
+	return models.IntrospectionResponse{
+		Active:    true,
+		Scope:     storedToken.MetaData[models.TokenMetaDataKeyScope],
+		ClientID:  storedToken.MetaData[models.TokenMetaDataKeyClientID],
+		Subject:   storedToken.TargetID,
+		ExpiresAt: storedToken.Expiration.Unix(),
+		TokenType: tokenType,
+		Issuer:    oas.issuer,
+	}, nil
+}
+
+func (oas oauthService) RevokeToken(ctx context.Context, logger *zap.Logger, app models.App, token, tokenTypeHint string, initiator string) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "RevokeToken")
+	defer span.End()
+	if token == "" {
+		err := coreerrors.NewMissingRequiredParameterError("token", true)
+		evtString := "token was not provided"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	storedToken, err := oas.findToken(ctx, logger, token, tokenTypeHint)
+	if err != nil {
+		// per https://datatracker.ietf.org/doc/html/rfc7009#section-2.2 invalid tokens do not cause an error because the client cannot do anything about it.
+		if isInactiveTokenError(err) {
+			span.AddEvent("token is not active")
+			return nil
+		}
+		logger.Error("failed to find token", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	if storedToken.MetaData[models.TokenMetaDataKeyClientID] != app.ClientID {
+		data := map[string]interface{}{
+			"clientId":       app.ClientID,
+			"issuedClientId": storedToken.MetaData[models.TokenMetaDataKeyClientID],
+			"tokenType":      storedToken.TokenType.String(),
+			"initiator":      initiator,
+		}
+		// the mismatch error is returned either way, so a failure to write the audit log is only logged.
+		_ = oas.writeAuditLog(ctx, logger, &span, models.AuditLogCode_TokenClientMismatch, "client tried to revoke a token issued to another client", models.AssetType_Application, app.ID, data)
+		err := coreerrors.NewTokenClientMismatchError(app.ClientID, true)
+		evtString := fmt.Sprintf("token was not issued to client: %s", app.ClientID)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	if storedToken.TokenType == models.TokenTypeRefreshToken {
+		oas.revokeRefreshTokenFamily(ctx, logger, storedToken)
+		span.AddEvent("refresh token revoked")
+		return nil
+	}
+	err = oas.tokenService.DeleteToken(ctx, logger, storedToken.Value)
+	if err != nil {
+		evtString := "failed to delete access token"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return err
+	}
+	span.AddEvent("access token revoked")
+	return nil
+}
+
+func (oas oauthService) RevokeAppTokens(ctx context.Context, logger *zap.Logger, app models.App, initiator string) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "RevokeAppTokens")
+	defer span.End()
+	err := oas.tokenService.DeleteTokensByClientID(ctx, logger, app.ClientID)
+	if err != nil {
+		evtString := "failed to delete app tokens"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return err
+	}
+	span.AddEvent("app tokens revoked")
+	data := map[string]interface{}{
+		"clientId":  app.ClientID,
+		"initiator": initiator,
+	}
+	err = oas.writeAuditLog(ctx, logger, &span, models.AuditLogCode_AppTokensRevoked, "app tokens revoked", models.AssetType_Application, app.ID, data)
+	if err != nil {
+		return err
+	}
+	span.AddEvent("app tokens revoked audit logged")
+	return nil
+}
+
+// RevokeCompromisedClientSecret revokes the client secret first, so the secret cannot be used to get new tokens once the existing tokens are revoked.
+func (oas oauthService) RevokeCompromisedClientSecret(ctx context.Context, logger *zap.Logger, app *models.App, clientSecretID string, initiator string) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, oas.GetName(), "RevokeCompromisedClientSecret")
+	defer span.End()
+	err := oas.appService.RevokeClientSecret(ctx, logger, app, clientSecretID, initiator)
+	if err != nil {
+		logger.Error("appService.RevokeClientSecret call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	span.AddEvent("compromised client secret revoked")
+	err = oas.RevokeAppTokens(ctx, logger, *app, initiator)
+	if err != nil {
+		logger.Error("RevokeAppTokens call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	span.AddEvent("app tokens revoked for compromised client secret")
+	return nil
+}
+
+// getConsentedScopeNames returns the set of scope names the user has consented to the app being granted.
+func (oas oauthService) getConsentedScopeNames(ctx context.Context, userID, appID string) (map[string]bool, errors.RichError) {
+	consents, err := oas.consentRepo.GetConsentsByUserIDAndAppID(ctx, userID, appID)
+	if err != nil {
+		return nil, err
+	}
+	consentedScopeNames := make(map[string]bool, len(consents))
+	for _, consent := range consents {
+		consentedScopeNames[consent.ScopeName] = true
+	}
+	return consentedScopeNames, nil
+}
+
+// findToken finds the stored token for a signed access token, or an opaque access or refresh token.
+// Opaque tokens are checked as access tokens first unless the token type hint says it is a refresh token.
+func (oas oauthService) findToken(ctx context.Context, logger *zap.Logger, token, tokenTypeHint string) (models.Token, errors.RichError) {
+	if isSignedToken(token) {
+		return oas.verifyAccessToken(ctx, logger, token)
+	}
+	tokenTypes := []models.TokenType{models.TokenTypeAccessToken, models.TokenTypeRefreshToken}
+	if tokenTypeHint == core.OAUTH_TOKEN_TYPE_HINT_REFRESH_TOKEN {
+		tokenTypes = []models.TokenType{models.TokenTypeRefreshToken, models.TokenTypeAccessToken}
+	}
+	var err errors.RichError
+	for _, tokenType := range tokenTypes {
+		var storedToken models.Token
+		storedToken, err = oas.tokenService.GetToken(ctx, logger, token, tokenType)
+		if err == nil {
+			return storedToken, nil
+		}
+		if !coreerrors.IsWrongTokenTypeError(err) {
+			return models.Token{}, err
+		}
+	}
+	return models.Token{}, err
+}
+
+// verifyAccessToken returns the stored token for a signed or opaque access token.
+// Signed access tokens have their signature and lifetime verified, and the token their jti references must not have been revoked.
+func (oas oauthService) verifyAccessToken(ctx context.Context, logger *zap.Logger, accessToken string) (models.Token, errors.RichError) {
+	if !isSignedToken(accessToken) {
+		return oas.tokenService.GetToken(ctx, logger, accessToken, models.TokenTypeAccessToken)
+	}
+	var claims jwt.AccessTokenClaims
+	_, err := jwt.Verify(accessToken, oas.keyProvider, &claims)
+	if err != nil {
+		return models.Token{}, err
+	}
+	return oas.tokenService.GetToken(ctx, logger, claims.ID, models.TokenTypeAccessToken)
+}
+
+// issueAccessToken creates and stores an access token for the target issued to the app with the given scopes.
+// Unless the app policy asks for opaque access tokens the access token is a signed JWT whose jti is the value of the stored token, so it can be revoked before it expires.
+func (oas oauthService) issueAccessToken(ctx context.Context, logger *zap.Logger, app models.App, targetID, scope string) (models.AccessTokenResponse, models.Token, errors.RichError) {
+	accessTokenDuration := oas.getAccessTokenDuration(app)
+	accessToken, err := models.NewToken(targetID, models.TokenTypeAccessToken, accessTokenDuration)
+	if err != nil {
+		return models.AccessTokenResponse{}, models.Token{}, err
+	}
+	accessToken.AddMetaData(models.TokenMetaDataKeyClientID, app.ClientID)
+	accessToken.AddMetaData(models.TokenMetaDataKeyScope, scope)
+	accessTokenResponse := models.AccessTokenResponse{
+		AccessToken: accessToken.Value,
+		TokenType:   core.OAUTH_TOKEN_TYPE_BEARER,
+		ExpiresIn:   int64(accessTokenDuration.Seconds()),
+		Scope:       scope,
+	}
+	if app.Policy.UsesOpaqueAccessTokens() {
+		err = oas.tokenService.PutToken(ctx, logger, accessToken)
+		if err != nil {
+			return models.AccessTokenResponse{}, models.Token{}, err
+		}
+		return accessTokenResponse, accessToken, nil
+	}
+	signer, err := oas.keyProvider.GetSigner()
+	if err != nil {
+		return models.AccessTokenResponse{}, models.Token{}, err
+	}
+	claims := jwt.AccessTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    oas.issuer,
+			Subject:   targetID,
+			ExpiresAt: accessToken.Expiration.Unix(),
+			IssuedAt:  time.Now().Unix(),
+			ID:        accessToken.Value,
+		},
+		ClientID: app.ClientID,
+		Scope:    scope,
+	}
+	signedAccessToken, err := jwt.Sign(signer, claims)
+	if err != nil {
+		return models.AccessTokenResponse{}, models.Token{}, err
+	}
+	err = oas.tokenService.PutToken(ctx, logger, accessToken)
+	if err != nil {
+		return models.AccessTokenResponse{}, models.Token{}, err
+	}
+	accessTokenResponse.AccessToken = signedAccessToken
+	return accessTokenResponse, accessToken, nil
+}
+
+// issueIDToken creates a signed id token for the user an authorization code was issued to, including the user claims granted by the scopes.
+func (oas oauthService) issueIDToken(ctx context.Context, logger *zap.Logger, app models.App, authorizationCode models.Token, scopes []string, initiator string) (string, errors.RichError) {
+	fullUser, err := oas.userService.GetFullUserByID(ctx, logger, authorizationCode.TargetID, initiator)
+	if err != nil {
+		return "", err
+	}
+	signer, err := oas.keyProvider.GetSigner()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := jwt.IDTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    oas.issuer,
+			Subject:   authorizationCode.TargetID,
+			Audience:  app.ClientID,
+			ExpiresAt: now.Add(oas.getIDTokenDuration(app)).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		Nonce:      authorizationCode.MetaData[models.TokenMetaDataKeyNonce],
+		ACR:        authorizationCode.MetaData[models.TokenMetaDataKeyACR],
+		UserClaims: fullUser.GetUserClaims(scopes),
+	}
+	if authTime, parseErr := strconv.ParseInt(authorizationCode.MetaData[models.TokenMetaDataKeyAuthTime], 10, 64); parseErr == nil {
+		claims.AuthTime = authTime
+	}
+	return jwt.Sign(signer, claims)
+}
+
+// issueRefreshToken creates and stores a refresh token for the target issued to the app with the given scopes, along with the access token issued with it.
+func (oas oauthService) issueRefreshToken(ctx context.Context, logger *zap.Logger, app models.App, targetID, scope, accessTokenValue string) (models.Token, errors.RichError) {
+	refreshToken, err := oas.buildRefreshToken(app, targetID, scope)
+	if err != nil {
+		return models.Token{}, err
+	}
+	refreshToken.AddMetaData(models.TokenMetaDataKeyAccessToken, accessTokenValue)
+	err = oas.tokenService.PutToken(ctx, logger, refreshToken)
+	if err != nil {
+		return models.Token{}, err
+	}
+	return refreshToken, nil
+}
+
+// buildRefreshToken creates a refresh token for the app without storing it.
+func (oas oauthService) buildRefreshToken(app models.App, targetID, scope string) (models.Token, errors.RichError) {
+	refreshToken, err := models.NewToken(targetID, models.TokenTypeRefreshToken, oas.getRefreshTokenDuration(app))
+	if err != nil {
+		return models.Token{}, err
+	}
+	refreshToken.AddMetaData(models.TokenMetaDataKeyClientID, app.ClientID)
+	refreshToken.AddMetaData(models.TokenMetaDataKeyScope, scope)
+	return refreshToken, nil
+}
+
+// getAuthorizationCodeDuration returns the lifetime of authorization codes for the app, falling back to the server default.
+func (oas oauthService) getAuthorizationCodeDuration(app models.App) time.Duration {
+	if app.Policy.AuthorizationCodeDuration > 0 {
+		return app.Policy.AuthorizationCodeDuration
+	}
+	return oas.authorizationCodeDuration
+}
+
+// getAccessTokenDuration returns the lifetime of access tokens for the app, falling back to the server default.
+// Signed access tokens are capped so they are always covered by the lifetime of the keys that sign them.
+func (oas oauthService) getAccessTokenDuration(app models.App) time.Duration {
+	accessTokenDuration := oas.accessTokenDuration
+	if app.Policy.AccessTokenDuration > 0 {
+		accessTokenDuration = app.Policy.AccessTokenDuration
+	}
+	if !app.Policy.UsesOpaqueAccessTokens() && accessTokenDuration > oas.maxJWTDuration {
+		return oas.maxJWTDuration
+	}
+	return accessTokenDuration
+}
+
+// getIDTokenDuration returns the lifetime of id tokens for the app, falling back to the server access token duration.
+// id tokens are capped so they are always covered by the lifetime of the keys that sign them.
+func (oas oauthService) getIDTokenDuration(app models.App) time.Duration {
+	idTokenDuration := oas.accessTokenDuration
+	if app.Policy.IDTokenDuration > 0 {
+		idTokenDuration = app.Policy.IDTokenDuration
+	}
+	if idTokenDuration > oas.maxJWTDuration {
+		return oas.maxJWTDuration
+	}
+	return idTokenDuration
+}
+
+// getRefreshTokenDuration returns the lifetime of refresh tokens for the app, falling back to the server default.
+func (oas oauthService) getRefreshTokenDuration(app models.App) time.Duration {
+	if app.Policy.RefreshTokenDuration > 0 {
+		return app.Policy.RefreshTokenDuration
+	}
+	return oas.refreshTokenDuration
+}
+
+// revokeRefreshTokenFamily follows the chain of rotated refresh tokens starting at the given token, deleting each refresh token and the access token issued with it.
+// Failures are logged but do not stop the walk, because the rest of the family still needs to be revoked.
+func (oas oauthService) revokeRefreshTokenFamily(ctx context.Context, logger *zap.Logger, refreshToken models.Token) {
+	for {
+		if accessTokenValue := refreshToken.MetaData[models.TokenMetaDataKeyAccessToken]; accessTokenValue != "" {
+			// the access token may have already expired and been removed, so errors are expected here.
+			_ = oas.tokenService.DeleteToken(ctx, logger, accessTokenValue)
+		}
+		err := oas.tokenService.DeleteToken(ctx, logger, refreshToken.Value)
+		if err != nil {
+			logger.Error("failed to delete refresh token in family", zap.Reflect("error", err))
+		}
+		rotatedTo := refreshToken.MetaData[models.TokenMetaDataKeyRotatedTo]
+		if rotatedTo == "" {
+			return
+		}
+		refreshToken, err = oas.tokenService.GetToken(ctx, logger, rotatedTo, models.TokenTypeRefreshToken)
+		if err != nil {
+			logger.Error("failed to retreive next refresh token in family", zap.Reflect("error", err))
+			return
+		}
+	}
+}
+
+// validateScopeNarrowing ensures every scope in the requested scope was included in the granted scope.
+func validateScopeNarrowing(app models.App, grantedScope, requestedScope string) errors.RichError {
+	grantedScopeNames := make(map[string]bool)
+	for _, scopeName := range strings.Fields(grantedScope) {
+		grantedScopeNames[scopeName] = true
+	}
+	for _, scopeName := range strings.Fields(requestedScope) {
+		if !grantedScopeNames[scopeName] {
+			return coreerrors.NewInvalidScopeError(app.ClientID, scopeName, true)
+		}
+	}
+	return nil
+}
+
+// verifyCodeVerifier ensures the code verifier matches the code challenge stored with the authorization code.
+func verifyCodeVerifier(app models.App, authorizationCode models.Token, codeVerifier string) errors.RichError {
+	codeChallenge := authorizationCode.MetaData[models.TokenMetaDataKeyCodeChallenge]
+	if codeChallenge == "" {
+		if codeVerifier != "" {
+			// a code verifier without a code challenge could mean the code challenge was stripped from the authorization request.
+			return coreerrors.NewInvalidCodeVerifierError(app.ClientID, true)
+		}
+		return nil
+	}
+	if codeVerifier == "" {
+		return coreerrors.NewMissingRequiredParameterError("code_verifier", true)
+	}
+	codeChallengeMethod := authorizationCode.MetaData[models.TokenMetaDataKeyCodeChallengeMethod]
+	if !utilities.VerifyPKCECodeVerifier(codeVerifier, codeChallenge, codeChallengeMethod) {
+		return coreerrors.NewInvalidCodeVerifierError(app.ClientID, true)
+	}
+	return nil
+}
+
+// validateGrantType ensures the app policy allows the grant type.
+func validateGrantType(app models.App, grantType string) errors.RichError {
+	if !app.Policy.AllowsGrantType(grantType) {
+		return coreerrors.NewGrantTypeNotAllowedError(app.ClientID, grantType, true)
+	}
+	return nil
+}
+
+// validateCodeChallenge ensures the PKCE code challenge and method are valid, and that one was provided if the app requires it.
+func validateCodeChallenge(app models.App, authorizationRequest models.AuthorizationRequest) errors.RichError {
+	if authorizationRequest.CodeChallenge == "" {
+		if authorizationRequest.CodeChallengeMethod != "" {
+			return coreerrors.NewMissingRequiredParameterError("code_challenge", true)
+		}
+		if app.Policy.RequirePKCE {
+			return coreerrors.NewPKCERequiredError(app.ClientID, true)
+		}
+		return nil
+	}
+	codeChallengeMethod := authorizationRequest.GetCodeChallengeMethod()
+	if codeChallengeMethod != core.PKCE_CODE_CHALLENGE_METHOD_S256 && codeChallengeMethod != core.PKCE_CODE_CHALLENGE_METHOD_PLAIN {
+		return coreerrors.NewUnsupportedCodeChallengeMethodError(codeChallengeMethod, true)
+	}
+	if !utilities.IsValidPKCEValue(authorizationRequest.CodeChallenge) {
+		return coreerrors.NewInvalidCodeChallengeError(app.ClientID, true)
+	}
+	return nil
+}
+
+// findRequestedScopes maps the requested scope names to the scopes of the app, and returns an error for the first one that the app does not have.
+// When includeOIDCScopes is true the standard OIDC scopes are allowed for every app.
+func findRequestedScopes(app models.App, appScopes []models.Scope, requestedScopeNames []string, includeOIDCScopes bool) ([]models.Scope, errors.RichError) {
+	scopesByName := make(map[string]models.Scope, len(appScopes))
+	for _, scope := range appScopes {
+		scopesByName[scope.Name] = scope
+	}
+	requestedScopes := make([]models.Scope, 0, len(requestedScopeNames))
+	for _, scopeName := range requestedScopeNames {
+		scope, ok := scopesByName[scopeName]
+		if !ok {
+			description, isOIDCScope := models.OIDCScopeDescriptions[scopeName]
+			if !includeOIDCScopes || !isOIDCScope {
+				return nil, coreerrors.NewInvalidScopeError(app.ClientID, scopeName, true)
+			}
+			scope = models.NewScope(app.ID, scopeName, description)
+		}
+		requestedScopes = append(requestedScopes, scope)
+	}
+	return requestedScopes, nil
+}
+
+// containsScope returns true if the scope name is one of the scope names provided.
+func containsScope(scopeNames []string, scopeName string) bool {
+	for _, name := range scopeNames {
+		if name == scopeName {
+			return true
+		}
+	}
+	return false
+}
+
+// isSignedToken returns true if the token is a JWT. Opaque tokens never contain a period.
+func isSignedToken(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// isInactiveTokenError returns true if the error means a token is not active, as opposed to a failure to find out.
+func isInactiveTokenError(err errors.RichError) bool {
+	switch err.GetErrorCode() {
+	case coreerrors.ErrCodeInvalidToken,
+		coreerrors.ErrCodeExpiredToken,
+		coreerrors.ErrCodeWrongTokenType,
+		coreerrors.ErrCodeMalformedJWT,
+		coreerrors.ErrCodeUnsupportedJWTAlgorithm,
+		coreerrors.ErrCodeInvalidJWTSignature,
+		coreerrors.ErrCodeJWTAlgorithmMismatch,
+		coreerrors.ErrCodeNoSigningKeyFound,
+		coreerrors.ErrCodeJWTExpired,
+		coreerrors.ErrCodeJWTNotYetValid:
+		return true
+	default:
+		return false
+	}
+}
+
+// writeAuditLog writes an audit log message, and records a failure to write it on the span of the caller.
+func (oas oauthService) writeAuditLog(ctx context.Context, logger *zap.Logger, span *trace.Span, code, message, assetType, assetID string, data map[string]interface{}) errors.RichError {
+	auditLog := models.NewAuditLog(code, message, assetType, assetID, data)
+	err := oas.auditLogRepo.LogMessage(ctx, auditLog)
+	if err != nil {
+		evtString := fmt.Sprintf("failed to write audit log: %s", code)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(span, err, evtString)
+		return err
+	}
+	return nil
+}
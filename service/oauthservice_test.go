@@ -0,0 +1,1616 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/calvine/goauth/core"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/core/jwt"
+	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/nullable"
+	repo "github.com/calvine/goauth/core/repositories"
+	"github.com/calvine/goauth/core/services"
+	"github.com/calvine/goauth/core/testutilities"
+	"github.com/calvine/goauth/dataaccess/memory"
+	"github.com/calvine/richerror/errors"
+	"go.uber.org/zap/zaptest"
+)
+
+const (
+	oauthServiceTest_CreatedBy = "oauth service tests"
+	oauthServiceTest_UserID    = "oauth_service_test_user_id"
+	oauthServiceTest_UserEmail = "OAuthServiceUser@email.com"
+	oauthServiceTest_NumScopes = 3
+	oauthServiceTest_Issuer    = "https://goauth.test"
+	// values taken from https://datatracker.ietf.org/doc/html/rfc7636#appendix-B
+	oauthServiceTest_CodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	oauthServiceTest_CodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	// the lifetime of access tokens issued to the policy app
+	oauthServiceTest_PolicyAccessTokenDuration = time.Minute * 5
+)
+
+var (
+	oauthServiceTest_App               models.App
+	oauthServiceTest_AppSecret         string
+	oauthServiceTest_AppScopes         []models.Scope
+	oauthServiceTest_DisabledApp       models.App
+	oauthServiceTest_DisabledAppSecret string
+	oauthServiceTest_PKCEApp           models.App
+	oauthServiceTest_PolicyApp         models.App
+	oauthServiceTest_KeyProvider       jwt.KeyProvider
+	oauthServiceTest_TokenRepo         *heldTokenRepo
+	oauthServiceTest_AppService        services.AppService
+	oauthServiceTest_Authentication    models.Authentication
+)
+
+func TestOAuthService(t *testing.T) {
+	oauthService, tokenService := buildOAuthService(t)
+
+	t.Run("GetName", func(t *testing.T) {
+		_testOAuthServiceGetName(t, oauthService)
+	})
+
+	t.Run("ValidateAuthorizationClient", func(t *testing.T) {
+		_testValidateAuthorizationClient(t, oauthService)
+	})
+
+	t.Run("ValidateAuthorizationRequest", func(t *testing.T) {
+		_testValidateAuthorizationRequest(t, oauthService)
+	})
+
+	t.Run("Consent", func(t *testing.T) {
+		_testConsent(t, oauthService)
+	})
+
+	t.Run("IssueAuthorizationCode", func(t *testing.T) {
+		_testIssueAuthorizationCode(t, oauthService, tokenService)
+	})
+
+	t.Run("AuthenticateClient", func(t *testing.T) {
+		_testAuthenticateClient(t, oauthService)
+	})
+
+	t.Run("ExchangeAuthorizationCode", func(t *testing.T) {
+		_testExchangeAuthorizationCode(t, oauthService, tokenService)
+	})
+
+	t.Run("ExchangeAuthorizationCodeIDToken", func(t *testing.T) {
+		_testExchangeAuthorizationCodeIDToken(t, oauthService)
+	})
+
+	t.Run("GetUserInfo", func(t *testing.T) {
+		_testGetUserInfo(t, oauthService, tokenService)
+	})
+
+	t.Run("IntrospectToken", func(t *testing.T) {
+		_testIntrospectToken(t, oauthService, tokenService)
+	})
+
+	t.Run("IssueClientCredentialsToken", func(t *testing.T) {
+		_testIssueClientCredentialsToken(t, oauthService, tokenService)
+	})
+
+	t.Run("ExchangeRefreshToken", func(t *testing.T) {
+		_testExchangeRefreshToken(t, oauthService, tokenService)
+	})
+
+	t.Run("AppPolicy", func(t *testing.T) {
+		_testAppPolicy(t, oauthService)
+	})
+
+	t.Run("RevokeToken", func(t *testing.T) {
+		_testRevokeToken(t, oauthService)
+	})
+
+	t.Run("RevokeAppTokens", func(t *testing.T) {
+		_testRevokeAppTokens(t, oauthService)
+	})
+
+	// this runs last because it replaces the client secret of the app.
+	t.Run("RevokeCompromisedClientSecret", func(t *testing.T) {
+		_testRevokeCompromisedClientSecret(t, oauthService)
+	})
+}
+
+func setupOAuthServiceTestData(t *testing.T, appRepo repo.AppRepo, userRepo repo.UserRepo, contactRepo repo.ContactRepo) {
+	var err error
+	user := models.User{ID: oauthServiceTest_UserID}
+	rErr := userRepo.AddUser(context.TODO(), &user, oauthServiceTest_CreatedBy)
+	if rErr != nil {
+		t.Log(rErr.Error())
+		t.Fatalf("failed to add test user: %s", rErr.GetErrorCode())
+	}
+	contact := models.NewContact(user.ID, "", oauthServiceTest_UserEmail, core.CONTACT_TYPE_EMAIL, true)
+	contact.ConfirmedDate.Set(time.Now().Add(-time.Minute))
+	rErr = contactRepo.AddContact(context.TODO(), &contact, oauthServiceTest_CreatedBy)
+	if rErr != nil {
+		t.Log(rErr.Error())
+		t.Fatalf("failed to add test contact: %s", rErr.GetErrorCode())
+	}
+	oauthServiceTest_Authentication = models.Authentication{
+		UserID:   user.ID,
+		AuthTime: time.Now().Add(-time.Minute),
+		ACR:      core.OIDC_ACR_PASSWORD,
+	}
+	oauthServiceTest_App, oauthServiceTest_AppSecret, err = models.NewApp("oauth service owner", "oauth app", []string{"https://oauth.app/callback", "http://127.0.0.1/callback", "app.oauth.native:/callback"}, "https://oauth.app/logo.png")
+	if err != nil {
+		t.Fatalf("failed to create test app: %s", err.Error())
+	}
+	rErr = appRepo.AddApp(context.TODO(), &oauthServiceTest_App, oauthServiceTest_CreatedBy)
+	if rErr != nil {
+		t.Log(rErr.Error())
+		t.Fatalf("failed to add test app: %s", rErr.GetErrorCode())
+	}
+	oauthServiceTest_AppScopes = make([]models.Scope, 0, oauthServiceTest_NumScopes)
+	for i := 1; i <= oauthServiceTest_NumScopes; i++ {
+		scope := models.NewScope(oauthServiceTest_App.ID, fmt.Sprintf("oauth_scope_%d", i), fmt.Sprintf("oauth scope %d", i))
+		rErr = appRepo.AddScope(context.TODO(), &scope, oauthServiceTest_CreatedBy)
+		if rErr != nil {
+			t.Log(rErr.Error())
+			t.Fatalf("failed to add test scope: %s", rErr.GetErrorCode())
+		}
+		oauthServiceTest_AppScopes = append(oauthServiceTest_AppScopes, scope)
+	}
+	oauthServiceTest_DisabledApp, oauthServiceTest_DisabledAppSecret, err = models.NewApp("oauth service owner", "disabled oauth app", []string{"https://disabled.app/callback"}, "https://disabled.app/logo.png")
+	if err != nil {
+		t.Fatalf("failed to create test app: %s", err.Error())
+	}
+	oauthServiceTest_DisabledApp.IsDisabled = true
+	rErr = appRepo.AddApp(context.TODO(), &oauthServiceTest_DisabledApp, oauthServiceTest_CreatedBy)
+	if rErr != nil {
+		t.Log(rErr.Error())
+		t.Fatalf("failed to add test app: %s", rErr.GetErrorCode())
+	}
+	oauthServiceTest_PKCEApp, _, err = models.NewApp("oauth service owner", "pkce oauth app", []string{"https://pkce.app/callback"}, "https://pkce.app/logo.png")
+	if err != nil {
+		t.Fatalf("failed to create test app: %s", err.Error())
+	}
+	oauthServiceTest_PKCEApp.Policy.RequirePKCE = true
+	rErr = appRepo.AddApp(context.TODO(), &oauthServiceTest_PKCEApp, oauthServiceTest_CreatedBy)
+	if rErr != nil {
+		t.Log(rErr.Error())
+		t.Fatalf("failed to add test app: %s", rErr.GetErrorCode())
+	}
+	oauthServiceTest_PolicyApp, _, err = models.NewApp("oauth service owner", "policy oauth app", []string{"https://policy.app/callback"}, "https://policy.app/logo.png")
+	if err != nil {
+		t.Fatalf("failed to create test app: %s", err.Error())
+	}
+	oauthServiceTest_PolicyApp.Policy = models.AppPolicy{
+		AllowedGrantTypes:   []string{core.OAUTH_GRANT_TYPE_AUTHORIZATION_CODE},
+		AccessTokenDuration: oauthServiceTest_PolicyAccessTokenDuration,
+		AccessTokenFormat:   core.OAUTH_ACCESS_TOKEN_FORMAT_OPAQUE,
+	}
+	rErr = appRepo.AddApp(context.TODO(), &oauthServiceTest_PolicyApp, oauthServiceTest_CreatedBy)
+	if rErr != nil {
+		t.Log(rErr.Error())
+		t.Fatalf("failed to add test app: %s", rErr.GetErrorCode())
+	}
+}
+
+// heldTokenRepo can hold reads of a token until a number of callers have read it, so concurrent requests for the same token are forced to overlap.
+type heldTokenRepo struct {
+	repo.TokenRepo
+	lock       sync.Mutex
+	tokenValue string
+	numReaders int
+	release    chan struct{}
+}
+
+// holdReads holds reads of the token until numReaders callers have read it. Later reads are not held.
+func (htr *heldTokenRepo) holdReads(tokenValue string, numReaders int) {
+	htr.lock.Lock()
+	defer htr.lock.Unlock()
+	htr.tokenValue = tokenValue
+	htr.numReaders = numReaders
+	htr.release = make(chan struct{})
+}
+
+func (htr *heldTokenRepo) GetToken(ctx context.Context, tokenValue string) (models.Token, errors.RichError) {
+	token, err := htr.TokenRepo.GetToken(ctx, tokenValue)
+	// the memory repo shares the meta data map with the stored token, it is copied so each reader has its own copy like it would from the other repos.
+	if token.MetaData != nil {
+		metaData := make(map[string]string, len(token.MetaData))
+		for key, value := range token.MetaData {
+			metaData[key] = value
+		}
+		token.MetaData = metaData
+	}
+	htr.lock.Lock()
+	var release chan struct{}
+	if tokenValue == htr.tokenValue && htr.numReaders > 0 {
+		release = htr.release
+		htr.numReaders--
+		if htr.numReaders == 0 {
+			close(release)
+		}
+	}
+	htr.lock.Unlock()
+	if release != nil {
+		<-release
+	}
+	return token, err
+}
+
+func buildOAuthService(t *testing.T) (services.OAuthService, services.TokenService) {
+	appRepo := memory.NewMemoryAppRepo()
+	auditLogRepo := memory.NewMemoryAuditLogRepo(false)
+	oauthServiceTest_TokenRepo = &heldTokenRepo{TokenRepo: memory.NewMemoryTokenRepo(context.TODO())}
+	tokenRepo := oauthServiceTest_TokenRepo
+	consentRepo := memory.NewMemoryConsentRepo()
+	userStore := memory.NewUserStore()
+	userRepo, rErr := memory.NewMemoryUserRepo(userStore)
+	if rErr != nil {
+		t.Fatalf("failed to create user repo: %s", rErr.GetErrorCode())
+	}
+	contactRepo, rErr := memory.NewMemoryContactRepo(userStore)
+	if rErr != nil {
+		t.Fatalf("failed to create contact repo: %s", rErr.GetErrorCode())
+	}
+	appService := NewAppService(appRepo, auditLogRepo)
+	oauthServiceTest_AppService = appService
+	tokenService := NewTokenService(tokenRepo)
+	emailService, _ := NewEmailService(StackEmailService, nil)
+	userService := NewUserService(userRepo, contactRepo, tokenService, emailService, nil, nil, "")
+	setupOAuthServiceTestData(t, appRepo, userRepo, contactRepo)
+	signingKey, err := jwt.GenerateSigningKey(jwt.AlgorithmES256, "")
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to generate signing key: %s", err.GetErrorCode())
+	}
+	oauthServiceTest_KeyProvider = jwt.NewStaticKeyProvider(signingKey)
+	options := OAuthServiceOptions{
+		AppService:   appService,
+		UserService:  userService,
+		TokenService: tokenService,
+		ConsentRepo:  consentRepo,
+		AuditLogRepo: auditLogRepo,
+		KeyProvider:  oauthServiceTest_KeyProvider,
+		Issuer:       oauthServiceTest_Issuer,
+	}
+	return NewOAuthService(options), tokenService
+}
+
+func _testOAuthServiceGetName(t *testing.T, oauthService services.OAuthService) {
+	serviceName := oauthService.GetName()
+	expectedServiceName := "oauthService"
+	if serviceName != expectedServiceName {
+		t.Errorf("service name is not what was expected: got %s - expected %s", serviceName, expectedServiceName)
+	}
+}
+
+func _testValidateAuthorizationClient(t *testing.T, oauthService services.OAuthService) {
+	testCases := []struct {
+		baseData            testutilities.BaseTestCase
+		clientID            string
+		redirectURI         string
+		expectedRedirectURI string
+	}{
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success",
+			},
+			clientID:            oauthServiceTest_App.ClientID,
+			redirectURI:         oauthServiceTest_App.RedirectURIs[0],
+			expectedRedirectURI: oauthServiceTest_App.RedirectURIs[0],
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success loopback redirect uri with port",
+			},
+			clientID:            oauthServiceTest_App.ClientID,
+			redirectURI:         "http://127.0.0.1:51004/callback",
+			expectedRedirectURI: "http://127.0.0.1:51004/callback",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success private-use scheme redirect uri",
+			},
+			clientID:            oauthServiceTest_App.ClientID,
+			redirectURI:         "app.oauth.native:/callback",
+			expectedRedirectURI: "app.oauth.native:/callback",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success redirect uri omitted with one registered",
+			},
+			clientID:            oauthServiceTest_PKCEApp.ClientID,
+			expectedRedirectURI: oauthServiceTest_PKCEApp.RedirectURIs[0],
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeMissingRequiredParameter,
+				Name:              "failure redirect uri omitted with many registered",
+			},
+			clientID: oauthServiceTest_App.ClientID,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeMissingRequiredParameter,
+				Name:              "failure client id missing",
+			},
+			redirectURI: oauthServiceTest_App.RedirectURIs[0],
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeNoAppFound,
+				Name:              "failure unknown client id",
+			},
+			clientID:    "not a real client id",
+			redirectURI: oauthServiceTest_App.RedirectURIs[0],
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidRedirectURI,
+				Name:              "failure redirect uri not registered",
+			},
+			clientID:    oauthServiceTest_App.ClientID,
+			redirectURI: "https://evil.app/callback",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidRedirectURI,
+				Name:              "failure loopback redirect uri path does not match",
+			},
+			clientID:    oauthServiceTest_App.ClientID,
+			redirectURI: "http://127.0.0.1:51004/other",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidRedirectURI,
+				Name:              "failure redirect uri differs only by trailing slash",
+			},
+			clientID:    oauthServiceTest_App.ClientID,
+			redirectURI: "https://oauth.app/callback/",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeAppDisabled,
+				Name:              "failure app disabled",
+			},
+			clientID:    oauthServiceTest_DisabledApp.ClientID,
+			redirectURI: oauthServiceTest_DisabledApp.RedirectURIs[0],
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.baseData.Name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			app, scopes, redirectURI, err := oauthService.ValidateAuthorizationClient(context.TODO(), logger, tt.clientID, tt.redirectURI, oauthServiceTest_CreatedBy)
+			testutilities.PerformErrorCheck(t, tt.baseData, err)
+			if err == nil {
+				if tt.baseData.ExpectedError {
+					t.Fatalf("expected error %s but got none", tt.baseData.ExpectedErrorCode)
+				}
+				if app.ClientID != tt.clientID {
+					t.Errorf("returned app client id does not match expected value: got %s - expected %s", app.ClientID, tt.clientID)
+				}
+				if app.ID == oauthServiceTest_App.ID && len(scopes) != oauthServiceTest_NumScopes {
+					t.Errorf("number of scopes returned does not match expected value: got %d - expected %d", len(scopes), oauthServiceTest_NumScopes)
+				}
+				if redirectURI != tt.expectedRedirectURI {
+					t.Errorf("redirect uri returned does not match expected value: got %s - expected %s", redirectURI, tt.expectedRedirectURI)
+				}
+			}
+		})
+	}
+}
+
+func _testValidateAuthorizationRequest(t *testing.T, oauthService services.OAuthService) {
+	testCases := []struct {
+		baseData            testutilities.BaseTestCase
+		app                 models.App
+		authRequest         models.AuthorizationRequest
+		expectedScopeNumber int
+	}{
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:     oauthServiceTest_App.ClientID,
+				ResponseType: core.OAUTH_RESPONSE_TYPE_CODE,
+				Scope:        fmt.Sprintf("%s %s", oauthServiceTest_AppScopes[0].Name, oauthServiceTest_AppScopes[1].Name),
+			},
+			expectedScopeNumber: 2,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success no scopes requested",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:     oauthServiceTest_App.ClientID,
+				ResponseType: core.OAUTH_RESPONSE_TYPE_CODE,
+			},
+			expectedScopeNumber: 0,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success with OIDC scopes the app did not register",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:     oauthServiceTest_App.ClientID,
+				ResponseType: core.OAUTH_RESPONSE_TYPE_CODE,
+				Scope:        fmt.Sprintf("%s %s %s", core.OIDC_SCOPE_OPENID, core.OIDC_SCOPE_PROFILE, oauthServiceTest_AppScopes[0].Name),
+			},
+			expectedScopeNumber: 3,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success with S256 code challenge",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:            oauthServiceTest_App.ClientID,
+				ResponseType:        core.OAUTH_RESPONSE_TYPE_CODE,
+				CodeChallenge:       oauthServiceTest_CodeChallenge,
+				CodeChallengeMethod: core.PKCE_CODE_CHALLENGE_METHOD_S256,
+			},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success with plain code challenge for app requiring pkce",
+			},
+			app: oauthServiceTest_PKCEApp,
+			authRequest: models.AuthorizationRequest{
+				ClientID:      oauthServiceTest_PKCEApp.ClientID,
+				ResponseType:  core.OAUTH_RESPONSE_TYPE_CODE,
+				CodeChallenge: oauthServiceTest_CodeVerifier,
+			},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodePKCERequired,
+				Name:              "failure code challenge missing for app requiring pkce",
+			},
+			app: oauthServiceTest_PKCEApp,
+			authRequest: models.AuthorizationRequest{
+				ClientID:     oauthServiceTest_PKCEApp.ClientID,
+				ResponseType: core.OAUTH_RESPONSE_TYPE_CODE,
+			},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeMissingRequiredParameter,
+				Name:              "failure code challenge method without code challenge",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:            oauthServiceTest_App.ClientID,
+				ResponseType:        core.OAUTH_RESPONSE_TYPE_CODE,
+				CodeChallengeMethod: core.PKCE_CODE_CHALLENGE_METHOD_S256,
+			},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeUnsupportedCodeChallengeMethod,
+				Name:              "failure code challenge method not supported",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:            oauthServiceTest_App.ClientID,
+				ResponseType:        core.OAUTH_RESPONSE_TYPE_CODE,
+				CodeChallenge:       oauthServiceTest_CodeChallenge,
+				CodeChallengeMethod: "S512",
+			},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidCodeChallenge,
+				Name:              "failure code challenge too short",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:            oauthServiceTest_App.ClientID,
+				ResponseType:        core.OAUTH_RESPONSE_TYPE_CODE,
+				CodeChallenge:       "too_short",
+				CodeChallengeMethod: core.PKCE_CODE_CHALLENGE_METHOD_S256,
+			},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeMissingRequiredParameter,
+				Name:              "failure response type missing",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID: oauthServiceTest_App.ClientID,
+			},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeUnsupportedResponseType,
+				Name:              "failure response type not supported",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:     oauthServiceTest_App.ClientID,
+				ResponseType: "token",
+			},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidScope,
+				Name:              "failure scope not valid for app",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:     oauthServiceTest_App.ClientID,
+				ResponseType: core.OAUTH_RESPONSE_TYPE_CODE,
+				Scope:        fmt.Sprintf("%s not_a_real_scope", oauthServiceTest_AppScopes[0].Name),
+			},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.baseData.Name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			app := tt.app
+			if app.ID == "" {
+				app = oauthServiceTest_App
+			}
+			scopes, err := oauthService.ValidateAuthorizationRequest(context.TODO(), logger, app, oauthServiceTest_AppScopes, tt.authRequest, oauthServiceTest_CreatedBy)
+			testutilities.PerformErrorCheck(t, tt.baseData, err)
+			if err == nil {
+				if tt.baseData.ExpectedError {
+					t.Fatalf("expected error %s but got none", tt.baseData.ExpectedErrorCode)
+				}
+				if len(scopes) != tt.expectedScopeNumber {
+					t.Errorf("number of scopes returned does not match expected value: got %d - expected %d", len(scopes), tt.expectedScopeNumber)
+				}
+			}
+		})
+	}
+}
+
+func _testConsent(t *testing.T, oauthService services.OAuthService) {
+	logger := zaptest.NewLogger(t)
+	openIDScope := models.NewScope(oauthServiceTest_App.ID, core.OIDC_SCOPE_OPENID, models.OIDCScopeDescriptions[core.OIDC_SCOPE_OPENID])
+	scopes := []models.Scope{oauthServiceTest_AppScopes[0], oauthServiceTest_AppScopes[1], openIDScope}
+	missingConsent, err := oauthService.GetMissingConsent(context.TODO(), logger, oauthServiceTest_UserID, oauthServiceTest_App, scopes, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get missing consent: %s", err.GetErrorCode())
+	}
+	if len(missingConsent) != len(scopes) {
+		t.Errorf("expected every scope to be missing consent before any is granted: got %d - expected %d", len(missingConsent), len(scopes))
+	}
+	err = oauthService.GrantConsent(context.TODO(), logger, oauthServiceTest_UserID, oauthServiceTest_App, []models.Scope{scopes[0], scopes[2]}, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to grant consent: %s", err.GetErrorCode())
+	}
+	missingConsent, err = oauthService.GetMissingConsent(context.TODO(), logger, oauthServiceTest_UserID, oauthServiceTest_App, scopes, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get missing consent: %s", err.GetErrorCode())
+	}
+	if len(missingConsent) != 1 || missingConsent[0].Name != scopes[1].Name {
+		t.Errorf("expected only %s to be missing consent but got: %v", scopes[1].Name, missingConsent)
+	}
+	err = oauthService.GrantConsent(context.TODO(), logger, oauthServiceTest_UserID, oauthServiceTest_App, scopes, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to grant consent: %s", err.GetErrorCode())
+	}
+	missingConsent, err = oauthService.GetMissingConsent(context.TODO(), logger, oauthServiceTest_UserID, oauthServiceTest_App, scopes, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get missing consent: %s", err.GetErrorCode())
+	}
+	if len(missingConsent) != 0 {
+		t.Errorf("expected no scopes to be missing consent after every scope is granted but got: %v", missingConsent)
+	}
+	// consent is given to a specific app, so it does not carry over to other apps.
+	missingConsent, err = oauthService.GetMissingConsent(context.TODO(), logger, oauthServiceTest_UserID, oauthServiceTest_PKCEApp, scopes, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get missing consent: %s", err.GetErrorCode())
+	}
+	if len(missingConsent) != len(scopes) {
+		t.Errorf("expected every scope to be missing consent for another app: got %d - expected %d", len(missingConsent), len(scopes))
+	}
+}
+
+func _testIssueAuthorizationCode(t *testing.T, oauthService services.OAuthService, tokenService services.TokenService) {
+	logger := zaptest.NewLogger(t)
+	authRequest := models.AuthorizationRequest{
+		ClientID:     oauthServiceTest_App.ClientID,
+		RedirectURI:  oauthServiceTest_App.RedirectURIs[0],
+		ResponseType: core.OAUTH_RESPONSE_TYPE_CODE,
+		Scope:        oauthServiceTest_AppScopes[0].Name,
+		State:        "some state",
+		// the code challenge method is omitted to ensure it defaults to plain
+		CodeChallenge: oauthServiceTest_CodeVerifier,
+		Nonce:         "some nonce",
+	}
+	code, err := oauthService.IssueAuthorizationCode(context.TODO(), logger, oauthServiceTest_App, oauthServiceTest_Authentication, authRequest, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to issue authorization code: %s", err.GetErrorCode())
+	}
+	storedCode, err := tokenService.GetToken(context.TODO(), logger, code.Value, models.TokenTypeAuthorizationCode)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to retreive issued authorization code: %s", err.GetErrorCode())
+	}
+	if storedCode.TargetID != oauthServiceTest_UserID {
+		t.Errorf("authorization code target id does not match expected value: got %s - expected %s", storedCode.TargetID, oauthServiceTest_UserID)
+	}
+	if storedCode.MetaData[models.TokenMetaDataKeyClientID] != authRequest.ClientID {
+		t.Errorf("authorization code client id does not match expected value: got %s - expected %s", storedCode.MetaData[models.TokenMetaDataKeyClientID], authRequest.ClientID)
+	}
+	if storedCode.MetaData[models.TokenMetaDataKeyRedirectURI] != authRequest.RedirectURI {
+		t.Errorf("authorization code redirect uri does not match expected value: got %s - expected %s", storedCode.MetaData[models.TokenMetaDataKeyRedirectURI], authRequest.RedirectURI)
+	}
+	if storedCode.MetaData[models.TokenMetaDataKeyScope] != authRequest.Scope {
+		t.Errorf("authorization code scope does not match expected value: got %s - expected %s", storedCode.MetaData[models.TokenMetaDataKeyScope], authRequest.Scope)
+	}
+	if storedCode.MetaData[models.TokenMetaDataKeyCodeChallenge] != authRequest.CodeChallenge {
+		t.Errorf("authorization code code challenge does not match expected value: got %s - expected %s", storedCode.MetaData[models.TokenMetaDataKeyCodeChallenge], authRequest.CodeChallenge)
+	}
+	if storedCode.MetaData[models.TokenMetaDataKeyNonce] != authRequest.Nonce {
+		t.Errorf("authorization code nonce does not match expected value: got %s - expected %s", storedCode.MetaData[models.TokenMetaDataKeyNonce], authRequest.Nonce)
+	}
+	expectedAuthTime := strconv.FormatInt(oauthServiceTest_Authentication.AuthTime.Unix(), 10)
+	if storedCode.MetaData[models.TokenMetaDataKeyAuthTime] != expectedAuthTime {
+		t.Errorf("authorization code auth time does not match expected value: got %s - expected %s", storedCode.MetaData[models.TokenMetaDataKeyAuthTime], expectedAuthTime)
+	}
+	if storedCode.MetaData[models.TokenMetaDataKeyACR] != oauthServiceTest_Authentication.ACR {
+		t.Errorf("authorization code acr does not match expected value: got %s - expected %s", storedCode.MetaData[models.TokenMetaDataKeyACR], oauthServiceTest_Authentication.ACR)
+	}
+	if storedCode.MetaData[models.TokenMetaDataKeyCodeChallengeMethod] != core.PKCE_CODE_CHALLENGE_METHOD_PLAIN {
+		t.Errorf("authorization code code challenge method does not match expected value: got %s - expected %s", storedCode.MetaData[models.TokenMetaDataKeyCodeChallengeMethod], core.PKCE_CODE_CHALLENGE_METHOD_PLAIN)
+	}
+}
+
+func _testAuthenticateClient(t *testing.T, oauthService services.OAuthService) {
+	testCases := []struct {
+		baseData     testutilities.BaseTestCase
+		clientID     string
+		clientSecret string
+	}{
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success",
+			},
+			clientID:     oauthServiceTest_App.ClientID,
+			clientSecret: oauthServiceTest_AppSecret,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidClientCredentials,
+				Name:              "failure client secret missing",
+			},
+			clientID: oauthServiceTest_App.ClientID,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidClientCredentials,
+				Name:              "failure wrong client secret",
+			},
+			clientID:     oauthServiceTest_App.ClientID,
+			clientSecret: oauthServiceTest_DisabledAppSecret,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidClientCredentials,
+				Name:              "failure unknown client id",
+			},
+			clientID:     "not a real client id",
+			clientSecret: oauthServiceTest_AppSecret,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeAppDisabled,
+				Name:              "failure app disabled",
+			},
+			clientID:     oauthServiceTest_DisabledApp.ClientID,
+			clientSecret: oauthServiceTest_DisabledAppSecret,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.baseData.Name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			app, _, err := oauthService.AuthenticateClient(context.TODO(), logger, tt.clientID, tt.clientSecret, oauthServiceTest_CreatedBy)
+			testutilities.PerformErrorCheck(t, tt.baseData, err)
+			if err == nil {
+				if tt.baseData.ExpectedError {
+					t.Fatalf("expected error %s but got none", tt.baseData.ExpectedErrorCode)
+				}
+				if app.ID != oauthServiceTest_App.ID {
+					t.Errorf("returned app id does not match expected value: got %s - expected %s", app.ID, oauthServiceTest_App.ID)
+				}
+			}
+		})
+	}
+}
+
+func _testExchangeAuthorizationCode(t *testing.T, oauthService services.OAuthService, tokenService services.TokenService) {
+	testCases := []struct {
+		baseData     testutilities.BaseTestCase
+		authRequest  models.AuthorizationRequest
+		app          models.App
+		redirectURI  string
+		codeVerifier string
+	}{
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:    oauthServiceTest_App.ClientID,
+				RedirectURI: oauthServiceTest_App.RedirectURIs[0],
+				Scope:       oauthServiceTest_AppScopes[0].Name,
+			},
+			app:         oauthServiceTest_App,
+			redirectURI: oauthServiceTest_App.RedirectURIs[0],
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success with pkce",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:            oauthServiceTest_App.ClientID,
+				CodeChallenge:       oauthServiceTest_CodeChallenge,
+				CodeChallengeMethod: core.PKCE_CODE_CHALLENGE_METHOD_S256,
+			},
+			app:          oauthServiceTest_App,
+			codeVerifier: oauthServiceTest_CodeVerifier,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeAuthorizationCodeClientMismatch,
+				Name:              "failure code issued to another client",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID: oauthServiceTest_PKCEApp.ClientID,
+			},
+			app: oauthServiceTest_App,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeAuthorizationCodeRedirectURIMismatch,
+				Name:              "failure redirect uri does not match",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:    oauthServiceTest_App.ClientID,
+				RedirectURI: oauthServiceTest_App.RedirectURIs[0],
+			},
+			app: oauthServiceTest_App,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeMissingRequiredParameter,
+				Name:              "failure code verifier missing",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:            oauthServiceTest_App.ClientID,
+				CodeChallenge:       oauthServiceTest_CodeChallenge,
+				CodeChallengeMethod: core.PKCE_CODE_CHALLENGE_METHOD_S256,
+			},
+			app: oauthServiceTest_App,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidCodeVerifier,
+				Name:              "failure code verifier does not match",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID:            oauthServiceTest_App.ClientID,
+				CodeChallenge:       oauthServiceTest_CodeChallenge,
+				CodeChallengeMethod: core.PKCE_CODE_CHALLENGE_METHOD_S256,
+			},
+			app:          oauthServiceTest_App,
+			codeVerifier: oauthServiceTest_CodeChallenge,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidCodeVerifier,
+				Name:              "failure code verifier without code challenge",
+			},
+			authRequest: models.AuthorizationRequest{
+				ClientID: oauthServiceTest_App.ClientID,
+			},
+			app:          oauthServiceTest_App,
+			codeVerifier: oauthServiceTest_CodeVerifier,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.baseData.Name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			// the code is issued to the app the authorization request was made for
+			issuingApp := oauthServiceTest_App
+			if tt.authRequest.ClientID == oauthServiceTest_PKCEApp.ClientID {
+				issuingApp = oauthServiceTest_PKCEApp
+			}
+			code, err := oauthService.IssueAuthorizationCode(context.TODO(), logger, issuingApp, oauthServiceTest_Authentication, tt.authRequest, oauthServiceTest_CreatedBy)
+			if err != nil {
+				t.Log(err.Error())
+				t.Fatalf("failed to issue authorization code: %s", err.GetErrorCode())
+			}
+			accessTokenResponse, err := oauthService.ExchangeAuthorizationCode(context.TODO(), logger, tt.app, code.Value, tt.redirectURI, tt.codeVerifier, oauthServiceTest_CreatedBy)
+			testutilities.PerformErrorCheck(t, tt.baseData, err)
+			// the authorization code must be consumed even if the exchange fails
+			_, codeErr := tokenService.GetToken(context.TODO(), logger, code.Value, models.TokenTypeAuthorizationCode)
+			if codeErr == nil {
+				t.Error("authorization code was not consumed")
+			}
+			if err == nil {
+				if tt.baseData.ExpectedError {
+					t.Fatalf("expected error %s but got none", tt.baseData.ExpectedErrorCode)
+				}
+				if accessTokenResponse.TokenType != core.OAUTH_TOKEN_TYPE_BEARER {
+					t.Errorf("token type does not match expected value: got %s - expected %s", accessTokenResponse.TokenType, core.OAUTH_TOKEN_TYPE_BEARER)
+				}
+				if accessTokenResponse.Scope != tt.authRequest.Scope {
+					t.Errorf("scope does not match expected value: got %s - expected %s", accessTokenResponse.Scope, tt.authRequest.Scope)
+				}
+				if accessTokenResponse.IDToken != "" {
+					t.Error("id token should not be issued when the openid scope was not granted")
+				}
+				accessToken, err := tokenService.GetToken(context.TODO(), logger, getAccessTokenIDForOAuthServiceTest(t, accessTokenResponse.AccessToken), models.TokenTypeAccessToken)
+				if err != nil {
+					t.Log(err.Error())
+					t.Fatalf("failed to retreive issued access token: %s", err.GetErrorCode())
+				}
+				if accessToken.TargetID != oauthServiceTest_UserID {
+					t.Errorf("access token target id does not match expected value: got %s - expected %s", accessToken.TargetID, oauthServiceTest_UserID)
+				}
+				refreshToken, err := tokenService.GetToken(context.TODO(), logger, accessTokenResponse.RefreshToken, models.TokenTypeRefreshToken)
+				if err != nil {
+					t.Log(err.Error())
+					t.Fatalf("failed to retreive issued refresh token: %s", err.GetErrorCode())
+				}
+				if refreshToken.MetaData[models.TokenMetaDataKeyAccessToken] != accessToken.Value {
+					t.Errorf("refresh token access token does not match expected value: got %s - expected %s", refreshToken.MetaData[models.TokenMetaDataKeyAccessToken], accessToken.Value)
+				}
+				// authorization codes must only be usable once
+				_, err = oauthService.ExchangeAuthorizationCode(context.TODO(), logger, tt.app, code.Value, tt.redirectURI, tt.codeVerifier, oauthServiceTest_CreatedBy)
+				if err == nil {
+					t.Error("authorization code was exchanged more than once")
+				}
+			}
+		})
+	}
+}
+
+func _testExchangeAuthorizationCodeIDToken(t *testing.T, oauthService services.OAuthService) {
+	logger := zaptest.NewLogger(t)
+	authRequest := models.AuthorizationRequest{
+		ClientID: oauthServiceTest_App.ClientID,
+		Scope:    fmt.Sprintf("%s %s %s", core.OIDC_SCOPE_OPENID, core.OIDC_SCOPE_EMAIL, oauthServiceTest_AppScopes[0].Name),
+		Nonce:    "id token nonce",
+	}
+	code, err := oauthService.IssueAuthorizationCode(context.TODO(), logger, oauthServiceTest_App, oauthServiceTest_Authentication, authRequest, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to issue authorization code: %s", err.GetErrorCode())
+	}
+	accessTokenResponse, err := oauthService.ExchangeAuthorizationCode(context.TODO(), logger, oauthServiceTest_App, code.Value, "", "", oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to exchange authorization code: %s", err.GetErrorCode())
+	}
+	if accessTokenResponse.IDToken == "" {
+		t.Fatal("id token was not issued when the openid scope was granted")
+	}
+	var claims jwt.IDTokenClaims
+	_, err = jwt.Verify(accessTokenResponse.IDToken, oauthServiceTest_KeyProvider, &claims)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to verify id token: %s", err.GetErrorCode())
+	}
+	if claims.Issuer != oauthServiceTest_Issuer {
+		t.Errorf("id token issuer does not match expected value: got %s - expected %s", claims.Issuer, oauthServiceTest_Issuer)
+	}
+	if claims.Subject != oauthServiceTest_UserID {
+		t.Errorf("id token subject does not match expected value: got %s - expected %s", claims.Subject, oauthServiceTest_UserID)
+	}
+	if claims.Audience != oauthServiceTest_App.ClientID {
+		t.Errorf("id token audience does not match expected value: got %s - expected %s", claims.Audience, oauthServiceTest_App.ClientID)
+	}
+	if claims.Nonce != authRequest.Nonce {
+		t.Errorf("id token nonce does not match expected value: got %s - expected %s", claims.Nonce, authRequest.Nonce)
+	}
+	if claims.AuthTime != oauthServiceTest_Authentication.AuthTime.Unix() {
+		t.Errorf("id token auth time does not match expected value: got %d - expected %d", claims.AuthTime, oauthServiceTest_Authentication.AuthTime.Unix())
+	}
+	if claims.ACR != oauthServiceTest_Authentication.ACR {
+		t.Errorf("id token acr does not match expected value: got %s - expected %s", claims.ACR, oauthServiceTest_Authentication.ACR)
+	}
+	if claims.Email != oauthServiceTest_UserEmail {
+		t.Errorf("id token email does not match expected value: got %s - expected %s", claims.Email, oauthServiceTest_UserEmail)
+	}
+	if claims.EmailVerified == nil || !*claims.EmailVerified {
+		t.Error("id token email_verified should be true for a confirmed primary email")
+	}
+	if claims.Name != "" {
+		t.Errorf("id token should not include profile claims when the profile scope was not granted: got %s", claims.Name)
+	}
+}
+
+func _testGetUserInfo(t *testing.T, oauthService services.OAuthService, tokenService services.TokenService) {
+	logger := zaptest.NewLogger(t)
+	openIDResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, fmt.Sprintf("%s %s", core.OIDC_SCOPE_OPENID, core.OIDC_SCOPE_EMAIL))
+	noOpenIDResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, core.OIDC_SCOPE_EMAIL)
+	revokedResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, core.OIDC_SCOPE_OPENID)
+	rErr := tokenService.DeleteToken(context.TODO(), logger, getAccessTokenIDForOAuthServiceTest(t, revokedResponse.AccessToken))
+	if rErr != nil {
+		t.Log(rErr.Error())
+		t.Fatalf("failed to revoke access token: %s", rErr.GetErrorCode())
+	}
+	testCases := []struct {
+		baseData      testutilities.BaseTestCase
+		accessToken   string
+		expectedEmail string
+	}{
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success",
+			},
+			accessToken:   openIDResponse.AccessToken,
+			expectedEmail: oauthServiceTest_UserEmail,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInsufficientScope,
+				Name:              "failure openid scope not granted",
+			},
+			accessToken: noOpenIDResponse.AccessToken,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidToken,
+				Name:              "failure access token revoked",
+			},
+			accessToken: revokedResponse.AccessToken,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidToken,
+				Name:              "failure unknown opaque access token",
+			},
+			accessToken: "unknown opaque token",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeMalformedJWT,
+				Name:              "failure access token is a malformed jwt",
+			},
+			accessToken: "not.a.jwt",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeMissingRequiredParameter,
+				Name:              "failure access token not provided",
+			},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.baseData.Name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			userInfo, err := oauthService.GetUserInfo(context.TODO(), logger, tt.accessToken, oauthServiceTest_CreatedBy)
+			testutilities.PerformErrorCheck(t, tt.baseData, err)
+			if err == nil {
+				if tt.baseData.ExpectedError {
+					t.Fatalf("expected error %s but got none", tt.baseData.ExpectedErrorCode)
+				}
+				if userInfo.Subject != oauthServiceTest_UserID {
+					t.Errorf("user info subject does not match expected value: got %s - expected %s", userInfo.Subject, oauthServiceTest_UserID)
+				}
+				if userInfo.Email != tt.expectedEmail {
+					t.Errorf("user info email does not match expected value: got %s - expected %s", userInfo.Email, tt.expectedEmail)
+				}
+			}
+		})
+	}
+}
+
+func _testIntrospectToken(t *testing.T, oauthService services.OAuthService, tokenService services.TokenService) {
+	logger := zaptest.NewLogger(t)
+	scope := oauthServiceTest_AppScopes[0].Name
+	activeResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, scope)
+	revokedResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, scope)
+	rErr := tokenService.DeleteToken(context.TODO(), logger, getAccessTokenIDForOAuthServiceTest(t, revokedResponse.AccessToken))
+	if rErr != nil {
+		t.Log(rErr.Error())
+		t.Fatalf("failed to revoke access token: %s", rErr.GetErrorCode())
+	}
+	rotatedResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, scope)
+	_, rErr = oauthService.ExchangeRefreshToken(context.TODO(), logger, oauthServiceTest_App, rotatedResponse.RefreshToken, "", oauthServiceTest_CreatedBy)
+	if rErr != nil {
+		t.Log(rErr.Error())
+		t.Fatalf("failed to rotate refresh token: %s", rErr.GetErrorCode())
+	}
+	testCases := []struct {
+		baseData          testutilities.BaseTestCase
+		token             string
+		tokenTypeHint     string
+		expectedActive    bool
+		expectedTokenType string
+	}{
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success signed access token",
+			},
+			token:             activeResponse.AccessToken,
+			expectedActive:    true,
+			expectedTokenType: core.OAUTH_TOKEN_TYPE_BEARER,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success opaque access token",
+			},
+			token:             getAccessTokenIDForOAuthServiceTest(t, activeResponse.AccessToken),
+			expectedActive:    true,
+			expectedTokenType: core.OAUTH_TOKEN_TYPE_BEARER,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success refresh token with hint",
+			},
+			token:             activeResponse.RefreshToken,
+			tokenTypeHint:     core.OAUTH_TOKEN_TYPE_HINT_REFRESH_TOKEN,
+			expectedActive:    true,
+			expectedTokenType: core.OAUTH_TOKEN_TYPE_HINT_REFRESH_TOKEN,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success refresh token with wrong hint",
+			},
+			token:             activeResponse.RefreshToken,
+			tokenTypeHint:     core.OAUTH_TOKEN_TYPE_HINT_ACCESS_TOKEN,
+			expectedActive:    true,
+			expectedTokenType: core.OAUTH_TOKEN_TYPE_HINT_REFRESH_TOKEN,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success revoked access token is not active",
+			},
+			token: revokedResponse.AccessToken,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success rotated refresh token is not active",
+			},
+			token: rotatedResponse.RefreshToken,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success unknown opaque token is not active",
+			},
+			token: "not_a_real_token",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success invalid signed token is not active",
+			},
+			token: "not.a.jwt",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeMissingRequiredParameter,
+				Name:              "failure token not provided",
+			},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.baseData.Name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			introspectionResponse, err := oauthService.IntrospectToken(context.TODO(), logger, oauthServiceTest_App, tt.token, tt.tokenTypeHint, oauthServiceTest_CreatedBy)
+			testutilities.PerformErrorCheck(t, tt.baseData, err)
+			if err == nil {
+				if tt.baseData.ExpectedError {
+					t.Fatalf("expected error %s but got none", tt.baseData.ExpectedErrorCode)
+				}
+				if introspectionResponse.Active != tt.expectedActive {
+					t.Fatalf("active does not match expected value: got %t - expected %t", introspectionResponse.Active, tt.expectedActive)
+				}
+				if !tt.expectedActive {
+					if introspectionResponse != (models.IntrospectionResponse{}) {
+						t.Errorf("inactive tokens should not include any other details: %v", introspectionResponse)
+					}
+					return
+				}
+				if introspectionResponse.TokenType != tt.expectedTokenType {
+					t.Errorf("token type does not match expected value: got %s - expected %s", introspectionResponse.TokenType, tt.expectedTokenType)
+				}
+				if introspectionResponse.Scope != scope {
+					t.Errorf("scope does not match expected value: got %s - expected %s", introspectionResponse.Scope, scope)
+				}
+				if introspectionResponse.ClientID != oauthServiceTest_App.ClientID {
+					t.Errorf("client id does not match expected value: got %s - expected %s", introspectionResponse.ClientID, oauthServiceTest_App.ClientID)
+				}
+				if introspectionResponse.Subject != oauthServiceTest_UserID {
+					t.Errorf("subject does not match expected value: got %s - expected %s", introspectionResponse.Subject, oauthServiceTest_UserID)
+				}
+				if introspectionResponse.ExpiresAt == 0 {
+					t.Error("exp should be populated for active tokens")
+				}
+			}
+		})
+	}
+}
+
+func _testIssueClientCredentialsToken(t *testing.T, oauthService services.OAuthService, tokenService services.TokenService) {
+	testCases := []struct {
+		baseData testutilities.BaseTestCase
+		scope    string
+	}{
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success",
+			},
+			scope: fmt.Sprintf("%s %s", oauthServiceTest_AppScopes[0].Name, oauthServiceTest_AppScopes[2].Name),
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success no scopes requested",
+			},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidScope,
+				Name:              "failure scope not valid for app",
+			},
+			scope: "not_a_real_scope",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidScope,
+				Name:              "failure OIDC scope requested without a user",
+			},
+			scope: core.OIDC_SCOPE_OPENID,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.baseData.Name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			accessTokenResponse, err := oauthService.IssueClientCredentialsToken(context.TODO(), logger, oauthServiceTest_App, oauthServiceTest_AppScopes, tt.scope, oauthServiceTest_CreatedBy)
+			testutilities.PerformErrorCheck(t, tt.baseData, err)
+			if err == nil {
+				if tt.baseData.ExpectedError {
+					t.Fatalf("expected error %s but got none", tt.baseData.ExpectedErrorCode)
+				}
+				if accessTokenResponse.Scope != tt.scope {
+					t.Errorf("scope does not match expected value: got %s - expected %s", accessTokenResponse.Scope, tt.scope)
+				}
+				accessToken, err := tokenService.GetToken(context.TODO(), logger, getAccessTokenIDForOAuthServiceTest(t, accessTokenResponse.AccessToken), models.TokenTypeAccessToken)
+				if err != nil {
+					t.Log(err.Error())
+					t.Fatalf("failed to retreive issued access token: %s", err.GetErrorCode())
+				}
+				if accessToken.TargetID != oauthServiceTest_App.ClientID {
+					t.Errorf("access token target id does not match expected value: got %s - expected %s", accessToken.TargetID, oauthServiceTest_App.ClientID)
+				}
+				if accessTokenResponse.RefreshToken != "" {
+					t.Error("refresh token should not be issued for the client credentials grant")
+				}
+			}
+		})
+	}
+}
+
+func _testExchangeRefreshToken(t *testing.T, oauthService services.OAuthService, tokenService services.TokenService) {
+	grantedScope := fmt.Sprintf("%s %s", oauthServiceTest_AppScopes[0].Name, oauthServiceTest_AppScopes[1].Name)
+	testCases := []struct {
+		baseData      testutilities.BaseTestCase
+		app           models.App
+		scope         string
+		expectedScope string
+	}{
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success",
+			},
+			app:           oauthServiceTest_App,
+			expectedScope: grantedScope,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success narrowed scope",
+			},
+			app:           oauthServiceTest_App,
+			scope:         oauthServiceTest_AppScopes[1].Name,
+			expectedScope: oauthServiceTest_AppScopes[1].Name,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeInvalidScope,
+				Name:              "failure scope not originally granted",
+			},
+			app:   oauthServiceTest_App,
+			scope: fmt.Sprintf("%s %s", oauthServiceTest_AppScopes[0].Name, oauthServiceTest_AppScopes[2].Name),
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeRefreshTokenClientMismatch,
+				Name:              "failure refresh token issued to another client",
+			},
+			app: oauthServiceTest_PKCEApp,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.baseData.Name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			initialResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, grantedScope)
+			accessTokenResponse, err := oauthService.ExchangeRefreshToken(context.TODO(), logger, tt.app, initialResponse.RefreshToken, tt.scope, oauthServiceTest_CreatedBy)
+			testutilities.PerformErrorCheck(t, tt.baseData, err)
+			if err == nil {
+				if tt.baseData.ExpectedError {
+					t.Fatalf("expected error %s but got none", tt.baseData.ExpectedErrorCode)
+				}
+				if accessTokenResponse.Scope != tt.expectedScope {
+					t.Errorf("scope does not match expected value: got %s - expected %s", accessTokenResponse.Scope, tt.expectedScope)
+				}
+				if accessTokenResponse.RefreshToken == "" || accessTokenResponse.RefreshToken == initialResponse.RefreshToken {
+					t.Error("refresh token was not rotated")
+				}
+				newRefreshToken, err := tokenService.GetToken(context.TODO(), logger, accessTokenResponse.RefreshToken, models.TokenTypeRefreshToken)
+				if err != nil {
+					t.Log(err.Error())
+					t.Fatalf("failed to retreive rotated refresh token: %s", err.GetErrorCode())
+				}
+				if newRefreshToken.MetaData[models.TokenMetaDataKeyScope] != grantedScope {
+					t.Errorf("rotated refresh token scope does not match expected value: got %s - expected %s", newRefreshToken.MetaData[models.TokenMetaDataKeyScope], grantedScope)
+				}
+			}
+		})
+	}
+
+	t.Run("reuse revokes token family", func(t *testing.T) {
+		logger := zaptest.NewLogger(t)
+		firstResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, grantedScope)
+		secondResponse, err := oauthService.ExchangeRefreshToken(context.TODO(), logger, oauthServiceTest_App, firstResponse.RefreshToken, "", oauthServiceTest_CreatedBy)
+		if err != nil {
+			t.Log(err.Error())
+			t.Fatalf("failed to exchange refresh token: %s", err.GetErrorCode())
+		}
+		thirdResponse, err := oauthService.ExchangeRefreshToken(context.TODO(), logger, oauthServiceTest_App, secondResponse.RefreshToken, "", oauthServiceTest_CreatedBy)
+		if err != nil {
+			t.Log(err.Error())
+			t.Fatalf("failed to exchange refresh token: %s", err.GetErrorCode())
+		}
+		_, err = oauthService.ExchangeRefreshToken(context.TODO(), logger, oauthServiceTest_App, firstResponse.RefreshToken, "", oauthServiceTest_CreatedBy)
+		if err == nil {
+			t.Fatal("expected error when reusing a rotated refresh token")
+		}
+		if err.GetErrorCode() != coreerrors.ErrCodeRefreshTokenReused {
+			t.Errorf("unexpected error code: got %s - expected %s", err.GetErrorCode(), coreerrors.ErrCodeRefreshTokenReused)
+		}
+		for _, refreshToken := range []string{firstResponse.RefreshToken, secondResponse.RefreshToken, thirdResponse.RefreshToken} {
+			_, err = tokenService.GetToken(context.TODO(), logger, refreshToken, models.TokenTypeRefreshToken)
+			if err == nil {
+				t.Errorf("refresh token in family was not revoked: %s", refreshToken)
+			}
+		}
+		for _, accessToken := range []string{firstResponse.AccessToken, secondResponse.AccessToken, thirdResponse.AccessToken} {
+			_, err = tokenService.GetToken(context.TODO(), logger, getAccessTokenIDForOAuthServiceTest(t, accessToken), models.TokenTypeAccessToken)
+			if err == nil {
+				t.Errorf("access token in family was not revoked: %s", accessToken)
+			}
+		}
+	})
+	t.Run("concurrent exchanges are treated as reuse", func(t *testing.T) {
+		logger := zaptest.NewLogger(t)
+		initialResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, grantedScope)
+		const numExchanges = 10
+		// every exchange reads the refresh token before any of them can rotate it.
+		oauthServiceTest_TokenRepo.holdReads(initialResponse.RefreshToken, numExchanges)
+		var wg sync.WaitGroup
+		var lock sync.Mutex
+		successfulResponses := make([]models.AccessTokenResponse, 0, 1)
+		for i := 0; i < numExchanges; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				accessTokenResponse, err := oauthService.ExchangeRefreshToken(context.TODO(), logger, oauthServiceTest_App, initialResponse.RefreshToken, "", oauthServiceTest_CreatedBy)
+				if err != nil {
+					// exchanges that start after the family is revoked no longer find the refresh token.
+					if err.GetErrorCode() != coreerrors.ErrCodeRefreshTokenReused && err.GetErrorCode() != coreerrors.ErrCodeInvalidToken {
+						t.Errorf("unexpected error code: got %s - expected %s", err.GetErrorCode(), coreerrors.ErrCodeRefreshTokenReused)
+					}
+					return
+				}
+				lock.Lock()
+				successfulResponses = append(successfulResponses, accessTokenResponse)
+				lock.Unlock()
+			}()
+		}
+		wg.Wait()
+		if len(successfulResponses) > 1 {
+			t.Fatalf("only one exchange of the same refresh token should succeed: got %d", len(successfulResponses))
+		}
+		// the other exchanges are reuse, so the tokens from the exchange that won are revoked along with the rest of the family.
+		for _, accessTokenResponse := range successfulResponses {
+			_, err := tokenService.GetToken(context.TODO(), logger, accessTokenResponse.RefreshToken, models.TokenTypeRefreshToken)
+			if err == nil {
+				t.Errorf("refresh token from concurrent exchange was not revoked: %s", accessTokenResponse.RefreshToken)
+			}
+			_, err = tokenService.GetToken(context.TODO(), logger, getAccessTokenIDForOAuthServiceTest(t, accessTokenResponse.AccessToken), models.TokenTypeAccessToken)
+			if err == nil {
+				t.Errorf("access token from concurrent exchange was not revoked: %s", accessTokenResponse.AccessToken)
+			}
+		}
+		_, err := tokenService.GetToken(context.TODO(), logger, initialResponse.RefreshToken, models.TokenTypeRefreshToken)
+		if err == nil {
+			t.Error("reused refresh token was not revoked")
+		}
+	})
+}
+
+func _testAppPolicy(t *testing.T, oauthService services.OAuthService) {
+	logger := zaptest.NewLogger(t)
+	_, err := oauthService.IssueClientCredentialsToken(context.TODO(), logger, oauthServiceTest_PolicyApp, nil, "", oauthServiceTest_CreatedBy)
+	if err == nil {
+		t.Error("expected error GrantTypeNotAllowed for client credentials grant but got none")
+	} else if !coreerrors.IsGrantTypeNotAllowedError(err) {
+		t.Errorf("error code is not what was expected: got %s - expected %s", err.GetErrorCode(), coreerrors.ErrCodeGrantTypeNotAllowed)
+	}
+	_, err = oauthService.ExchangeRefreshToken(context.TODO(), logger, oauthServiceTest_PolicyApp, "refresh token", "", oauthServiceTest_CreatedBy)
+	if err == nil {
+		t.Error("expected error GrantTypeNotAllowed for refresh token grant but got none")
+	} else if !coreerrors.IsGrantTypeNotAllowedError(err) {
+		t.Errorf("error code is not what was expected: got %s - expected %s", err.GetErrorCode(), coreerrors.ErrCodeGrantTypeNotAllowed)
+	}
+	authRequest := models.AuthorizationRequest{
+		ClientID:     oauthServiceTest_PolicyApp.ClientID,
+		ResponseType: core.OAUTH_RESPONSE_TYPE_CODE,
+		Scope:        core.OIDC_SCOPE_OPENID,
+	}
+	code, err := oauthService.IssueAuthorizationCode(context.TODO(), logger, oauthServiceTest_PolicyApp, oauthServiceTest_Authentication, authRequest, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to issue authorization code: %s", err.GetErrorCode())
+	}
+	accessTokenResponse, err := oauthService.ExchangeAuthorizationCode(context.TODO(), logger, oauthServiceTest_PolicyApp, code.Value, "", "", oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to exchange authorization code: %s", err.GetErrorCode())
+	}
+	if accessTokenResponse.RefreshToken != "" {
+		t.Error("refresh token was issued to an app that is not allowed to use the refresh token grant")
+	}
+	if accessTokenResponse.IDToken == "" {
+		t.Error("id token was not issued for the openid scope")
+	}
+	expectedExpiresIn := int64(oauthServiceTest_PolicyAccessTokenDuration.Seconds())
+	if accessTokenResponse.ExpiresIn != expectedExpiresIn {
+		t.Errorf("access token expires in does not match the app policy: got %d - expected %d", accessTokenResponse.ExpiresIn, expectedExpiresIn)
+	}
+	if isSignedToken(accessTokenResponse.AccessToken) {
+		t.Errorf("access token is not opaque: %s", accessTokenResponse.AccessToken)
+	}
+	userInfo, err := oauthService.GetUserInfo(context.TODO(), logger, accessTokenResponse.AccessToken, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to get user info with opaque access token: %s", err.GetErrorCode())
+	}
+	if userInfo.Subject != oauthServiceTest_UserID {
+		t.Errorf("user info subject does not match expected value: got %s - expected %s", userInfo.Subject, oauthServiceTest_UserID)
+	}
+	introspectionResponse, err := oauthService.IntrospectToken(context.TODO(), logger, oauthServiceTest_App, accessTokenResponse.AccessToken, "", oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to introspect opaque access token: %s", err.GetErrorCode())
+	}
+	if !introspectionResponse.Active || introspectionResponse.ClientID != oauthServiceTest_PolicyApp.ClientID {
+		t.Errorf("introspection response for opaque access token is not what was expected: %+v", introspectionResponse)
+	}
+}
+
+func _testRevokeToken(t *testing.T, oauthService services.OAuthService) {
+	scope := oauthServiceTest_AppScopes[0].Name
+	signedAccessTokenResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, scope)
+	opaqueAccessTokenResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, scope)
+	refreshTokenResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, scope)
+	otherClientResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, scope)
+	testCases := []struct {
+		baseData      testutilities.BaseTestCase
+		app           models.App
+		token         string
+		tokenTypeHint string
+		// revokedTokens are the tokens that should no longer be active after the token is revoked.
+		revokedTokens []string
+		// activeTokens are the tokens that should still be active after the token is revoked.
+		activeTokens []string
+	}{
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success signed access token",
+			},
+			app:           oauthServiceTest_App,
+			token:         signedAccessTokenResponse.AccessToken,
+			revokedTokens: []string{signedAccessTokenResponse.AccessToken},
+			activeTokens:  []string{signedAccessTokenResponse.RefreshToken},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success opaque access token",
+			},
+			app:           oauthServiceTest_App,
+			token:         getAccessTokenIDForOAuthServiceTest(t, opaqueAccessTokenResponse.AccessToken),
+			tokenTypeHint: core.OAUTH_TOKEN_TYPE_HINT_ACCESS_TOKEN,
+			revokedTokens: []string{opaqueAccessTokenResponse.AccessToken},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success refresh token revokes access token issued with it",
+			},
+			app:           oauthServiceTest_App,
+			token:         refreshTokenResponse.RefreshToken,
+			tokenTypeHint: core.OAUTH_TOKEN_TYPE_HINT_REFRESH_TOKEN,
+			revokedTokens: []string{refreshTokenResponse.RefreshToken, refreshTokenResponse.AccessToken},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success already revoked token",
+			},
+			app:   oauthServiceTest_App,
+			token: refreshTokenResponse.RefreshToken,
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				Name: "success unknown token",
+			},
+			app:   oauthServiceTest_App,
+			token: "not_a_real_token",
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeTokenClientMismatch,
+				Name:              "failure token issued to another client",
+			},
+			app:          oauthServiceTest_PKCEApp,
+			token:        otherClientResponse.RefreshToken,
+			activeTokens: []string{otherClientResponse.RefreshToken, otherClientResponse.AccessToken},
+		},
+		{
+			baseData: testutilities.BaseTestCase{
+				ExpectedError:     true,
+				ExpectedErrorCode: coreerrors.ErrCodeMissingRequiredParameter,
+				Name:              "failure token not provided",
+			},
+			app: oauthServiceTest_App,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.baseData.Name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			err := oauthService.RevokeToken(context.TODO(), logger, tt.app, tt.token, tt.tokenTypeHint, oauthServiceTest_CreatedBy)
+			testutilities.PerformErrorCheck(t, tt.baseData, err)
+			if err == nil && tt.baseData.ExpectedError {
+				t.Fatalf("expected error %s but got none", tt.baseData.ExpectedErrorCode)
+			}
+			for _, token := range tt.revokedTokens {
+				assertTokenActiveForOAuthServiceTest(t, oauthService, token, false)
+			}
+			for _, token := range tt.activeTokens {
+				assertTokenActiveForOAuthServiceTest(t, oauthService, token, true)
+			}
+		})
+	}
+}
+
+func _testRevokeAppTokens(t *testing.T, oauthService services.OAuthService) {
+	logger := zaptest.NewLogger(t)
+	accessTokenResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, oauthServiceTest_AppScopes[0].Name)
+	err := oauthService.RevokeAppTokens(context.TODO(), logger, oauthServiceTest_App, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to revoke app tokens: %s", err.GetErrorCode())
+	}
+	assertTokenActiveForOAuthServiceTest(t, oauthService, accessTokenResponse.AccessToken, false)
+	assertTokenActiveForOAuthServiceTest(t, oauthService, accessTokenResponse.RefreshToken, false)
+}
+
+func _testRevokeCompromisedClientSecret(t *testing.T, oauthService services.OAuthService) {
+	logger := zaptest.NewLogger(t)
+	app, err := oauthServiceTest_AppService.GetAppByID(context.TODO(), logger, oauthServiceTest_App.ID, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to retreive app: %s", err.GetErrorCode())
+	}
+	compromisedClientSecretID := app.ClientSecrets[0].ID
+	accessTokenResponse := getRefreshTokenForOAuthServiceTest(t, oauthService, oauthServiceTest_AppScopes[0].Name)
+	err = oauthService.RevokeCompromisedClientSecret(context.TODO(), logger, &app, compromisedClientSecretID, oauthServiceTest_CreatedBy)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeLastActiveClientSecret {
+		t.Fatal("expected last active client secret error when revoking the only client secret")
+	}
+	assertTokenActiveForOAuthServiceTest(t, oauthService, accessTokenResponse.RefreshToken, true)
+	newClientSecret, err := oauthServiceTest_AppService.AddClientSecret(context.TODO(), logger, &app, nullable.NullableTime{}, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to add client secret: %s", err.GetErrorCode())
+	}
+	err = oauthService.RevokeCompromisedClientSecret(context.TODO(), logger, &app, compromisedClientSecretID, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to revoke compromised client secret: %s", err.GetErrorCode())
+	}
+	assertTokenActiveForOAuthServiceTest(t, oauthService, accessTokenResponse.AccessToken, false)
+	assertTokenActiveForOAuthServiceTest(t, oauthService, accessTokenResponse.RefreshToken, false)
+	storedApp, err := oauthServiceTest_AppService.GetAppByID(context.TODO(), logger, app.ID, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to retreive app: %s", err.GetErrorCode())
+	}
+	now := time.Now()
+	if storedApp.MatchClientSecret(oauthServiceTest_AppSecret, now) || !storedApp.MatchClientSecret(newClientSecret, now) {
+		t.Error("only the new client secret should match after the compromised one is revoked")
+	}
+}
+
+func assertTokenActiveForOAuthServiceTest(t *testing.T, oauthService services.OAuthService, token string, expectedActive bool) {
+	logger := zaptest.NewLogger(t)
+	introspectionResponse, err := oauthService.IntrospectToken(context.TODO(), logger, oauthServiceTest_App, token, "", oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to introspect token: %s", err.GetErrorCode())
+	}
+	if introspectionResponse.Active != expectedActive {
+		t.Errorf("token active state does not match expected value: got %t - expected %t", introspectionResponse.Active, expectedActive)
+	}
+}
+
+func getRefreshTokenForOAuthServiceTest(t *testing.T, oauthService services.OAuthService, scope string) models.AccessTokenResponse {
+	logger := zaptest.NewLogger(t)
+	authRequest := models.AuthorizationRequest{
+		ClientID: oauthServiceTest_App.ClientID,
+		Scope:    scope,
+	}
+	code, err := oauthService.IssueAuthorizationCode(context.TODO(), logger, oauthServiceTest_App, oauthServiceTest_Authentication, authRequest, oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to issue authorization code: %s", err.GetErrorCode())
+	}
+	accessTokenResponse, err := oauthService.ExchangeAuthorizationCode(context.TODO(), logger, oauthServiceTest_App, code.Value, "", "", oauthServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to exchange authorization code: %s", err.GetErrorCode())
+	}
+	return accessTokenResponse
+}
+
+func getAccessTokenIDForOAuthServiceTest(t *testing.T, signedAccessToken string) string {
+	var claims jwt.AccessTokenClaims
+	_, err := jwt.Verify(signedAccessToken, oauthServiceTest_KeyProvider, &claims)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to verify access token: %s", err.GetErrorCode())
+	}
+	if claims.Issuer != oauthServiceTest_Issuer {
+		t.Errorf("access token issuer does not match expected value: got %s - expected %s", claims.Issuer, oauthServiceTest_Issuer)
+	}
+	return claims.ID
+}
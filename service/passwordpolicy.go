@@ -0,0 +1,309 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/calvine/goauth/core/apptelemetry"
+	coreerrors "github.com/calvine/goauth/core/errors"
+	repo "github.com/calvine/goauth/core/repositories"
+	coreservices "github.com/calvine/goauth/core/services"
+	"github.com/calvine/richerror/errors"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPasswordMinLength int = 8
+	defaultPasswordMaxLength int = 64
+	// bcrypt ignores everything past the first 72 bytes of a password.
+	bcryptMaxPasswordBytes int = 72
+
+	// breached passwords are looked up by the first 5 hex characters (20 bits) of their SHA-1 hash.
+	breachedPasswordHashPrefixLength int = 5
+	breachedPasswordHashPrefixCount  int = 1 << 20
+	breachedPasswordHashLength       int = sha1.Size * 2
+
+	// the local part of an email is only checked when it has at least this many characters, shorter ones like jo in jo@example.com would reject most passwords.
+	minEmailLocalPartMatchLength int = 4
+)
+
+type passwordPolicy struct {
+	minLength        int
+	maxLength        int
+	requireUppercase bool
+	requireLowercase bool
+	requireDigit     bool
+	requireSymbol    bool
+	denylist         map[string]struct{}
+
+	breachedPasswordFile string
+	// breachedPasswordIndex holds the offset of the first line in the breached password file for each hash prefix,
+	// so the lines for a prefix are between breachedPasswordIndex[prefix] and breachedPasswordIndex[prefix+1].
+	breachedPasswordIndex []int64
+}
+
+type PasswordPolicyOptions struct {
+	// MinLength is the minimum number of characters a password must have. Defaults to 8.
+	MinLength int
+	// MaxLength is the maximum number of characters a password can have. Defaults to 64, and cannot be more than 72 because bcrypt ignores the rest.
+	MaxLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	// Denylist is passwords that are never allowed. They are compared case insensitively.
+	Denylist []string
+	// BreachedPasswordFile is the path to a file of upper case hex SHA-1 hashes of breached passwords, one per line and sorted by hash.
+	// Anything after a colon on a line is ignored, so the ordered by hash download from https://haveibeenpwned.com/Passwords can be used as is.
+	BreachedPasswordFile string
+}
+
+// NewPasswordPolicy creates a password policy. When a breached password file is configured it is read once here to index it by hash prefix.
+func NewPasswordPolicy(options PasswordPolicyOptions) (coreservices.PasswordPolicy, errors.RichError) {
+	if options.MinLength <= 0 {
+		options.MinLength = defaultPasswordMinLength
+	}
+	if options.MaxLength <= 0 {
+		options.MaxLength = defaultPasswordMaxLength
+	}
+	fields := make(map[string]interface{})
+	if options.MaxLength > bcryptMaxPasswordBytes {
+		fields["MaxLength"] = fmt.Sprintf("password policy MaxLength cannot be more than %d", bcryptMaxPasswordBytes)
+	}
+	if options.MinLength > options.MaxLength {
+		fields["MinLength"] = "password policy MinLength cannot be more than MaxLength"
+	}
+	policy := passwordPolicy{
+		minLength:            options.MinLength,
+		maxLength:            options.MaxLength,
+		requireUppercase:     options.RequireUppercase,
+		requireLowercase:     options.RequireLowercase,
+		requireDigit:         options.RequireDigit,
+		requireSymbol:        options.RequireSymbol,
+		denylist:             make(map[string]struct{}, len(options.Denylist)),
+		breachedPasswordFile: options.BreachedPasswordFile,
+	}
+	for _, deniedPassword := range options.Denylist {
+		policy.denylist[strings.ToLower(deniedPassword)] = struct{}{}
+	}
+	if options.BreachedPasswordFile != "" {
+		index, err := buildBreachedPasswordIndex(options.BreachedPasswordFile)
+		if err != nil {
+			fields["BreachedPasswordFile"] = err.Error()
+		}
+		policy.breachedPasswordIndex = index
+	}
+	if len(fields) > 0 {
+		return nil, coreerrors.NewInvalidPasswordPolicyError(fields, true)
+	}
+	return policy, nil
+}
+
+func (passwordPolicy) GetName() string {
+	return "passwordPolicy"
+}
+
+func (pp passwordPolicy) ValidatePassword(ctx context.Context, logger *zap.Logger, password string, contactPrincipals []string) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, pp.GetName(), "ValidatePassword")
+	defer span.End()
+	fields := make(map[string]interface{})
+	length := utf8.RuneCountInString(password)
+	if length < pp.minLength {
+		fields["Password.MinLength"] = fmt.Sprintf("password must be at least %d characters", pp.minLength)
+	}
+	if length > pp.maxLength || len(password) > bcryptMaxPasswordBytes {
+		fields["Password.MaxLength"] = fmt.Sprintf("password cannot be more than %d characters", pp.maxLength)
+	}
+	var hasUppercase, hasLowercase, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUppercase = true
+		case unicode.IsLower(r):
+			hasLowercase = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if pp.requireUppercase && !hasUppercase {
+		fields["Password.Uppercase"] = "password must contain an upper case letter"
+	}
+	if pp.requireLowercase && !hasLowercase {
+		fields["Password.Lowercase"] = "password must contain a lower case letter"
+	}
+	if pp.requireDigit && !hasDigit {
+		fields["Password.Digit"] = "password must contain a digit"
+	}
+	if pp.requireSymbol && !hasSymbol {
+		fields["Password.Symbol"] = "password must contain a symbol"
+	}
+	lowerPassword := strings.ToLower(password)
+	if _, denied := pp.denylist[lowerPassword]; denied {
+		fields["Password.Denylist"] = "password is too common"
+	}
+	for _, principal := range contactPrincipals {
+		if containsContactPrincipal(lowerPassword, strings.ToLower(principal)) {
+			fields["Password.ContactPrincipal"] = "password cannot contain your email address or phone number"
+			break
+		}
+	}
+	if pp.breachedPasswordFile != "" {
+		breached, err := pp.isBreachedPassword(password)
+		if err != nil {
+			evtString := "failed to check password against breached password file"
+			logger.Error(evtString, zap.Reflect("error", err))
+			apptelemetry.SetSpanOriginalError(&span, err, evtString)
+			return err
+		}
+		if breached {
+			fields["Password.Breached"] = "password has appeared in a data breach"
+		}
+	}
+	if len(fields) > 0 {
+		err := coreerrors.NewPasswordPolicyViolationError(fields, true)
+		evtString := "password does not meet the password policy"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	span.AddEvent("password meets password policy")
+	return nil
+}
+
+// containsContactPrincipal checks for the whole principal, and for emails the part before the @ as well since it is often the users name.
+// The part before the @ is only checked when it is long enough to be meaningful.
+func containsContactPrincipal(lowerPassword, lowerPrincipal string) bool {
+	if lowerPrincipal == "" {
+		return false
+	}
+	if strings.Contains(lowerPassword, lowerPrincipal) {
+		return true
+	}
+	if at := strings.LastIndex(lowerPrincipal, "@"); at > 0 {
+		localPart := lowerPrincipal[:at]
+		return utf8.RuneCountInString(localPart) >= minEmailLocalPartMatchLength && strings.Contains(lowerPassword, localPart)
+	}
+	return false
+}
+
+func (pp passwordPolicy) isBreachedPassword(password string) (bool, errors.RichError) {
+	hash := sha1.Sum([]byte(password))
+	hashString := strings.ToUpper(hex.EncodeToString(hash[:]))
+	prefix, _ := strconv.ParseUint(hashString[:breachedPasswordHashPrefixLength], 16, 32)
+	start, end := pp.breachedPasswordIndex[prefix], pp.breachedPasswordIndex[prefix+1]
+	if start == end {
+		return false, nil
+	}
+	// the file is opened per check so the policy holds no open handles and is safe for concurrent use.
+	file, err := os.Open(pp.breachedPasswordFile)
+	if err != nil {
+		return false, coreerrors.NewBreachedPasswordCheckFailedError(pp.breachedPasswordFile, err, true)
+	}
+	defer file.Close()
+	lines := make([]byte, end-start)
+	_, err = file.ReadAt(lines, start)
+	if err != nil {
+		return false, coreerrors.NewBreachedPasswordCheckFailedError(pp.breachedPasswordFile, err, true)
+	}
+	for _, line := range bytes.Split(lines, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) >= breachedPasswordHashLength && string(bytes.ToUpper(line[:breachedPasswordHashLength])) == hashString {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// buildBreachedPasswordIndex reads the whole breached password file once to record where the lines for each hash prefix start.
+func buildBreachedPasswordIndex(path string) ([]int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	index := make([]int64, breachedPasswordHashPrefixCount+1)
+	reader := bufio.NewReader(file)
+	var offset int64
+	nextPrefix := 0
+	lineNumber := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(line) == 0 {
+			break
+		}
+		lineNumber++
+		hash := strings.TrimSpace(line)
+		if colon := strings.Index(hash, ":"); colon != -1 {
+			hash = hash[:colon]
+		}
+		if hash != "" {
+			if len(hash) != breachedPasswordHashLength {
+				return nil, fmt.Errorf("line %d is not a SHA-1 hash", lineNumber)
+			}
+			prefix, parseErr := strconv.ParseUint(hash[:breachedPasswordHashPrefixLength], 16, 32)
+			if parseErr != nil {
+				return nil, fmt.Errorf("line %d is not a SHA-1 hash", lineNumber)
+			}
+			if int(prefix) < nextPrefix-1 {
+				return nil, fmt.Errorf("line %d is out of order, the file must be sorted by hash", lineNumber)
+			}
+			// every prefix up to this one that has not been seen yet starts at this line.
+			for ; nextPrefix <= int(prefix); nextPrefix++ {
+				index[nextPrefix] = offset
+			}
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			break
+		}
+	}
+	for ; nextPrefix <= breachedPasswordHashPrefixCount; nextPrefix++ {
+		index[nextPrefix] = offset
+	}
+	return index, nil
+}
+
+// validateNewPassword checks a new password for a user against the password policy, including the users contact principals.
+func validateNewPassword(ctx context.Context, logger *zap.Logger, span *trace.Span, passwordPolicy coreservices.PasswordPolicy, contactRepo repo.ContactRepo, userID string, newPassword string) errors.RichError {
+	contacts, err := contactRepo.GetContactsByUserID(ctx, userID)
+	if err != nil && err.GetErrorCode() != coreerrors.ErrCodeNoContactFound {
+		evtString := "failed to retreive contacts for password policy"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(span, err, evtString)
+		return err
+	}
+	contactPrincipals := make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		contactPrincipals = append(contactPrincipals, contact.Principal)
+	}
+	err = passwordPolicy.ValidatePassword(ctx, logger, newPassword, contactPrincipals)
+	if err != nil {
+		logger.Error("passwordPolicy.ValidatePassword call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(span, err, "")
+		return err
+	}
+	return nil
+}
+
+// defaultPasswordPolicy is used by services that are not given a password policy.
+func defaultPasswordPolicy() coreservices.PasswordPolicy {
+	// the default options have no breached password file, so creating the policy cannot fail.
+	policy, _ := NewPasswordPolicy(PasswordPolicyOptions{})
+	return policy
+}
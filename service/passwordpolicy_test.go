@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	coreerrors "github.com/calvine/goauth/core/errors"
+	"github.com/calvine/goauth/internal/testutils"
+	"go.uber.org/zap/zaptest"
+)
+
+var passwordPolicyTest_BreachedPasswords = []string{
+	"correcthorsebatterystaple",
+	"Tr0ub4dor&3",
+	"iloveyou2021",
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	breachedPasswordFile := writeBreachedPasswordFile(t, passwordPolicyTest_BreachedPasswords)
+
+	t.Run("NewPasswordPolicy", func(t *testing.T) {
+		_testNewPasswordPolicy(t, breachedPasswordFile)
+	})
+
+	t.Run("ValidatePassword", func(t *testing.T) {
+		_testValidatePassword(t, breachedPasswordFile)
+	})
+
+	t.Run("ValidatePasswordConcurrently", func(t *testing.T) {
+		_testValidatePasswordConcurrently(t, breachedPasswordFile)
+	})
+}
+
+// writeBreachedPasswordFile writes a breached password file in the same format as the ordered by hash download from haveibeenpwned.
+func writeBreachedPasswordFile(t *testing.T, passwords []string) string {
+	lines := make([]string, 0, len(passwords))
+	for i, password := range passwords {
+		hash := sha1.Sum([]byte(password))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(hash[:]))+":"+strings.Repeat("1", i+1))
+	}
+	sort.Strings(lines)
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")), 0600)
+	if err != nil {
+		t.Errorf("failed to write breached password file: %s", err.Error())
+		t.FailNow()
+	}
+	return path
+}
+
+func _testNewPasswordPolicy(t *testing.T, breachedPasswordFile string) {
+	unsortedFile := filepath.Join(t.TempDir(), "unsorted.txt")
+	err := os.WriteFile(unsortedFile, []byte("FFFFF0000000000000000000000000000000000F:1\n00000000000000000000000000000000000000AA:1\n"), 0600)
+	if err != nil {
+		t.Errorf("failed to write unsorted breached password file: %s", err.Error())
+		t.FailNow()
+	}
+	malformedFile := filepath.Join(t.TempDir(), "malformed.txt")
+	err = os.WriteFile(malformedFile, []byte("not a hash\n"), 0600)
+	if err != nil {
+		t.Errorf("failed to write malformed breached password file: %s", err.Error())
+		t.FailNow()
+	}
+	type testCase struct {
+		name              string
+		options           PasswordPolicyOptions
+		expectedErrorCode string
+	}
+	testCases := []testCase{
+		{
+			name:    "GIVEN default options EXPECT success",
+			options: PasswordPolicyOptions{},
+		},
+		{
+			name:    "GIVEN a valid breached password file EXPECT success",
+			options: PasswordPolicyOptions{BreachedPasswordFile: breachedPasswordFile},
+		},
+		{
+			name:              "GIVEN a min length greater than the max length EXPECT error code invalid password policy",
+			options:           PasswordPolicyOptions{MinLength: 20, MaxLength: 10},
+			expectedErrorCode: coreerrors.ErrCodeInvalidPasswordPolicy,
+		},
+		{
+			name:              "GIVEN a max length longer than bcrypt supports EXPECT error code invalid password policy",
+			options:           PasswordPolicyOptions{MaxLength: 100},
+			expectedErrorCode: coreerrors.ErrCodeInvalidPasswordPolicy,
+		},
+		{
+			name:              "GIVEN a breached password file that does not exist EXPECT error code invalid password policy",
+			options:           PasswordPolicyOptions{BreachedPasswordFile: filepath.Join(t.TempDir(), "missing.txt")},
+			expectedErrorCode: coreerrors.ErrCodeInvalidPasswordPolicy,
+		},
+		{
+			name:              "GIVEN a breached password file that is not sorted EXPECT error code invalid password policy",
+			options:           PasswordPolicyOptions{BreachedPasswordFile: unsortedFile},
+			expectedErrorCode: coreerrors.ErrCodeInvalidPasswordPolicy,
+		},
+		{
+			name:              "GIVEN a breached password file with a line that is not a hash EXPECT error code invalid password policy",
+			options:           PasswordPolicyOptions{BreachedPasswordFile: malformedFile},
+			expectedErrorCode: coreerrors.ErrCodeInvalidPasswordPolicy,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewPasswordPolicy(tc.options)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			}
+		})
+	}
+}
+
+func _testValidatePassword(t *testing.T, breachedPasswordFile string) {
+	logger := zaptest.NewLogger(t)
+	options := PasswordPolicyOptions{
+		MinLength:            10,
+		MaxLength:            20,
+		RequireUppercase:     true,
+		RequireLowercase:     true,
+		RequireDigit:         true,
+		RequireSymbol:        true,
+		Denylist:             []string{"Password123!"},
+		BreachedPasswordFile: breachedPasswordFile,
+	}
+	passwordPolicy, err := NewPasswordPolicy(options)
+	if err != nil {
+		t.Errorf("failed to create password policy: %s - %s", err.GetErrorCode(), err.Error())
+		t.FailNow()
+	}
+	// the breached passwords do not meet the other rules, so they are checked with a policy that only checks the breached password file.
+	breachedOnlyPolicy, err := NewPasswordPolicy(PasswordPolicyOptions{BreachedPasswordFile: breachedPasswordFile})
+	if err != nil {
+		t.Errorf("failed to create password policy: %s - %s", err.GetErrorCode(), err.Error())
+		t.FailNow()
+	}
+	type testCase struct {
+		name              string
+		password          string
+		contactPrincipals []string
+		breachedOnly      bool
+		expectedFields    []string
+	}
+	testCases := []testCase{
+		{
+			name:     "GIVEN a password that meets every rule EXPECT success",
+			password: "Sunny-Meadow42",
+		},
+		{
+			name:           "GIVEN a password that is too short EXPECT min length violation",
+			password:       "Sh0rt!",
+			expectedFields: []string{"Password.MinLength"},
+		},
+		{
+			name:           "GIVEN a password that is too long EXPECT max length violation",
+			password:       "This-Password-Is-Far-Too-L0ng",
+			expectedFields: []string{"Password.MaxLength"},
+		},
+		{
+			name:           "GIVEN a password missing character classes EXPECT a violation for each missing class",
+			password:       "alllowercaseletters",
+			expectedFields: []string{"Password.Uppercase", "Password.Digit", "Password.Symbol"},
+		},
+		{
+			name:           "GIVEN a password on the denylist in a different case EXPECT denylist violation",
+			password:       "PASSWORD123!",
+			expectedFields: []string{"Password.Lowercase", "Password.Denylist"},
+		},
+		{
+			name:              "GIVEN a password containing the users email EXPECT contact principal violation",
+			password:          "Xx-Jane@Example.com1",
+			contactPrincipals: []string{"jane@example.com"},
+			expectedFields:    []string{"Password.ContactPrincipal"},
+		},
+		{
+			name:              "GIVEN a password containing the local part of the users email EXPECT contact principal violation",
+			password:          "Janedoe-Rocks9",
+			contactPrincipals: []string{"janedoe@example.com"},
+			expectedFields:    []string{"Password.ContactPrincipal"},
+		},
+		{
+			name:              "GIVEN a password containing a short local part of the users email EXPECT success",
+			password:          "Jo-Rocks-Hard9",
+			contactPrincipals: []string{"jo@example.com", "a@example.com"},
+		},
+		{
+			name:              "GIVEN a password containing a four character local part of the users email EXPECT contact principal violation",
+			password:          "Jane-Rocks-Hard9",
+			contactPrincipals: []string{"jane@example.com"},
+			expectedFields:    []string{"Password.ContactPrincipal"},
+		},
+		{
+			name:           "GIVEN a breached password EXPECT breached violation",
+			password:       passwordPolicyTest_BreachedPasswords[1],
+			breachedOnly:   true,
+			expectedFields: []string{"Password.Breached"},
+		},
+		{
+			name:         "GIVEN a password that is not breached EXPECT success",
+			password:     "not-in-the-breach-file",
+			breachedOnly: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := passwordPolicy
+			if tc.breachedOnly {
+				policy = breachedOnlyPolicy
+			}
+			err := policy.ValidatePassword(context.TODO(), logger, tc.password, tc.contactPrincipals)
+			if len(tc.expectedFields) == 0 {
+				if err != nil {
+					testutils.HandleTestError(t, err, "")
+				}
+				return
+			}
+			if err == nil {
+				t.Errorf("expected an error to occurr: %s", coreerrors.ErrCodePasswordPolicyViolation)
+				return
+			}
+			if err.GetErrorCode() != coreerrors.ErrCodePasswordPolicyViolation {
+				testutils.HandleTestError(t, err, coreerrors.ErrCodePasswordPolicyViolation)
+				return
+			}
+			fields := err.GetMetaData()
+			if len(fields) != len(tc.expectedFields) {
+				t.Errorf("wrong number of password policy violations: got - %v expected - %v", fields, tc.expectedFields)
+			}
+			for _, expectedField := range tc.expectedFields {
+				if _, ok := fields[expectedField]; !ok {
+					t.Errorf("expected password policy violation not found: got - %v expected - %s", fields, expectedField)
+				}
+			}
+		})
+	}
+}
+
+func _testValidatePasswordConcurrently(t *testing.T, breachedPasswordFile string) {
+	logger := zaptest.NewLogger(t)
+	passwordPolicy, err := NewPasswordPolicy(PasswordPolicyOptions{BreachedPasswordFile: breachedPasswordFile})
+	if err != nil {
+		t.Errorf("failed to create password policy: %s - %s", err.GetErrorCode(), err.Error())
+		t.FailNow()
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(password string) {
+			defer wg.Done()
+			err := passwordPolicy.ValidatePassword(context.TODO(), logger, password, nil)
+			if err == nil || err.GetErrorCode() != coreerrors.ErrCodePasswordPolicyViolation {
+				t.Errorf("expected breached password to be rejected: %s", password)
+			}
+		}(passwordPolicyTest_BreachedPasswords[i%len(passwordPolicyTest_BreachedPasswords)])
+	}
+	wg.Wait()
+}
@@ -100,3 +100,86 @@ func (ts tokenService) DeleteToken(ctx context.Context, logger *zap.Logger, toke
 	span.AddEvent(evtString)
 	return nil
 }
+
+func (ts tokenService) ConsumeToken(ctx context.Context, logger *zap.Logger, tokenValue string, expectedTokenType models.TokenType) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, ts.GetName(), "ConsumeToken")
+	defer span.End()
+	token, err := ts.tokenRepo.ConsumeToken(ctx, tokenValue)
+	if err != nil {
+		apptelemetry.SetSpanError(&span, err, "")
+		logger.Error("tokenRepo.ConsumeToken call failed", zap.Reflect("error", err))
+		return token, err
+	}
+	span.AddEvent("token consumed from tokenRepo")
+	if token.IsExpired() {
+		evtString := fmt.Sprintf("token expired on %s", token.Expiration.UTC().String())
+		err := coreerrors.NewExpiredTokenError(tokenValue, token.TokenType.String(), token.Expiration, true)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.Token{}, err
+	} else if token.TokenType != expectedTokenType {
+		// TODO: Audit log this
+		evtString := fmt.Sprintf("token type %s does not match expected type %s", token.TokenType.String(), expectedTokenType.String())
+		err := coreerrors.NewWrongTokenTypeError(token.Value, token.TokenType.String(), expectedTokenType.String(), true)
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return models.Token{}, err
+	}
+	span.AddEvent("token consumed")
+	return token, nil
+}
+
+func (ts tokenService) MarkTokenRotated(ctx context.Context, logger *zap.Logger, tokenValue, rotatedTo string, expiration time.Time) (models.Token, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, ts.GetName(), "MarkTokenRotated")
+	defer span.End()
+	token, err := ts.tokenRepo.MarkTokenRotated(ctx, tokenValue, rotatedTo, expiration)
+	if err != nil {
+		logger.Error("tokenRepo.MarkTokenRotated call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return token, err
+	}
+	span.AddEvent("token marked rotated")
+	return token, nil
+}
+
+func (ts tokenService) DeleteTokensByTargetID(ctx context.Context, logger *zap.Logger, targetID string, tokenTypes []models.TokenType) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, ts.GetName(), "DeleteTokensByTargetID")
+	defer span.End()
+	if targetID == "" {
+		err := coreerrors.NewMissingRequiredParameterError("targetID", true)
+		evtString := "target id was not provided"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	err := ts.tokenRepo.DeleteTokensByTargetID(ctx, targetID, tokenTypes)
+	if err != nil {
+		logger.Error("tokenRepo.DeleteTokensByTargetID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	evtString := fmt.Sprintf("tokens deleted for target: %s", targetID)
+	span.AddEvent(evtString)
+	return nil
+}
+
+func (ts tokenService) DeleteTokensByClientID(ctx context.Context, logger *zap.Logger, clientID string) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, ts.GetName(), "DeleteTokensByClientID")
+	defer span.End()
+	if clientID == "" {
+		err := coreerrors.NewMissingRequiredParameterError("clientID", true)
+		evtString := "client id was not provided"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	err := ts.tokenRepo.DeleteTokensByClientID(ctx, clientID)
+	if err != nil {
+		logger.Error("tokenRepo.DeleteTokensByClientID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	evtString := fmt.Sprintf("tokens deleted for client: %s", clientID)
+	span.AddEvent(evtString)
+	return nil
+}
@@ -32,7 +32,7 @@ const (
 )
 
 func TestTokenService(t *testing.T) {
-	tokenRepo := memory.NewMemoryTokenRepo()
+	tokenRepo := memory.NewMemoryTokenRepo(context.TODO())
 	tokenService := NewTokenService(tokenRepo)
 
 	setupTestTokens(t)
@@ -55,6 +55,16 @@ func TestTokenService(t *testing.T) {
 	t.Run("DeleteToken", func(t *testing.T) {
 		_testDeleteToken(t, tokenService)
 	})
+
+	// delete tokens by target id
+	t.Run("DeleteTokensByTargetID", func(t *testing.T) {
+		_testDeleteTokensByTargetID(t, tokenService)
+	})
+
+	// delete tokens by client id
+	t.Run("DeleteTokensByClientID", func(t *testing.T) {
+		_testDeleteTokensByClientID(t, tokenService)
+	})
 }
 
 func _testTokenServiceGetName(t *testing.T, tokenService services.TokenService) {
@@ -299,6 +309,52 @@ func __testDeleteTokenSuccess(t *testing.T, tokenService services.TokenService)
 	}
 }
 
+func _testDeleteTokensByTargetID(t *testing.T, tokenService services.TokenService) {
+	logger := zaptest.NewLogger(t)
+	err := tokenService.DeleteTokensByTargetID(context.TODO(), logger, tokenUserID, []models.TokenType{models.TokenTypeSession})
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("failed to delete tokens by target id got error: %s", err.GetErrorCode())
+	}
+	_, err = tokenService.GetToken(context.TODO(), logger, sessionToken.Value, models.TokenTypeSession)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeInvalidToken {
+		t.Error("expected session token to be deleted by target id")
+	}
+	err = tokenService.DeleteTokensByTargetID(context.TODO(), logger, "", []models.TokenType{models.TokenTypeSession})
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeMissingRequiredParameter {
+		t.Error("expected missing required parameter error when target id is empty")
+	}
+}
+
+func _testDeleteTokensByClientID(t *testing.T, tokenService services.TokenService) {
+	logger := zaptest.NewLogger(t)
+	const clientID = "test_token_client_id"
+	token, err := models.NewToken(tokenUserID, models.TokenTypeRefreshToken, time.Minute*1)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("faiiled to create token for testing token service: %s", err.GetErrorCode())
+	}
+	token.AddMetaData(models.TokenMetaDataKeyClientID, clientID)
+	err = tokenService.PutToken(context.TODO(), logger, token)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fatalf("failed to put token got error: %s", err.GetErrorCode())
+	}
+	err = tokenService.DeleteTokensByClientID(context.TODO(), logger, clientID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("failed to delete tokens by client id got error: %s", err.GetErrorCode())
+	}
+	_, err = tokenService.GetToken(context.TODO(), logger, token.Value, models.TokenTypeRefreshToken)
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeInvalidToken {
+		t.Error("expected refresh token to be deleted by client id")
+	}
+	err = tokenService.DeleteTokensByClientID(context.TODO(), logger, "")
+	if err == nil || err.GetErrorCode() != coreerrors.ErrCodeMissingRequiredParameter {
+		t.Error("expected missing required parameter error when client id is empty")
+	}
+}
+
 // func __testDeleteTokenFailuireTokenNotFound(t *testing.T, tokenService services.TokenService) {
 // 	err := tokenService.DeleteToken(context.TODO(), "not a real token value9867568797567687")
 // 	if err != nil {
@@ -3,31 +3,49 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/calvine/goauth/core/apptelemetry"
 	coreerrors "github.com/calvine/goauth/core/errors"
 	"github.com/calvine/goauth/core/models"
+	"github.com/calvine/goauth/core/models/aggregate"
 	repo "github.com/calvine/goauth/core/repositories"
 	"github.com/calvine/goauth/core/services"
+	"github.com/calvine/goauth/core/utilities"
 	"github.com/calvine/richerror/errors"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 type userService struct {
-	userRepo     repo.UserRepo
-	contactRepo  repo.ContactRepo
-	tokenService services.TokenService
-	emailService services.EmailService
+	userRepo       repo.UserRepo
+	contactRepo    repo.ContactRepo
+	tokenService   services.TokenService
+	emailService   services.EmailService
+	passwordPolicy services.PasswordPolicy
+	// passwordHashers hashes the password a user sets when they confirm their contact.
+	passwordHashers *utilities.PasswordHashers
+	// issuer is the base url goauth is served from. It is used to build the links sent to users.
+	issuer string
 }
 
-func NewUserService(userRepo repo.UserRepo, contactRepo repo.ContactRepo, tokenService services.TokenService, emailService services.EmailService) services.UserService {
+// NewUserService creates a user service. The default password policy and password hashers are used when passwordPolicy or passwordHashers are nil.
+func NewUserService(userRepo repo.UserRepo, contactRepo repo.ContactRepo, tokenService services.TokenService, emailService services.EmailService, passwordPolicy services.PasswordPolicy, passwordHashers *utilities.PasswordHashers, issuer string) services.UserService {
+	if passwordPolicy == nil {
+		passwordPolicy = defaultPasswordPolicy()
+	}
+	if passwordHashers == nil {
+		passwordHashers = utilities.DefaultPasswordHashers()
+	}
 	return userService{
-		userRepo:     userRepo,
-		contactRepo:  contactRepo,
-		tokenService: tokenService,
-		emailService: emailService,
+		userRepo:        userRepo,
+		contactRepo:     contactRepo,
+		tokenService:    tokenService,
+		emailService:    emailService,
+		passwordPolicy:  passwordPolicy,
+		passwordHashers: passwordHashers,
+		issuer:          strings.TrimSuffix(issuer, "/"),
 	}
 }
 
@@ -65,6 +83,20 @@ func (us userService) RegisterUserAndPrimaryContact(ctx context.Context, logger
 	err := us.checkForExistingConfirmedContacts(ctx, logger, &span, contactType, contactPrincipal, "")
 	if err != nil {
 		// additional error stuff handeled in checkForExistingConfirmedContacts function
+		if err.GetErrorCode() == coreerrors.ErrCodeRegistrationContactAlreadyConfirmed {
+			// let the owner of the contact know instead of telling whoever submitted the registration that the account exists.
+			// TODO: convert this email into a template...
+			passwordResetLink := fmt.Sprintf("%s/auth/resetpassword", us.issuer)
+			body := fmt.Sprintf("Someone tried to register with this email, but you already have an account. If you forgot your password you can reset it here: %s", passwordResetLink)
+			emailErr := us.emailService.SendPlainTextEmail(ctx, logger, []string{contactPrincipal}, "Account already registered", body)
+			if emailErr != nil {
+				evtString := "failed to send account already registered notification error occurred"
+				logger.Error(evtString, zap.Reflect("error", emailErr))
+				apptelemetry.SetSpanError(&span, emailErr, evtString)
+				return emailErr
+			}
+			span.AddEvent("account already registered notification sent")
+		}
 		return err
 	}
 	// create new user and contact in datastore
@@ -103,14 +135,15 @@ func (us userService) RegisterUserAndPrimaryContact(ctx context.Context, logger
 	// send confirmation email
 	// TODO: convert this email into a template...
 	to := []string{contactPrincipal}
-	err = us.emailService.SendPlainTextEmail(ctx, logger, to, "contact confirmation link", confirmationToken.Value)
+	confirmationLink := fmt.Sprintf("%s/user/confirmcontact/%s", us.issuer, confirmationToken.Value)
+	body := fmt.Sprintf("Thanks for registering! Follow this link to confirm your contact and set your password: %s", confirmationLink)
+	err = us.emailService.SendPlainTextEmail(ctx, logger, to, "contact confirmation link", body)
 	if err != nil {
 		evtString := "failed to send contact confirmation notification error occurred"
 		logger.Error(evtString, zap.Reflect("error", err))
 		apptelemetry.SetSpanError(&span, err, evtString)
 		return err // TODO: what should we do here???
 	}
-	// NOTE: allow user to set password on confirmation link click.
 	span.AddEvent("user registered and confirmation notification sent")
 	return nil
 }
@@ -129,6 +162,20 @@ func (us userService) GetUserPrimaryContact(ctx context.Context, logger *zap.Log
 	return contact, nil
 }
 
+func (us userService) GetFullUserByID(ctx context.Context, logger *zap.Logger, userID string, initiator string) (aggregate.FullUser, errors.RichError) {
+	span := apptelemetry.CreateFunctionSpan(ctx, us.GetName(), "GetFullUserByID")
+	defer span.End()
+	fullUser, err := us.userRepo.GetFullUserByID(ctx, userID)
+	if err != nil {
+		evtString := "failed to retreive full user by user id"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, evtString)
+		return aggregate.FullUser{}, err
+	}
+	span.AddEvent("full user retreived")
+	return fullUser, nil
+}
+
 func (us userService) GetUsersContacts(ctx context.Context, logger *zap.Logger, userID string, initiator string) ([]models.Contact, errors.RichError) {
 	span := apptelemetry.CreateFunctionSpan(ctx, us.GetName(), "GetUsersContacts")
 	defer span.End()
@@ -328,19 +375,95 @@ func (us userService) SetContactAsPrimary(ctx context.Context, logger *zap.Logge
 func (us userService) ConfirmContact(ctx context.Context, logger *zap.Logger, confirmationCode string, initiator string) errors.RichError {
 	span := apptelemetry.CreateFunctionSpan(ctx, us.GetName(), "ConfirmContact")
 	defer span.End()
+	contactToConfirm, err := us.getContactToConfirm(ctx, logger, &span, confirmationCode)
+	if err != nil {
+		// additional error stuff handeled in getContactToConfirm function
+		return err
+	}
+	err = us.markContactConfirmed(ctx, logger, &span, confirmationCode, &contactToConfirm, initiator)
+	if err != nil {
+		// additional error stuff handeled in markContactConfirmed function
+		return err
+	}
+	span.AddEvent("contact confirmed")
+	return nil
+}
+
+func (us userService) ConfirmContactAndSetPassword(ctx context.Context, logger *zap.Logger, confirmationCode string, newPassword string, initiator string) errors.RichError {
+	span := apptelemetry.CreateFunctionSpan(ctx, us.GetName(), "ConfirmContactAndSetPassword")
+	defer span.End()
+	if newPassword == "" {
+		err := coreerrors.NewNoNewPasswordHashProvidedError(true)
+		evtString := "new password is empty string"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	contactToConfirm, err := us.getContactToConfirm(ctx, logger, &span, confirmationCode)
+	if err != nil {
+		// additional error stuff handeled in getContactToConfirm function
+		return err
+	}
+	user, err := us.userRepo.GetUserByID(ctx, contactToConfirm.UserID)
+	if err != nil {
+		logger.Error("userRepo.GetUserByID call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	span.AddEvent("user retreived from repo")
+	// this only sets the initial password, changing an existing password goes through the password reset flow.
+	if user.PasswordHash != "" {
+		err := coreerrors.NewUserPasswordAlreadySetError(user.ID, true)
+		evtString := "user already has a password set"
+		logger.Error(evtString, zap.String("userId", user.ID), zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	err = validateNewPassword(ctx, logger, &span, us.passwordPolicy, us.contactRepo, user.ID, newPassword)
+	if err != nil {
+		// additional error stuff handeled in validateNewPassword function
+		return err
+	}
+	span.AddEvent("new password meets password policy")
+	newPasswordHash, err := us.passwordHashers.Hash(newPassword)
+	if err != nil {
+		evtString := "failed to hash users new password"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		return err
+	}
+	span.AddEvent("new password hash generated")
+	err = us.markContactConfirmed(ctx, logger, &span, confirmationCode, &contactToConfirm, initiator)
+	if err != nil {
+		// additional error stuff handeled in markContactConfirmed function
+		return err
+	}
+	user.PasswordHash = newPasswordHash
+	err = us.userRepo.UpdateUser(ctx, &user, initiator)
+	if err != nil {
+		logger.Error("userRepo.UpdateUser call failed", zap.Reflect("error", err))
+		apptelemetry.SetSpanError(&span, err, "")
+		return err
+	}
+	span.AddEvent("contact confirmed and user password set")
+	return nil
+}
+
+// getContactToConfirm gets the unconfirmed contact targeted by a contact confirmation token.
+func (us userService) getContactToConfirm(ctx context.Context, logger *zap.Logger, span *trace.Span, confirmationCode string) (models.Contact, errors.RichError) {
 	confirmationToken, err := us.tokenService.GetToken(ctx, logger, confirmationCode, models.TokenTypeConfirmContact)
 	if err != nil {
 		evtString := "failed to retreive confirmation token from data store"
 		logger.Error(evtString, zap.Reflect("error", err))
-		apptelemetry.SetSpanError(&span, err, evtString)
-		return err
+		apptelemetry.SetSpanError(span, err, evtString)
+		return models.Contact{}, err
 	}
 	if confirmationToken.TokenType != models.TokenTypeConfirmContact {
 		err := coreerrors.NewInvalidTokenError(confirmationToken.Value, true)
 		evtString := "token type is not valid"
 		logger.Error(evtString, zap.String("tokenType", confirmationToken.TokenType.String()), zap.String("tokenValue", confirmationToken.Value), zap.Reflect("error", err))
-		apptelemetry.SetSpanOriginalError(&span, err, evtString)
-		return err
+		apptelemetry.SetSpanOriginalError(span, err, evtString)
+		return models.Contact{}, err
 	}
 	// it appears that the token service will return this error if the token is expired, so this code is redundant...
 	// should this service rely on the token service for some business logic?
@@ -356,25 +479,37 @@ func (us userService) ConfirmContact(ctx context.Context, logger *zap.Logger, co
 	if err != nil {
 		evtString := "failed to retreive contact to confirm from data store"
 		logger.Error(evtString, zap.Reflect("error", err))
-		apptelemetry.SetSpanError(&span, err, evtString)
-		return err
+		apptelemetry.SetSpanError(span, err, evtString)
+		return models.Contact{}, err
 	}
 	if contactToConfirm.IsConfirmed() {
 		err := coreerrors.NewContactAlreadyConfirmedError(contactToConfirm.UserID, contactToConfirm.ID, contactToConfirm.Principal, contactToConfirm.Type, nil, true)
-		evtString := "token is expired"
+		evtString := "contact is already confirmed"
 		logger.Error(evtString, zap.String("tokenType", confirmationToken.TokenType.String()), zap.String("tokenValue", confirmationToken.Value), zap.Reflect("error", err))
-		apptelemetry.SetSpanOriginalError(&span, err, evtString)
+		apptelemetry.SetSpanOriginalError(span, err, evtString)
+		return models.Contact{}, err
+	}
+	return contactToConfirm, nil
+}
+
+// markContactConfirmed consumes the confirmation token and sets the confirmed date on the contact.
+func (us userService) markContactConfirmed(ctx context.Context, logger *zap.Logger, span *trace.Span, confirmationCode string, contactToConfirm *models.Contact, initiator string) errors.RichError {
+	// the token is consumed first so a confirmation link can only be used once, even by concurrent requests.
+	_, err := us.tokenService.ConsumeToken(ctx, logger, confirmationCode, models.TokenTypeConfirmContact)
+	if err != nil {
+		evtString := "failed to consume confirmation token"
+		logger.Error(evtString, zap.Reflect("error", err))
+		apptelemetry.SetSpanError(span, err, evtString)
 		return err
 	}
 	contactToConfirm.ConfirmedDate.Set(time.Now().UTC())
-	err = us.contactRepo.UpdateContact(ctx, &contactToConfirm, initiator)
+	err = us.contactRepo.UpdateContact(ctx, contactToConfirm, initiator)
 	if err != nil {
 		evtString := "failed to update contact to confirmed"
 		logger.Error(evtString, zap.Reflect("error", err))
-		apptelemetry.SetSpanError(&span, err, evtString)
+		apptelemetry.SetSpanError(span, err, evtString)
 		return err
 	}
-	span.AddEvent("contact confirmed")
 	return nil
 }
 
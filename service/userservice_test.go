@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/calvine/goauth/core/models"
 	repo "github.com/calvine/goauth/core/repositories"
 	"github.com/calvine/goauth/core/services"
+	"github.com/calvine/goauth/core/utilities"
 	"github.com/calvine/goauth/dataaccess/memory"
 	"github.com/calvine/goauth/internal/testutils"
 	"go.uber.org/zap/zaptest"
@@ -31,6 +33,11 @@ var (
 
 	userServiceTest_UnconfirmedUser_UnconfirmedPrimaryContact models.Contact
 
+	userServiceTest_RegisteredUser models.User
+
+	userServiceTest_RegisteredUser_UnconfirmedPrimaryContact models.Contact
+
+	userServiceText_UserRepo    repo.UserRepo
 	userServiceText_ContactRepo repo.ContactRepo
 	userServiceText_TokenRepo   repo.TokenRepo
 )
@@ -47,7 +54,11 @@ const (
 
 	userServiceTest_UnconfirmedUser_UnconfirmedPrimaryEmail = "userserviceunconprim@email.com"
 
+	userServiceTest_RegisteredUser_UnconfirmedPrimaryEmail = "userserviceregisteredprim@email.com"
+
 	userServiceTest_UserToRegisterEmail = "userservicetoregister@email.com"
+
+	userServiceTest_Issuer = "https://goauth.test"
 )
 
 func TestUserService(t *testing.T) {
@@ -65,6 +76,10 @@ func TestUserService(t *testing.T) {
 		_testRegisterUserAndPrimaryContact(t, userService)
 	})
 
+	t.Run("GetFullUserByID", func(t *testing.T) {
+		_testGetFullUserByID(t, userService)
+	})
+
 	t.Run("GetUserPrimaryContact", func(t *testing.T) {
 		_testGetUserPrimaryContact(t, userService)
 	})
@@ -96,6 +111,10 @@ func TestUserService(t *testing.T) {
 	t.Run("ConfirmContact", func(t *testing.T) {
 		_testConfirmContact(t, userService, userServiceText_ContactRepo, userServiceText_TokenRepo)
 	})
+
+	t.Run("ConfirmContactAndSetPassword", func(t *testing.T) {
+		_testConfirmContactAndSetPassword(t, userService, userServiceText_UserRepo, userServiceText_ContactRepo, userServiceText_TokenRepo)
+	})
 }
 
 func setupTestUserServiceData(t *testing.T, userRepo repo.UserRepo, contactRepo repo.ContactRepo) {
@@ -180,26 +199,44 @@ func setupTestUserServiceData(t *testing.T, userRepo repo.UserRepo, contactRepo
 		t.Errorf("\tfailed to create unconfirmed primary contact for user with no confirmed contact for tests: %s", err.GetErrorCode())
 		t.FailNow()
 	}
+
+	// add registered user who has not set a password yet
+	userServiceTest_RegisteredUser = models.NewUser()
+	err = userRepo.AddUser(context.TODO(), &userServiceTest_RegisteredUser, userServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("\tfailed to create registered user with no password for tests: %s", err.GetErrorCode())
+		t.FailNow()
+	}
+
+	// add registered user unconfirmed primary contact
+	userServiceTest_RegisteredUser_UnconfirmedPrimaryContact = models.NewContact(userServiceTest_RegisteredUser.ID, "", userServiceTest_RegisteredUser_UnconfirmedPrimaryEmail, core.CONTACT_TYPE_EMAIL, true)
+	err = contactRepo.AddContact(context.TODO(), &userServiceTest_RegisteredUser_UnconfirmedPrimaryContact, userServiceTest_CreatedBy)
+	if err != nil {
+		t.Log(err.Error())
+		t.Errorf("\tfailed to create unconfirmed primary contact for registered user for tests: %s", err.GetErrorCode())
+		t.FailNow()
+	}
 }
 
 func buildUserService(t *testing.T) services.UserService {
-	users := make(map[string]models.User)
-	contacts := make(map[string]models.Contact)
-	userRepo, err := memory.NewMemoryUserRepo(&users, &contacts)
+	userStore := memory.NewUserStore()
+	var err error
+	userServiceText_UserRepo, err = memory.NewMemoryUserRepo(userStore)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
 	}
-	userServiceText_ContactRepo, err = memory.NewMemoryContactRepo(&users, &contacts)
+	userServiceText_ContactRepo, err = memory.NewMemoryContactRepo(userStore)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
 	}
-	userServiceText_TokenRepo = memory.NewMemoryTokenRepo()
+	userServiceText_TokenRepo = memory.NewMemoryTokenRepo(context.TODO())
 	tokenService := NewTokenService(userServiceText_TokenRepo)
 	userServiceTest_EmailService, _ = NewEmailService(StackEmailService, nil)
-	userService := NewUserService(userRepo, userServiceText_ContactRepo, tokenService, userServiceTest_EmailService)
-	setupTestUserServiceData(t, userRepo, userServiceText_ContactRepo)
+	userService := NewUserService(userServiceText_UserRepo, userServiceText_ContactRepo, tokenService, userServiceTest_EmailService, nil, nil, userServiceTest_Issuer)
+	setupTestUserServiceData(t, userServiceText_UserRepo, userServiceText_ContactRepo)
 	return userService
 }
 
@@ -300,6 +337,25 @@ func _testRegisterUserAndPrimaryContact(t *testing.T, userService services.UserS
 			err := userService.RegisterUserAndPrimaryContact(context.TODO(), logger, tc.contactType, tc.contactPrincipal, userServiceTest_CreatedBy)
 			if err != nil {
 				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+				if err.GetErrorCode() == coreerrors.ErrCodeRegistrationContactAlreadyConfirmed {
+					ses, ok := userServiceTest_EmailService.(*stackEmailService)
+					if !ok {
+						t.Errorf("\texpected stackEmailService instance of email service but got %s", userServiceTest_EmailService.GetName())
+						t.FailNow()
+					}
+					lastMessage, ok := ses.PopMessage()
+					if !ok {
+						t.Error("\tno account already registered message found in email stack")
+						return
+					}
+					if len(lastMessage.To) != 1 || lastMessage.To[0] != tc.contactPrincipal {
+						t.Errorf("\tto value not expected: got - %v expected - %s", lastMessage.To, tc.contactPrincipal)
+					}
+					expectedLink := userServiceTest_Issuer + "/auth/resetpassword"
+					if !strings.Contains(lastMessage.Body, expectedLink) {
+						t.Errorf("\tmessage body does not contain password reset link: got - %s expected to contain - %s", lastMessage.Body, expectedLink)
+					}
+				}
 			} else if tc.expectedErrorCode != "" {
 				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
 			} else {
@@ -321,7 +377,49 @@ func _testRegisterUserAndPrimaryContact(t *testing.T, userService services.UserS
 				if lastMessage.To[0] != tc.contactPrincipal {
 					t.Errorf("\tto value not expected: got - %s expected - %s", lastMessage.To[0], tc.contactPrincipal)
 				}
-				// TODO: check subject and body once I write those....
+				expectedLinkPrefix := userServiceTest_Issuer + "/user/confirmcontact/"
+				if !strings.Contains(lastMessage.Body, expectedLinkPrefix) {
+					t.Errorf("\tmessage body does not contain confirmation link: got - %s expected to contain - %s", lastMessage.Body, expectedLinkPrefix)
+				}
+			}
+		})
+	}
+}
+
+func _testGetFullUserByID(t *testing.T, userService services.UserService) {
+	logger := zaptest.NewLogger(t)
+	type testCase struct {
+		name                 string
+		userID               string
+		expectedContactCount int
+		expectedErrorCode    string
+	}
+	testCases := []testCase{
+		{
+			name:                 "GIVEN a valid user id EXPECT the user and all of their contacts",
+			userID:               userServiceTest_ConfirmedUser.ID,
+			expectedContactCount: 5,
+		},
+		{
+			name:              "GIVEN a non existant user id EXPECT error code no user found",
+			userID:            "not a real user id",
+			expectedErrorCode: coreerrors.ErrCodeNoUserFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fullUser, err := userService.GetFullUserByID(context.TODO(), logger, tc.userID, userServiceTest_CreatedBy)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			} else {
+				if fullUser.ID != tc.userID {
+					t.Errorf("\tuser id not expected value: got - %s expected - %s", fullUser.ID, tc.userID)
+				}
+				if len(fullUser.Contacts) != tc.expectedContactCount {
+					t.Errorf("\tcontact count not expected value: got - %d expected - %d", len(fullUser.Contacts), tc.expectedContactCount)
+				}
 			}
 		})
 	}
@@ -859,3 +957,103 @@ func _testConfirmContact(t *testing.T, userService services.UserService, contact
 		})
 	}
 }
+
+func _testConfirmContactAndSetPassword(t *testing.T, userService services.UserService, userRepo repo.UserRepo, contactRepo repo.ContactRepo, tokenRepo repo.TokenRepo) {
+	logger := zaptest.NewLogger(t)
+	type testCase struct {
+		name                          string
+		contactToConfirm              *models.Contact
+		newPassword                   string
+		mockConfirmContactTokenString string
+		expectedErrorCode             string
+	}
+	testCases := []testCase{
+		{
+			name:              "GIVEN an empty password EXPECT error code no new password hash provided",
+			contactToConfirm:  &userServiceTest_RegisteredUser_UnconfirmedPrimaryContact,
+			newPassword:       "",
+			expectedErrorCode: coreerrors.ErrCodeNoNewPasswordHashProvided,
+		},
+		{
+			name:              "GIVEN a password that breaks the password policy EXPECT error code password policy violation",
+			contactToConfirm:  &userServiceTest_RegisteredUser_UnconfirmedPrimaryContact,
+			newPassword:       "short",
+			expectedErrorCode: coreerrors.ErrCodePasswordPolicyViolation,
+		},
+		{
+			name:              "GIVEN a password containing the contact being confirmed EXPECT error code password policy violation",
+			contactToConfirm:  &userServiceTest_RegisteredUser_UnconfirmedPrimaryContact,
+			newPassword:       "my" + userServiceTest_RegisteredUser_UnconfirmedPrimaryEmail,
+			expectedErrorCode: coreerrors.ErrCodePasswordPolicyViolation,
+		},
+		{
+			name:                          "GIVEN an invalid confirmation code EXPECT error code invalid token",
+			contactToConfirm:              &userServiceTest_RegisteredUser_UnconfirmedPrimaryContact,
+			newPassword:                   "Password1!",
+			mockConfirmContactTokenString: "whyuibgvouieynboiuwyb04t8b5tu7yv394uy9tur",
+			expectedErrorCode:             coreerrors.ErrCodeInvalidToken,
+		},
+		{
+			name:              "GIVEN a contact of a user who already has a password EXPECT error code user password already set",
+			contactToConfirm:  &userServiceTest_ConfirmedUser_UnconfirmedSecondaryMobileContact,
+			newPassword:       "Password1!",
+			expectedErrorCode: coreerrors.ErrCodeUserPasswordAlreadySet,
+		},
+		{
+			name:             "GIVEN a valid confirmation code for a newly registered user EXPECT success and contact confirmed and password set",
+			contactToConfirm: &userServiceTest_RegisteredUser_UnconfirmedPrimaryContact,
+			newPassword:      "Password1!",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var confirmContactToken string
+			if tc.mockConfirmContactTokenString != "" {
+				confirmContactToken = tc.mockConfirmContactTokenString
+			} else {
+				newConfirmToken, err := models.NewToken(tc.contactToConfirm.ID, models.TokenTypeConfirmContact, time.Minute)
+				if err != nil {
+					t.Errorf("failed to create new confirm contact token: %s - %s", err.GetErrorCode(), err.Error())
+				}
+				err = tokenRepo.PutToken(context.TODO(), newConfirmToken)
+				if err != nil {
+					t.Errorf("failed to new confirm contact token in repo for validation: %s - %s", err.GetErrorCode(), err.Error())
+				}
+				confirmContactToken = newConfirmToken.Value
+			}
+			err := userService.ConfirmContactAndSetPassword(context.TODO(), logger, confirmContactToken, tc.newPassword, userServiceTest_CreatedBy)
+			if err != nil {
+				testutils.HandleTestError(t, err, tc.expectedErrorCode)
+			} else if tc.expectedErrorCode != "" {
+				t.Errorf("expected an error to occurr: %s", tc.expectedErrorCode)
+			} else {
+				newlyConfirmedContact, err := contactRepo.GetContactByID(context.TODO(), tc.contactToConfirm.ID)
+				if err != nil {
+					t.Errorf("failed to retreive newly confirmed contact from repo for validation: %s - %s", err.GetErrorCode(), err.Error())
+				}
+				if !newlyConfirmedContact.IsConfirmed() {
+					t.Error("newly confirmed contact is not confirmed in the underlying data store.")
+				}
+				user, err := userRepo.GetUserByID(context.TODO(), tc.contactToConfirm.UserID)
+				if err != nil {
+					t.Errorf("failed to retreive user from repo for validation: %s - %s", err.GetErrorCode(), err.Error())
+				}
+				if algorithm := utilities.GetPasswordHashAlgorithm(user.PasswordHash); algorithm != core.PASSWORD_HASH_ALGORITHM_ARGON2ID {
+					t.Errorf("user password was not hashed with the default algorithm: got - %s expected - %s", algorithm, core.PASSWORD_HASH_ALGORITHM_ARGON2ID)
+				}
+				passwordMatches, _, err := utilities.DefaultPasswordHashers().Verify(user.PasswordHash, tc.newPassword, user.ID)
+				if err != nil {
+					t.Errorf("failed to compare user password hash: %s - %s", err.GetErrorCode(), err.Error())
+				}
+				if !passwordMatches {
+					t.Error("user password hash does not match the new password")
+				}
+				// the confirmation token is consumed so the link cannot be used again.
+				err = userService.ConfirmContactAndSetPassword(context.TODO(), logger, confirmContactToken, tc.newPassword, userServiceTest_CreatedBy)
+				if err == nil {
+					t.Error("expected reusing the confirmation token to fail")
+				}
+			}
+		})
+	}
+}